@@ -28,6 +28,10 @@ func newCalTransactionRunner(postgresCnf *postgresdb.Postgres, htmlReportHelper
 	}
 }
 
+func (c *calTransactionRunner) cronProcess(ctx context.Context) error {
+	return c.run(ctx)
+}
+
 func (c *calTransactionRunner) run(_ context.Context) error {
 	if c.postgresCnf == nil {
 		return fmt.Errorf(cons.Err_PostgresConfig_Missing)
@@ -12,12 +12,17 @@ import (
 type configAuditor struct {
 	postgresConfig   *postgresdb.Postgres
 	htmlReportHelper *htmlreport.HtmlReportHelper
+	fileData         map[string]interface{}
+	outputType       string
 }
 
-func newConfigAuditor(postgresConfig *postgresdb.Postgres, htmlReportHelper *htmlreport.HtmlReportHelper) *configAuditor {
+func newConfigAuditor(postgresConfig *postgresdb.Postgres, fileData map[string]interface{},
+	htmlReportHelper *htmlreport.HtmlReportHelper, outputType string) *configAuditor {
 	return &configAuditor{
 		postgresConfig:   postgresConfig,
 		htmlReportHelper: htmlReportHelper,
+		fileData:         fileData,
+		outputType:       outputType,
 	}
 }
 
@@ -38,6 +43,9 @@ func (h *configAuditor) run(ctx context.Context) error {
 	}
 
 	h.htmlReportHelper.RegisterConfigAudit(result)
+	if h.outputType == "json" {
+		h.fileData["Config Audit"] = result
+	}
 
 	postgres.PrintConfigAuditSummary(result)
 
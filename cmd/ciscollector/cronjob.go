@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+	"github.com/klouddb/klouddbshield/pkg/config"
+	cons "github.com/klouddb/klouddbshield/pkg/const"
+	"github.com/rs/zerolog/log"
+)
+
+// cronRetryDelay is the delay before the first retry of a failed command,
+// it grows linearly with the attempts.
+var cronRetryDelay = 30 * time.Second
+
+// runCronCommand runs the command for all its targets with the timeout and
+// retries of the command. Each attempt gets its own report helpers, which are
+// merged in htmlHelperMap only on success, so a failed or timed out attempt
+// does not leave partial results in the report.
+func runCronCommand(ctx context.Context, schedule string, commnd *config.Command,
+	htmlHelperMap htmlreport.HtmlReportHelperMap) *htmlreport.CronJobResult {
+
+	start := time.Now()
+	result := &htmlreport.CronJobResult{Command: commnd.Name, Status: htmlreport.CronJobStatus_Success}
+	defer func() {
+		result.Duration = time.Since(start).Round(time.Millisecond).String()
+	}()
+
+	count, err := countProcessorsForCron(commnd)
+	if err != nil {
+		result.Status = htmlreport.CronJobStatus_Failed
+		result.Errors = append(result.Errors, err.Error())
+		return result
+	}
+
+	targets := map[string]bool{}
+	for i := 0; i < count; i++ {
+		attempts, keys, err := runCronProcessor(ctx, schedule, commnd, i, htmlHelperMap)
+		if attempts > result.Attempts {
+			result.Attempts = attempts
+		}
+		for _, k := range keys {
+			targets[k] = true
+		}
+
+		if err == nil {
+			continue
+		}
+
+		result.Errors = append(result.Errors, err.Error())
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			if result.Status == htmlreport.CronJobStatus_Success {
+				result.Status = htmlreport.CronJobStatus_TimedOut
+			}
+		default:
+			result.Status = htmlreport.CronJobStatus_Failed
+		}
+	}
+
+	for k := range targets {
+		result.Targets = append(result.Targets, k)
+	}
+	sort.Strings(result.Targets)
+
+	return result
+}
+
+// countProcessorsForCron returns the number of processors
+// getProcessorsForCron creates for the command, without creating them. It
+// returns the same errors for the missing inputs.
+func countProcessorsForCron(commnd *config.Command) (int, error) {
+	switch commnd.Name {
+	case cons.RootCMD_All:
+		if len(commnd.Postgres) == 0 {
+			return 0, fmt.Errorf(cons.Err_PostgresConfig_Missing)
+		}
+		// postgres, hba and common users runners, and the optional log parser
+		count := 3 * len(commnd.Postgres)
+		if commnd.LogParser != nil {
+			count += len(commnd.Postgres)
+		}
+		return count, nil
+
+	case cons.RootCMD_PostgresCIS, cons.RootCMD_HBAScanner, cons.PasswordManager_CommonUsers,
+		cons.RootCMD_SSLCheck, cons.RootCMD_ConfigAuditing, cons.RootCMD_TransactionWraparound:
+		if len(commnd.Postgres) == 0 {
+			return 0, fmt.Errorf(cons.Err_PostgresConfig_Missing)
+		}
+		return len(commnd.Postgres), nil
+
+	case cons.RootCMD_AWSRDS, cons.RootCMD_AWSAurora:
+		return 1, nil
+
+	case cons.RootCMD_MySQL:
+		return len(commnd.MySQL), nil
+
+	case cons.RootCMD_PiiScanner:
+		if len(commnd.Postgres) == 0 {
+			return 0, fmt.Errorf(cons.Err_PostgresConfig_Missing)
+		}
+		if commnd.PiiScanner == nil {
+			return 0, fmt.Errorf("piiscanner input is required for %s command", commnd.Name)
+		}
+		return len(commnd.Postgres), nil
+
+	case cons.RootCMD_CompareConfig:
+		if commnd.CompareConfig == nil || len(commnd.CompareConfig.Servers) == 0 {
+			return 0, fmt.Errorf("compareconfig input with servers is required for %s command", commnd.Name)
+		}
+		return 1, nil
+
+	case cons.RootCMD_BackupAuditTool:
+		if commnd.BackupAudit == nil {
+			return 0, fmt.Errorf("backupaudit input is required for %s command", commnd.Name)
+		}
+		if len(commnd.Postgres) == 0 {
+			return 1, nil
+		}
+		return len(commnd.Postgres), nil
+
+	case cons.LogParserCMD_UniqueIPs, cons.LogParserCMD_InactiveUser,
+		cons.LogParserCMD_HBAUnusedLines, cons.LogParserCMD_PasswordLeakScanner,
+		cons.LogParserCMD_AuthFailures, cons.LogParserCMD_Connections, cons.LogParserCMD_PrivilegedAudit,
+		cons.LogParserCMD_PgAudit:
+		if len(commnd.Postgres) == 0 {
+			return 0, fmt.Errorf(cons.Err_PostgresConfig_Missing)
+		}
+		if commnd.LogParser == nil {
+			return 0, fmt.Errorf("logparser input is required for %s command", commnd.Name)
+		}
+		return len(commnd.Postgres), nil
+
+	default:
+		return 0, fmt.Errorf("invalid command %s", commnd.Name)
+	}
+}
+
+// timedOutRuns has the processors which are still running after their last
+// attempt timed out, by command and processor index.
+var timedOutRuns = struct {
+	sync.Mutex
+	m map[string]<-chan struct{}
+}{m: map[string]<-chan struct{}{}}
+
+// waitTimedOutRun waits for the timed out run of the processor from an
+// earlier run of the command, of the same or other schedule.
+func waitTimedOutRun(ctx context.Context, key string) error {
+	timedOutRuns.Lock()
+	running, ok := timedOutRuns.m[key]
+	timedOutRuns.Unlock()
+	if !ok {
+		return nil
+	}
+
+	select {
+	case <-running:
+		return nil
+	default:
+	}
+
+	log.Info().Msg("Waiting for the timed out run of " + key + " to finish")
+	select {
+	case <-running:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// trackTimedOutRun stores the processor which is still running, so that the
+// next run of the processor waits for it.
+func trackTimedOutRun(key string, running <-chan struct{}) {
+	timedOutRuns.Lock()
+	defer timedOutRuns.Unlock()
+
+	select {
+	case <-running:
+		delete(timedOutRuns.m, key)
+	default:
+		timedOutRuns.m[key] = running
+	}
+}
+
+// runCronProcessor runs the processor at index i of the command with retries
+// and returns the number of attempts and the report keys of the result.
+// Processors are created again for every attempt, as processors can not be
+// reused once they fail. A retry, and the next run of the processor after the
+// last attempt timed out, starts only after the timed out attempt finishes,
+// so the same target is not scanned twice at once and log parser checkpoint
+// is not written by two attempts.
+func runCronProcessor(ctx context.Context, schedule string, commnd *config.Command, i int,
+	htmlHelperMap htmlreport.HtmlReportHelperMap) (int, []string, error) {
+
+	key := fmt.Sprintf("%s/%d", commnd.Name, i)
+	if err := waitTimedOutRun(ctx, key); err != nil {
+		return 0, nil, err
+	}
+
+	var err error
+	var running <-chan struct{}
+	defer func() {
+		if running != nil {
+			trackTimedOutRun(key, running)
+		}
+	}()
+
+	attempt := 0
+	for ; attempt <= commnd.Retries; attempt++ {
+		if attempt > 0 {
+			log.Info().Msg(fmt.Sprintf("Retrying %s (attempt %d of %d): %v", commnd.Name, attempt+1, commnd.Retries+1, err))
+			select {
+			case <-time.After(cronRetryDelay * time.Duration(attempt)):
+			case <-ctx.Done():
+				return attempt, nil, ctx.Err()
+			}
+
+			select {
+			case <-running:
+			default:
+				log.Info().Msg(fmt.Sprintf("Waiting for the timed out attempt of %s to finish before retrying", commnd.Name))
+				select {
+				case <-running:
+				case <-ctx.Done():
+					return attempt, nil, ctx.Err()
+				}
+			}
+		}
+
+		attemptMap := htmlreport.NewHtmlReportHelperMap()
+		processors, perr := getProcessorsForCron(schedule, commnd, attemptMap)
+		if perr != nil {
+			return attempt + 1, nil, perr
+		}
+		if i >= len(processors) {
+			return attempt + 1, nil, fmt.Errorf("processor %d of %s not found", i, commnd.Name)
+		}
+
+		running, err = runWithTimeout(ctx, commnd.Timeout, processors[i])
+		if err == nil {
+			htmlHelperMap.Merge(attemptMap)
+
+			keys := make([]string, 0, len(attemptMap))
+			for k := range attemptMap {
+				keys = append(keys, k)
+			}
+			return attempt + 1, keys, nil
+		}
+	}
+
+	return attempt, nil, err
+}
+
+// runWithTimeout runs the processor and stops waiting for it after the
+// timeout. Most of the processors don't support cancellation, so a timed out
+// processor keeps running in background till it finishes, its result is
+// discarded. The returned channel is closed when the processor finishes.
+func runWithTimeout(ctx context.Context, timeout time.Duration, p Runner) (<-chan struct{}, error) {
+	finished := make(chan struct{})
+	if timeout <= 0 {
+		defer close(finished)
+		return finished, p.cronProcess(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer close(finished)
+		defer func() {
+			if r := recover(); r != nil {
+				log.Info().Str("stack_trace", string(debug.Stack())).Msg("")
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- p.cronProcess(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return finished, err
+	case <-ctx.Done():
+		return finished, fmt.Errorf("timed out after %s: %w", timeout, ctx.Err())
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+	"github.com/klouddb/klouddbshield/pkg/config"
+	cons "github.com/klouddb/klouddbshield/pkg/const"
+	"github.com/klouddb/klouddbshield/pkg/postgresdb"
+)
+
+type fakeRunner struct {
+	delay time.Duration
+	err   error
+}
+
+func (f *fakeRunner) cronProcess(ctx context.Context) error {
+	time.Sleep(f.delay)
+	return f.err
+}
+
+func TestRunWithTimeout(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name        string
+		runner      *fakeRunner
+		timeout     time.Duration
+		wantErr     error
+		wantTimeout bool
+	}{
+		{name: "no timeout", runner: &fakeRunner{delay: 10 * time.Millisecond}},
+		{name: "error without timeout", runner: &fakeRunner{err: errFailed}, wantErr: errFailed},
+		{name: "finished before timeout", runner: &fakeRunner{delay: 10 * time.Millisecond}, timeout: time.Second},
+		{name: "timed out", runner: &fakeRunner{delay: time.Second}, timeout: 10 * time.Millisecond, wantTimeout: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finished, err := runWithTimeout(context.Background(), tt.timeout, tt.runner)
+			if tt.wantTimeout {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("runWithTimeout() error = %v, want deadline exceeded", err)
+				}
+				select {
+				case <-finished:
+					t.Errorf("runWithTimeout() finished before the timed out runner")
+				default:
+				}
+				select {
+				case <-finished:
+				case <-time.After(5 * time.Second):
+					t.Errorf("runWithTimeout() not finished after the timed out runner")
+				}
+				return
+			}
+			select {
+			case <-finished:
+			default:
+				t.Errorf("runWithTimeout() returned before the runner finished")
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("runWithTimeout() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWaitTimedOutRun(t *testing.T) {
+	running := make(chan struct{})
+	trackTimedOutRun("logparser/0", running)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := waitTimedOutRun(ctx, "logparser/0"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waitTimedOutRun() error = %v, want deadline exceeded while the run is running", err)
+	}
+	if err := waitTimedOutRun(context.Background(), "logparser/1"); err != nil {
+		t.Errorf("waitTimedOutRun() error = %v for other processor", err)
+	}
+
+	close(running)
+	if err := waitTimedOutRun(context.Background(), "logparser/0"); err != nil {
+		t.Errorf("waitTimedOutRun() error = %v after the run finished", err)
+	}
+
+	trackTimedOutRun("logparser/0", running)
+	if _, ok := timedOutRuns.m["logparser/0"]; ok {
+		t.Errorf("finished run is still tracked")
+	}
+}
+
+func TestCountProcessorsForCron(t *testing.T) {
+	postgres := []*postgresdb.Postgres{
+		{Host: "pg1", Port: "5432", DBName: "postgres"},
+		{Host: "pg2", Port: "5432", DBName: "postgres"},
+	}
+
+	tests := []struct {
+		name    string
+		command *config.Command
+	}{
+		{name: "all", command: &config.Command{Name: cons.RootCMD_All, Postgres: postgres}},
+		{name: "all without postgres", command: &config.Command{Name: cons.RootCMD_All}},
+		{name: "hba scanner", command: &config.Command{Name: cons.RootCMD_HBAScanner, Postgres: postgres}},
+		{name: "mysql", command: &config.Command{Name: cons.RootCMD_MySQL, MySQL: []*config.MySQL{{Host: "my1"}}}},
+		{name: "backup without postgres", command: &config.Command{Name: cons.RootCMD_BackupAuditTool, BackupAudit: &config.BackupAuditCronInput{}}},
+		{name: "backup without input", command: &config.Command{Name: cons.RootCMD_BackupAuditTool, Postgres: postgres}},
+		{name: "log parser without input", command: &config.Command{Name: cons.LogParserCMD_UniqueIPs, Postgres: postgres}},
+		{name: "invalid command", command: &config.Command{Name: "invalid"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processors, wantErr := getProcessorsForCron("", tt.command, htmlreport.NewHtmlReportHelperMap())
+			got, err := countProcessorsForCron(tt.command)
+			if (err != nil) != (wantErr != nil) || got != len(processors) {
+				t.Errorf("countProcessorsForCron() = %d, %v; want %d, %v", got, err, len(processors), wantErr)
+			}
+		})
+	}
+}
@@ -3,17 +3,24 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"path"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/jedib0t/go-pretty/v6/text"
 	"github.com/klouddb/klouddbshield/htmlreport"
+	"github.com/klouddb/klouddbshield/pkg/backuphistory"
 	"github.com/klouddb/klouddbshield/pkg/config"
 	cons "github.com/klouddb/klouddbshield/pkg/const"
 	"github.com/klouddb/klouddbshield/pkg/cron"
 	"github.com/klouddb/klouddbshield/pkg/email"
+	"github.com/klouddb/klouddbshield/pkg/metrics"
+	"github.com/klouddb/klouddbshield/pkg/notification"
+	"github.com/klouddb/klouddbshield/pkg/piiscanner"
 	"github.com/klouddb/klouddbshield/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
@@ -40,6 +47,11 @@ func getProcessorsForCron(schedule string, commnd *config.Command, htmlHelperMap
 			out = append(out, newPwnedUserRunner(p, true, map[string]interface{}{}, htmlHelper, "json"))
 		}
 
+		// log parser is optional for all command
+		if commnd.LogParser == nil {
+			return out, nil
+		}
+
 		logPaser, err := getLogParserCron(schedule, commnd, htmlHelperMap)
 		if err != nil {
 			return nil, err
@@ -95,8 +107,96 @@ func getProcessorsForCron(schedule string, commnd *config.Command, htmlHelperMap
 		}
 		return out, nil
 
+	case cons.RootCMD_SSLCheck:
+		if len(commnd.Postgres) == 0 {
+			return nil, fmt.Errorf(cons.Err_PostgresConfig_Missing)
+		}
+
+		out := make([]Runner, 0, len(commnd.Postgres))
+		for _, p := range commnd.Postgres {
+			out = append(out, newSslAuditor(p, htmlHelperMap.Get(p.HtmlReportName())))
+		}
+		return out, nil
+
+	case cons.RootCMD_ConfigAuditing:
+		if len(commnd.Postgres) == 0 {
+			return nil, fmt.Errorf(cons.Err_PostgresConfig_Missing)
+		}
+
+		out := make([]Runner, 0, len(commnd.Postgres))
+		for _, p := range commnd.Postgres {
+			out = append(out, newConfigAuditor(p, map[string]interface{}{}, htmlHelperMap.Get(p.HtmlReportName()), "json"))
+		}
+		return out, nil
+
+	case cons.RootCMD_TransactionWraparound:
+		if len(commnd.Postgres) == 0 {
+			return nil, fmt.Errorf(cons.Err_PostgresConfig_Missing)
+		}
+
+		out := make([]Runner, 0, len(commnd.Postgres))
+		for _, p := range commnd.Postgres {
+			out = append(out, newCalTransactionRunner(p, htmlHelperMap.Get(p.HtmlReportName()), true))
+		}
+		return out, nil
+
+	case cons.RootCMD_PiiScanner:
+		if len(commnd.Postgres) == 0 {
+			return nil, fmt.Errorf(cons.Err_PostgresConfig_Missing)
+		}
+		if commnd.PiiScanner == nil {
+			return nil, fmt.Errorf("piiscanner input is required for %s command", commnd.Name)
+		}
+
+		out := make([]Runner, 0, len(commnd.Postgres))
+		for _, p := range commnd.Postgres {
+			in := commnd.PiiScanner
+			piiConfig, err := piiscanner.NewConfig(p, in.RunOption, in.ExcludeTables, in.IncludeTables,
+				in.Database, in.Schema, false, false, true)
+			if err != nil {
+				return nil, fmt.Errorf("error creating pii scanner config: %v", err)
+			}
+			out = append(out, newPiiDbScanner(p, piiConfig, htmlHelperMap.Get(p.HtmlReportName())))
+		}
+		return out, nil
+
+	case cons.RootCMD_CompareConfig:
+		in := commnd.CompareConfig
+		if in == nil || len(in.Servers) == 0 {
+			return nil, fmt.Errorf("compareconfig input with servers is required for %s command", commnd.Name)
+		}
+
+		// comparison is not about a single target, so it has its own report
+		return []Runner{newCompareConfigRunner(in.BaseServer, in.Servers, htmlHelperMap.Get(cons.RootCMD_CompareConfig))}, nil
+
+	case cons.RootCMD_BackupAuditTool:
+		in := commnd.BackupAudit
+		if in == nil {
+			return nil, fmt.Errorf("backupaudit input is required for %s command", commnd.Name)
+		}
+
+		input := backuphistory.BackupHistoryInput{
+			BackupTool:      in.BackupTool,
+			BackupPath:      in.BackupPath,
+			BackupFrequency: in.BackupFrequency,
+		}
+
+		// backups are checked on this host, result is added in the report of
+		// the postgres targets running here if they are provided.
+		if len(commnd.Postgres) == 0 {
+			return []Runner{newBackupHistory(input, htmlHelperMap.Get(cons.RootCMD_BackupAuditTool))}, nil
+		}
+
+		out := make([]Runner, 0, len(commnd.Postgres))
+		for _, p := range commnd.Postgres {
+			out = append(out, newBackupHistory(input, htmlHelperMap.Get(p.HtmlReportName())))
+		}
+		return out, nil
+
 	case cons.LogParserCMD_UniqueIPs, cons.LogParserCMD_InactiveUser,
-		cons.LogParserCMD_HBAUnusedLines, cons.LogParserCMD_PasswordLeakScanner:
+		cons.LogParserCMD_HBAUnusedLines, cons.LogParserCMD_PasswordLeakScanner,
+		cons.LogParserCMD_AuthFailures, cons.LogParserCMD_Connections, cons.LogParserCMD_PrivilegedAudit,
+		cons.LogParserCMD_PgAudit:
 		return getLogParserCron(schedule, commnd, htmlHelperMap)
 
 	default:
@@ -108,22 +208,23 @@ func getLogParserCron(schedule string, command *config.Command, htmlHelperMap ht
 	if len(command.Postgres) == 0 {
 		return nil, fmt.Errorf(cons.Err_PostgresConfig_Missing)
 	}
-
-	startTime, err := cron.GetPreviousExecutionTime(schedule)
-	if err != nil {
-		return nil, fmt.Errorf("error getting previous execution time: %v", err)
+	if command.LogParser == nil {
+		return nil, fmt.Errorf("logparser input is required for %s command", command.Name)
 	}
 
 	out := make([]Runner, 0, len(command.Postgres))
 	for _, p := range command.Postgres {
 		logParserConfig, err := config.NewLogParser(command.Name, "", "",
-			command.LogParser.Prefix, command.LogParser.LogFile, command.LogParser.HbaConfFile)
+			command.LogParser.Prefix, command.LogParser.LogFormat, command.LogParser.LogFile, command.LogParser.HbaConfFile)
 		if err != nil {
 			return nil, fmt.Errorf("error creating logparser config: %v", err)
 		}
 
-		logParserConfig.Begin = startTime
-		logParserConfig.End = time.Now()
+		// every run processes the lines written since the previous run, even
+		// when a run is skipped.
+		logParserConfig.CheckpointFile = getCheckpointFile(p.HtmlReportName(), command.Name)
+		logParserConfig.PIIReportFile = command.LogParser.PIIReportFile
+		logParserConfig.InactiveDays = command.LogParser.InactiveDays
 
 		u := newLogParserRunnerFromConfig(p, logParserConfig, false, map[string]interface{}{}, htmlHelperMap.Get(p.HtmlReportName()), "json")
 		out = append(out, u)
@@ -132,17 +233,76 @@ func getLogParserCron(schedule string, command *config.Command, htmlHelperMap ht
 	return out, nil
 }
 
+type emailRecipients struct {
+	to []string
+	cc []string
+}
+
+// getEmailRecipients returns the email recipients for each schedule. Crons
+// with the same schedule run together, so their recipients are merged. Crons
+// without their own recipients use the recipients of email config.
+func getEmailRecipients(emailCnf *config.AuthConfig, crons []config.Cron) map[string]*emailRecipients {
+	var defaultTo, defaultCc []string
+	if emailCnf != nil {
+		defaultTo, defaultCc = emailCnf.To, emailCnf.Cc
+	}
+
+	out := map[string]*emailRecipients{}
+	for _, v := range crons {
+		to, cc := v.To, v.Cc
+		if len(to) == 0 && len(cc) == 0 {
+			to, cc = defaultTo, defaultCc
+		}
+
+		r, ok := out[v.Schedule]
+		if !ok {
+			r = &emailRecipients{}
+			out[v.Schedule] = r
+		}
+		r.to = appendUnique(r.to, to...)
+		r.cc = appendUnique(r.cc, cc...)
+	}
+
+	return out
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, l := range list {
+			if l == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
 type cronHelper struct {
 	cnf *config.Config
 	c   *cron.Cron
 	ctx context.Context
+
+	metrics *metrics.Collector
+
+	ledger    *cron.Ledger
+	startedAt time.Time
+	mu        sync.Mutex
+	running   map[string]time.Time
 }
 
 func NewCronHelper(ctx context.Context, cnf *config.Config) *cronHelper {
 	return &cronHelper{
-		cnf: cnf,
-		c:   cron.New(),
-		ctx: ctx,
+		cnf:       cnf,
+		c:         cron.New(),
+		ctx:       ctx,
+		metrics:   metrics.NewCollector(),
+		startedAt: time.Now(),
+		running:   map[string]time.Time{},
 	}
 }
 
@@ -168,8 +328,11 @@ func (c *cronHelper) SetupCron() error {
 		}
 	}
 
+	c.ledger = cron.NewLedger(path.Join(reportDirPath, "runs.jsonl"))
+
 	if c.cnf.Email != nil {
-		emailHelper = email.NewEmailHelper(c.cnf.Email.Host, c.cnf.Email.Port, c.cnf.Email.Username, c.cnf.Email.Password)
+		emailHelper = email.NewEmailHelper(c.cnf.Email.Host, c.cnf.Email.Port, c.cnf.Email.Username, c.cnf.Email.Password,
+			c.cnf.Email.From, c.cnf.Email.TLS, c.cnf.Email.InsecureSkipVerify)
 		err := emailHelper.VerifyConfig()
 		if err != nil {
 			return err
@@ -178,69 +341,163 @@ func (c *cronHelper) SetupCron() error {
 		fmt.Println("> Email configuration not found in config file. For report you can refer your home directory. [" + homeDir + "]")
 	}
 
+	notifiers, err := newNotifiers(c.cnf.Notifications)
+	if err != nil {
+		return err
+	}
+
+	siemSinks, err := newSIEMSinks(c.cnf.SIEM)
+	if err != nil {
+		return err
+	}
+
 	commandMap := map[string][]config.Command{}
+	alertOnChange := map[string]bool{}
+	recipientsMap := getEmailRecipients(c.cnf.Email, c.cnf.Crons)
 
 	for _, v := range c.cnf.Crons {
-		// if v.Schedule freequence is less than 24 hours, then return error
 		ok, err := cron.IsLessThan24Hours(v.Schedule)
 		if err != nil {
 			return err
 		}
 
-		if !ok {
-			return fmt.Errorf("schedule frequency should be less than 24 hours")
+		// frequent runs are allowed, but every run sends the report again
+		// and adds an entry in the history.
+		if ok {
+			fmt.Println(text.FgHiYellow.Sprint("> Warning: schedule " + v.Schedule + " runs more than once a day, every run sends the report and adds an entry in run history"))
 		}
 
 		commandMap[v.Schedule] = append(commandMap[v.Schedule], v.Commands...)
+		if v.AlertOnChange {
+			alertOnChange[v.Schedule] = true
+		}
+	}
+
+	if emailHelper != nil {
+		for schedule, recipients := range recipientsMap {
+			if len(recipients.to) == 0 && len(recipients.cc) == 0 {
+				return fmt.Errorf("no email recipient for cron %q, set to in email config or in the cron", schedule)
+			}
+		}
 	}
 
 	for schedule, commands := range commandMap {
-		err := c.c.AddFunc(schedule, func() {
+		schedule, commands := schedule, commands
+		err := c.c.AddJob(schedule, func() {
 			ctx := context.Background()
+			start := time.Now()
 			htmlHelperMap := htmlreport.NewHtmlReportHelperMap()
+			runSummary := &notification.RunSummary{Schedule: schedule, Time: start}
+			jobReport := &htmlreport.CronJobReport{Schedule: schedule}
+			reportTime := start.Format(reportTimeFormat)
+
+			c.setRunning(schedule, start)
+			var reportPaths []string
+			// ledger is written after the reports, so it has the paths of
+			// the reports and retention does not remove them.
+			defer func() {
+				c.clearRunning(schedule)
+				c.recordRun(schedule, start, jobReport, runSummary.Errors, reportPaths)
+				c.applyRetention(reportDirPath, time.Now())
+			}()
 
 			defer func() {
+				for _, v := range htmlHelperMap {
+					v.RegisterCronJobs(jobReport)
+				}
+
 				allFiles := []string{}
+				reportFiles := map[string]string{}
+				changes := map[string]*htmlreport.TargetChanges{}
 				for k, v := range htmlHelperMap {
-					filename := path.Join(reportDirPath, "klouddbshield_report_"+k+".html")
+					previous := recordHistory(path.Join(reportDirPath, "history"), k, c.historyPerTarget(), v)
+					changes[k] = htmlreport.NewTargetChanges(v.Findings(), previous)
+					runSummary.Targets = append(runSummary.Targets, notification.NewTargetSummary(k, v.Summary(), v.Findings(), previous))
+					sendSIEMEvents(siemSinks, k, v, previous)
+
+					filename := path.Join(reportDirPath, "klouddbshield_report_"+k+"_"+reportTime+".html")
 					filePath, err := v.RenderInfile(filename, 0600)
 					if err != nil {
 						log.Error().Err(err).Msg("Unable to generate klouddbshield_report.html file: " + err.Error())
-						return
+						continue
 					}
 
 					if filePath != "" {
 						allFiles = append(allFiles, filePath)
+						reportFiles[k] = filePath
 					}
 				}
 
+				updateMetrics(c.cnf.Prometheus, c.metrics, htmlHelperMap)
+
+				// reports are still written when nothing has changed, only
+				// the notifications are skipped.
+				notify, subject := true, "KloudDBShield Report"
+				if alertOnChange[schedule] {
+					var changed *notification.RunSummary
+					changed, notify = runSummary.OnlyChanges()
+					if notify {
+						sendNotifications(ctx, notifiers, changed)
+						subject = fmt.Sprintf("KloudDBShield Report: changes in %d target(s)", len(changed.Targets))
+					} else {
+						log.Info().Msg("No change since previous run of " + schedule + ", notifications are skipped")
+					}
+				} else {
+					sendNotifications(ctx, notifiers, runSummary)
+				}
+
 				if len(allFiles) == 0 {
 					return
 				}
 
+				// fleet index gives a single page overview of all the targets
+				indexFile, err := htmlHelperMap.RenderFleetIndex(path.Join(reportDirPath, "klouddbshield_report_index_"+reportTime+".html"), reportFiles, 0600)
+				if err != nil {
+					log.Error().Err(err).Msg("Unable to generate klouddbshield_report_index.html file: " + err.Error())
+				} else if indexFile != "" {
+					allFiles = append([]string{indexFile}, allFiles...)
+				}
+				reportPaths = allFiles
+
+				if !notify {
+					return
+				}
+
 				if emailHelper == nil {
 					log.Info().Msg("Email configuration not found in config file. For report you can refer your home directory. [" + homeDir + "]")
+					return
+				}
+
+				body, err := htmlHelperMap.RenderEmailBody(changes)
+				if err != nil {
+					log.Error().Err(err).Msg("Unable to generate email body: " + err.Error())
+					return
 				}
 
-				err := emailHelper.Send("prince.soamedia@gmail.com", "KloudDBShield Report", "Klouddb shield email report", allFiles)
+				recipients := recipientsMap[schedule]
+				err = emailHelper.Send(&email.Message{
+					To:          recipients.to,
+					Cc:          recipients.cc,
+					Subject:     subject,
+					HTMLBody:    string(body),
+					Attachments: allFiles,
+				})
 				if err != nil {
 					log.Error().Err(err).Msg("Unable to send email: " + err.Error())
 				}
 			}()
-			for _, commnd := range commands {
+			for i := range commands {
+				commnd := &commands[i]
 				fmt.Println("Running command: ", commnd.Name)
-				processors, err := getProcessorsForCron(schedule, &commnd, htmlHelperMap)
-				if err != nil {
+				result := runCronCommand(ctx, schedule, commnd, htmlHelperMap)
+				jobReport.Jobs = append(jobReport.Jobs, result)
+				for _, err := range result.Errors {
 					fmt.Printf("Error: %v\n", err)
-					continue
-				}
-
-				for _, p := range processors {
-					if err := p.cronProcess(ctx); err != nil {
-						fmt.Printf("Error: %v\n", err)
-					}
+					runSummary.Errors = append(runSummary.Errors, commnd.Name+": "+err)
 				}
 			}
+		}, func() {
+			c.recordSkippedRun(schedule)
 		})
 
 		if err != nil {
@@ -257,6 +514,26 @@ func (c *cronHelper) Run(cancel context.CancelFunc) {
 	// Start the cron
 	c.c.Start()
 
+	// metrics and daemon endpoints can share the same address
+	muxes := map[string]*http.ServeMux{}
+	getMux := func(addr string) *http.ServeMux {
+		if _, ok := muxes[addr]; !ok {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+
+	if c.cnf.Prometheus != nil && c.cnf.Prometheus.ListenAddress != "" {
+		getMux(c.cnf.Prometheus.ListenAddress).Handle("/metrics", c.metrics)
+	}
+	if c.cnf.Daemon != nil && c.cnf.Daemon.ListenAddress != "" {
+		c.registerDaemonEndpoints(getMux(c.cnf.Daemon.ListenAddress), c.cnf.Daemon.ListenAddress)
+	}
+
+	for addr, mux := range muxes {
+		startHTTPServer(c.ctx, addr, mux)
+	}
+
 	// Handle interrupts
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
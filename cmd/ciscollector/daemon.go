@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+	"github.com/klouddb/klouddbshield/pkg/cron"
+	"github.com/rs/zerolog/log"
+)
+
+// reportTimeFormat is the time suffix of the report files written by crons.
+const reportTimeFormat = "20060102T150405"
+
+// defaultReportsPerTarget is the number of reports kept for each target when
+// it is not configured.
+const defaultReportsPerTarget = 30
+
+// defaultRunsLimit is the number of runs returned by /runs by default.
+const defaultRunsLimit = 50
+
+func (c *cronHelper) setRunning(schedule string, start time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running[schedule] = start
+}
+
+func (c *cronHelper) clearRunning(schedule string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.running, schedule)
+}
+
+// recordRun adds the run of the schedule in the ledger. Run is failed if any
+// of its commands is not successful.
+func (c *cronHelper) recordRun(schedule string, start time.Time, jobReport *htmlreport.CronJobReport,
+	errs []string, reports []string) {
+
+	entry := cron.LedgerEntry{
+		ID:       strconv.FormatInt(start.UnixNano(), 10),
+		Schedule: schedule,
+		Status:   cron.RunStatus_Success,
+		Start:    start,
+		End:      time.Now(),
+		Errors:   errs,
+		Reports:  reports,
+	}
+
+	for _, j := range jobReport.Jobs {
+		entry.Commands = append(entry.Commands, cron.LedgerCommand{
+			Name:     j.Command,
+			Status:   j.Status,
+			Attempts: j.Attempts,
+			Errors:   j.Errors,
+		})
+		if j.Status != htmlreport.CronJobStatus_Success {
+			entry.Status = cron.RunStatus_Failed
+		}
+	}
+
+	c.appendLedger(entry)
+}
+
+// recordSkippedRun adds the run skipped because the previous run of the
+// schedule was still running.
+func (c *cronHelper) recordSkippedRun(schedule string) {
+	now := time.Now()
+	entry := cron.LedgerEntry{
+		ID:       strconv.FormatInt(now.UnixNano(), 10),
+		Schedule: schedule,
+		Status:   cron.RunStatus_Skipped,
+		Start:    now,
+		End:      now,
+	}
+
+	c.mu.Lock()
+	if started, ok := c.running[schedule]; ok {
+		entry.Errors = []string{"previous run started at " + started.Format(time.RFC3339) + " is still running"}
+	}
+	c.mu.Unlock()
+
+	c.appendLedger(entry)
+}
+
+func (c *cronHelper) appendLedger(entry cron.LedgerEntry) {
+	if c.ledger == nil {
+		return
+	}
+
+	if err := c.ledger.Append(entry); err != nil {
+		log.Error().Err(err).Msg("Unable to record run in ledger: " + err.Error())
+	}
+}
+
+// applyRetention removes the old reports, history and ledger entries as per
+// the retention config. Nothing is removed when daemon section is not
+// configured.
+func (c *cronHelper) applyRetention(reportDirPath string, now time.Time) {
+	if c.cnf.Daemon == nil {
+		return
+	}
+
+	keep := defaultReportsPerTarget
+	if c.cnf.Daemon.ReportsPerTarget != 0 {
+		keep = c.cnf.Daemon.ReportsPerTarget
+	}
+	var maxAge time.Duration
+	if c.cnf.Daemon.RetentionDays > 0 {
+		maxAge = time.Duration(c.cnf.Daemon.RetentionDays) * 24 * time.Hour
+	}
+
+	if err := pruneReports(reportDirPath, keep, maxAge, now); err != nil {
+		log.Error().Err(err).Msg("Unable to remove old reports: " + err.Error())
+	}
+
+	var before time.Time
+	if maxAge > 0 {
+		before = now.Add(-maxAge)
+	}
+	store := htmlreport.NewHistoryStore(path.Join(reportDirPath, "history"))
+	if err := store.Prune(before, c.historyPerTarget()); err != nil {
+		log.Error().Err(err).Msg("Unable to remove old history: " + err.Error())
+	}
+	if c.ledger != nil && maxAge > 0 {
+		if err := c.ledger.Prune(before); err != nil {
+			log.Error().Err(err).Msg("Unable to remove old ledger entries: " + err.Error())
+		}
+	}
+}
+
+// historyPerTarget returns the number of history entries kept for every
+// target.
+func (c *cronHelper) historyPerTarget() int {
+	if c.cnf.Daemon != nil && c.cnf.Daemon.HistoryPerTarget != 0 {
+		return c.cnf.Daemon.HistoryPerTarget
+	}
+	return htmlreport.DefaultHistoryEntries
+}
+
+var reportFileRegex = regexp.MustCompile(`^klouddbshield_report_(.+)_(\d{8}T\d{6})\.html$`)
+
+// pruneReports removes the report files which are older than maxAge and keeps
+// only the latest keep reports of every target. Fleet index is treated as
+// a target. Zero maxAge and negative keep disable the respective limit.
+func pruneReports(dir string, keep int, maxAge time.Duration, now time.Time) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	type report struct {
+		name string
+		time time.Time
+	}
+	byTarget := map[string][]report{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		m := reportFileRegex.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		t, err := time.ParseInLocation(reportTimeFormat, m[2], time.Local)
+		if err != nil {
+			continue
+		}
+		byTarget[m[1]] = append(byTarget[m[1]], report{name: e.Name(), time: t})
+	}
+
+	for _, reports := range byTarget {
+		sort.Slice(reports, func(i, j int) bool {
+			return reports[i].time.After(reports[j].time)
+		})
+
+		for i, r := range reports {
+			tooMany := keep >= 0 && i >= keep
+			tooOld := maxAge > 0 && now.Sub(r.time) > maxAge
+			if !tooMany && !tooOld {
+				continue
+			}
+
+			if err := os.Remove(filepath.Join(dir, r.name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// registerDaemonEndpoints adds /healthz and /runs in the mux. /runs has the
+// errors and report paths of the runs, so it requires the token, and it is
+// not served on other than loopback address without a token.
+func (c *cronHelper) registerDaemonEndpoints(mux *http.ServeMux, addr string) {
+	mux.HandleFunc("/healthz", c.handleHealthz)
+
+	token := c.cnf.Daemon.Token
+	if token == "" {
+		token = os.Getenv("KSHIELD_API_TOKEN")
+	}
+	if token == "" && !isLoopbackAddr(addr) {
+		log.Error().Msg("/runs is not served on " + addr + ", daemon token or KSHIELD_API_TOKEN is required to serve it on other than loopback address")
+		return
+	}
+
+	mux.Handle("/runs", requireToken(token, http.HandlerFunc(c.handleRuns)))
+}
+
+type healthResponse struct {
+	Status    string                       `json:"status"`
+	StartedAt time.Time                    `json:"started_at"`
+	Running   map[string]time.Time         `json:"running"`
+	LastRuns  map[string]*cron.LedgerEntry `json:"last_runs"`
+}
+
+// handleHealthz reports that the daemon is up, with the running jobs and the
+// last run of every schedule. Status is degraded when the last run of any
+// schedule failed, http status is still 200 as the daemon itself is healthy.
+func (c *cronHelper) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	resp := healthResponse{
+		Status:    "ok",
+		StartedAt: c.startedAt,
+		Running:   map[string]time.Time{},
+		LastRuns:  map[string]*cron.LedgerEntry{},
+	}
+
+	c.mu.Lock()
+	for k, v := range c.running {
+		resp.Running[k] = v
+	}
+	c.mu.Unlock()
+
+	if c.ledger != nil {
+		entries, err := c.ledger.Load(0)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		for i := range entries {
+			e := &entries[i]
+			// skipped runs don't tell anything about the result of the job
+			// and schedules removed from config are not relevant anymore.
+			if e.Status == cron.RunStatus_Skipped || !c.isConfiguredSchedule(e.Schedule) {
+				continue
+			}
+			resp.LastRuns[e.Schedule] = e
+		}
+	}
+
+	for _, e := range resp.LastRuns {
+		if e.Status == cron.RunStatus_Failed {
+			resp.Status = "degraded"
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (c *cronHelper) isConfiguredSchedule(schedule string) bool {
+	for _, v := range c.cnf.Crons {
+		if v.Schedule == schedule {
+			return true
+		}
+	}
+	return false
+}
+
+// handleRuns returns the latest runs from the ledger, newest first.
+func (c *cronHelper) handleRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	limit := defaultRunsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit %q", v))
+			return
+		}
+		limit = n
+	}
+
+	entries := []cron.LedgerEntry{}
+	if c.ledger != nil {
+		var err error
+		entries, err = c.ledger.Load(limit)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if entries == nil {
+			entries = []cron.LedgerEntry{}
+		}
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
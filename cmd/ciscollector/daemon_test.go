@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/cron"
+)
+
+func TestPruneReports(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.Local)
+
+	files := []string{"klouddbshield_report.html", "runs.jsonl"}
+	for i := 0; i < 4; i++ {
+		ts := now.AddDate(0, 0, -i*3).Format(reportTimeFormat)
+		files = append(files,
+			"klouddbshield_report_pg1_"+ts+".html",
+			"klouddbshield_report_index_"+ts+".html",
+		)
+	}
+	files = append(files, "klouddbshield_report_pg2_"+now.Format(reportTimeFormat)+".html")
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// keeps 3 reports per target, and removes reports older than a week
+	if err := pruneReports(dir, 3, 7*24*time.Hour, now); err != nil {
+		t.Fatalf("pruneReports() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	sort.Strings(got)
+
+	want := []string{
+		"klouddbshield_report.html",
+		"klouddbshield_report_index_20240104T120000.html",
+		"klouddbshield_report_index_20240107T120000.html",
+		"klouddbshield_report_index_20240110T120000.html",
+		"klouddbshield_report_pg1_20240104T120000.html",
+		"klouddbshield_report_pg1_20240107T120000.html",
+		"klouddbshield_report_pg1_20240110T120000.html",
+		"klouddbshield_report_pg2_20240110T120000.html",
+		"runs.jsonl",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got files %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got files %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestDaemonEndpoints(t *testing.T) {
+	c := NewCronHelper(nil, &config.Config{Crons: []config.Cron{{Schedule: "@daily"}, {Schedule: "@hourly"}}})
+	c.ledger = cron.NewLedger(filepath.Join(t.TempDir(), "runs.jsonl"))
+
+	start := time.Now()
+	for _, e := range []cron.LedgerEntry{
+		{ID: "1", Schedule: "@daily", Status: cron.RunStatus_Failed, Start: start, End: start},
+		{ID: "2", Schedule: "@daily", Status: cron.RunStatus_Success, Start: start, End: start},
+		{ID: "3", Schedule: "@hourly", Status: cron.RunStatus_Failed, Start: start, End: start},
+		{ID: "4", Schedule: "@hourly", Status: cron.RunStatus_Skipped, Start: start, End: start},
+	} {
+		if err := c.ledger.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.setRunning("@hourly", start)
+
+	rec := httptest.NewRecorder()
+	c.handleHealthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/healthz status = %d", rec.Code)
+	}
+	var health healthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatal(err)
+	}
+	if health.Status != "degraded" || health.LastRuns["@daily"].ID != "2" || health.LastRuns["@hourly"].ID != "3" {
+		t.Errorf("unexpected health %+v", health)
+	}
+	if _, ok := health.Running["@hourly"]; !ok {
+		t.Errorf("running job missing in health %+v", health)
+	}
+
+	rec = httptest.NewRecorder()
+	c.handleRuns(rec, httptest.NewRequest(http.MethodGet, "/runs?limit=2", nil))
+	var runs []cron.LedgerEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &runs); err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID != "4" || runs[1].ID != "3" {
+		t.Errorf("/runs?limit=2 = %+v, want runs 4 and 3", runs)
+	}
+
+	rec = httptest.NewRecorder()
+	c.handleRuns(rec, httptest.NewRequest(http.MethodGet, "/runs?limit=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("/runs?limit=x status = %d, want 400", rec.Code)
+	}
+}
+
+func TestRegisterDaemonEndpoints(t *testing.T) {
+	tests := []struct {
+		name       string
+		addr       string
+		token      string
+		reqToken   string
+		wantStatus int
+	}{
+		{name: "loopback without token", addr: "127.0.0.1:9188", wantStatus: http.StatusOK},
+		{name: "valid token", addr: ":9188", token: "secret", reqToken: "secret", wantStatus: http.StatusOK},
+		{name: "missing token", addr: ":9188", token: "secret", wantStatus: http.StatusUnauthorized},
+		{name: "other address without token", addr: ":9188", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("KSHIELD_API_TOKEN", "")
+			c := NewCronHelper(nil, &config.Config{Daemon: &config.DaemonConfig{ListenAddress: tt.addr, Token: tt.token}})
+			mux := http.NewServeMux()
+			c.registerDaemonEndpoints(mux, tt.addr)
+
+			r := httptest.NewRequest(http.MethodGet, "/runs", nil)
+			if tt.reqToken != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.reqToken)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, r)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("/runs status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			rec = httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("/healthz status = %d, want %d", rec.Code, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name        string
+		daemon      *config.DaemonConfig
+		wantReports int
+	}{
+		{name: "without daemon section", wantReports: 40},
+		{name: "default reports per target", daemon: &config.DaemonConfig{}, wantReports: defaultReportsPerTarget},
+		{name: "configured reports per target", daemon: &config.DaemonConfig{ReportsPerTarget: 5}, wantReports: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for i := 0; i < 40; i++ {
+				name := "klouddbshield_report_pg1_" + now.Add(-time.Duration(i)*time.Hour).Format(reportTimeFormat) + ".html"
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c := NewCronHelper(nil, &config.Config{Daemon: tt.daemon})
+			c.applyRetention(dir, now)
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != tt.wantReports {
+				t.Errorf("got %d reports, want %d", len(entries), tt.wantReports)
+			}
+		})
+	}
+}
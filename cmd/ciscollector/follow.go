@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jedib0t/go-pretty/text"
+	"github.com/klouddb/klouddbshield/htmlreport"
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/runner"
+	"github.com/klouddb/klouddbshield/pkg/siem"
+	"github.com/rs/zerolog/log"
+)
+
+// newLiveAlerter creates the alerter of follow mode, which prints the alerts
+// and sends them to the siem sinks as soon as they are found. Client ips of
+// the checkpoint and the history of the target are known, so only new ips
+// are alerted.
+func newLiveAlerter(logParserCnf *config.LogParser, target string, sinks []*siem.Sink) *siem.LiveAlerter {
+	alerter := siem.NewLiveAlerter(logParserCnf, target, func(e siem.Event) {
+		printLiveAlert(e)
+
+		for _, s := range sinks {
+			if err := s.Send([]siem.Event{e}); err != nil {
+				log.Error().Err(err).Msg("Unable to send alert to siem: " + err.Error())
+			}
+		}
+	})
+
+	if logParserCnf.CheckpointFile != "" {
+		checkpoint, err := runner.LoadCheckpoint(logParserCnf.CheckpointFile)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to load known client ips: " + err.Error())
+		} else {
+			alerter.AddKnownIPs(checkpoint.UniqueIPs...)
+		}
+	}
+
+	entries, err := htmlreport.NewHistoryStore(getHistoryDir()).Load(target, historyTrendLimit)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to load known client ips: " + err.Error())
+	}
+	alerter.AddKnownIPsFromHistory(entries)
+
+	return alerter
+}
+
+func printLiveAlert(e siem.Event) {
+	color := text.FgHiRed
+	switch e.Severity {
+	case htmlreport.Severity_Medium:
+		color = text.FgHiYellow
+	case htmlreport.Severity_Low, htmlreport.Severity_Info:
+		color = text.FgCyan
+	}
+
+	fmt.Printf("%s %s %s\n", e.Time.Format("2006-01-02 15:04:05"), color.Sprintf("[%s] %s:", e.Severity, e.Control), e.Message)
+}
@@ -43,7 +43,10 @@ func (h *hbaRunner) run(ctx context.Context) ([]*model.HBAScannerResult, error)
 	}
 	defer postgresStore.Close()
 
-	listOfResults := hbascanner.HBAScanner(postgresStore, ctx)
+	listOfResults, err := hbascanner.HBAScanner(postgresStore, ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	h.htmlReportHelper.RegisterHBAReportData(listOfResults)
 
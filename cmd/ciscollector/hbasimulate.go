@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/hbarules"
+	"github.com/klouddb/klouddbshield/pkg/postgresdb"
+	"github.com/klouddb/klouddbshield/pkg/utils"
+)
+
+// exit codes for hba simulate command
+const (
+	hbaSimulateExit_Allowed  = 0
+	hbaSimulateExit_Rejected = 1
+	hbaSimulateExit_Error    = 2
+)
+
+const hbaSimulateUsage = "Usage: ciscollector hba simulate --type host --db <database> --user <user> --addr <address> [--ssl] [--gssenc] [--replication] [--hba-file <file>] [--roles <role,...>] [--config <dir>]"
+
+// runHBACommand runs the `ciscollector hba <command>` sub commands and
+// returns the exit code.
+func runHBACommand(args []string) int {
+	if len(args) == 0 || args[0] != "simulate" {
+		fmt.Println(hbaSimulateUsage)
+		return hbaSimulateExit_Error
+	}
+
+	flags := flag.NewFlagSet("hba simulate", flag.ContinueOnError)
+	connType := flags.String("type", hbarules.HBAType_Host, "Connection type, local or host")
+	database := flags.String("db", "", "Database of the connection")
+	user := flags.String("user", "", "User of the connection")
+	addr := flags.String("addr", "", "Client ip address or host name, required for host connections")
+	ssl := flags.Bool("ssl", false, "Connection uses ssl")
+	gssenc := flags.Bool("gssenc", false, "Connection uses gssapi encryption")
+	replication := flags.Bool("replication", false, "Physical replication connection, database is not used for it")
+	hbaFile := flags.String("hba-file", "", "pg_hba.conf file path. default is hba_file of the server from postgres config")
+	roles := flags.String("roles", "", "Comma separated roles the user is member of, for +role and samerole lines. fetched from the server when postgres config is given")
+	configPath := flags.String("config", "", "Config file path of kshieldconfig.toml, for fetching hba file and role membership from the server")
+	if err := flags.Parse(args[1:]); err != nil {
+		return hbaSimulateExit_Error
+	}
+
+	conn := hbarules.HBAConnection{
+		Type:        *connType,
+		Database:    *database,
+		User:        *user,
+		Address:     *addr,
+		SSL:         *ssl,
+		GSSEnc:      *gssenc,
+		Replication: *replication,
+	}
+	if *roles != "" {
+		conn.Roles = strings.Split(*roles, ",")
+	}
+
+	if err := validateHBAConnection(conn); err != nil {
+		fmt.Println("> Error: ", text.FgHiRed.Sprint(err))
+		fmt.Println(hbaSimulateUsage)
+		return hbaSimulateExit_Error
+	}
+
+	if *configPath != "" {
+		path, memberOf, err := hbaFileAndRolesFromServer(context.Background(), *configPath, conn.User)
+		if err != nil {
+			fmt.Println("> Error while connecting to postgres: ", text.FgHiRed.Sprint(err))
+			return hbaSimulateExit_Error
+		}
+		if *hbaFile == "" {
+			*hbaFile = path
+		}
+		if conn.Roles == nil {
+			conn.Roles = memberOf
+		}
+	}
+	if *hbaFile == "" {
+		fmt.Println("> Error: ", text.FgHiRed.Sprint("please provide --hba-file or --config with postgres connection"))
+		return hbaSimulateExit_Error
+	}
+
+	conf, err := hbarules.ParseHBAConf(*hbaFile)
+	if err != nil {
+		fmt.Println("> Error while reading hba file: ", text.FgHiRed.Sprint(err))
+		return hbaSimulateExit_Error
+	}
+	for _, e := range conf.Errors {
+		fmt.Println(text.FgHiYellow.Sprint("> Invalid line, postgres will not load the hba file with it: " + e.Error()))
+	}
+
+	if conn.Type != hbarules.HBAType_Local && net.ParseIP(conn.Address) != nil && hasHostnameEntry(conf.Entries) {
+		names, err := net.LookupAddr(conn.Address)
+		if err != nil {
+			fmt.Println(text.FgHiYellow.Sprintf("> Host name lookup of %s failed, host name lines will not match: %v", conn.Address, err))
+		}
+		conn.Hostnames = names
+	}
+
+	simulation := hbarules.SimulateHBA(conf.Entries, conn)
+	printHBASimulation(simulation, *hbaFile)
+
+	if simulation.Rejected() {
+		return hbaSimulateExit_Rejected
+	}
+	return hbaSimulateExit_Allowed
+}
+
+func validateHBAConnection(conn hbarules.HBAConnection) error {
+	switch {
+	case conn.Type != hbarules.HBAType_Local && conn.Type != hbarules.HBAType_Host:
+		return fmt.Errorf("invalid connection type %q, it should be local or host", conn.Type)
+	case conn.User == "":
+		return fmt.Errorf("--user is required")
+	case conn.Database == "" && !conn.Replication:
+		return fmt.Errorf("--db is required")
+	case conn.Type == hbarules.HBAType_Host && conn.Address == "":
+		return fmt.Errorf("--addr is required for host connections")
+	case conn.Type == hbarules.HBAType_Local && (conn.SSL || conn.GSSEnc):
+		return fmt.Errorf("local connections can not use ssl or gssapi encryption")
+	}
+	return nil
+}
+
+// hbaFileAndRolesFromServer returns the hba file of the server and the roles
+// the user is member of.
+func hbaFileAndRolesFromServer(ctx context.Context, configPath, user string) (string, []string, error) {
+	cnf, err := config.LoadConfig(configPath)
+	if err != nil {
+		return "", nil, err
+	}
+	if cnf.Postgres == nil {
+		return "", nil, fmt.Errorf("postgres config not found in %s", configPath)
+	}
+
+	store, _, err := postgresdb.Open(*cnf.Postgres)
+	if err != nil {
+		return "", nil, err
+	}
+	defer store.Close()
+
+	path, err := utils.GetHBAFilePath(ctx, store)
+	if err != nil {
+		return "", nil, err
+	}
+
+	roles, err := utils.GetRolesOfUser(ctx, store, user)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return path, roles, nil
+}
+
+func hasHostnameEntry(entries []hbarules.HBAEntry) bool {
+	for _, e := range entries {
+		if e.IsHostname() {
+			return true
+		}
+	}
+	return false
+}
+
+func printHBASimulation(s *hbarules.HBASimulation, hbaFile string) {
+	conn := s.Connection
+	target := "database " + conn.Database
+	if conn.Replication {
+		target = "replication"
+	}
+	from := ""
+	if conn.Type != hbarules.HBAType_Local {
+		from = " from " + conn.Address
+	}
+	fmt.Printf("Checking %s connection of user %s to %s%s with %s\n", conn.Type, conn.User, target, from, hbaFile)
+
+	for _, c := range s.Checks {
+		location := strconv.Itoa(c.Entry.LineNo)
+		if c.Entry.File != hbaFile {
+			location = c.Entry.File + ":" + location
+		}
+
+		if !c.Matched {
+			fmt.Printf("  line %s: %s\n", location, text.FgHiBlack.Sprint("no match, "+c.Reason))
+			continue
+		}
+
+		fmt.Println()
+		fmt.Printf("> Line %s matches: %s\n", location, text.Bold.Sprint(c.Entry.Raw))
+		fmt.Println("  because " + c.Reason)
+	}
+
+	switch {
+	case s.Match == nil:
+		fmt.Println(text.FgHiRed.Sprint("> No line matches, connection will be rejected"))
+	case s.Rejected():
+		fmt.Println(text.FgHiRed.Sprint("> Connection will be rejected by the line"))
+	default:
+		method := s.Match.Method
+		if len(s.Match.Options) > 0 {
+			method += " (" + strings.Join(s.Match.Options, " ") + ")"
+		}
+		fmt.Println(text.FgGreen.Sprint("> Connection will be authenticated with " + method))
+	}
+}
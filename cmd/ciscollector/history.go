@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+	"github.com/rs/zerolog/log"
+)
+
+// historyTrendLimit is the number of previous runs used for trend charts.
+const historyTrendLimit = 30
+
+// getHistoryDir returns the directory used for storing the history of runs.
+func getHistoryDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = os.TempDir()
+	}
+
+	return path.Join(homeDir, ".klouddb", "history")
+}
+
+var checkpointFileNameRegex = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// getCheckpointFile returns the log parser checkpoint of the command for the
+// target. Commands have their own checkpoints as they process the same files
+// on different schedules.
+func getCheckpointFile(target, command string) string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = os.TempDir()
+	}
+
+	name := checkpointFileNameRegex.ReplaceAllString(target+"_"+command, "_")
+	return path.Join(homeDir, ".klouddb", "checkpoints", "logparser_"+name+".json")
+}
+
+// recordHistory stores the summary of the current run in history and adds
+// trends tab in the report from the previous runs of the same target. It
+// returns the previous entry to compare the run with, nil if there is none.
+// maxEntries is the number of entries kept for the key.
+func recordHistory(historyDir, key string, maxEntries int, htmlReportHelper *htmlreport.HtmlReportHelper) *htmlreport.HistoryEntry {
+	findings := htmlReportHelper.Findings()
+	entry := htmlreport.NewHistoryEntry(time.Now(), htmlReportHelper.Summary())
+	entry.SetFindings(findings)
+	if entry.IsEmpty() {
+		return nil
+	}
+
+	store := htmlreport.NewHistoryStore(historyDir)
+	store.MaxEntries = maxEntries
+	if err := store.Append(key, entry); err != nil {
+		log.Error().Err(err).Msg("Unable to store run history: " + err.Error())
+		return nil
+	}
+
+	// all the entries are loaded, as modules of other schedules can be
+	// older than the trend limit
+	entries, err := store.Load(key, 0)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to load run history: " + err.Error())
+		return nil
+	}
+
+	trends := entries
+	if len(trends) > historyTrendLimit {
+		trends = trends[len(trends)-historyTrendLimit:]
+	}
+	htmlReportHelper.RegisterTrends(trends)
+
+	// other runs of the target can be appended at the same time, so the
+	// entry of this run is removed by its time instead of taking the last
+	previous := make([]htmlreport.HistoryEntry, 0, len(entries))
+	for _, e := range entries {
+		if !e.Time.Equal(entry.Time) {
+			previous = append(previous, e)
+		}
+	}
+	return htmlreport.PreviousEntry(previous, findings)
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/jedib0t/go-pretty/text"
 	"github.com/klouddb/klouddbshield/htmlreport"
@@ -15,6 +17,7 @@ import (
 	"github.com/klouddb/klouddbshield/pkg/parselog"
 	"github.com/klouddb/klouddbshield/pkg/postgresdb"
 	"github.com/klouddb/klouddbshield/pkg/runner"
+	"github.com/klouddb/klouddbshield/pkg/siem"
 	"github.com/klouddb/klouddbshield/pkg/utils"
 )
 
@@ -25,6 +28,9 @@ type logParserRunner struct {
 	isRunCmd         bool
 	htmlReportHelper *htmlreport.HtmlReportHelper
 	outputType       string
+
+	// alertSinks receive the live alerts in follow mode
+	alertSinks []*siem.Sink
 }
 
 func newLogParserRunnerFromConfig(postgresConfig *postgresdb.Postgres, logParserCnf *config.LogParser, isRunCmd bool,
@@ -53,7 +59,11 @@ func (l *logParserRunner) run(ctx context.Context) error {
 		}
 	}
 	updatePgSettings(ctx, store, l.logParserCnf.PgSettings)
-	return runLogParserWithMultipleParser(ctx, l.isRunCmd, l.logParserCnf, store, l.htmlReportHelper, l.fileData, l.outputType)
+	var alert runner.ParserFunc
+	if l.logParserCnf.Follow != nil {
+		alert = newLiveAlerter(l.logParserCnf, l.postgresConfig.HtmlReportName(), l.alertSinks).Feed
+	}
+	return runLogParserWithMultipleParser(ctx, l.isRunCmd, l.logParserCnf, store, l.htmlReportHelper, l.fileData, l.outputType, alert)
 }
 
 func updatePgSettings(ctx context.Context, store *sql.DB, pgSettings *model.PgSettings) {
@@ -67,10 +77,12 @@ func updatePgSettings(ctx context.Context, store *sql.DB, pgSettings *model.PgSe
 	}
 
 	pgSettings.LogConnections = ps.LogConnections
+	pgSettings.SyslogIdent = ps.SyslogIdent
 }
 
 func runLogParserWithMultipleParser(ctx context.Context, runCmd bool, logParserCnf *config.LogParser,
-	store *sql.DB, htmlReportHelper *htmlreport.HtmlReportHelper, fileData map[string]interface{}, outputType string) error {
+	store *sql.DB, htmlReportHelper *htmlreport.HtmlReportHelper, fileData map[string]interface{}, outputType string,
+	alert runner.ParserFunc) error {
 
 	allParser, err := getAllParser(ctx, logParserCnf, store)
 	if err != nil {
@@ -82,15 +94,41 @@ func runLogParserWithMultipleParser(ctx context.Context, runCmd bool, logParserC
 		runnerFunctions = append(runnerFunctions, parser.Feed)
 	}
 
-	baseParser := parselog.GetDynamicBaseParser(logParserCnf.PgSettings.LogLinePrefix)
+	var checkpoint *runner.Checkpoint
+	if logParserCnf.CheckpointFile != "" {
+		checkpoint, err = runner.LoadCheckpoint(logParserCnf.CheckpointFile)
+		if err != nil {
+			return err
+		}
+		addCheckpointResults(allParser, checkpoint)
+	}
+
+	baseParser := parselog.GetBaseParser(logParserCnf.LogFormat, logParserCnf.PgSettings)
 
-	fastRunnerResp, err := runner.RunFastParser(ctx, runCmd, baseParser, logParserCnf, runnerFunctions)
-	if err != nil {
-		return fmt.Errorf("Error while running fast parser: %v", err)
+	var fastRunnerResp *runner.FastRunnerResponse
+	if logParserCnf.Follow != nil {
+		// summary is printed for the lines processed till interrupted
+		followCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		fastRunnerResp, err = runner.RunFollower(followCtx, baseParser, logParserCnf, runnerFunctions, alert)
+		stop()
+		if err != nil {
+			return fmt.Errorf("Error while following log file: %v", err)
+		}
+	} else {
+		fastRunnerResp, err = runner.RunFastParserWithCheckpoint(ctx, runCmd, baseParser, logParserCnf, runnerFunctions, checkpoint)
+		if err != nil {
+			return fmt.Errorf("Error while running fast parser: %v", err)
+		}
 	}
 
 	fmt.Println(text.Bold.Sprint("Log Parser Summary:"))
-	if fastRunnerResp == nil || fastRunnerResp.TotalLines == 0 {
+	if logParserCnf.Follow != nil && fastRunnerResp.TotalLines == 0 {
+		fmt.Println("No new log lines were written while following the log file")
+		return nil
+	}
+	// with checkpoint there are no lines when nothing is logged since the
+	// previous run, results accumulated till now are reported.
+	if fastRunnerResp == nil || (fastRunnerResp.TotalLines == 0 && (checkpoint == nil || len(fastRunnerResp.FileErrors) > 0)) {
 		logparser.PrintFileParsingError(fastRunnerResp.FileErrors)
 		htmlReportHelper.RanderLogParserError(fmt.Errorf("We were not able parse any log line. Please check your log file and log line prefix."))
 		return fmt.Errorf("We were not able parse any log line. Please check your log file and log line prefix.")
@@ -105,6 +143,13 @@ func runLogParserWithMultipleParser(ctx context.Context, runCmd bool, logParserC
 		}
 	}
 
+	if checkpoint != nil {
+		if err := saveCheckpoint(allParser, checkpoint, logParserCnf); err != nil {
+			logparser.PrintErrorBox("Error", err)
+		}
+		fmt.Println("Results are accumulated since " + checkpoint.Since.Format("2006-01-02 15:04:05"))
+	}
+
 	if runCmd {
 		logparser.PrintSummary(ctx, allParser, logParserCnf, fastRunnerResp, fileData, outputType)
 	} else {
@@ -116,6 +161,38 @@ func runLogParserWithMultipleParser(ctx context.Context, runCmd bool, logParserC
 	return nil
 }
 
+// addCheckpointResults adds the unique ips and users of the previous runs in
+// the parsers, so the results cover all the runs since the first one.
+func addCheckpointResults(allParser []runner.Parser, checkpoint *runner.Checkpoint) {
+	for _, parser := range allParser {
+		switch p := parser.(type) {
+		case *logparser.UniqueIPHelper:
+			p.AddIPs(checkpoint.UniqueIPs...)
+		case *logparser.InactiveUsersHelper:
+			p.AddUsers(checkpoint.Users...)
+			p.AddLastSeen(checkpoint.UserLastSeen)
+		}
+	}
+}
+
+// saveCheckpoint saves the checkpoint with the results of the current run.
+func saveCheckpoint(allParser []runner.Parser, checkpoint *runner.Checkpoint, logParserCnf *config.LogParser) error {
+	for _, parser := range allParser {
+		switch p := parser.(type) {
+		case *logparser.UniqueIPHelper:
+			checkpoint.AddUniqueIPs(p.GetUniqueIPs())
+		case *logparser.InactiveUsersHelper:
+			checkpoint.AddUsers(p.GetUniqueUser())
+			checkpoint.AddUserLastSeen(p.GetLastSeen())
+		}
+	}
+
+	if err := checkpoint.Save(logParserCnf.CheckpointFile, logParserCnf.LogFiles); err != nil {
+		return fmt.Errorf("Error while saving log parser checkpoint: %v", err)
+	}
+	return nil
+}
+
 func getAllParser(ctx context.Context, logParserCnf *config.LogParser, store *sql.DB) ([]runner.Parser, error) {
 	allParser := []runner.Parser{}
 
@@ -162,6 +239,38 @@ func getAllParser(ctx context.Context, logParserCnf *config.LogParser, store *sq
 			} else {
 				allParser = append(allParser, sqlInjectionScan)
 			}
+		case cons.LogParserCMD_AuthFailures:
+			authFailures := logparser.NewAuthFailureHelper()
+			err := authFailures.Init(ctx, logParserCnf)
+			if err != nil {
+				allParser = append(allParser, logparser.NewErrorHelper(command, "warning", err.Error()))
+			} else {
+				allParser = append(allParser, authFailures)
+			}
+		case cons.LogParserCMD_Connections:
+			connections := logparser.NewConnectionHelper()
+			err := connections.Init(ctx, logParserCnf)
+			if err != nil {
+				allParser = append(allParser, logparser.NewErrorHelper(command, "warning", err.Error()))
+			} else {
+				allParser = append(allParser, connections)
+			}
+		case cons.LogParserCMD_PrivilegedAudit:
+			privilegedAudit := logparser.NewPrivilegedAuditHelper()
+			err := privilegedAudit.Init(ctx, logParserCnf)
+			if err != nil {
+				allParser = append(allParser, logparser.NewErrorHelper(command, "warning", err.Error()))
+			} else {
+				allParser = append(allParser, privilegedAudit)
+			}
+		case cons.LogParserCMD_PgAudit:
+			pgAudit := logparser.NewPgAuditHelper()
+			err := pgAudit.Init(ctx, logParserCnf)
+			if err != nil {
+				allParser = append(allParser, logparser.NewErrorHelper(command, "warning", err.Error()))
+			} else {
+				allParser = append(allParser, pgAudit)
+			}
 		default:
 			return nil, fmt.Errorf("Invalid command: %s", command)
 		}
@@ -18,6 +18,7 @@ import (
 	"github.com/klouddb/klouddbshield/pkg/config"
 	cons "github.com/klouddb/klouddbshield/pkg/const"
 	"github.com/klouddb/klouddbshield/pkg/logger"
+	"github.com/klouddb/klouddbshield/pkg/metrics"
 	"github.com/klouddb/klouddbshield/postgresconfig"
 
 	"github.com/klouddb/klouddbshield/postgres"
@@ -28,6 +29,19 @@ func init() {
 }
 
 func main() {
+	// sub commands have their own flags, so they are handled before parsing
+	// the flags of main command.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "report":
+			os.Exit(runReportCommand(os.Args[2:]))
+		case "serve":
+			os.Exit(runServeCommand(os.Args[2:]))
+		case "hba":
+			os.Exit(runHBACommand(os.Args[2:]))
+		}
+	}
+
 	cnf := config.MustNewConfig()
 	// Setup log level
 	if !cnf.App.Debug {
@@ -63,9 +77,16 @@ func main() {
 
 	fileData := map[string]interface{}{}
 	defer func() {
-		if len(fileData) > 0 {
-			saveResultInFile(fileData, cnf.OutputType)
+		saveResultInFile(fileData, cnf.OutputType, htmlReportHelper)
+		previous := recordHistory(getHistoryDir(), cnf.Postgres.HtmlReportName(), htmlreport.DefaultHistoryEntries, htmlReportHelper)
+		siemSinks, err := newSIEMSinks(cnf.SIEM)
+		if err != nil {
+			fmt.Println("> Error while sending events to siem: ", text.FgHiRed.Sprint(err))
 		}
+		sendSIEMEvents(siemSinks, cnf.Postgres.HtmlReportName(), htmlReportHelper, previous)
+		updateMetrics(cnf.Prometheus, metrics.NewCollector(), htmlreport.HtmlReportHelperMap{
+			cnf.Postgres.HtmlReportName(): htmlReportHelper,
+		})
 		filePath, err := htmlReportHelper.RenderInfile("klouddbshield_report.html", 0600)
 		if err != nil {
 			log.Error().Err(err).Msg("Unable to generate klouddbshield_report.html file: " + err.Error())
@@ -113,8 +134,16 @@ func main() {
 	}
 
 	if cnf.LogParser != nil {
-		err := newLogParserRunnerFromConfig(cnf.Postgres, cnf.LogParser, cnf.App.Run,
-			fileData, htmlReportHelper, cnf.OutputType).run(ctx)
+		logParserRunner := newLogParserRunnerFromConfig(cnf.Postgres, cnf.LogParser, cnf.App.Run,
+			fileData, htmlReportHelper, cnf.OutputType)
+		if cnf.LogParser.Follow != nil {
+			var err error
+			logParserRunner.alertSinks, err = newSIEMSinks(cnf.SIEM)
+			if err != nil {
+				fmt.Println("> Live alerts will not be sent to siem: ", text.FgHiRed.Sprint(err))
+			}
+		}
+		err := logParserRunner.run(ctx)
 		if cnf.App.PrintSummaryOnly {
 			overviewErrorMap[cons.LogParserCMD_InactiveUser] = err
 			overviewErrorMap[cons.LogParserCMD_UniqueIPs] = err
@@ -196,7 +225,7 @@ func main() {
 	}
 
 	if cnf.ConfigAudit {
-		overviewErrorMap[cons.RootCMD_ConfigAuditing] = newConfigAuditor(cnf.Postgres, htmlReportHelper).run(ctx)
+		overviewErrorMap[cons.RootCMD_ConfigAuditing] = newConfigAuditor(cnf.Postgres, fileData, htmlReportHelper, cnf.OutputType).run(ctx)
 	}
 
 	if cnf.SSLCheck {
@@ -321,7 +350,27 @@ func main() {
 // 	return nil
 // }
 
-func saveResultInFile(data map[string]interface{}, outputType string) {
+func saveResultInFile(data map[string]interface{}, outputType string, htmlReportHelper *htmlreport.HtmlReportHelper) {
+	switch outputType {
+	case "markdown":
+		// markdown and csv are generated from html report data, because it
+		// contains the results of all the modules.
+		writeReportFile("klouddbshield_report.md", htmlReportHelper.RenderMarkdown())
+		return
+	case "csv":
+		result, err := htmlReportHelper.RenderCSV()
+		if err != nil {
+			fmt.Println("Error while converting data to csv:", text.FgHiRed.Sprint(err))
+			return
+		}
+		writeReportFile("klouddbshield_report.csv", result)
+		return
+	}
+
+	if len(data) == 0 {
+		return
+	}
+
 	if outputType == "json" {
 		result, err := json.MarshalIndent(data, "", "\t")
 		if err != nil {
@@ -349,3 +398,18 @@ func saveResultInFile(data map[string]interface{}, outputType string) {
 		fmt.Println("**********listOfResults*************\n", string(result))
 	}
 }
+
+func writeReportFile(filename string, result []byte) {
+	if len(result) == 0 {
+		return
+	}
+
+	err := os.WriteFile(filename, result, 0600)
+	if err != nil {
+		fmt.Println("Error while saving result in file:", text.FgHiRed.Sprint(err))
+		fmt.Println("**********listOfResults*************\n", string(result))
+		return
+	}
+
+	fmt.Println("Report saved in file [" + filename + "]")
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// updateMetrics updates the collector with the results of all the targets
+// and writes the textfile if it is configured.
+func updateMetrics(cnf *config.PrometheusConfig, collector *metrics.Collector, htmlHelperMap htmlreport.HtmlReportHelperMap) {
+	if cnf == nil {
+		return
+	}
+
+	now := time.Now()
+	for k, v := range htmlHelperMap {
+		collector.Update(k, v.Summary(), now)
+	}
+
+	if cnf.Textfile == "" {
+		return
+	}
+
+	if err := collector.WriteTextfile(cnf.Textfile); err != nil {
+		log.Error().Err(err).Msg("Unable to write prometheus textfile: " + err.Error())
+	}
+}
+
+// startHTTPServer starts the http server for cron mode in background. Server
+// is stopped when ctx is cancelled.
+func startHTTPServer(ctx context.Context, addr string, handler http.Handler) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx) //nolint:errcheck
+	}()
+
+	go func() {
+		log.Info().Msg("Serving metrics on " + addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("Unable to start http server: " + err.Error())
+		}
+	}()
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/notification"
+	"github.com/rs/zerolog/log"
+)
+
+// newNotifiers creates the webhook notifiers from the config and verifies
+// them, so invalid config is reported while setting up the crons.
+func newNotifiers(cnf []config.Notification) ([]*notification.WebhookNotifier, error) {
+	out := make([]*notification.WebhookNotifier, 0, len(cnf))
+	for i, n := range cnf {
+		notifier := notification.NewWebhookNotifier(n.URL, n.Format, n.OnlyNewFailures, n.OnlyCritical)
+		if err := notifier.VerifyConfig(); err != nil {
+			return nil, fmt.Errorf("notification %d: %v", i+1, err)
+		}
+		out = append(out, notifier)
+	}
+
+	return out, nil
+}
+
+// sendNotifications sends the run summary to all the webhooks. Failure of
+// one webhook does not stop the others.
+func sendNotifications(ctx context.Context, notifiers []*notification.WebhookNotifier, summary *notification.RunSummary) {
+	for _, n := range notifiers {
+		if _, err := n.Notify(ctx, summary); err != nil {
+			log.Error().Err(err).Msg("Unable to send notification: " + err.Error())
+		}
+	}
+}
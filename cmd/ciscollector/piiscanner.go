@@ -23,6 +23,10 @@ func newPiiDbScanner(postgresConfig *postgresdb.Postgres, piiConfig *piiscanner.
 	}
 }
 
+func (p *piiDbScanner) cronProcess(ctx context.Context) error {
+	return p.run(ctx)
+}
+
 func (p *piiDbScanner) run(ctx context.Context) error {
 	pgConfig := *p.postgresConfig
 	pgConfig.DBName = p.cnf.Database
@@ -50,6 +54,7 @@ func (p *piiDbScanner) run(ctx context.Context) error {
 	p.htmlReportHelper.RegisterPIIReport(result)
 
 	piiscanner.CreateTabularOutputfile(result, *p.cnf)
+	piiscanner.CreateJSONOutputFile(result)
 
 	return nil
 }
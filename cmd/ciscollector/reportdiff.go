@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/klouddb/klouddbshield/htmlreport"
+	"github.com/klouddb/klouddbshield/model"
+)
+
+// exit codes for report diff command
+const (
+	reportDiffExit_NoRegression = 0
+	reportDiffExit_Regression   = 1
+	reportDiffExit_Error        = 2
+)
+
+// runReportCommand runs the `ciscollector report <command>` sub commands and
+// returns the exit code.
+func runReportCommand(args []string) int {
+	if len(args) == 0 || args[0] != "diff" {
+		fmt.Println("Usage: ciscollector report diff [--history <report key>] [--html <file>] [old.json new.json]")
+		return reportDiffExit_Error
+	}
+
+	flags := flag.NewFlagSet("report diff", flag.ContinueOnError)
+	historyKey := flags.String("history", "", "Compare the last two stored runs of the report key (e.g postgres_localhost:5432_postgres) instead of json reports")
+	htmlFile := flags.String("html", "klouddbshield_report_diff.html", "HTML file for the diff report. empty value disables html report")
+	if err := flags.Parse(args[1:]); err != nil {
+		return reportDiffExit_Error
+	}
+
+	diff, err := newReportDiff(*historyKey, flags.Args())
+	if err != nil {
+		fmt.Println("> Error while comparing reports: ", text.FgHiRed.Sprint(err))
+		return reportDiffExit_Error
+	}
+
+	printReportDiff(diff)
+
+	if *htmlFile != "" {
+		htmlReportHelper := htmlreport.NewHtmlReportHelper()
+		htmlReportHelper.RegisterReportDiff(diff)
+		filePath, err := htmlReportHelper.RenderInfile(*htmlFile, 0600)
+		if err != nil {
+			fmt.Println("> Error while generating html report: ", text.FgHiRed.Sprint(err))
+			return reportDiffExit_Error
+		}
+		fmt.Println("For Detailed report please open HTML report in your browser [" + filePath + "]")
+	}
+
+	if diff.HasRegression() {
+		return reportDiffExit_Regression
+	}
+
+	return reportDiffExit_NoRegression
+}
+
+func newReportDiff(historyKey string, files []string) (*htmlreport.ReportDiff, error) {
+	if historyKey != "" {
+		if len(files) != 0 {
+			return nil, fmt.Errorf("json reports can not be used with --history flag")
+		}
+
+		entries, err := htmlreport.NewHistoryStore(getHistoryDir()).Load(historyKey, 2)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) < 2 {
+			return nil, fmt.Errorf("at least two stored runs are required for %s", historyKey)
+		}
+
+		return htmlreport.DiffFindings(
+			"run at "+entries[0].Time.Format("2006-01-02 15:04:05"), entries[0].ComparableFindings(),
+			"run at "+entries[1].Time.Format("2006-01-02 15:04:05"), entries[1].ComparableFindings(),
+		), nil
+	}
+
+	if len(files) != 2 {
+		return nil, fmt.Errorf("expected two json reports, got %d", len(files))
+	}
+
+	oldFindings, err := loadJSONReportFindings(files[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %v", files[0], err)
+	}
+
+	newFindings, err := loadJSONReportFindings(files[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %v", files[1], err)
+	}
+
+	return htmlreport.DiffFindings(filepath.Base(files[0]), oldFindings, filepath.Base(files[1]), newFindings), nil
+}
+
+// loadJSONReportFindings reads klouddbshield_report.json file and returns
+// the findings of all the supported modules in it.
+func loadJSONReportFindings(filename string) ([]htmlreport.Finding, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("invalid json report: %v", err)
+	}
+
+	// findings are created from html report helper so the modules and
+	// controls are named same as in html report and history.
+	htmlReportHelper := htmlreport.NewHtmlReportHelper()
+
+	if v, ok := data["Postgres Report"]; ok {
+		report := struct {
+			Result  []*model.Result `json:"result"`
+			Version string          `json:"version"`
+		}{}
+		if err := json.Unmarshal(v, &report); err != nil {
+			return nil, fmt.Errorf("invalid Postgres Report: %v", err)
+		}
+		htmlReportHelper.RegisterPostgresReportData(report.Result, nil, report.Version, false)
+	}
+
+	if v, ok := data["MySQL Report"]; ok {
+		report := struct {
+			Result []*model.Result `json:"mysql"`
+		}{}
+		if err := json.Unmarshal(v, &report); err != nil {
+			return nil, fmt.Errorf("invalid MySQL Report: %v", err)
+		}
+		htmlReportHelper.AddTab("Mysql", &htmlreport.PostgresReport{PostgresResults: report.Result})
+	}
+
+	if v, ok := data["HBA Report"]; ok {
+		var result []*model.HBAScannerResult
+		if err := json.Unmarshal(v, &result); err != nil {
+			return nil, fmt.Errorf("invalid HBA Report: %v", err)
+		}
+		htmlReportHelper.RegisterHBAReportData(result)
+	}
+
+	if v, ok := data["Config Audit"]; ok {
+		var result []*model.ConfigAuditResult
+		if err := json.Unmarshal(v, &result); err != nil {
+			return nil, fmt.Errorf("invalid Config Audit: %v", err)
+		}
+		htmlReportHelper.RegisterConfigAudit(result)
+	}
+
+	findings := htmlReportHelper.Findings()
+	if len(findings) == 0 {
+		return nil, fmt.Errorf("no supported module found in report")
+	}
+
+	return findings, nil
+}
+
+func printReportDiff(diff *htmlreport.ReportDiff) {
+	fmt.Println("Comparing", text.Bold.Sprint(diff.OldName), "with", text.Bold.Sprint(diff.NewName))
+	if len(diff.MissingModules) > 0 {
+		fmt.Println("> Not compared, module is only present in one report:", strings.Join(diff.MissingModules, ", "))
+	}
+
+	if diff.IsEmpty() {
+		fmt.Println(text.FgGreen.Sprint("> No difference found between the reports."))
+		return
+	}
+
+	for _, m := range diff.Modules {
+		fmt.Println()
+		fmt.Println(text.Bold.Sprint(m.Module))
+		for _, f := range m.Added {
+			fmt.Println(text.FgHiRed.Sprintf("  + [%s] %s: %s", f.Severity, f.Control, f.Reason))
+		}
+		for _, f := range m.Resolved {
+			fmt.Println(text.FgGreen.Sprintf("  - [%s] %s", f.Severity, f.Control))
+		}
+		for _, c := range m.Changed {
+			line := fmt.Sprintf("  ~ %s: %s/%s -> %s/%s", c.New.Control, c.Old.Status, c.Old.Severity, c.New.Status, c.New.Severity)
+			if c.Regression {
+				line = text.FgHiRed.Sprint(line)
+			}
+			fmt.Println(line)
+
+			for _, i := range c.AddedItems {
+				fmt.Println(text.FgHiRed.Sprint("      + " + i))
+			}
+			for _, i := range c.RemovedItems {
+				fmt.Println(text.FgGreen.Sprint("      - " + i))
+			}
+			if len(c.AddedItems) == 0 && len(c.RemovedItems) == 0 && c.Old.Reason != c.New.Reason {
+				fmt.Println("      - " + c.Old.Reason)
+				fmt.Println("      + " + c.New.Reason)
+			}
+		}
+	}
+}
@@ -0,0 +1,513 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/rs/zerolog/log"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+	"github.com/klouddb/klouddbshield/pkg/config"
+	cons "github.com/klouddb/klouddbshield/pkg/const"
+	"github.com/klouddb/klouddbshield/pkg/postgresdb"
+)
+
+// serveJobLimit is the number of finished jobs kept in memory.
+const serveJobLimit = 100
+
+// job status values
+const (
+	ScanJobStatus_Queued    = "queued"
+	ScanJobStatus_Running   = "running"
+	ScanJobStatus_Completed = "completed"
+	ScanJobStatus_Failed    = "failed"
+)
+
+// runServeCommand runs `ciscollector serve` and returns the exit code.
+func runServeCommand(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := flags.String("listen", "127.0.0.1:8080", "Address for the api server, token is required to listen on other than loopback address")
+	configPath := flags.String("config", "/etc/klouddbshield", "Config file path")
+	token := flags.String("token", os.Getenv("KSHIELD_API_TOKEN"), "Bearer token required for api requests. default is KSHIELD_API_TOKEN environment variable")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cnf, err := config.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Println("> Error while loading config: ", text.FgHiRed.Sprint(err))
+		return 1
+	}
+
+	s := newScanServer(cnf, *token)
+	if len(s.targets) == 0 {
+		fmt.Println("> Error: ", text.FgHiRed.Sprint("no postgres or mysql target found in config file"))
+		return 1
+	}
+	if *token == "" {
+		if !isLoopbackAddr(*listen) {
+			fmt.Println("> Error: ", text.FgHiRed.Sprint("--token or KSHIELD_API_TOKEN is required to serve api on "+*listen))
+			return 1
+		}
+		fmt.Println(text.FgHiYellow.Sprint("> Api token is not set, api is accessible without authentication from this host"))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	go s.worker(ctx)
+
+	server := &http.Server{
+		Addr:              *listen,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx) //nolint:errcheck
+	}()
+
+	fmt.Println("> Serving api on " + *listen)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Println("> Error while serving api: ", text.FgHiRed.Sprint(err))
+		return 1
+	}
+
+	return 0
+}
+
+// isLoopbackAddr reports whether the listen address accepts connections only
+// from this host. Empty host listens on all the interfaces.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+type serveTarget struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Modules  []string `json:"modules"`
+	postgres *postgresdb.Postgres
+	mysql    *config.MySQL
+}
+
+type scanJob struct {
+	ID         string     `json:"id"`
+	Target     string     `json:"target"`
+	Module     string     `json:"module"`
+	Status     string     `json:"status"`
+	Errors     []string   `json:"errors,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	htmlReportHelper *htmlreport.HtmlReportHelper
+}
+
+type scanServer struct {
+	token   string
+	targets map[string]*serveTarget
+
+	mu    sync.RWMutex
+	jobs  map[string]*scanJob
+	order []string
+	queue chan *scanJob
+}
+
+func newScanServer(cnf *config.Config, token string) *scanServer {
+	s := &scanServer{
+		token:   token,
+		targets: map[string]*serveTarget{},
+		jobs:    map[string]*scanJob{},
+		queue:   make(chan *scanJob, serveJobLimit),
+	}
+
+	addPostgres := func(p *postgresdb.Postgres) {
+		if p == nil {
+			return
+		}
+		s.targets[p.HtmlReportName()] = &serveTarget{
+			Name: p.HtmlReportName(),
+			Type: "postgres",
+			Modules: []string{cons.RootCMD_All, cons.RootCMD_PostgresCIS, cons.RootCMD_HBAScanner, cons.PasswordManager_CommonUsers,
+				cons.RootCMD_SSLCheck, cons.RootCMD_ConfigAuditing, cons.RootCMD_TransactionWraparound},
+			postgres: p,
+		}
+	}
+	addMySQL := func(m *config.MySQL) {
+		if m == nil {
+			return
+		}
+		s.targets[m.HtmlReportName()] = &serveTarget{
+			Name:    m.HtmlReportName(),
+			Type:    "mysql",
+			Modules: []string{cons.RootCMD_All, cons.RootCMD_MySQL},
+			mysql:   m,
+		}
+	}
+
+	addPostgres(cnf.Postgres)
+	addMySQL(cnf.MySQL)
+	for _, c := range cnf.Crons {
+		for _, cmd := range c.Commands {
+			for _, p := range cmd.Postgres {
+				addPostgres(p)
+			}
+			for _, m := range cmd.MySQL {
+				addMySQL(m)
+			}
+		}
+	}
+
+	return s
+}
+
+func (s *scanServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/targets", s.handleTargets)
+	mux.HandleFunc("/api/v1/scans", s.handleScans)
+	mux.HandleFunc("/api/v1/scans/", s.handleScan)
+	mux.HandleFunc("/api/v1/history/", s.handleHistory)
+
+	return s.authenticate(mux)
+}
+
+func (s *scanServer) authenticate(next http.Handler) http.Handler {
+	return requireToken(s.token, next)
+}
+
+// requireToken checks the bearer token of the requests, requests are not
+// checked when token is empty.
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				writeJSONError(w, http.StatusUnauthorized, "invalid or missing api token")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GET /api/v1/targets
+func (s *scanServer) handleTargets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	out := make([]*serveTarget, 0, len(s.targets))
+	for _, t := range s.targets {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+// GET /api/v1/scans lists the jobs, POST /api/v1/scans creates a new job
+func (s *scanServer) handleScans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.RLock()
+		out := make([]scanJob, 0, len(s.order))
+		for i := len(s.order) - 1; i >= 0; i-- {
+			out = append(out, *s.jobs[s.order[i]])
+		}
+		s.mu.RUnlock()
+
+		writeJSON(w, http.StatusOK, out)
+
+	case http.MethodPost:
+		req := struct {
+			Target string `json:"target"`
+			Module string `json:"module"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+
+		job, err := s.enqueue(req.Target, req.Module)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		writeJSON(w, http.StatusAccepted, job)
+
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// GET /api/v1/scans/{id} returns the job status and
+// GET /api/v1/scans/{id}/result?format=json|html returns the result.
+func (s *scanServer) handleScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/v1/scans/"), "/")
+
+	s.mu.RLock()
+	job, ok := s.jobs[id]
+	var jobCopy scanJob
+	if ok {
+		jobCopy = *job
+	}
+	s.mu.RUnlock()
+
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "job not found")
+		return
+	}
+
+	switch rest {
+	case "":
+		writeJSON(w, http.StatusOK, jobCopy)
+
+	case "result":
+		if jobCopy.Status != ScanJobStatus_Completed && jobCopy.Status != ScanJobStatus_Failed {
+			writeJSONError(w, http.StatusConflict, "job is "+jobCopy.Status)
+			return
+		}
+
+		// rendering sorts the tabs of the helper, so it is not safe for
+		// concurrent requests
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.URL.Query().Get("format") == "html" {
+			data, err := jobCopy.htmlReportHelper.Render()
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(data) //nolint:errcheck
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"job":      jobCopy,
+			"summary":  jobCopy.htmlReportHelper.Summary(),
+			"findings": jobCopy.htmlReportHelper.Findings(),
+		})
+
+	default:
+		writeJSONError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// GET /api/v1/history/{target}?limit=N
+func (s *scanServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	target := strings.TrimPrefix(r.URL.Path, "/api/v1/history/")
+	if _, ok := s.targets[target]; !ok {
+		writeJSONError(w, http.StatusNotFound, "target not found")
+		return
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid limit: "+err.Error())
+			return
+		}
+	}
+
+	entries, err := htmlreport.NewHistoryStore(getHistoryDir()).Load(target, limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if entries == nil {
+		entries = []htmlreport.HistoryEntry{}
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *scanServer) enqueue(targetName, module string) (*scanJob, error) {
+	target, ok := s.targets[targetName]
+	if !ok {
+		return nil, fmt.Errorf("unknown target %q", targetName)
+	}
+
+	supported := false
+	for _, m := range target.Modules {
+		if m == module {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return nil, fmt.Errorf("module %q is not supported for target %q, supported modules are %s",
+			module, targetName, strings.Join(target.Modules, ", "))
+	}
+
+	uid, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	job := &scanJob{
+		ID:               uid.String(),
+		Target:           targetName,
+		Module:           module,
+		Status:           ScanJobStatus_Queued,
+		CreatedAt:        time.Now(),
+		htmlReportHelper: htmlreport.NewHtmlReportHelper(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case s.queue <- job:
+	default:
+		return nil, fmt.Errorf("too many queued jobs, please try again later")
+	}
+
+	s.jobs[job.ID] = job
+	s.order = append(s.order, job.ID)
+	s.cleanupJobs()
+
+	out := *job
+	return &out, nil
+}
+
+// cleanupJobs removes the oldest finished jobs when there are more than
+// serveJobLimit jobs. It must be called with lock held.
+func (s *scanServer) cleanupJobs() {
+	for i := 0; len(s.order) > serveJobLimit && i < len(s.order); {
+		job := s.jobs[s.order[i]]
+		if job.Status == ScanJobStatus_Queued || job.Status == ScanJobStatus_Running {
+			i++
+			continue
+		}
+		delete(s.jobs, job.ID)
+		s.order = append(s.order[:i], s.order[i+1:]...)
+	}
+}
+
+// worker runs the jobs one by one, runners print on terminal and use shared
+// resources so they are not executed in parallel.
+func (s *scanServer) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-s.queue:
+			s.runJob(ctx, job)
+		}
+	}
+}
+
+func (s *scanServer) runJob(ctx context.Context, job *scanJob) {
+	s.mu.Lock()
+	now := time.Now()
+	job.Status = ScanJobStatus_Running
+	job.StartedAt = &now
+	s.mu.Unlock()
+
+	errs := s.runModules(ctx, s.targets[job.Target], job.Module, job.htmlReportHelper)
+	recordHistory(getHistoryDir(), job.Target, htmlreport.DefaultHistoryEntries, job.htmlReportHelper)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	finished := time.Now()
+	job.FinishedAt = &finished
+	job.Status = ScanJobStatus_Completed
+	for _, err := range errs {
+		job.Errors = append(job.Errors, err.Error())
+	}
+	if len(errs) > 0 {
+		job.Status = ScanJobStatus_Failed
+	}
+}
+
+func (s *scanServer) runModules(ctx context.Context, target *serveTarget, module string, htmlReportHelper *htmlreport.HtmlReportHelper) []error {
+	modules := []string{module}
+	if module == cons.RootCMD_All {
+		modules = target.Modules[1:]
+	}
+
+	command := &config.Command{}
+	if target.postgres != nil {
+		command.Postgres = []*postgresdb.Postgres{target.postgres}
+	}
+	if target.mysql != nil {
+		command.MySQL = []*config.MySQL{target.mysql}
+	}
+
+	// runners register the results in helper of the target
+	htmlHelperMap := htmlreport.HtmlReportHelperMap{target.Name: htmlReportHelper}
+
+	errs := []error{}
+	for _, m := range modules {
+		command.Name = m
+		processors, err := getProcessorsForCron("", command, htmlHelperMap)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", m, err))
+			continue
+		}
+
+		for _, p := range processors {
+			if err := p.cronProcess(ctx); err != nil {
+				log.Error().Err(err).Msg("Error while running " + m + " for " + target.Name)
+				errs = append(errs, fmt.Errorf("%s: %v", m, err))
+			}
+		}
+	}
+
+	return errs
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data) //nolint:errcheck
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klouddb/klouddbshield/model"
+	"github.com/klouddb/klouddbshield/pkg/config"
+	cons "github.com/klouddb/klouddbshield/pkg/const"
+	"github.com/klouddb/klouddbshield/pkg/postgresdb"
+)
+
+const testServeTarget = "postgres_localhost:5432_postgres"
+
+func newTestScanServer(token string) *scanServer {
+	return newScanServer(&config.Config{
+		Postgres: &postgresdb.Postgres{Host: "localhost", Port: "5432", DBName: "postgres"},
+	}, token)
+}
+
+func serveRequest(t *testing.T, h http.Handler, method, path, token, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	var out map[string]interface{}
+	if strings.HasPrefix(strings.TrimSpace(w.Body.String()), "{") {
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatalf("%s %s: invalid json response %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w, out
+}
+
+func TestScanServerAuthentication(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverKey  string
+		token      string
+		wantStatus int
+	}{
+		{name: "no token configured", wantStatus: http.StatusOK},
+		{name: "valid token", serverKey: "secret", token: "secret", wantStatus: http.StatusOK},
+		{name: "missing token", serverKey: "secret", wantStatus: http.StatusUnauthorized},
+		{name: "invalid token", serverKey: "secret", token: "wrong", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestScanServer(tt.serverKey).handler()
+			w, _ := serveRequest(t, h, http.MethodGet, "/api/v1/targets", tt.token, "")
+			if w.Code != tt.wantStatus {
+				t.Errorf("GET /api/v1/targets status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestScanServerStartJob(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "supported module", body: `{"target":"` + testServeTarget + `","module":"` + cons.RootCMD_HBAScanner + `"}`, wantStatus: http.StatusAccepted},
+		{name: "unknown target", body: `{"target":"pg","module":"` + cons.RootCMD_HBAScanner + `"}`, wantStatus: http.StatusBadRequest},
+		{name: "unsupported module", body: `{"target":"` + testServeTarget + `","module":"mysql"}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid body", body: `{`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanServer("")
+			w, out := serveRequest(t, s.handler(), http.MethodPost, "/api/v1/scans", "", tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("POST /api/v1/scans status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusAccepted {
+				return
+			}
+			if out["status"] != ScanJobStatus_Queued || len(s.queue) != 1 {
+				t.Errorf("job = %v with %d queued jobs, want one queued job", out, len(s.queue))
+			}
+		})
+	}
+}
+
+func TestScanServerJobResult(t *testing.T) {
+	s := newTestScanServer("")
+	h := s.handler()
+
+	job, err := s.enqueue(testServeTarget, cons.RootCMD_HBAScanner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w, _ := serveRequest(t, h, http.MethodGet, "/api/v1/scans/unknown", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown job status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w, _ := serveRequest(t, h, http.MethodGet, "/api/v1/scans/"+job.ID+"/result", "", ""); w.Code != http.StatusConflict {
+		t.Errorf("result of queued job status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	// job is completed without the worker, runners need a database
+	s.mu.Lock()
+	s.jobs[job.ID].Status = ScanJobStatus_Completed
+	s.jobs[job.ID].htmlReportHelper.RegisterHBAReportData([]*model.HBAScannerResult{
+		{Control: 1, Description: "Trust auth", Status: "Fail", FailRows: []string{"host all all 0.0.0.0/0 trust"}, FailRowsLineNums: []int{3}},
+	})
+	s.mu.Unlock()
+
+	w, out := serveRequest(t, h, http.MethodGet, "/api/v1/scans/"+job.ID, "", "")
+	if w.Code != http.StatusOK || out["status"] != ScanJobStatus_Completed {
+		t.Errorf("GET job = %d %v, want completed job", w.Code, out)
+	}
+
+	w, out = serveRequest(t, h, http.MethodGet, "/api/v1/scans/"+job.ID+"/result", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET result status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	findings, _ := out["findings"].([]interface{})
+	if len(findings) != 1 || findings[0].(map[string]interface{})["Reason"] != "host all all 0.0.0.0/0 trust" {
+		t.Errorf("GET result findings = %v", out["findings"])
+	}
+
+	w, _ = serveRequest(t, h, http.MethodGet, "/api/v1/scans/"+job.ID+"/result?format=html", "", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("GET html result = %d %s, want html report", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func Test_isLoopbackAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1:8080", true},
+		{"localhost:8080", true},
+		{"[::1]:8080", true},
+		{":8080", false},
+		{"0.0.0.0:8080", false},
+		{"10.0.0.1:8080", false},
+		{"8080", false},
+	}
+
+	for _, tt := range tests {
+		if got := isLoopbackAddr(tt.addr); got != tt.want {
+			t.Errorf("isLoopbackAddr(%q) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestScanServerModulesSupportedByCron(t *testing.T) {
+	s := newScanServer(&config.Config{
+		Postgres: &postgresdb.Postgres{Host: "localhost", Port: "5432", DBName: "postgres"},
+		MySQL:    &config.MySQL{Host: "localhost", Port: "3306"},
+	}, "")
+
+	for _, target := range s.targets {
+		command := &config.Command{}
+		if target.postgres != nil {
+			command.Postgres = []*postgresdb.Postgres{target.postgres}
+		}
+		if target.mysql != nil {
+			command.MySQL = []*config.MySQL{target.mysql}
+		}
+
+		for _, m := range target.Modules[1:] {
+			command.Name = m
+			if n, err := countProcessorsForCron(command); err != nil || n == 0 {
+				t.Errorf("module %s of %s: countProcessorsForCron() = %d, %v", m, target.Name, n, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/siem"
+	"github.com/rs/zerolog/log"
+)
+
+// newSIEMSinks creates the siem sinks from the config and verifies them.
+func newSIEMSinks(cnf []config.SIEM) ([]*siem.Sink, error) {
+	out := make([]*siem.Sink, 0, len(cnf))
+	for i, s := range cnf {
+		sink := siem.NewSink(s.Format, s.Network, s.Address, s.File)
+		if err := sink.VerifyConfig(); err != nil {
+			return nil, fmt.Errorf("siem %d: %v", i+1, err)
+		}
+		out = append(out, sink)
+	}
+
+	return out, nil
+}
+
+// sendSIEMEvents forwards the findings of the target to all the sinks.
+// previous is the history entry of the previous run, used for finding the
+// new client IPs.
+func sendSIEMEvents(sinks []*siem.Sink, target string, htmlReportHelper *htmlreport.HtmlReportHelper, previous *htmlreport.HistoryEntry) {
+	if len(sinks) == 0 {
+		return
+	}
+
+	events := siem.NewEvents(time.Now(), target, htmlReportHelper.Findings(), previous)
+	for _, s := range sinks {
+		if err := s.Send(events); err != nil {
+			log.Error().Err(err).Msg("Unable to send events to siem: " + err.Error())
+		}
+	}
+}
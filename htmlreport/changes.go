@@ -0,0 +1,209 @@
+package htmlreport
+
+import (
+	"strings"
+
+	"github.com/klouddb/klouddbshield/pkg/parselog"
+)
+
+// controls of the findings which are tracked separately in TargetChanges
+const (
+	LeakedPasswordControl = "Leaked Password"
+	// UniqueIPsControl is the log parser finding with all the unique IPs
+	// as reason.
+	UniqueIPsControl    = "Unique IPs"
+	unusedHBALinePrefix = "Unused HBA Line"
+	backupPrefix        = "Backup "
+)
+
+// logParserModule is the module of log parser findings. They are of the log
+// lines processed in the run, so they are not resolved when missing in the
+// next run.
+const logParserModule = "Log Parser"
+
+// TargetChanges is the difference of a target from its previous run. It is
+// used to alert only when something has changed.
+type TargetChanges struct {
+	// FirstRun is set when there is no previous run of the target, all the
+	// failures are considered new then.
+	FirstRun bool `json:"first_run,omitempty"`
+
+	// NewFailures are the failing findings which were not failing or got
+	// worse since the previous run.
+	NewFailures []Finding `json:"new_failures,omitempty"`
+	// Resolved are the findings which were failing in the previous run.
+	Resolved []Finding `json:"resolved,omitempty"`
+
+	NewLeakedPasswords []string `json:"new_leaked_passwords,omitempty"`
+	NewUniqueIPs       []string `json:"new_unique_ips,omitempty"`
+	NewUnusedHBALines  []string `json:"new_unused_hba_lines,omitempty"`
+	// BackupSLABreaches are the dates with missing backup.
+	BackupSLABreaches []string `json:"backup_sla_breaches,omitempty"`
+}
+
+// HasChanges reports whether the target has anything worth alerting.
+func (c *TargetChanges) HasChanges() bool {
+	if c == nil {
+		return false
+	}
+
+	return len(c.NewFailures) > 0 || len(c.Resolved) > 0 || len(c.NewLeakedPasswords) > 0 ||
+		len(c.NewUniqueIPs) > 0 || len(c.NewUnusedHBALines) > 0 || len(c.BackupSLABreaches) > 0
+}
+
+// PreviousEntry returns the entry to compare the findings of the current run
+// with, entries are the previous runs of the target sorted by time. Runs of
+// different schedules execute different modules, so every module is taken
+// from the latest entry which has the module. Log parser findings are taken
+// from all the entries, as every run only has the findings of its own log
+// window. Nil is returned when there is no previous run.
+func PreviousEntry(entries []HistoryEntry, findings []Finding) *HistoryEntry {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	out := &HistoryEntry{Time: entries[len(entries)-1].Time}
+	added := map[string]bool{}
+	addModule := func(e HistoryEntry, module string) {
+		if !added[module] {
+			added[module] = true
+			out.Modules = append(out.Modules, module)
+		}
+		for _, f := range e.Findings {
+			if f.Module == module {
+				out.Findings = append(out.Findings, f)
+			}
+		}
+	}
+
+	for _, f := range findings {
+		if added[f.Module] {
+			continue
+		}
+
+		if f.Module == logParserModule {
+			for _, e := range entries {
+				if e.HasModule(f.Module) {
+					addModule(e, f.Module)
+				}
+			}
+			continue
+		}
+
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].HasModule(f.Module) {
+				addModule(entries[i], f.Module)
+				break
+			}
+		}
+	}
+
+	return out
+}
+
+// NewTargetChanges compares the findings with the previous history entry of
+// the target, which is created by PreviousEntry. When previous is nil
+// everything is considered new.
+func NewTargetChanges(findings []Finding, previous *HistoryEntry) *TargetChanges {
+	out := &TargetChanges{FirstRun: previous == nil}
+
+	var previousFindings []Finding
+	if previous == nil {
+		for _, f := range findings {
+			if f.IsFailing() {
+				out.NewFailures = append(out.NewFailures, f)
+			}
+		}
+	} else {
+		previousFindings = previous.ComparableFindings()
+
+		diff := DiffFindings("previous", previousFindings, "current", findings)
+		for _, m := range diff.Modules {
+			out.NewFailures = append(out.NewFailures, m.Added...)
+			for _, c := range m.Changed {
+				if c.Regression {
+					out.NewFailures = append(out.NewFailures, c.New)
+				}
+			}
+			// log window of the next run has different lines
+			if m.Module != logParserModule {
+				out.Resolved = append(out.Resolved, m.Resolved...)
+			}
+		}
+
+		// modules which were not executed in the previous run are not
+		// compared by diff, so their failures are new.
+		previousModules := map[string]bool{}
+		for _, f := range previousFindings {
+			previousModules[f.Module] = true
+		}
+		for _, f := range findings {
+			if f.IsFailing() && !previousModules[f.Module] {
+				out.NewFailures = append(out.NewFailures, f)
+			}
+		}
+	}
+
+	out.NewLeakedPasswords = newValues(findings, previousFindings, func(f Finding) []string {
+		// reasons of older history entries may have the password
+		if f.Control == LeakedPasswordControl && f.IsFailing() {
+			return []string{parselog.MaskPasswords(f.Reason)}
+		}
+		return nil
+	})
+
+	out.NewUniqueIPs = newValues(findings, previousFindings, func(f Finding) []string {
+		if f.Control != UniqueIPsControl {
+			return nil
+		}
+		ips := []string{}
+		for _, ip := range strings.Split(f.Reason, ",") {
+			if ip = strings.TrimSpace(ip); ip != "" {
+				ips = append(ips, ip)
+			}
+		}
+		return ips
+	})
+
+	// line numbers change when lines are added in pg_hba.conf, so lines are
+	// compared by their content.
+	out.NewUnusedHBALines = newValues(findings, previousFindings, func(f Finding) []string {
+		if strings.HasPrefix(f.Control, unusedHBALinePrefix) && f.IsFailing() {
+			return []string{f.Reason}
+		}
+		return nil
+	})
+
+	out.BackupSLABreaches = newValues(findings, previousFindings, func(f Finding) []string {
+		if strings.HasPrefix(f.Control, backupPrefix) && f.IsFailing() {
+			return []string{strings.TrimPrefix(f.Control, backupPrefix)}
+		}
+		return nil
+	})
+
+	return out
+}
+
+// newValues returns the values of current findings which are not present in
+// the values of previous findings, in the order of current findings.
+func newValues(current, previous []Finding, values func(Finding) []string) []string {
+	seen := map[string]bool{}
+	for _, f := range previous {
+		for _, v := range values(f) {
+			seen[v] = true
+		}
+	}
+
+	var out []string
+	for _, f := range current {
+		for _, v := range values(f) {
+			if seen[v] {
+				continue
+			}
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
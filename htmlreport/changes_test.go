@@ -0,0 +1,110 @@
+package htmlreport
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewTargetChanges(t *testing.T) {
+	previous := &HistoryEntry{Findings: []Finding{
+		{Module: "Postgres", Control: "1.1", Status: "Fail", Severity: Severity_High},
+		{Module: "Postgres", Control: "1.2", Status: "Fail", Severity: Severity_High},
+		{Module: "Log Parser", Control: "Unique IPs", Status: "Info", Reason: "10.0.0.1, 10.0.0.2"},
+		{Module: "Log Parser", Control: "Leaked Password", Status: "Fail", Severity: Severity_High, Reason: "ALTER USER a"},
+		{Module: "Log Parser", Control: "Unused HBA Line 3", Status: "Fail", Severity: Severity_Low, Reason: "host all all 10.0.0.0/8 md5"},
+	}}
+	current := []Finding{
+		{Module: "Postgres", Control: "1.1", Status: "Fail", Severity: Severity_High},
+		{Module: "Postgres", Control: "1.2", Status: "Pass"},
+		{Module: "Log Parser", Control: "Unique IPs", Status: "Info", Reason: "10.0.0.2, 10.0.0.3"},
+		{Module: "Log Parser", Control: "Leaked Password", Status: "Fail", Severity: Severity_High, Reason: "ALTER USER a"},
+		{Module: "Log Parser", Control: "Leaked Password", Status: "Fail", Severity: Severity_High, Reason: "ALTER USER b PASSWORD 'secret'"},
+		// line number changed, but the line is same
+		{Module: "Log Parser", Control: "Unused HBA Line 4", Status: "Fail", Severity: Severity_Low, Reason: "host all all 10.0.0.0/8 md5"},
+		{Module: "Backup History", Control: "Backup 2024-01-02", Status: "Fail", Severity: Severity_High},
+	}
+
+	got := NewTargetChanges(current, previous)
+	if got.FirstRun || !got.HasChanges() {
+		t.Errorf("FirstRun, HasChanges() = %v, %v; want false, true", got.FirstRun, got.HasChanges())
+	}
+	// log parser findings are not resolved when the log window moves, and
+	// renumbered unused line is not a newly unused line.
+	if len(got.Resolved) != 1 || got.Resolved[0].Control != "1.2" {
+		t.Errorf("Resolved = %+v, want 1.2", got.Resolved)
+	}
+	if !reflect.DeepEqual(got.NewLeakedPasswords, []string{"ALTER USER b PASSWORD '***'"}) {
+		t.Errorf("NewLeakedPasswords = %v", got.NewLeakedPasswords)
+	}
+	if !reflect.DeepEqual(got.NewUniqueIPs, []string{"10.0.0.3"}) {
+		t.Errorf("NewUniqueIPs = %v", got.NewUniqueIPs)
+	}
+	if len(got.NewUnusedHBALines) != 0 {
+		t.Errorf("NewUnusedHBALines = %v, want none", got.NewUnusedHBALines)
+	}
+	if !reflect.DeepEqual(got.BackupSLABreaches, []string{"2024-01-02"}) {
+		t.Errorf("BackupSLABreaches = %v", got.BackupSLABreaches)
+	}
+
+	if unchanged := NewTargetChanges(previous.Findings, previous); unchanged.HasChanges() {
+		t.Errorf("same findings should not have changes: %+v", unchanged)
+	}
+
+	first := NewTargetChanges(current, nil)
+	if !first.FirstRun || len(first.NewUnusedHBALines) != 1 || len(first.NewUniqueIPs) != 2 {
+		t.Errorf("unexpected changes of first run: %+v", first)
+	}
+}
+
+func TestPreviousEntry(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []HistoryEntry{
+		{
+			Time:    start,
+			Modules: []string{"Postgres", "Log Parser"},
+			Findings: []Finding{
+				{Module: "Postgres", Control: "1.1", Status: "Fail"},
+				{Module: "Log Parser", Control: "Unique IPs", Status: "Info", Reason: "10.0.0.1"},
+				{Module: "Log Parser", Control: "Leaked Password", Status: "Fail", Reason: "ALTER USER a PASSWORD '***'"},
+			},
+		},
+		{
+			Time:     start.Add(time.Hour),
+			Modules:  []string{"HBA Scanner Report", "Log Parser"},
+			Findings: []Finding{{Module: "Log Parser", Control: "Unique IPs", Status: "Info", Reason: "10.0.0.2"}},
+		},
+		// other schedule which only runs hba scanner
+		{
+			Time:     start.Add(2 * time.Hour),
+			Modules:  []string{"HBA Scanner Report"},
+			Findings: []Finding{{Module: "HBA Scanner Report", Control: "HBA Check 1", Status: "Fail"}},
+		},
+	}
+	current := []Finding{
+		{Module: "Postgres", Control: "1.1", Status: "Fail"},
+		{Module: "Log Parser", Control: "Unique IPs", Status: "Info", Reason: "10.0.0.1, 10.0.0.2, 10.0.0.3"},
+		{Module: "SSL Report", Control: "ssl", Status: "Fail"},
+	}
+
+	if got := PreviousEntry(nil, current); got != nil {
+		t.Errorf("PreviousEntry() without history = %+v, want nil", got)
+	}
+
+	previous := PreviousEntry(entries, current)
+	if !reflect.DeepEqual(previous.Modules, []string{"Postgres", "Log Parser"}) {
+		t.Errorf("Modules = %v, want only the modules of current run", previous.Modules)
+	}
+
+	changes := NewTargetChanges(current, previous)
+	if len(changes.NewFailures) != 1 || changes.NewFailures[0].Control != "ssl" {
+		t.Errorf("NewFailures = %+v, want only ssl", changes.NewFailures)
+	}
+	if !reflect.DeepEqual(changes.NewUniqueIPs, []string{"10.0.0.3"}) {
+		t.Errorf("NewUniqueIPs = %v, want 10.0.0.3", changes.NewUniqueIPs)
+	}
+	// leaked password of the previous log window is not resolved
+	if len(changes.Resolved) != 0 {
+		t.Errorf("Resolved = %+v, want none", changes.Resolved)
+	}
+}
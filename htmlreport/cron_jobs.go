@@ -0,0 +1,45 @@
+package htmlreport
+
+// cron job statuses
+const (
+	CronJobStatus_Success  = "Success"
+	CronJobStatus_Failed   = "Failed"
+	CronJobStatus_TimedOut = "Timed Out"
+)
+
+// CronJobReport is the result of all the commands of a scheduled run.
+type CronJobReport struct {
+	Schedule string
+	Jobs     []*CronJobResult
+}
+
+// CronJobResult is the result of a single command of the scheduled run.
+type CronJobResult struct {
+	Command  string
+	Status   string
+	Attempts int
+	Duration string
+	Targets  []string
+	Errors   []string
+}
+
+// RegisterCronJobs adds the scheduled jobs tab in the report.
+func (h *HtmlReportHelper) RegisterCronJobs(report *CronJobReport) {
+	if report == nil || len(report.Jobs) == 0 {
+		return
+	}
+
+	h.AddTab("Scheduled Jobs", report)
+}
+
+// Merge adds all the tabs of other map in the helpers of same key.
+func (m HtmlReportHelperMap) Merge(other HtmlReportHelperMap) {
+	for k, h := range other {
+		if h == nil {
+			continue
+		}
+
+		dst := m.Get(k)
+		dst.templateData = append(dst.templateData, h.templateData...)
+	}
+}
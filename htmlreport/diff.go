@@ -0,0 +1,246 @@
+package htmlreport
+
+import (
+	"sort"
+	"strings"
+)
+
+// ReportDiff is the difference of findings between two reports.
+type ReportDiff struct {
+	OldName string
+	NewName string
+
+	Modules []*ModuleDiff
+
+	// MissingModules are the modules which are only present in one of the
+	// reports, so they are not compared.
+	MissingModules []string
+}
+
+// ModuleDiff is the difference of findings for a single module.
+type ModuleDiff struct {
+	Module   string
+	Added    []Finding
+	Resolved []Finding
+	Changed  []FindingChange
+}
+
+// FindingChange is a finding which is failing in both the reports but its
+// details are changed.
+type FindingChange struct {
+	Old          Finding
+	New          Finding
+	AddedItems   []string
+	RemovedItems []string
+	Regression   bool
+}
+
+var severityRank = map[string]int{
+	Severity_Info:     0,
+	Severity_Low:      1,
+	Severity_Medium:   2,
+	Severity_High:     3,
+	Severity_Critical: 4,
+}
+
+// HasRegression reports whether the new report has new failing findings or
+// the existing findings got worse.
+func (d *ReportDiff) HasRegression() bool {
+	if d == nil {
+		return false
+	}
+
+	for _, m := range d.Modules {
+		if len(m.Added) > 0 {
+			return true
+		}
+		for _, c := range m.Changed {
+			if c.Regression {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// IsEmpty reports whether there is no difference between the reports.
+func (d *ReportDiff) IsEmpty() bool {
+	return d == nil || len(d.Modules) == 0
+}
+
+// DiffFindings compares the findings of old and new report. Findings are
+// matched by module and control, only the modules present in both the reports
+// are compared.
+func DiffFindings(oldName string, oldFindings []Finding, newName string, newFindings []Finding) *ReportDiff {
+	out := &ReportDiff{OldName: oldName, NewName: newName}
+
+	oldModules, oldOrder := groupFindings(oldFindings)
+	newModules, newOrder := groupFindings(newFindings)
+
+	for _, module := range oldOrder {
+		if _, ok := newModules[module]; !ok {
+			out.MissingModules = append(out.MissingModules, module)
+		}
+	}
+
+	for _, module := range newOrder {
+		oldFindings, ok := oldModules[module]
+		if !ok {
+			out.MissingModules = append(out.MissingModules, module)
+			continue
+		}
+
+		m := diffModule(module, oldFindings, newModules[module])
+		if len(m.Added) > 0 || len(m.Resolved) > 0 || len(m.Changed) > 0 {
+			out.Modules = append(out.Modules, m)
+		}
+	}
+
+	return out
+}
+
+type findingGroup struct {
+	order    []string
+	findings map[string]Finding
+}
+
+// groupFindings groups the findings by module and then by control. Findings
+// with same control (like multiple PII labels of a column) are merged into
+// one finding with all the reasons as items.
+func groupFindings(findings []Finding) (map[string]*findingGroup, []string) {
+	out := map[string]*findingGroup{}
+	order := []string{}
+
+	for _, f := range findings {
+		g, ok := out[f.Module]
+		if !ok {
+			g = &findingGroup{findings: map[string]Finding{}}
+			out[f.Module] = g
+			order = append(order, f.Module)
+		}
+
+		existing, ok := g.findings[f.Control]
+		if !ok {
+			g.order = append(g.order, f.Control)
+			g.findings[f.Control] = f
+			continue
+		}
+
+		items := []string{}
+		items = append(items, findingItems(existing)...)
+		items = append(items, findingItems(f)...)
+		existing.Items = uniqueSorted(items)
+		existing.Reason = strings.Join(existing.Items, ", ")
+		if severityRank[f.Severity] > severityRank[existing.Severity] {
+			existing.Severity = f.Severity
+		}
+		if f.IsFailing() && !existing.IsFailing() {
+			existing.Status = f.Status
+		}
+		g.findings[f.Control] = existing
+	}
+
+	return out, order
+}
+
+func diffModule(module string, oldGroup, newGroup *findingGroup) *ModuleDiff {
+	out := &ModuleDiff{Module: module}
+
+	for _, control := range newGroup.order {
+		newFinding := newGroup.findings[control]
+		oldFinding, ok := oldGroup.findings[control]
+
+		switch {
+		case !newFinding.IsFailing():
+			continue
+		case !ok || !oldFinding.IsFailing():
+			out.Added = append(out.Added, newFinding)
+		default:
+			if change, changed := diffFinding(oldFinding, newFinding); changed {
+				out.Changed = append(out.Changed, change)
+			}
+		}
+	}
+
+	for _, control := range oldGroup.order {
+		oldFinding := oldGroup.findings[control]
+		if !oldFinding.IsFailing() {
+			continue
+		}
+
+		newFinding, ok := newGroup.findings[control]
+		if !ok || !newFinding.IsFailing() {
+			out.Resolved = append(out.Resolved, oldFinding)
+		}
+	}
+
+	return out
+}
+
+func diffFinding(oldFinding, newFinding Finding) (FindingChange, bool) {
+	change := FindingChange{Old: oldFinding, New: newFinding}
+
+	if len(oldFinding.Items) > 0 || len(newFinding.Items) > 0 {
+		oldItems := map[string]bool{}
+		for _, i := range oldFinding.Items {
+			oldItems[i] = true
+		}
+		newItems := map[string]bool{}
+		for _, i := range newFinding.Items {
+			newItems[i] = true
+			if !oldItems[i] {
+				change.AddedItems = append(change.AddedItems, i)
+			}
+		}
+		for _, i := range oldFinding.Items {
+			if !newItems[i] {
+				change.RemovedItems = append(change.RemovedItems, i)
+			}
+		}
+	}
+
+	severityChanged := oldFinding.Severity != newFinding.Severity || oldFinding.Status != newFinding.Status
+	itemsChanged := len(change.AddedItems) > 0 || len(change.RemovedItems) > 0
+	// reason is only compared when items are not available, as reason of
+	// the findings with items is generated from items.
+	reasonChanged := len(oldFinding.Items) == 0 && len(newFinding.Items) == 0 && oldFinding.Reason != newFinding.Reason
+
+	if !severityChanged && !itemsChanged && !reasonChanged {
+		return change, false
+	}
+
+	change.Regression = severityRank[newFinding.Severity] > severityRank[oldFinding.Severity] ||
+		len(change.AddedItems) > 0
+	return change, true
+}
+
+func findingItems(f Finding) []string {
+	if len(f.Items) > 0 {
+		return f.Items
+	}
+	return []string{f.Reason}
+}
+
+func uniqueSorted(items []string) []string {
+	set := map[string]struct{}{}
+	out := []string{}
+	for _, i := range items {
+		if _, ok := set[i]; ok {
+			continue
+		}
+		set[i] = struct{}{}
+		out = append(out, i)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// RegisterReportDiff adds the report diff tab in the report.
+func (h *HtmlReportHelper) RegisterReportDiff(diff *ReportDiff) {
+	if diff == nil {
+		return
+	}
+
+	h.AddTab("Report Diff", diff)
+}
@@ -0,0 +1,52 @@
+package htmlreport
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffFindings(t *testing.T) {
+	oldFindings := []Finding{
+		{Module: "HBA Scanner Report", Control: "HBA Check 1", Status: "Fail", Severity: Severity_High, Items: []string{"a", "b"}},
+		{Module: "HBA Scanner Report", Control: "HBA Check 2", Status: "Fail", Severity: Severity_High, Items: []string{"c"}},
+		{Module: "Config Audit", Control: "ssl", Status: "Fail", Severity: Severity_High, Reason: "ssl=off"},
+		{Module: "SSL Report", Control: "ssl", Status: "Fail", Severity: Severity_High},
+	}
+	newFindings := []Finding{
+		{Module: "HBA Scanner Report", Control: "HBA Check 1", Status: "Fail", Severity: Severity_High, Items: []string{"b", "d"}},
+		{Module: "HBA Scanner Report", Control: "HBA Check 2", Status: "Pass", Severity: Severity_Info},
+		{Module: "HBA Scanner Report", Control: "HBA Check 3", Status: "Fail", Severity: Severity_High},
+		{Module: "Config Audit", Control: "ssl", Status: "Fail", Severity: Severity_High, Reason: "ssl=off"},
+	}
+
+	diff := DiffFindings("old", oldFindings, "new", newFindings)
+
+	if !reflect.DeepEqual(diff.MissingModules, []string{"SSL Report"}) {
+		t.Errorf("MissingModules = %v, want [SSL Report]", diff.MissingModules)
+	}
+	if len(diff.Modules) != 1 {
+		t.Fatalf("got %d changed modules, want 1", len(diff.Modules))
+	}
+
+	m := diff.Modules[0]
+	if len(m.Added) != 1 || m.Added[0].Control != "HBA Check 3" {
+		t.Errorf("Added = %+v, want HBA Check 3", m.Added)
+	}
+	if len(m.Resolved) != 1 || m.Resolved[0].Control != "HBA Check 2" {
+		t.Errorf("Resolved = %+v, want HBA Check 2", m.Resolved)
+	}
+	if len(m.Changed) != 1 {
+		t.Fatalf("Changed = %+v, want HBA Check 1", m.Changed)
+	}
+	if c := m.Changed[0]; !reflect.DeepEqual(c.AddedItems, []string{"d"}) || !reflect.DeepEqual(c.RemovedItems, []string{"a"}) || !c.Regression {
+		t.Errorf("Changed = %+v, want added [d] removed [a] with regression", c)
+	}
+
+	if !diff.HasRegression() {
+		t.Error("HasRegression() = false, want true")
+	}
+
+	if DiffFindings("old", oldFindings, "new", oldFindings).HasRegression() {
+		t.Error("HasRegression() = true for same reports")
+	}
+}
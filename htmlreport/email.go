@@ -0,0 +1,107 @@
+package htmlreport
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// emailTopFailures is the number of failures listed per target in the email
+// body, the detailed report is attached for the rest.
+const emailTopFailures = 5
+
+// emailReasonLength is the number of runes of a finding reason in the email
+// body, reasons of findings with many items can be very long.
+const emailReasonLength = 300
+
+// EmailSummary is the data for the email body of a scheduled run.
+type EmailSummary struct {
+	GeneratedAt string
+	Targets     []*EmailTarget
+}
+
+// EmailTarget is the summary of a single target in the email body.
+type EmailTarget struct {
+	Name        string
+	CISScore    string
+	HBAFailures string
+
+	FailingCount  int
+	CriticalCount int
+	TopFailures   []Finding
+
+	// Changes is the difference from the previous run, nil when it is not
+	// known.
+	Changes *TargetChanges
+}
+
+// NewEmailSummary creates the email summary from all the report helpers in
+// the map, targets are sorted by name. Changes of the targets since their
+// previous run are added from the changes map, which can be nil.
+func (m HtmlReportHelperMap) NewEmailSummary(changes map[string]*TargetChanges) *EmailSummary {
+	fleet := m.NewFleetReport(nil)
+
+	out := &EmailSummary{GeneratedAt: fleet.GeneratedAt}
+	for _, t := range fleet.Targets {
+		target := &EmailTarget{
+			Name:        t.Name,
+			CISScore:    t.CISScore,
+			HBAFailures: t.HBAFailures,
+		}
+		if c := changes[t.Name]; c != nil {
+			targetChanges := *c
+			targetChanges.NewFailures = truncateReasons(c.NewFailures)
+			targetChanges.Resolved = truncateReasons(c.Resolved)
+			target.Changes = &targetChanges
+		}
+
+		failing := []Finding{}
+		for _, f := range m[t.Name].Findings() {
+			if !f.IsFailing() {
+				continue
+			}
+			failing = append(failing, f)
+			if f.Severity == Severity_Critical {
+				target.CriticalCount++
+			}
+		}
+		target.FailingCount = len(failing)
+
+		sort.SliceStable(failing, func(i, j int) bool {
+			return severityRank[failing[i].Severity] > severityRank[failing[j].Severity]
+		})
+		if len(failing) > emailTopFailures {
+			failing = failing[:emailTopFailures]
+		}
+		target.TopFailures = truncateReasons(failing)
+
+		out.Targets = append(out.Targets, target)
+	}
+
+	return out
+}
+
+// truncateReasons returns a copy of the findings with the reasons truncated
+// on rune boundaries.
+func truncateReasons(findings []Finding) []Finding {
+	out := make([]Finding, len(findings))
+	for i, f := range findings {
+		if reason := []rune(f.Reason); len(reason) > emailReasonLength {
+			f.Reason = string(reason[:emailReasonLength]) + "..."
+		}
+		out[i] = f
+	}
+	return out
+}
+
+// RenderEmailBody returns the html body of the email for the scheduled run.
+// It only uses inline styles as most of the email clients ignore style
+// sheets and scripts.
+func (m HtmlReportHelperMap) RenderEmailBody(changes map[string]*TargetChanges) ([]byte, error) {
+	output := bytes.NewBuffer(nil)
+	if err := tmpl.ExecuteTemplate(output, "emailBody", m.NewEmailSummary(changes)); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %v", err)
+	}
+
+	return output.Bytes(), nil
+}
@@ -0,0 +1,78 @@
+package htmlreport
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/klouddb/klouddbshield/model"
+)
+
+func TestRenderEmailBody(t *testing.T) {
+	m := NewHtmlReportHelperMap()
+	m.Get("pg1").RegisterHBAReportData([]*model.HBAScannerResult{
+		{Control: 1, Description: "Trust auth", Status: "Fail", FailRows: []string{"host all all 0.0.0.0/0 trust"}},
+		{Control: 2, Description: "Password auth", Status: "Pass"},
+	})
+	m.Get("pg2").RegisterConfigAudit([]*model.ConfigAuditResult{
+		{Name: "ssl", Status: "Pass"},
+	})
+
+	summary := m.NewEmailSummary(nil)
+	if len(summary.Targets) != 2 {
+		t.Fatalf("got %d targets, want 2", len(summary.Targets))
+	}
+	if got := summary.Targets[0]; got.Name != "pg1" || got.FailingCount != 1 || len(got.TopFailures) != 1 {
+		t.Errorf("unexpected summary of pg1: %+v", got)
+	}
+	if got := summary.Targets[1]; got.FailingCount != 0 || len(got.TopFailures) != 0 {
+		t.Errorf("unexpected summary of pg2: %+v", got)
+	}
+
+	changes := map[string]*TargetChanges{
+		"pg1": NewTargetChanges(m.Get("pg1").Findings(), nil),
+		"pg2": NewTargetChanges(m.Get("pg2").Findings(), &HistoryEntry{}),
+	}
+	body, err := m.RenderEmailBody(changes)
+	if err != nil {
+		t.Fatalf("RenderEmailBody() error = %v", err)
+	}
+	if strings.Contains(string(body), "Changes in pg2") {
+		t.Errorf("email body should not contain changes of unchanged target")
+	}
+	for _, want := range []string{"pg1", "pg2", "Changes in pg1 since first run", "Top failures of pg1", "HBA Check 1 Trust auth", "host all all 0.0.0.0/0 trust"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("email body does not contain %q", want)
+		}
+	}
+	if strings.Contains(string(body), "<script") {
+		t.Errorf("email body should not contain scripts")
+	}
+}
+
+func TestEmailSummaryTruncatesReason(t *testing.T) {
+	m := NewHtmlReportHelperMap()
+	m.Get("pg1").RegisterHBAReportData([]*model.HBAScannerResult{
+		{Control: 1, Description: "Trust auth", Status: "Fail", FailRows: []string{strings.Repeat("é", 400)}, FailRowsLineNums: []int{3}},
+	})
+
+	long := strings.Repeat("é", 400)
+	changes := map[string]*TargetChanges{"pg1": {NewFailures: []Finding{{Module: "HBA Scanner", Control: "1", Status: "Fail", Reason: long}}}}
+
+	summary := m.NewEmailSummary(changes)
+	if len(summary.Targets) != 1 || len(summary.Targets[0].TopFailures) != 1 || summary.Targets[0].Changes == nil {
+		t.Fatalf("unexpected summary: %+v", summary.Targets)
+	}
+
+	for _, reason := range []string{summary.Targets[0].TopFailures[0].Reason, summary.Targets[0].Changes.NewFailures[0].Reason} {
+		if !utf8.ValidString(reason) {
+			t.Errorf("reason %q is not valid utf-8", reason)
+		}
+		if n := utf8.RuneCountInString(reason); n != 303 {
+			t.Errorf("reason has %d runes, want 303", n)
+		}
+	}
+	if changes["pg1"].NewFailures[0].Reason != long {
+		t.Errorf("reason of changes is modified")
+	}
+}
@@ -0,0 +1,282 @@
+package htmlreport
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/klouddb/klouddbshield/model"
+	"github.com/klouddb/klouddbshield/pkg/backuphistory"
+	"github.com/klouddb/klouddbshield/pkg/piiscanner"
+)
+
+// Severity values used in Finding.
+const (
+	Severity_Critical = "Critical"
+	Severity_High     = "High"
+	Severity_Medium   = "Medium"
+	Severity_Low      = "Low"
+	Severity_Info     = "Info"
+)
+
+// Finding is a single row of the flat report, used for csv and markdown
+// output. Module is the title of the tab the finding belongs to.
+type Finding struct {
+	Module   string
+	Control  string
+	Status   string
+	Severity string
+	Reason   string
+
+	// Items is the list form of the reason when the finding is about
+	// multiple rows, like the failed lines of HBA checks. It is used for
+	// comparing the reports.
+	Items []string `json:",omitempty"`
+}
+
+// IsFailing reports whether the finding needs attention.
+func (f Finding) IsFailing() bool {
+	switch f.Status {
+	case "Pass", "Manual", "Info", "Error", "":
+		return false
+	default:
+		return true
+	}
+}
+
+// FindingSection is the list of findings for one tab of the report.
+type FindingSection struct {
+	Title    string
+	Findings []Finding
+
+	// CISSections is only set for CIS reports, which have section wise score.
+	CISScore    *SectionProgress
+	CISSections []SectionProgress
+}
+
+// FindingSections returns the findings of all the registered tabs, grouped
+// by tab in the same order as they are rendered in html report.
+func (h *HtmlReportHelper) FindingSections() []FindingSection {
+	if h == nil {
+		return nil
+	}
+
+	out := []FindingSection{}
+	for _, t := range h.reportTabs() {
+		out = appendFindingSections(out, t)
+	}
+
+	return out
+}
+
+// reportTabs returns the registered tabs in the order they are rendered.
+// "All" tab contains the other tabs, so only its tabs are returned when it is
+// created.
+func (h *HtmlReportHelper) reportTabs() []Tab {
+	tabs := make([]Tab, len(h.templateData))
+	copy(tabs, h.templateData)
+	sort.SliceStable(tabs, func(i, j int) bool {
+		return tabs[i].Prority > tabs[j].Prority
+	})
+
+	for _, t := range tabs {
+		body, ok := t.Body.([]any)
+		if !ok {
+			continue
+		}
+
+		out := []Tab{}
+		for _, v := range body {
+			if tab, ok := v.(Tab); ok {
+				out = append(out, tab)
+			}
+		}
+		return out
+	}
+
+	return tabs
+}
+
+// Findings returns the flat list of findings of all the registered tabs.
+func (h *HtmlReportHelper) Findings() []Finding {
+	out := []Finding{}
+	for _, section := range h.FindingSections() {
+		out = append(out, section.Findings...)
+	}
+	return out
+}
+
+func appendFindingSections(out []FindingSection, t Tab) []FindingSection {
+	section := FindingSection{Title: t.Title}
+
+	add := func(control, status, severity, reason string, items ...string) {
+		section.Findings = append(section.Findings, Finding{
+			Module:   t.Title,
+			Control:  control,
+			Status:   status,
+			Severity: severity,
+			Reason:   reason,
+			Items:    items,
+		})
+	}
+
+	switch body := t.Body.(type) {
+	case *PostgresReport:
+		if body == nil {
+			return out
+		}
+		summary := &ReportSummary{}
+		summary.addCISResults(t.Title, body)
+		section.CISScore = summary.CISScore
+		section.CISSections = summary.CISSections
+
+		for _, r := range body.PostgresResults {
+			severity := statusSeverity(r.Status)
+			if r.Critical && r.Status == "Fail" {
+				severity = Severity_Critical
+			}
+			add(strings.TrimSpace(r.Control+" "+r.Title), r.Status, severity, r.FailReason)
+		}
+
+	case []*model.HBAScannerResult:
+		for _, r := range body {
+			reason := r.FailRowsInString
+			if reason == "" {
+				reason = strings.Join(r.FailRows, ", ")
+			}
+			add("HBA Check "+strconv.Itoa(r.Control)+" "+r.Description, r.Status, statusSeverity(r.Status), reason, r.FailRows...)
+		}
+
+	case []*model.ConfigAuditResult:
+		for _, r := range body {
+			add(r.Name, r.Status, statusSeverity(r.Status), r.FailReason)
+		}
+
+	case *model.SSLScanResult:
+		if body == nil {
+			return out
+		}
+		for _, c := range body.Cells {
+			add(c.Title, c.Status, statusSeverity(c.Status), c.Message)
+		}
+
+	case LogparserHTMLReport:
+		for _, e := range body.Error {
+			add("Log Parser", "Error", Severity_Info, e)
+		}
+		if body.InactiveUsers != nil && body.InactiveUsers.InactiveUsersInDB != "" {
+			add("Inactive Users", "Fail", Severity_Medium, body.InactiveUsers.InactiveUsersInDB)
+		}
+		if body.UniqueIPs != nil {
+			add(UniqueIPsControl, "Info", Severity_Info, strings.Join(body.UniqueIPs.IPs, ", "))
+		}
+		if body.UnusedHBALines != nil {
+			for _, l := range body.UnusedHBALines.Lines {
+				add(fmt.Sprintf("Unused HBA Line %d", l.LineNo), "Fail", Severity_Low, l.Line)
+			}
+			for _, l := range body.UnusedHBALines.Suggestions {
+				if !l.Unused {
+					add(fmt.Sprintf("Broad HBA Line %d", l.LineNo), "Fail", Severity_Medium, l.Original, l.Lines...)
+				}
+			}
+		}
+		if body.LeakedPasswords != nil {
+			for _, l := range body.LeakedPasswords.LeakedPasswords {
+				// findings are exported and sent to notifications, so the
+				// password is masked in the query.
+				add(LeakedPasswordControl, "Fail", Severity_High, l.RedactedQuery())
+			}
+		}
+		if body.SQLInjection != nil {
+			for _, l := range body.SQLInjection.Logs {
+				add("SQL Injection", "Fail", Severity_High, l)
+			}
+		}
+		if body.PrivilegedAudit != nil {
+			for _, e := range body.PrivilegedAudit.Events {
+				if e.HighRisk {
+					add(e.Action, "Found", Severity_High, e.Statement, e.User+"@"+e.Database)
+				}
+			}
+		}
+		if body.PgAudit != nil {
+			for _, a := range body.PgAudit.PIIReads {
+				add("Read of PII table "+a.ObjectName, "Found", Severity_Medium,
+					fmt.Sprintf("%d reads by %s, PII labels %s", a.Reads, a.User, strings.Join(a.PIILabels, ", ")), a.User)
+			}
+		}
+		if body.AuthFailures != nil {
+			for _, a := range body.AuthFailures.Alerts {
+				add("Authentication "+strings.ReplaceAll(a.Pattern, "_", " "), "Fail", Severity_High,
+					fmt.Sprintf("%d failed authentications from %s between %s and %s", a.Count, a.Host,
+						a.Start.Format("2006-01-02 15:04:05"), a.End.Format("2006-01-02 15:04:05")), a.Users...)
+			}
+		}
+
+	case *piiscanner.DatabasePIIScanOutput:
+		if body == nil {
+			return out
+		}
+		tables := make([]string, 0, len(body.Data))
+		for table := range body.Data {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+
+		for _, table := range tables {
+			columns := make([]string, 0, len(body.Data[table]))
+			for column := range body.Data[table] {
+				columns = append(columns, column)
+			}
+			sort.Strings(columns)
+
+			for _, column := range columns {
+				for _, pii := range body.Data[table][column] {
+					add(table+"."+column, "Found", piiSeverity(pii.Confidence),
+						fmt.Sprintf("%s detected by %s (%s confidence)", pii.Label, pii.DetectorName, pii.Confidence))
+				}
+			}
+		}
+
+	case backuphistory.BackupHistoryOutput:
+		if len(body.MissingDates) == 0 {
+			add("Backup History", "Pass", Severity_Info,
+				fmt.Sprintf("no missing backup between %s and %s", body.StartDate, body.EndDate))
+		}
+		for _, d := range body.MissingDates {
+			add("Backup "+d, "Fail", Severity_High, "no backup found, expected backup frequency is "+body.BackupFrequency)
+		}
+
+	default:
+		return out
+	}
+
+	return append(out, section)
+}
+
+func statusSeverity(status string) string {
+	switch status {
+	case "Critical":
+		return Severity_Critical
+	case "Fail":
+		return Severity_High
+	case "Warning":
+		return Severity_Medium
+	case "Manual":
+		return Severity_Low
+	default:
+		return Severity_Info
+	}
+}
+
+func piiSeverity(confidence string) string {
+	switch confidence {
+	case "High":
+		return Severity_High
+	case "Medium":
+		return Severity_Medium
+	default:
+		return Severity_Low
+	}
+}
@@ -0,0 +1,75 @@
+package htmlreport
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/klouddb/klouddbshield/model"
+	"github.com/klouddb/klouddbshield/pkg/parselog"
+)
+
+func TestFindingsLeakedPasswordIsMasked(t *testing.T) {
+	tests := []struct {
+		name     string
+		leak     parselog.LeakedPasswordResponse
+		wantText string
+	}{
+		{
+			name:     "password literal",
+			leak:     parselog.LeakedPasswordResponse{Query: "statement: ALTER USER app PASSWORD 'S3cret!'", Password: "S3cret!"},
+			wantText: "ALTER USER app PASSWORD '***'",
+		},
+		{
+			name:     "password repeated in the statement",
+			leak:     parselog.LeakedPasswordResponse{Query: "statement: CREATE USER bob password 'hunter22' -- hunter22", Password: "hunter22"},
+			wantText: "CREATE USER bob password '***' -- ***",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHtmlReportHelper()
+			h.templateData = append(h.templateData, Tab{
+				Title: "Log Parser",
+				Body: LogparserHTMLReport{
+					LeakedPasswords: &PasswordLeakRenderData{LeakedPasswords: []parselog.LeakedPasswordResponse{tt.leak}},
+				},
+			})
+
+			findings := h.Findings()
+			if len(findings) != 1 {
+				t.Fatalf("Findings() = %+v, want 1 finding", findings)
+			}
+			if strings.Contains(findings[0].Reason, tt.leak.Password) {
+				t.Errorf("Findings() reason %q contains the password", findings[0].Reason)
+			}
+			if !strings.Contains(findings[0].Reason, tt.wantText) {
+				t.Errorf("Findings() reason = %q, want it to contain %q", findings[0].Reason, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestFindingsWithAllTab(t *testing.T) {
+	h := NewHtmlReportHelper()
+	h.RegisterConfigAudit([]*model.ConfigAuditResult{{Name: "ssl", Status: "Fail"}})
+	h.RegisterHBAReportData([]*model.HBAScannerResult{
+		{Control: 1, Description: "Trust auth", Status: "Fail", FailRows: []string{"host all all 0.0.0.0/0 trust"}},
+	})
+	h.RegisterSSLReport(&model.SSLScanResult{Cells: []*model.SSLScanResultCell{{Title: "ssl", Status: "Pass"}}})
+	h.CreateAllTab()
+
+	got := map[string]int{}
+	for _, f := range h.Findings() {
+		got[f.Module+"/"+f.Control]++
+	}
+	want := map[string]int{
+		"Config Audit/ssl":                          1,
+		"HBA Scanner Report/HBA Check 1 Trust auth": 1,
+		"SSL Report/ssl":                            1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Findings() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+package htmlreport
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RenderMarkdown generates the markdown version of the report, with one
+// section per tab and a table of findings in each section.
+func (h *HtmlReportHelper) RenderMarkdown() []byte {
+	sections := h.FindingSections()
+	if len(sections) == 0 {
+		return nil
+	}
+
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, "# KloudDB Shield Report\n\nGenerated at %s\n", time.Now().Format(time.RFC1123))
+
+	for _, section := range sections {
+		fmt.Fprintf(out, "\n## %s\n\n", section.Title)
+
+		if section.CISScore != nil {
+			out.WriteString("| Section | Score | Percentage |\n|---|---|---|\n")
+			for _, s := range section.CISSections {
+				writeMarkdownScoreRow(out, s)
+			}
+			writeMarkdownScoreRow(out, *section.CISScore)
+			out.WriteString("\n")
+		}
+
+		if len(section.Findings) == 0 {
+			out.WriteString("No findings.\n")
+			continue
+		}
+
+		out.WriteString("| Control | Status | Severity | Reason |\n|---|---|---|---|\n")
+		for _, f := range section.Findings {
+			fmt.Fprintf(out, "| %s | %s | %s | %s |\n", markdownEscape(f.Control), markdownEscape(f.Status),
+				markdownEscape(f.Severity), markdownEscape(f.Reason))
+		}
+	}
+
+	return out.Bytes()
+}
+
+// RenderCSV generates the csv version of the report with one row per
+// finding.
+func (h *HtmlReportHelper) RenderCSV() ([]byte, error) {
+	findings := h.Findings()
+	if len(findings) == 0 {
+		return nil, nil
+	}
+
+	out := &bytes.Buffer{}
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"module", "control", "status", "severity", "reason"}); err != nil {
+		return nil, err
+	}
+	for _, f := range findings {
+		if err := w.Write([]string{f.Module, f.Control, f.Status, f.Severity, f.Reason}); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+
+	return out.Bytes(), w.Error()
+}
+
+func writeMarkdownScoreRow(out *bytes.Buffer, s SectionProgress) {
+	fmt.Fprintf(out, "| %s | %d/%d | %.2f%% |\n", markdownEscape(s.SectionName), s.Score, s.MaxScore, s.Percentage)
+}
+
+var markdownReplacer = strings.NewReplacer("|", "\\|", "\r\n", "<br>", "\n", "<br>")
+
+func markdownEscape(s string) string {
+	return markdownReplacer.Replace(strings.TrimSpace(s))
+}
@@ -0,0 +1,52 @@
+package htmlreport
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/klouddb/klouddbshield/model"
+)
+
+func TestRenderCSV(t *testing.T) {
+	h := NewHtmlReportHelper()
+	h.RegisterHBAReportData([]*model.HBAScannerResult{
+		{Control: 1, Description: "Trust auth", Status: "Fail", FailRows: []string{"host all all 0.0.0.0/0 trust"}},
+	})
+	h.RegisterConfigAudit([]*model.ConfigAuditResult{
+		{Name: "ssl", Status: "Pass"},
+	})
+
+	got, err := h.RenderCSV()
+	if err != nil {
+		t.Fatalf("RenderCSV() error = %v", err)
+	}
+
+	want := "module,control,status,severity,reason\n" +
+		"HBA Scanner Report,HBA Check 1 Trust auth,Fail,High,host all all 0.0.0.0/0 trust\n" +
+		"Config Audit,ssl,Pass,Info,\n"
+	if string(got) != want {
+		t.Errorf("RenderCSV() got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	h := NewHtmlReportHelper()
+	h.RegisterSSLReport(&model.SSLScanResult{
+		Cells: []*model.SSLScanResultCell{{Title: "ssl | enabled", Status: "Fail", Message: "ssl is off"}},
+	})
+
+	got := string(h.RenderMarkdown())
+	for _, want := range []string{
+		"## SSL Report",
+		"| Control | Status | Severity | Reason |",
+		"| ssl \\| enabled | Fail | High | ssl is off |",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("RenderMarkdown() output does not contain %q\n%s", want, got)
+		}
+	}
+
+	if NewHtmlReportHelper().RenderMarkdown() != nil {
+		t.Error("RenderMarkdown() should return nil for empty report")
+	}
+}
@@ -0,0 +1,237 @@
+package htmlreport
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FleetReport is the data for the fleet index report, which summarizes all
+// the targets of a scheduled run in a single page.
+type FleetReport struct {
+	GeneratedAt string
+	Targets     []*FleetTarget
+	Heatmap     *FleetHeatmap
+}
+
+// FleetTarget is one row in the fleet overview table.
+type FleetTarget struct {
+	Name       string
+	AnchorID   string
+	ReportFile string
+
+	Summary *ReportSummary
+
+	CISScore          string
+	CISPercentage     float64
+	HBAFailures       string
+	SSLStatus         string
+	WraparoundPercent string
+	LastBackupAge     string
+
+	// sort keys for the columns which are displayed as text
+	HBAFailuresSortKey   int
+	WraparoundSortKey    float32
+	LastBackupAgeSortKey int64
+
+	FailedControls      []ControlStatus
+	FailedCriticalCount int
+}
+
+// FleetHeatmap shows which controls are failing on which target.
+type FleetHeatmap struct {
+	Targets []string
+	Rows    []FleetHeatmapRow
+}
+
+// FleetHeatmapRow is a single control in the heatmap. Cells are in the same
+// order as FleetHeatmap.Targets.
+type FleetHeatmapRow struct {
+	ID           string
+	Title        string
+	Critical     bool
+	FailingCount int
+	Cells        []string
+}
+
+// Heatmap cell values
+const (
+	FleetCell_Fail    = "fail"
+	FleetCell_Pass    = "pass"
+	FleetCell_Unknown = "na"
+)
+
+// NewFleetReport creates the fleet report from all the report helpers in
+// the map. reportFiles maps the key of the helper to the generated per target
+// report file, which is used for linking the detailed report.
+func (m HtmlReportHelperMap) NewFleetReport(reportFiles map[string]string) *FleetReport {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	now := time.Now()
+	out := &FleetReport{
+		GeneratedAt: now.Format(time.RFC1123),
+	}
+
+	for i, k := range keys {
+		summary := m[k].Summary()
+		target := &FleetTarget{
+			Name:       k,
+			AnchorID:   fmt.Sprintf("fleet_target_%d", i),
+			ReportFile: filepath.Base(reportFiles[k]),
+			Summary:    summary,
+
+			CISScore:          "-",
+			HBAFailures:       "-",
+			SSLStatus:         "-",
+			WraparoundPercent: "-",
+			LastBackupAge:     "-",
+
+			CISPercentage:        -1,
+			HBAFailuresSortKey:   -1,
+			WraparoundSortKey:    -1,
+			LastBackupAgeSortKey: -1,
+		}
+
+		if summary.CISScore != nil {
+			target.CISPercentage = summary.CISScore.Percentage
+			target.CISScore = fmt.Sprintf("%d/%d (%.1f%%)", summary.CISScore.Score, summary.CISScore.MaxScore, summary.CISScore.Percentage)
+		}
+		if summary.HBAChecks > 0 {
+			target.HBAFailuresSortKey = summary.HBAFailures
+			target.HBAFailures = fmt.Sprint(summary.HBAFailures)
+		}
+		if summary.SSLStatus != SSLStatus_NotChecked {
+			target.SSLStatus = summary.SSLStatus
+		}
+		if summary.HasWraparound {
+			target.WraparoundSortKey = summary.WraparoundPercent
+			target.WraparoundPercent = fmt.Sprintf("%.2f%%", summary.WraparoundPercent)
+		}
+		if summary.LastBackupDate != nil {
+			age := summary.LastBackupAge(now)
+			target.LastBackupAgeSortKey = int64(age.Seconds())
+			target.LastBackupAge = humanizeDuration(age)
+		}
+
+		target.FailedControls = summary.FailedControls()
+		for _, c := range target.FailedControls {
+			if c.Critical {
+				target.FailedCriticalCount++
+			}
+		}
+
+		out.Targets = append(out.Targets, target)
+	}
+
+	out.Heatmap = newFleetHeatmap(out.Targets)
+	return out
+}
+
+func newFleetHeatmap(targets []*FleetTarget) *FleetHeatmap {
+	heatmap := &FleetHeatmap{}
+
+	type rowKey struct{ id, title string }
+	rowIndex := map[rowKey]int{}
+
+	for i, t := range targets {
+		heatmap.Targets = append(heatmap.Targets, t.Name)
+
+		for _, c := range t.Summary.Controls {
+			key := rowKey{c.ID, c.Title}
+			ind, ok := rowIndex[key]
+			if !ok {
+				ind = len(heatmap.Rows)
+				rowIndex[key] = ind
+
+				cells := make([]string, len(targets))
+				for j := range cells {
+					cells[j] = FleetCell_Unknown
+				}
+				heatmap.Rows = append(heatmap.Rows, FleetHeatmapRow{
+					ID:    c.ID,
+					Title: c.Title,
+					Cells: cells,
+				})
+			}
+
+			row := &heatmap.Rows[ind]
+			row.Critical = row.Critical || c.Critical
+			switch {
+			case c.IsFailing():
+				row.Cells[i] = FleetCell_Fail
+				row.FailingCount++
+			case c.Status == "Pass" && row.Cells[i] != FleetCell_Fail:
+				row.Cells[i] = FleetCell_Pass
+			}
+		}
+	}
+
+	// heatmap only shows the controls which are failing on at least one target
+	rows := []FleetHeatmapRow{}
+	for _, r := range heatmap.Rows {
+		if r.FailingCount > 0 {
+			rows = append(rows, r)
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].FailingCount != rows[j].FailingCount {
+			return rows[i].FailingCount > rows[j].FailingCount
+		}
+		return rows[i].Critical && !rows[j].Critical
+	})
+	heatmap.Rows = rows
+
+	return heatmap
+}
+
+// RenderFleetIndex generates the fleet index report with the provided
+// filename and permission. It returns empty path if there is no target to
+// render.
+func (m HtmlReportHelperMap) RenderFleetIndex(filename string, reportFiles map[string]string, perm fs.FileMode) (string, error) {
+	if len(m) == 0 {
+		return "", nil
+	}
+
+	output := bytes.NewBuffer(nil)
+	err := tmpl.ExecuteTemplate(output, "html", []Tab{{
+		Title: "Fleet Overview",
+		Body:  m.NewFleetReport(reportFiles),
+	}})
+	if err != nil {
+		return "", fmt.Errorf("failed to execute template: %v", err)
+	}
+
+	err = os.WriteFile(filename, output.Bytes(), perm)
+	if err != nil {
+		return "", fmt.Errorf("failed to write file: %v", err)
+	}
+
+	return filepath.Abs(filename)
+}
+
+func humanizeDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+
+	parts := []string{}
+	if days > 0 {
+		parts = append(parts, fmt.Sprintf("%dd", days))
+	}
+	parts = append(parts, fmt.Sprintf("%dh", hours))
+
+	return strings.Join(parts, " ")
+}
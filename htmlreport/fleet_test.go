@@ -0,0 +1,129 @@
+package htmlreport
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/klouddb/klouddbshield/model"
+)
+
+func newTestFleetHelper(tabs ...Tab) *HtmlReportHelper {
+	h := NewHtmlReportHelper()
+	h.templateData = append(h.templateData, tabs...)
+	return h
+}
+
+func TestNewFleetReportTargets(t *testing.T) {
+	m := HtmlReportHelperMap{
+		"pg2": newTestFleetHelper(Tab{Title: "HBA Scanner Report", Body: []*model.HBAScannerResult{
+			{Control: 1, Description: "trust", Status: "Fail"},
+			{Control: 2, Description: "md5", Status: "Pass"},
+		}}),
+		"pg1": newTestFleetHelper(Tab{Title: "Postgres Security Report", Body: &PostgresReport{
+			PostgresResults: []*model.Result{
+				{Control: "1.1", Title: "a", Status: "Pass"},
+				{Control: "1.2", Title: "b", Status: "Fail", Critical: true},
+			},
+		}}),
+	}
+
+	report := m.NewFleetReport(map[string]string{"pg1": "/tmp/reports/pg1.html", "pg2": "/tmp/reports/pg2.html"})
+
+	tests := []struct {
+		name                string
+		target              *FleetTarget
+		wantName            string
+		wantReportFile      string
+		wantCISScore        string
+		wantCISPercentage   float64
+		wantHBAFailures     string
+		wantHBASortKey      int
+		wantFailedCritical  int
+		wantFailedControlID []string
+	}{
+		{
+			name:                "cis only target",
+			target:              report.Targets[0],
+			wantName:            "pg1",
+			wantReportFile:      "pg1.html",
+			wantCISScore:        "1/2 (50.0%)",
+			wantCISPercentage:   50,
+			wantHBAFailures:     "-",
+			wantHBASortKey:      -1,
+			wantFailedCritical:  1,
+			wantFailedControlID: []string{"1.2"},
+		},
+		{
+			name:                "hba only target",
+			target:              report.Targets[1],
+			wantName:            "pg2",
+			wantReportFile:      "pg2.html",
+			wantCISScore:        "-",
+			wantCISPercentage:   -1,
+			wantHBAFailures:     "1",
+			wantHBASortKey:      1,
+			wantFailedControlID: []string{"HBA Check 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target
+			if target.Name != tt.wantName || target.ReportFile != tt.wantReportFile {
+				t.Errorf("Name = %q, ReportFile = %q, want %q and %q", target.Name, target.ReportFile, tt.wantName, tt.wantReportFile)
+			}
+			if target.CISScore != tt.wantCISScore || target.CISPercentage != tt.wantCISPercentage {
+				t.Errorf("CISScore = %q (%v), want %q (%v)", target.CISScore, target.CISPercentage, tt.wantCISScore, tt.wantCISPercentage)
+			}
+			if target.HBAFailures != tt.wantHBAFailures || target.HBAFailuresSortKey != tt.wantHBASortKey {
+				t.Errorf("HBAFailures = %q (%d), want %q (%d)", target.HBAFailures, target.HBAFailuresSortKey, tt.wantHBAFailures, tt.wantHBASortKey)
+			}
+			if target.SSLStatus != "-" || target.WraparoundPercent != "-" || target.LastBackupAge != "-" {
+				t.Errorf("SSLStatus = %q, WraparoundPercent = %q, LastBackupAge = %q, want - for modules not executed",
+					target.SSLStatus, target.WraparoundPercent, target.LastBackupAge)
+			}
+			if target.FailedCriticalCount != tt.wantFailedCritical {
+				t.Errorf("FailedCriticalCount = %d, want %d", target.FailedCriticalCount, tt.wantFailedCritical)
+			}
+
+			ids := []string{}
+			for _, c := range target.FailedControls {
+				ids = append(ids, c.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantFailedControlID) {
+				t.Errorf("FailedControls = %v, want %v", ids, tt.wantFailedControlID)
+			}
+		})
+	}
+}
+
+func TestNewFleetHeatmap(t *testing.T) {
+	cisTab := func(status12, status13 string) Tab {
+		return Tab{Title: "Postgres Security Report", Body: &PostgresReport{
+			PostgresResults: []*model.Result{
+				{Control: "1.1", Title: "a", Status: "Pass"},
+				{Control: "1.2", Title: "b", Status: status12},
+				{Control: "1.3", Title: "c", Status: status13, Critical: true},
+			},
+		}}
+	}
+
+	m := HtmlReportHelperMap{
+		"pg1": newTestFleetHelper(cisTab("Fail", "Pass")),
+		"pg2": newTestFleetHelper(cisTab("Fail", "Fail")),
+		"pg3": newTestFleetHelper(),
+	}
+
+	heatmap := m.NewFleetReport(nil).Heatmap
+	if !reflect.DeepEqual(heatmap.Targets, []string{"pg1", "pg2", "pg3"}) {
+		t.Errorf("Targets = %v, want [pg1 pg2 pg3]", heatmap.Targets)
+	}
+
+	want := []FleetHeatmapRow{
+		{ID: "1.2", Title: "b", FailingCount: 2, Cells: []string{FleetCell_Fail, FleetCell_Fail, FleetCell_Unknown}},
+		{ID: "1.3", Title: "c", Critical: true, FailingCount: 1, Cells: []string{FleetCell_Pass, FleetCell_Fail, FleetCell_Unknown}},
+	}
+	if !reflect.DeepEqual(heatmap.Rows, want) {
+		t.Errorf("Rows = %+v, want %+v", heatmap.Rows, want)
+	}
+}
@@ -0,0 +1,314 @@
+package htmlreport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HistoryEntry is the snapshot of a single run which is stored in history
+// file. Pointer fields are nil when the corresponding module was not executed
+// in that run, so charts can show a gap instead of a zero.
+type HistoryEntry struct {
+	Time time.Time `json:"time"`
+
+	CISScore    *float64           `json:"cis_score,omitempty"`
+	CISSections map[string]float64 `json:"cis_sections,omitempty"`
+
+	HBAFailures       *int     `json:"hba_failures,omitempty"`
+	WraparoundPercent *float32 `json:"wraparound_percent,omitempty"`
+	UniqueIPs         *int     `json:"unique_ips,omitempty"`
+	PIIColumns        *int     `json:"pii_columns,omitempty"`
+
+	// Findings are only the failing findings of the run and the findings
+	// used for tracking new values, like unique ips. Modules are all the
+	// modules executed in the run.
+	Findings []Finding `json:"findings,omitempty"`
+	Modules  []string  `json:"modules,omitempty"`
+}
+
+// NewHistoryEntry creates the history entry for the run from report summary.
+func NewHistoryEntry(t time.Time, s *ReportSummary) HistoryEntry {
+	out := HistoryEntry{Time: t}
+	if s == nil {
+		return out
+	}
+
+	if s.CISScore != nil {
+		score := s.CISScore.Percentage
+		out.CISScore = &score
+	}
+	if len(s.CISSections) > 0 {
+		out.CISSections = map[string]float64{}
+		for _, section := range s.CISSections {
+			out.CISSections[section.SectionName] = section.Percentage
+		}
+	}
+	if s.HBAChecks > 0 {
+		hbaFailures := s.HBAFailures
+		out.HBAFailures = &hbaFailures
+	}
+	if s.HasWraparound {
+		wraparound := s.WraparoundPercent
+		out.WraparoundPercent = &wraparound
+	}
+	if s.HasUniqueIPs {
+		uniqueIPs := s.UniqueIPs
+		out.UniqueIPs = &uniqueIPs
+	}
+	if s.HasPII {
+		piiColumns := s.PIIColumns
+		out.PIIColumns = &piiColumns
+	}
+
+	return out
+}
+
+// SetFindings sets the findings of the run in the entry. Passing findings
+// are not needed for comparing the runs, so only the modules are stored for
+// them to keep the history file small.
+func (e *HistoryEntry) SetFindings(findings []Finding) {
+	e.Findings, e.Modules = nil, nil
+
+	seen := map[string]bool{}
+	for _, f := range findings {
+		if !seen[f.Module] {
+			seen[f.Module] = true
+			e.Modules = append(e.Modules, f.Module)
+		}
+		if f.IsFailing() || f.Control == UniqueIPsControl {
+			e.Findings = append(e.Findings, f)
+		}
+	}
+}
+
+// HasModule reports whether the module was executed in the run.
+func (e HistoryEntry) HasModule(module string) bool {
+	for _, m := range e.Modules {
+		if m == module {
+			return true
+		}
+	}
+	// entries stored before modules were added only have findings
+	for _, f := range e.Findings {
+		if f.Module == module {
+			return true
+		}
+	}
+	return false
+}
+
+// ComparableFindings returns the findings of the entry for DiffFindings.
+// Modules without any stored finding get an empty passing finding, so they
+// are compared instead of being reported as missing.
+func (e HistoryEntry) ComparableFindings() []Finding {
+	out := append([]Finding{}, e.Findings...)
+
+	withFindings := map[string]bool{}
+	for _, f := range e.Findings {
+		withFindings[f.Module] = true
+	}
+	for _, m := range e.Modules {
+		if !withFindings[m] {
+			out = append(out, Finding{Module: m, Status: "Pass"})
+		}
+	}
+
+	return out
+}
+
+// IsEmpty reports whether the entry has no data, which is the case when
+// none of the modules used for trends or findings were executed.
+func (e HistoryEntry) IsEmpty() bool {
+	return e.CISScore == nil && len(e.CISSections) == 0 && e.HBAFailures == nil &&
+		e.WraparoundPercent == nil && e.UniqueIPs == nil && e.PIIColumns == nil &&
+		len(e.Findings) == 0 && len(e.Modules) == 0
+}
+
+// DefaultHistoryEntries is the number of entries kept for every key.
+const DefaultHistoryEntries = 500
+
+// historyMu serializes the writes of history files, as the runs of different
+// schedules and the api server append to the same files.
+var historyMu sync.Mutex
+
+// HistoryStore stores the history entries of the runs in json lines files,
+// one file per report key.
+type HistoryStore struct {
+	dir string
+
+	// MaxEntries is the number of latest entries kept for every key, older
+	// entries are removed on append. Zero or negative keeps all entries.
+	MaxEntries int
+}
+
+func NewHistoryStore(dir string) *HistoryStore {
+	return &HistoryStore{dir: dir, MaxEntries: DefaultHistoryEntries}
+}
+
+var historyFileNameRegex = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+func (s *HistoryStore) filePath(key string) string {
+	if key == "" {
+		key = "default"
+	}
+	return filepath.Join(s.dir, "history_"+historyFileNameRegex.ReplaceAllString(key, "_")+".jsonl")
+}
+
+// Append adds the entry in the history file of the key.
+func (s *HistoryStore) Append(key string, entry HistoryEntry) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %v", err)
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entry: %v", err)
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	f, err := os.OpenFile(s.filePath(key), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %v", err)
+	}
+
+	_, err = f.Write(append(b, '\n'))
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write history file: %v", err)
+	}
+
+	if s.MaxEntries <= 0 {
+		return nil
+	}
+	return filterHistoryFile(s.filePath(key), func(lines [][]byte) [][]byte {
+		if len(lines) > s.MaxEntries {
+			return lines[len(lines)-s.MaxEntries:]
+		}
+		return lines
+	})
+}
+
+// Load returns the last limit entries of the key sorted by time. If limit is
+// zero or negative then all the entries are returned. Lines which can not be
+// parsed are skipped.
+func (s *HistoryStore) Load(key string, limit int) ([]HistoryEntry, error) {
+	f, err := os.Open(s.filePath(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %v", err)
+	}
+	defer f.Close()
+
+	out := []HistoryEntry{}
+	scanner := bufio.NewScanner(f)
+	// entries contain the failing findings of the run, so lines can be long
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		out = append(out, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %v", err)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Time.Before(out[j].Time)
+	})
+
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+
+	return out, nil
+}
+
+// Prune removes the entries older than the time and keeps only the latest
+// keep entries in all the history files. Zero time and zero or negative keep
+// disable the respective limit.
+func (s *HistoryStore) Prune(before time.Time, keep int) error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "history_*.jsonl"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := pruneHistoryFile(file, before, keep); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func pruneHistoryFile(file string, before time.Time, keep int) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	return filterHistoryFile(file, func(lines [][]byte) [][]byte {
+		out := [][]byte{}
+		for _, line := range lines {
+			var entry HistoryEntry
+			// lines which can not be parsed are kept, Load skips them anyway
+			if err := json.Unmarshal(line, &entry); err == nil && entry.Time.Before(before) {
+				continue
+			}
+			out = append(out, line)
+		}
+		if keep > 0 && len(out) > keep {
+			out = out[len(out)-keep:]
+		}
+		return out
+	})
+}
+
+// filterHistoryFile rewrites the history file with the lines returned by
+// filter, file is not written when no line is removed. Caller must hold
+// historyMu.
+func filterHistoryFile(file string, filter func(lines [][]byte) [][]byte) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read history file: %v", err)
+	}
+
+	lines := [][]byte{}
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	kept := filter(lines)
+	if len(kept) == len(lines) {
+		return nil
+	}
+
+	if len(kept) == 0 {
+		return os.Remove(file)
+	}
+
+	out := []byte{}
+	for _, line := range kept {
+		out = append(append(out, line...), '\n')
+	}
+
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, out, 0600); err != nil {
+		return fmt.Errorf("failed to write history file: %v", err)
+	}
+	return os.Rename(tmp, file)
+}
@@ -0,0 +1,162 @@
+package htmlreport
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistoryStore(t *testing.T) {
+	store := NewHistoryStore(t.TempDir())
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		entry := NewHistoryEntry(start.Add(time.Duration(i)*time.Hour), &ReportSummary{
+			CISScore:    &SectionProgress{Percentage: float64(50 + i)},
+			HBAChecks:   10,
+			HBAFailures: 5 - i,
+		})
+		if err := store.Append("postgres_localhost:5432_postgres", entry); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		limit     int
+		wantCount int
+		wantFirst float64
+	}{
+		{name: "all entries", key: "postgres_localhost:5432_postgres", limit: 0, wantCount: 5, wantFirst: 50},
+		{name: "last entries only", key: "postgres_localhost:5432_postgres", limit: 2, wantCount: 2, wantFirst: 53},
+		{name: "unknown key", key: "mysql_localhost:3306", limit: 0, wantCount: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Load(tt.key, tt.limit)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if len(got) != tt.wantCount {
+				t.Fatalf("Load() got %d entries, want %d", len(got), tt.wantCount)
+			}
+			if tt.wantCount > 0 && *got[0].CISScore != tt.wantFirst {
+				t.Errorf("Load() first CIS score = %v, want %v", *got[0].CISScore, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestNewTrendReport(t *testing.T) {
+	hbaFailures := 3
+	entries := []HistoryEntry{
+		{Time: time.Now().Add(-time.Hour), HBAFailures: &hbaFailures},
+		{Time: time.Now()},
+	}
+
+	report := NewTrendReport(entries)
+	if report == nil {
+		t.Fatal("NewTrendReport() returned nil")
+	}
+	if len(report.Charts) != 1 || report.Charts[0].ID != "trend_hba_failures" {
+		t.Fatalf("NewTrendReport() charts = %+v, want only hba failures chart", report.Charts)
+	}
+	if data := report.Charts[0].Datasets[0].Data; data[0] == nil || *data[0] != 3 || data[1] != nil {
+		t.Errorf("NewTrendReport() unexpected hba data %v", data)
+	}
+
+	if NewTrendReport(entries[:1]) != nil {
+		t.Error("NewTrendReport() should return nil for single entry")
+	}
+}
+
+func TestHistoryStoreMaxEntries(t *testing.T) {
+	store := NewHistoryStore(t.TempDir())
+	store.MaxEntries = 3
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		hbaFailures := i
+		if err := store.Append("pg1", HistoryEntry{Time: start.Add(time.Duration(i) * time.Hour), HBAFailures: &hbaFailures}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	got, err := store.Load("pg1", 0)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(got) != 3 || *got[0].HBAFailures != 2 || *got[2].HBAFailures != 4 {
+		t.Errorf("Load() = %d entries, want the latest 3", len(got))
+	}
+}
+
+func TestHistoryEntrySetFindings(t *testing.T) {
+	var entry HistoryEntry
+	entry.SetFindings([]Finding{
+		{Module: "Config Audit", Control: "ssl", Status: "Pass"},
+		{Module: "HBA Scanner Report", Control: "HBA Check 1", Status: "Fail"},
+		{Module: "HBA Scanner Report", Control: "HBA Check 2", Status: "Pass"},
+		{Module: "Log Parser", Control: UniqueIPsControl, Status: "Info", Reason: "10.0.0.1"},
+	})
+
+	if !reflect.DeepEqual(entry.Modules, []string{"Config Audit", "HBA Scanner Report", "Log Parser"}) {
+		t.Errorf("Modules = %v", entry.Modules)
+	}
+	if len(entry.Findings) != 2 || entry.Findings[0].Control != "HBA Check 1" || entry.Findings[1].Control != UniqueIPsControl {
+		t.Errorf("Findings = %+v, want the failing finding and unique ips", entry.Findings)
+	}
+	if !entry.HasModule("Config Audit") || entry.HasModule("SSL Report") {
+		t.Errorf("HasModule() does not match the modules %v", entry.Modules)
+	}
+
+	// module without failures is compared, so its failures are not new
+	diff := DiffFindings("old", entry.ComparableFindings(), "new", []Finding{
+		{Module: "Config Audit", Control: "ssl", Status: "Fail"},
+		{Module: "HBA Scanner Report", Control: "HBA Check 1", Status: "Pass"},
+	})
+	if len(diff.MissingModules) != 1 || diff.MissingModules[0] != "Log Parser" || len(diff.Modules) != 2 {
+		t.Errorf("DiffFindings() = %+v, missing %v", diff.Modules, diff.MissingModules)
+	}
+}
+
+func TestHistoryStorePrune(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		before    time.Time
+		keep      int
+		wantCount int
+	}{
+		{name: "no limit", wantCount: 5},
+		{name: "older entries", before: start.Add(2 * time.Hour), wantCount: 3},
+		{name: "entries per key", keep: 2, wantCount: 2},
+		{name: "both limits", before: start.Add(4 * time.Hour), keep: 2, wantCount: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewHistoryStore(t.TempDir())
+			for i := 0; i < 5; i++ {
+				hbaFailures := i
+				if err := store.Append("pg1", HistoryEntry{Time: start.Add(time.Duration(i) * time.Hour), HBAFailures: &hbaFailures}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := store.Prune(tt.before, tt.keep); err != nil {
+				t.Fatalf("Prune() error = %v", err)
+			}
+			got, err := store.Load("pg1", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.wantCount || (len(got) > 0 && *got[len(got)-1].HBAFailures != 4) {
+				t.Errorf("Load() after Prune() = %d entries, want the latest %d", len(got), tt.wantCount)
+			}
+		})
+	}
+}
@@ -17,12 +17,50 @@ type LogparserHTMLReport struct {
 	UnusedHBALines  *UnusedHBALinesRenderData
 	LeakedPasswords *PasswordLeakRenderData
 	SQLInjection    *SQLInjectionRenderData
+	AuthFailures    *AuthFailureRenderData
+	Connections     *ConnectionRenderData
+	PrivilegedAudit *PrivilegedAuditRenderData
+	PgAudit         *PgAuditRenderData
 }
 
 type PasswordLeakRenderData struct {
 	LeakedPasswords []parselog.LeakedPasswordResponse
 }
 
+type AuthFailureRenderData struct {
+	*parselog.AuthFailureReport
+	// Labels are the starts of the timeline buckets.
+	Labels []string
+}
+
+func NewAuthFailureRenderData(report *parselog.AuthFailureReport) *AuthFailureRenderData {
+	layout := "2006-01-02 15:04"
+	if report.TimelineBucket == "day" {
+		layout = "2006-01-02"
+	}
+
+	out := &AuthFailureRenderData{AuthFailureReport: report}
+	for _, b := range report.Timeline {
+		out.Labels = append(out.Labels, b.Start.Format(layout))
+	}
+	return out
+}
+
+type ConnectionRenderData struct {
+	Connections []parselog.ConnectionStats
+}
+
+type PrivilegedAuditRenderData struct {
+	Events []parselog.AuditEvent
+}
+
+type PgAuditRenderData struct {
+	*parselog.PgAuditReport
+	// HasPIIReport is true when pii scan output is given, otherwise reads of
+	// PII tables are not known.
+	HasPIIReport bool
+}
+
 type SQLInjectionRenderData struct {
 	Logs []string
 }
@@ -32,28 +70,36 @@ type UniqueIPRenderData struct {
 }
 
 type UnusedHBALinesRenderData struct {
-	Lines []hbarules.HBARawLine
+	Lines       []hbarules.HBARawLine
+	Usage       []hbarules.HBALineUsage
+	Suggestions []hbarules.HBASuggestion
+	// TightenedFile is the hba file with the suggestions applied.
+	TightenedFile string
 }
 
 type SimplifiedInactiveUserData struct {
 	UsersFromDB       string
 	UsersFromLog      string
 	InactiveUsersInDB string
+	Report            *parselog.InactiveUserReport
 }
 
-func GetSimplifiedInactiveUsers(userdata [][]string) *SimplifiedInactiveUserData {
-	if len(userdata) == 0 {
+func GetSimplifiedInactiveUsers(report *parselog.InactiveUserReport) *SimplifiedInactiveUserData {
+	if report == nil {
 		return nil
 	}
-	out := &SimplifiedInactiveUserData{}
+	out := &SimplifiedInactiveUserData{Report: report}
 
-	if len(userdata[0]) > 0 {
-		out.UsersFromDB = strings.Join(userdata[0], ", ")
+	out.UsersFromDB = strings.Join(report.UsersFromDB, ", ")
+	if report.DBError != "" {
+		out.UsersFromDB = report.DBError
 	}
-	out.UsersFromLog = strings.Join(userdata[1], ", ")
-	if len(userdata[2]) > 0 {
-		out.InactiveUsersInDB = strings.Join(userdata[2], ", ")
+	out.UsersFromLog = strings.Join(report.UsersFromLog, ", ")
+	inactiveUsers := []string{}
+	for _, u := range report.InactiveUsers {
+		inactiveUsers = append(inactiveUsers, u.Name)
 	}
+	out.InactiveUsersInDB = strings.Join(inactiveUsers, ", ")
 
 	return out
 }
@@ -71,7 +117,10 @@ func (h *HtmlReportHelper) RenderLogparserResponse(ctx context.Context, parsers
 		switch r := r.(type) {
 		case *logparser.UnusedHBALineHelper:
 			data.UnusedHBALines = &UnusedHBALinesRenderData{
-				Lines: r.GetResult(ctx),
+				Lines:         r.GetResult(ctx),
+				Usage:         r.GetUsage(),
+				Suggestions:   r.GetSuggestions(),
+				TightenedFile: r.GetTightenedHBAFile(),
 			}
 
 		case *logparser.UniqueIPHelper:
@@ -80,13 +129,27 @@ func (h *HtmlReportHelper) RenderLogparserResponse(ctx context.Context, parsers
 			}
 
 		case *logparser.InactiveUsersHelper:
-			userdata := r.GetResult(ctx)
-			data.InactiveUsers = GetSimplifiedInactiveUsers(userdata)
+			data.InactiveUsers = GetSimplifiedInactiveUsers(r.GetResult(ctx))
 
 		case *logparser.PasswordLeakHelper:
 			data.LeakedPasswords = &PasswordLeakRenderData{
 				LeakedPasswords: r.GetResult(ctx),
 			}
+		case *logparser.AuthFailureHelper:
+			data.AuthFailures = NewAuthFailureRenderData(r.GetResult(ctx))
+		case *logparser.ConnectionHelper:
+			data.Connections = &ConnectionRenderData{
+				Connections: r.GetResult(ctx),
+			}
+		case *logparser.PrivilegedAuditHelper:
+			data.PrivilegedAudit = &PrivilegedAuditRenderData{
+				Events: r.GetResult(ctx),
+			}
+		case *logparser.PgAuditHelper:
+			data.PgAudit = &PgAuditRenderData{
+				PgAuditReport: r.GetResult(ctx),
+				HasPIIReport:  r.HasPIIReport(),
+			}
 		case *logparser.SQLInjectionHelper:
 			data.SQLInjection = &SQLInjectionRenderData{
 				Logs: r.GetResult(ctx),
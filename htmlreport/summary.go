@@ -0,0 +1,300 @@
+package htmlreport
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/klouddb/klouddbshield/model"
+	"github.com/klouddb/klouddbshield/pkg/backuphistory"
+	"github.com/klouddb/klouddbshield/pkg/piiscanner"
+	"github.com/klouddb/klouddbshield/postgres/calctransactions"
+)
+
+// SSL status values used in ReportSummary, ordered from best to worst.
+const (
+	SSLStatus_NotChecked = ""
+	SSLStatus_Pass       = "Pass"
+	SSLStatus_Warning    = "Warning"
+	SSLStatus_Fail       = "Fail"
+	SSLStatus_Critical   = "Critical"
+)
+
+var sslStatusRank = map[string]int{
+	SSLStatus_NotChecked: 0,
+	SSLStatus_Pass:       1,
+	SSLStatus_Warning:    2,
+	SSLStatus_Fail:       3,
+	SSLStatus_Critical:   4,
+}
+
+// ControlStatus is the status of a single control (CIS, HBA or SSL check)
+// as it was registered in the report.
+type ControlStatus struct {
+	Module   string
+	ID       string
+	Title    string
+	Status   string
+	Critical bool
+}
+
+// IsFailing reports whether the control is failing. Manual checks and
+// warnings are not considered as failure.
+func (c ControlStatus) IsFailing() bool {
+	return c.Status == "Fail" || c.Status == "Critical"
+}
+
+// ReportSummary contains the key numbers of a report, extracted from the
+// tabs registered in HtmlReportHelper. Fields are left at zero value (or nil)
+// when the corresponding module was not executed.
+type ReportSummary struct {
+	CISScore    *SectionProgress
+	CISSections []SectionProgress
+
+	HBAChecks   int
+	HBAFailures int
+
+	SSLStatus     string
+	SSLCertExpiry *time.Time
+
+	HasWraparound     bool
+	WraparoundPercent float32
+
+	LastBackupDate *time.Time
+
+	HasUniqueIPs bool
+	UniqueIPs    int
+
+	HasPII     bool
+	PIIColumns int
+
+	Controls []ControlStatus
+}
+
+// LastBackupAge returns the time since the last backup found by backup audit
+// tool, or zero if backup audit tool was not executed.
+func (s *ReportSummary) LastBackupAge(now time.Time) time.Duration {
+	if s == nil || s.LastBackupDate == nil {
+		return 0
+	}
+
+	return now.Sub(*s.LastBackupDate)
+}
+
+// FailedControls returns the controls which are failing.
+func (s *ReportSummary) FailedControls() []ControlStatus {
+	if s == nil {
+		return nil
+	}
+
+	out := []ControlStatus{}
+	for _, c := range s.Controls {
+		if c.IsFailing() {
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+// Summary walks over all the registered tabs and collects the key numbers
+// for the report.
+func (h *HtmlReportHelper) Summary() *ReportSummary {
+	out := &ReportSummary{}
+	if h == nil {
+		return out
+	}
+
+	for _, t := range h.templateData {
+		out.addTab(t)
+	}
+
+	return out
+}
+
+func (s *ReportSummary) addTab(t Tab) {
+	switch body := t.Body.(type) {
+	case []any:
+		// "All" tab contains all other tabs as body
+		for _, v := range body {
+			if tab, ok := v.(Tab); ok {
+				s.addTab(tab)
+			}
+		}
+
+	case *PostgresReport:
+		s.addCISResults(t.Title, body)
+
+	case []*model.HBAScannerResult:
+		s.HBAChecks = len(body)
+		s.HBAFailures = 0
+		for _, r := range body {
+			if r.Status == "Fail" {
+				s.HBAFailures++
+			}
+			s.addControl(ControlStatus{
+				Module: t.Title,
+				ID:     "HBA Check " + strconv.Itoa(r.Control),
+				Title:  r.Description,
+				Status: r.Status,
+			})
+		}
+
+	case *model.SSLScanResult:
+		if body == nil {
+			return
+		}
+		s.SSLStatus = SSLStatus_Pass
+		s.SSLCertExpiry = body.CertExpiry
+		for _, c := range body.Cells {
+			if sslStatusRank[c.Status] > sslStatusRank[s.SSLStatus] {
+				s.SSLStatus = c.Status
+			}
+			s.addControl(ControlStatus{
+				Module:   t.Title,
+				ID:       "SSL",
+				Title:    c.Title,
+				Status:   c.Status,
+				Critical: c.Status == SSLStatus_Critical,
+			})
+		}
+
+	case calctransactions.ReportData:
+		s.HasWraparound = true
+		s.WraparoundPercent = body.ClusterStats.PercentTowardsWraparound
+
+	case backuphistory.BackupHistoryOutput:
+		if t, err := time.ParseInLocation(time.DateOnly, body.EndDate, time.Local); err == nil {
+			s.LastBackupDate = &t
+		}
+
+	case LogparserHTMLReport:
+		if body.UniqueIPs != nil {
+			s.HasUniqueIPs = true
+			s.UniqueIPs = len(body.UniqueIPs.IPs)
+		}
+
+	case *piiscanner.DatabasePIIScanOutput:
+		if body == nil {
+			return
+		}
+		s.HasPII = true
+		s.PIIColumns = 0
+		for _, table := range body.Data {
+			for _, labels := range table {
+				if len(labels) > 0 {
+					s.PIIColumns++
+				}
+			}
+		}
+	}
+}
+
+func (s *ReportSummary) addCISResults(module string, report *PostgresReport) {
+	if report == nil {
+		return
+	}
+
+	for _, r := range report.PostgresResults {
+		s.addControl(ControlStatus{
+			Module:   module,
+			ID:       r.Control,
+			Title:    r.Title,
+			Status:   r.Status,
+			Critical: r.Critical,
+		})
+	}
+
+	if report.Summary != nil {
+		overall := report.Summary.Overall
+		s.CISScore = &overall
+		s.CISSections = report.Summary.Data
+		return
+	}
+
+	// summary is not available when custom template is used, so we are
+	// calculating overall score from the results.
+	overall := SectionProgress{SectionName: "Overall Score"}
+	for _, r := range report.PostgresResults {
+		switch r.Status {
+		case "Pass":
+			overall.Score++
+			overall.MaxScore++
+		case "Fail":
+			overall.MaxScore++
+		}
+	}
+	if overall.MaxScore > 0 {
+		overall.Percentage = float64(overall.Score) / float64(overall.MaxScore) * 100
+	}
+	s.CISScore = &overall
+}
+
+// Merge returns a new summary with the values of newer summary, and the
+// values of s for the modules which are not present in newer summary. It is
+// used when different modules of the same target are executed separately.
+func (s *ReportSummary) Merge(newer *ReportSummary) *ReportSummary {
+	if s == nil {
+		return newer
+	}
+	if newer == nil {
+		return s
+	}
+
+	out := *newer
+	if out.CISScore == nil {
+		out.CISScore = s.CISScore
+		out.CISSections = s.CISSections
+	}
+	if out.HBAChecks == 0 {
+		out.HBAChecks = s.HBAChecks
+		out.HBAFailures = s.HBAFailures
+	}
+	if out.SSLStatus == SSLStatus_NotChecked {
+		out.SSLStatus = s.SSLStatus
+		out.SSLCertExpiry = s.SSLCertExpiry
+	}
+	if !out.HasWraparound {
+		out.HasWraparound = s.HasWraparound
+		out.WraparoundPercent = s.WraparoundPercent
+	}
+	if out.LastBackupDate == nil {
+		out.LastBackupDate = s.LastBackupDate
+	}
+	if !out.HasUniqueIPs {
+		out.HasUniqueIPs = s.HasUniqueIPs
+		out.UniqueIPs = s.UniqueIPs
+	}
+	if !out.HasPII {
+		out.HasPII = s.HasPII
+		out.PIIColumns = s.PIIColumns
+	}
+
+	// controls of the modules executed in newer run replace the old ones
+	modules := map[string]bool{}
+	for _, c := range newer.Controls {
+		modules[c.Module] = true
+	}
+	out.Controls = append([]ControlStatus{}, newer.Controls...)
+	for _, c := range s.Controls {
+		if !modules[c.Module] {
+			out.Controls = append(out.Controls, c)
+		}
+	}
+
+	return &out
+}
+
+// addControl adds the control to summary. Tabs are present in "All" tab too,
+// so the control which is already added is replaced.
+func (s *ReportSummary) addControl(c ControlStatus) {
+	if c.ID == "" && c.Title == "" {
+		return
+	}
+	for i, old := range s.Controls {
+		if old.Module == c.Module && old.ID == c.ID && old.Title == c.Title {
+			s.Controls[i] = c
+			return
+		}
+	}
+	s.Controls = append(s.Controls, c)
+}
@@ -0,0 +1,213 @@
+package htmlreport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/klouddb/klouddbshield/model"
+	"github.com/klouddb/klouddbshield/pkg/piiscanner"
+	"github.com/klouddb/klouddbshield/postgres/calctransactions"
+)
+
+func TestSummaryCISScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		report  *PostgresReport
+		want    *SectionProgress
+		wantLen int
+	}{
+		{
+			name:   "nil report",
+			report: nil,
+		},
+		{
+			name: "score from summary",
+			report: &PostgresReport{
+				PostgresResults: []*model.Result{{Control: "1.1", Title: "a", Status: "Pass"}},
+				Summary:         &SectionSummary{Overall: SectionProgress{SectionName: "Overall Score", Score: 7, MaxScore: 10, Percentage: 70}},
+			},
+			want:    &SectionProgress{SectionName: "Overall Score", Score: 7, MaxScore: 10, Percentage: 70},
+			wantLen: 1,
+		},
+		{
+			name: "score calculated from results, manual checks are not counted",
+			report: &PostgresReport{
+				PostgresResults: []*model.Result{
+					{Control: "1.1", Title: "a", Status: "Pass"},
+					{Control: "1.2", Title: "b", Status: "Pass"},
+					{Control: "1.3", Title: "c", Status: "Pass"},
+					{Control: "1.4", Title: "d", Status: "Fail"},
+					{Control: "1.5", Title: "e", Status: "Manual"},
+				},
+			},
+			want:    &SectionProgress{SectionName: "Overall Score", Score: 3, MaxScore: 4, Percentage: 75},
+			wantLen: 5,
+		},
+		{
+			name: "only manual checks",
+			report: &PostgresReport{
+				PostgresResults: []*model.Result{{Control: "1.1", Title: "a", Status: "Manual"}},
+			},
+			want:    &SectionProgress{SectionName: "Overall Score"},
+			wantLen: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHtmlReportHelper()
+			h.templateData = append(h.templateData, Tab{Title: "Postgres Security Report", Body: tt.report})
+
+			s := h.Summary()
+			if (s.CISScore == nil) != (tt.want == nil) || (s.CISScore != nil && *s.CISScore != *tt.want) {
+				t.Errorf("CISScore = %+v, want %+v", s.CISScore, tt.want)
+			}
+			if len(s.Controls) != tt.wantLen {
+				t.Errorf("got %d controls, want %d", len(s.Controls), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestSummaryTabs(t *testing.T) {
+	expiry := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	hbaTab := Tab{Title: "HBA Scanner Report", Body: []*model.HBAScannerResult{
+		{Control: 1, Description: "trust", Status: "Fail"},
+		{Control: 2, Description: "md5", Status: "Pass"},
+		{Control: 3, Description: "all", Status: "Fail"},
+	}}
+	sslTab := Tab{Title: "SSL Report", Body: &model.SSLScanResult{
+		CertExpiry: &expiry,
+		Cells: []*model.SSLScanResultCell{
+			{Title: "ssl", Status: SSLStatus_Pass},
+			{Title: "cert", Status: SSLStatus_Critical},
+			{Title: "ciphers", Status: SSLStatus_Warning},
+		},
+	}}
+	piiTab := Tab{Title: "PII Report", Body: &piiscanner.DatabasePIIScanOutput{
+		Data: map[string]piiscanner.TableDetailOutput{
+			"users":  {"email": {{Confidence: "High"}}, "id": nil},
+			"orders": {"phone": {{Confidence: "High"}}, "address": {{Confidence: "Low"}}},
+		},
+	}}
+
+	tests := []struct {
+		name  string
+		tabs  []Tab
+		check func(t *testing.T, s *ReportSummary)
+	}{
+		{
+			name: "hba failures",
+			tabs: []Tab{hbaTab},
+			check: func(t *testing.T, s *ReportSummary) {
+				if s.HBAChecks != 3 || s.HBAFailures != 2 {
+					t.Errorf("HBAChecks = %d, HBAFailures = %d, want 3 and 2", s.HBAChecks, s.HBAFailures)
+				}
+				if got := len(s.FailedControls()); got != 2 {
+					t.Errorf("got %d failed controls, want 2", got)
+				}
+			},
+		},
+		{
+			name: "ssl status is the worst cell",
+			tabs: []Tab{sslTab},
+			check: func(t *testing.T, s *ReportSummary) {
+				if s.SSLStatus != SSLStatus_Critical || s.SSLCertExpiry != &expiry {
+					t.Errorf("SSLStatus = %q, SSLCertExpiry = %v, want Critical and %v", s.SSLStatus, s.SSLCertExpiry, expiry)
+				}
+				failed := s.FailedControls()
+				if len(failed) != 1 || !failed[0].Critical {
+					t.Errorf("FailedControls() = %+v, want the critical cert control", failed)
+				}
+			},
+		},
+		{
+			name: "nil ssl result is not checked",
+			tabs: []Tab{{Title: "SSL Report", Body: (*model.SSLScanResult)(nil)}},
+			check: func(t *testing.T, s *ReportSummary) {
+				if s.SSLStatus != SSLStatus_NotChecked {
+					t.Errorf("SSLStatus = %q, want not checked", s.SSLStatus)
+				}
+			},
+		},
+		{
+			name: "pii columns with labels",
+			tabs: []Tab{piiTab},
+			check: func(t *testing.T, s *ReportSummary) {
+				if !s.HasPII || s.PIIColumns != 3 {
+					t.Errorf("HasPII = %v, PIIColumns = %d, want true and 3", s.HasPII, s.PIIColumns)
+				}
+			},
+		},
+		{
+			name: "wraparound, backup and unique ips",
+			tabs: []Tab{
+				{Title: "Transaction Wraparound", Body: calctransactions.ReportData{ClusterStats: calctransactions.ClusterStats{PercentTowardsWraparound: 12.5}}},
+				{Title: "Log Parser", Body: LogparserHTMLReport{UniqueIPs: &UniqueIPRenderData{IPs: []string{"10.0.0.1", "10.0.0.2"}}}},
+			},
+			check: func(t *testing.T, s *ReportSummary) {
+				if !s.HasWraparound || s.WraparoundPercent != 12.5 {
+					t.Errorf("HasWraparound = %v, WraparoundPercent = %v, want true and 12.5", s.HasWraparound, s.WraparoundPercent)
+				}
+				if !s.HasUniqueIPs || s.UniqueIPs != 2 {
+					t.Errorf("HasUniqueIPs = %v, UniqueIPs = %d, want true and 2", s.HasUniqueIPs, s.UniqueIPs)
+				}
+				if s.LastBackupDate != nil {
+					t.Errorf("LastBackupDate = %v, want nil", s.LastBackupDate)
+				}
+			},
+		},
+		{
+			name: "all tab is not counted twice",
+			tabs: []Tab{hbaTab, sslTab, {Title: "All", Body: []any{hbaTab, sslTab}}},
+			check: func(t *testing.T, s *ReportSummary) {
+				if s.HBAChecks != 3 || s.HBAFailures != 2 {
+					t.Errorf("HBAChecks = %d, HBAFailures = %d, want 3 and 2", s.HBAChecks, s.HBAFailures)
+				}
+				if len(s.Controls) != 6 {
+					t.Errorf("got %d controls, want 6", len(s.Controls))
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHtmlReportHelper()
+			h.templateData = append(h.templateData, tt.tabs...)
+			tt.check(t, h.Summary())
+		})
+	}
+}
+
+func TestReportSummaryMerge(t *testing.T) {
+	older := &ReportSummary{
+		CISScore:    &SectionProgress{Percentage: 70},
+		HBAChecks:   3,
+		HBAFailures: 1,
+		Controls: []ControlStatus{
+			{Module: "Postgres Security Report", ID: "1.1", Status: "Fail"},
+			{Module: "HBA Scanner Report", ID: "HBA Check 1", Status: "Fail"},
+		},
+	}
+	newer := &ReportSummary{
+		HBAChecks:   3,
+		HBAFailures: 0,
+		SSLStatus:   SSLStatus_Pass,
+		Controls: []ControlStatus{
+			{Module: "HBA Scanner Report", ID: "HBA Check 1", Status: "Pass"},
+		},
+	}
+
+	got := older.Merge(newer)
+	if got.CISScore != older.CISScore {
+		t.Errorf("CISScore = %+v, want the older score", got.CISScore)
+	}
+	if got.HBAFailures != 0 || got.SSLStatus != SSLStatus_Pass {
+		t.Errorf("HBAFailures = %d, SSLStatus = %q, want the newer values", got.HBAFailures, got.SSLStatus)
+	}
+	if failed := got.FailedControls(); len(failed) != 1 || failed[0].ID != "1.1" {
+		t.Errorf("FailedControls() = %+v, want only 1.1 from older run", failed)
+	}
+}
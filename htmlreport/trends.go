@@ -0,0 +1,137 @@
+package htmlreport
+
+import (
+	"sort"
+)
+
+// TrendReport is the data for trends tab. Each chart has one value per label
+// in every dataset, nil values are shown as gap in the chart.
+type TrendReport struct {
+	Labels []string
+	Charts []TrendChart
+}
+
+type TrendChart struct {
+	ID       string
+	Title    string
+	YLabel   string
+	Datasets []TrendDataset
+}
+
+type TrendDataset struct {
+	Label string
+	Data  []*float64
+}
+
+// RegisterTrends adds the trends tab to the report. At least two entries are
+// required to draw a trend, otherwise the tab is not added.
+func (h *HtmlReportHelper) RegisterTrends(entries []HistoryEntry) {
+	report := NewTrendReport(entries)
+	if report == nil {
+		return
+	}
+
+	h.AddTab("Trends", report)
+}
+
+// NewTrendReport creates the trend charts from history entries. It returns
+// nil if there are not enough entries to draw a trend.
+func NewTrendReport(entries []HistoryEntry) *TrendReport {
+	if len(entries) < 2 {
+		return nil
+	}
+
+	out := &TrendReport{}
+	for _, e := range entries {
+		out.Labels = append(out.Labels, e.Time.Local().Format("2006-01-02 15:04"))
+	}
+
+	out.addChart("trend_cis_overall", "Overall CIS Score", "Score (%)", []TrendDataset{
+		newTrendDataset("Overall", entries, func(e HistoryEntry) *float64 { return e.CISScore }),
+	})
+
+	sections := map[string]struct{}{}
+	for _, e := range entries {
+		for name := range e.CISSections {
+			sections[name] = struct{}{}
+		}
+	}
+	sectionNames := make([]string, 0, len(sections))
+	for name := range sections {
+		sectionNames = append(sectionNames, name)
+	}
+	sort.Strings(sectionNames)
+
+	sectionDatasets := []TrendDataset{}
+	for _, name := range sectionNames {
+		sectionDatasets = append(sectionDatasets, newTrendDataset(name, entries, func(e HistoryEntry) *float64 {
+			v, ok := e.CISSections[name]
+			if !ok {
+				return nil
+			}
+			return &v
+		}))
+	}
+	out.addChart("trend_cis_sections", "CIS Score By Section", "Score (%)", sectionDatasets)
+
+	out.addChart("trend_hba_failures", "HBA Failures", "Failed Checks", []TrendDataset{
+		newTrendDataset("HBA Failures", entries, func(e HistoryEntry) *float64 { return intToFloat(e.HBAFailures) }),
+	})
+
+	out.addChart("trend_wraparound", "Transaction Wraparound", "Percent Towards Wraparound", []TrendDataset{
+		newTrendDataset("Wraparound", entries, func(e HistoryEntry) *float64 {
+			if e.WraparoundPercent == nil {
+				return nil
+			}
+			v := float64(*e.WraparoundPercent)
+			return &v
+		}),
+	})
+
+	out.addChart("trend_unique_ips", "Unique IPs", "IP Count", []TrendDataset{
+		newTrendDataset("Unique IPs", entries, func(e HistoryEntry) *float64 { return intToFloat(e.UniqueIPs) }),
+	})
+
+	out.addChart("trend_pii_columns", "PII Columns", "Column Count", []TrendDataset{
+		newTrendDataset("PII Columns", entries, func(e HistoryEntry) *float64 { return intToFloat(e.PIIColumns) }),
+	})
+
+	if len(out.Charts) == 0 {
+		return nil
+	}
+
+	return out
+}
+
+// addChart adds the chart only if any of the dataset has a value.
+func (r *TrendReport) addChart(id, title, yLabel string, datasets []TrendDataset) {
+	for _, d := range datasets {
+		for _, v := range d.Data {
+			if v != nil {
+				r.Charts = append(r.Charts, TrendChart{
+					ID:       id,
+					Title:    title,
+					YLabel:   yLabel,
+					Datasets: datasets,
+				})
+				return
+			}
+		}
+	}
+}
+
+func newTrendDataset(label string, entries []HistoryEntry, value func(HistoryEntry) *float64) TrendDataset {
+	out := TrendDataset{Label: label, Data: make([]*float64, 0, len(entries))}
+	for _, e := range entries {
+		out.Data = append(out.Data, value(e))
+	}
+	return out
+}
+
+func intToFloat(v *int) *float64 {
+	if v == nil {
+		return nil
+	}
+	f := float64(*v)
+	return &f
+}
@@ -2,10 +2,13 @@ package model
 
 type HBAFIleRules struct {
 	LineNumber int    `json:"line_number"`
+	Type       string `json:"type"`
 	Database   string `json:"database"`
 	UserName   string `json:"user_name"`
 	Address    string `json:"address"`
 	NetMask    string `json:"netmask"`
+	// Method is the auth method with its options, like ident map=omicron.
+	Method string `json:"method"`
 
 	Raw string `json:"raw"`
 }
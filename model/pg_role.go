@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// PGRole is a role from pg_roles.
+type PGRole struct {
+	Name     string
+	CanLogin bool
+	// System is true for the bootstrap superuser, predefined roles and the
+	// admin roles of managed services, which are not used by the clients.
+	System bool
+	// ValidUntil is the expiry of the password, zero when it does not expire.
+	ValidUntil time.Time
+}
@@ -4,4 +4,7 @@ package model
 type PgSettings struct {
 	LogLinePrefix  string `json:"log_line_prefix"`
 	LogConnections bool   `json:"log_connections"`
+	// SyslogIdent is the program name of postgres in syslog, postgres when
+	// empty.
+	SyslogIdent string `json:"syslog_ident"`
 }
@@ -3,6 +3,7 @@ package model
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 func NewContextWithVersion(ctx context.Context, version string) context.Context {
@@ -95,6 +96,10 @@ type SSLScanResult struct {
 
 	HBALines  []string          `json:"hba_lines"`
 	SSLParams map[string]string `json:"ssl_params"`
+
+	// CertExpiry is the expiry time of server certificate, nil if
+	// certificate could not be checked.
+	CertExpiry *time.Time `json:"cert_expiry,omitempty"`
 }
 
 type SSLScanResultCell struct {
@@ -112,6 +117,9 @@ type HBAScannerResult struct {
 	FailRowsLineNums []int    `json:"-"`
 	FailRows         []string `json:"FailRows,omitempty"`
 	FailRowsInString string   `json:"-"`
+	// FailRowsConflictLineNums are the earlier lines conflicting with the
+	// failed rows, set by the rule order checks.
+	FailRowsConflictLineNums []int `json:"-"`
 }
 
 type DataTable struct {
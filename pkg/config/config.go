@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -43,6 +44,14 @@ type Config struct {
 
 	Email *AuthConfig `toml:"email"`
 
+	Prometheus *PrometheusConfig `toml:"prometheus"`
+
+	Notifications []Notification `toml:"notifications"`
+
+	SIEM []SIEM `toml:"siem"`
+
+	Daemon *DaemonConfig `toml:"daemon"`
+
 	PiiScannerConfig *piiscanner.Config `toml:"-"`
 
 	OutputType           string `toml:"outputType"`
@@ -84,11 +93,53 @@ func NewPiiInteractiveMode(pgConfig *postgresdb.Postgres, printAll, spacyOnly, s
 		readIncludeTable, readDatabase, readSchema, printAll, spacyOnly, summary)
 }
 
+// PrometheusConfig is the configuration for exporting the results as
+// prometheus metrics.
+type PrometheusConfig struct {
+	// Textfile is the path of the file for node_exporter textfile collector.
+	Textfile string `toml:"textfile"`
+	// ListenAddress is the address for serving /metrics endpoint. It is only
+	// used in cron mode.
+	ListenAddress string `toml:"listenAddress"`
+}
+
+// DaemonConfig is the configuration of the cron daemon started with
+// --setup-cron. Old reports, history and ledger entries are only removed
+// when this section is configured.
+type DaemonConfig struct {
+	// ListenAddress is the address for serving /healthz and /runs.
+	ListenAddress string `toml:"listenAddress"`
+	// Token is the bearer token required for /runs, default is
+	// KSHIELD_API_TOKEN environment variable. /runs is only served on
+	// loopback address without token.
+	Token string `toml:"token"`
+
+	// RetentionDays removes the reports, history and ledger entries older
+	// than the days. Zero keeps them forever.
+	RetentionDays int `toml:"retentionDays"`
+	// ReportsPerTarget is the number of reports kept for each target,
+	// default is 30. Negative value keeps all the reports.
+	ReportsPerTarget int `toml:"reportsPerTarget"`
+	// HistoryPerTarget is the number of history entries kept for each
+	// target, default is 500. Negative value keeps all the entries.
+	HistoryPerTarget int `toml:"historyPerTarget"`
+}
+
 type AuthConfig struct {
 	Host     string `toml:"host"`
 	Port     int    `toml:"port"`
 	Username string `toml:"username"`
 	Password string `toml:"password"`
+
+	// From defaults to Username.
+	From string   `toml:"from"`
+	To   []string `toml:"to"`
+	Cc   []string `toml:"cc"`
+
+	// TLS is either starttls or tls (implicit TLS). Default is tls for port
+	// 465 and starttls otherwise.
+	TLS                string `toml:"tls"`
+	InsecureSkipVerify bool   `toml:"insecureSkipVerify"`
 }
 
 type LogParser struct {
@@ -100,13 +151,147 @@ type LogParser struct {
 	End   time.Time
 
 	LogFiles []string
+	// LogFormat is stderr, csvlog, jsonlog or syslog. Log line prefix is
+	// only used for stderr and syslog, other formats have all the fields.
+	LogFormat string
 
 	// IpFilePath string
 
 	HbaConfFile string
+
+	// Follow is set when the current log file is tailed instead of
+	// processing the log files once.
+	Follow *LogFollow
+
+	// CheckpointFile persists the processed offset of the log files, so only
+	// the new lines are processed in every run. Empty to process all lines.
+	CheckpointFile string
+
+	// PIIReportFile is the json output of pii scanner, used by pgaudit command
+	// for the reads of the tables with PII data. optional.
+	PIIReportFile string
+
+	// InactiveDays is the number of days without log lines after which a
+	// user is inactive. Users not seen in the logs are always inactive.
+	InactiveDays int
+}
+
+// DefaultLogFilename is the default log_filename of postgres.
+const DefaultLogFilename = "postgresql-%Y-%m-%d_%H%M%S.log"
+
+// LogFollow has the files tailed in follow mode.
+type LogFollow struct {
+	// Pattern is the glob of the log files. The newest matching file is the
+	// current log file, a newer file means the log is rotated.
+	Pattern string
+}
+
+// NewFollowLogParser creates the log parser config for follow mode. logPath
+// is the log_directory of postgres, where the files are matched with the
+// log_filename pattern, or the glob of the log files.
+func NewFollowLogParser(logParser, prefix, logFormat, logPath, logFilename, hbaConfigFile string) (*LogParser, error) {
+	logPath = strings.TrimSpace(logPath)
+	logFormat = strings.TrimSpace(logFormat)
+	if logFilename = strings.TrimSpace(logFilename); logFilename == "" {
+		logFilename = DefaultLogFilename
+	}
+
+	follow := &LogFollow{Pattern: logPath}
+	if info, err := os.Stat(logPath); err == nil && info.IsDir() {
+		follow.Pattern = filepath.Join(logPath, logFilenameGlob(logFilename, logFormat))
+	}
+
+	current, err := follow.CurrentFile()
+	if err != nil {
+		return nil, err
+	}
+
+	// begin and end time are not used as only the new lines are processed
+	out, err := NewLogParser(logParser, "", "", prefix, logFormat, current, hbaConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	out.Follow = follow
+	return out, nil
+}
+
+// CurrentFile returns the log file postgres is writing to, which is the
+// newest file matching the pattern.
+func (f *LogFollow) CurrentFile() (string, error) {
+	files, err := filepath.Glob(f.Pattern)
+	if err != nil {
+		return "", fmt.Errorf("error while validating log file name %s (%v)", f.Pattern, err)
+	}
+
+	current := ""
+	var currentModTime time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		// names of log_filename pattern are sorted by time, so name is used
+		// when files are modified at the same time.
+		if current == "" || info.ModTime().After(currentModTime) ||
+			(info.ModTime().Equal(currentModTime) && file > current) {
+			current = file
+			currentModTime = info.ModTime()
+		}
+	}
+
+	if current == "" {
+		return "", fmt.Errorf("no file found for given pattern %s", f.Pattern)
+	}
+
+	return current, nil
+}
+
+// logFilenameGlob converts the strftime escapes of log_filename to glob.
+// Like postgres, .log suffix is replaced with .csv or .json for csvlog and
+// jsonlog.
+func logFilenameGlob(logFilename, logFormat string) string {
+	var b strings.Builder
+	wildcard := false
+	for i := 0; i < len(logFilename); i++ {
+		c := logFilename[i]
+		switch {
+		case c == '%' && i+1 < len(logFilename) && logFilename[i+1] == '%':
+			b.WriteByte('%')
+			i++
+		case c == '%' && i+1 < len(logFilename):
+			// consecutive escapes like %H%M%S need a single wildcard
+			if !wildcard {
+				b.WriteByte('*')
+			}
+			wildcard = true
+			i++
+			continue
+		case c == '*' || c == '?' || c == '[' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+		wildcard = false
+	}
+
+	glob := b.String()
+	ext := ""
+	switch logFormat {
+	case cons.LogFormat_CSV:
+		ext = ".csv"
+	case cons.LogFormat_JSON:
+		ext = ".json"
+	default:
+		return glob
+	}
+
+	return strings.TrimSuffix(glob, ".log") + ext
 }
 
-func NewLogParser(logParser string, beginTime, endTime, prefix, logfile, hbaConfigFile string) (*LogParser, error) {
+func NewLogParser(logParser string, beginTime, endTime, prefix, logFormat, logfile, hbaConfigFile string) (*LogParser, error) {
 	commands := []string{logParser}
 	if logParser == "all" {
 		commands = []string{}
@@ -140,10 +325,6 @@ func NewLogParser(logParser string, beginTime, endTime, prefix, logfile, hbaConf
 		}
 	}
 
-	if prefix == "" {
-		return nil, fmt.Errorf("log line prefix is required")
-	}
-
 	var begin, end time.Time
 	var err error
 	if beginTime != "" {
@@ -170,6 +351,15 @@ func NewLogParser(logParser string, beginTime, endTime, prefix, logfile, hbaConf
 		return nil, fmt.Errorf("no file found for given pattern %s", logfile)
 	}
 
+	logFormat, err = getLogFormat(strings.TrimSpace(logFormat), files)
+	if err != nil {
+		return nil, err
+	}
+
+	if prefix == "" && (logFormat == cons.LogFormat_Stderr || logFormat == cons.LogFormat_Syslog) {
+		return nil, fmt.Errorf("log line prefix is required")
+	}
+
 	// if utils.NewSetFromSlice(commands).IsAvailable(cons.LogParserCMD_MismatchIPs) {
 	// 	if ipfile == "" {
 	// 		return nil, fmt.Errorf("ip file path is required for mismatch_ips command")
@@ -189,12 +379,77 @@ func NewLogParser(logParser string, beginTime, endTime, prefix, logfile, hbaConf
 		Begin: begin,
 		End:   end,
 
-		LogFiles: files,
+		LogFiles:  files,
+		LogFormat: logFormat,
 		// IpFilePath:  ipfile,
 		HbaConfFile: hbaConfigFile,
 	}, nil
 }
 
+// getLogFormat validates the log format. If it is not given then it is
+// detected from the extension of the log files, .csv for csvlog and .json
+// for jsonlog, as postgres names the files. Extension of compression like
+// .csv.gz is ignored.
+func getLogFormat(logFormat string, files []string) (string, error) {
+	switch logFormat {
+	case cons.LogFormat_Stderr, cons.LogFormat_CSV, cons.LogFormat_JSON, cons.LogFormat_Syslog:
+		return logFormat, nil
+	case "":
+	default:
+		return "", fmt.Errorf("invalid log format %s, valid formats are %s, %s, %s and %s", logFormat,
+			cons.LogFormat_Stderr, cons.LogFormat_CSV, cons.LogFormat_JSON, cons.LogFormat_Syslog)
+	}
+
+	for i, file := range files {
+		format := cons.LogFormat_Stderr
+		switch logFileExt(file) {
+		case ".csv":
+			format = cons.LogFormat_CSV
+		case ".json":
+			format = cons.LogFormat_JSON
+		}
+
+		if i > 0 && format != logFormat {
+			return "", fmt.Errorf("log files have different formats (%s and %s), use --log-format to select one", logFormat, format)
+		}
+		logFormat = format
+	}
+
+	return logFormat, nil
+}
+
+// logFileExt returns the extension of the log file ignoring the compression
+// and rotation suffix added by logrotate, like postgresql.csv.1.gz.
+func logFileExt(file string) string {
+	file = strings.ToLower(file)
+	switch filepath.Ext(file) {
+	case ".gz", ".bz2", ".zst", ".xz":
+		file = strings.TrimSuffix(file, filepath.Ext(file))
+	}
+
+	if _, err := strconv.Atoi(strings.TrimPrefix(filepath.Ext(file), ".")); err == nil {
+		file = strings.TrimSuffix(file, filepath.Ext(file))
+	}
+
+	return filepath.Ext(file)
+}
+
+// HasPrefixField reports whether any of the log line prefix placeholders
+// like %u or %h is available in the log lines. csvlog and jsonlog always
+// have all the fields.
+func (a *LogParser) HasPrefixField(placeholders ...string) bool {
+	if a.LogFormat == cons.LogFormat_CSV || a.LogFormat == cons.LogFormat_JSON {
+		return true
+	}
+
+	for _, p := range placeholders {
+		if strings.Contains(a.PgSettings.LogLinePrefix, p) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsValidTime checks if given time is between begin and end time
 func (a *LogParser) IsValidTime(t time.Time) bool {
 	// if begin and end time is not zero then check if t is between begin and end time
@@ -314,7 +569,7 @@ func NewConfig() (*Config, error) {
 	var logParser string
 	var logfile string
 	flag.StringVar(&logParser, "logparser", logParser, `To run logparse using with flags. for more details use ciscollector --help`)
-	flag.StringVar(&logfile, "file-path", "", "File path e.g /location/to/log/file.log. required for all commands in log parser. for more details use ciscollector --help")
+	flag.StringVar(&logfile, "file-path", "", "File path e.g /location/to/log/file.log. gzip, bzip2, zstd and xz compressed files are read without unpacking. required for all commands in log parser. for more details use ciscollector --help")
 
 	var beginTime, endTime string
 	// read begin time
@@ -322,13 +577,23 @@ func NewConfig() (*Config, error) {
 	// read end time
 	flag.StringVar(&endTime, "end-time", "", "End time for log filtering. format supported [2006-01-02 15:04:05]. optional flag for log parser. for more details use ciscollector --help")
 	var prefix string
-	flag.StringVar(&prefix, "prefix", "", "Log line prefix for offline parsing. required for all commands in log parser")
+	flag.StringVar(&prefix, "prefix", "", "Log line prefix for offline parsing. required for all commands in log parser with stderr log format")
+	var logFormat string
+	flag.StringVar(&logFormat, "log-format", "", "Log format for log parser, stderr, csvlog, jsonlog or syslog. default is detected from file extension (.csv, .json)")
 	// var ipFilePath string
 	// flag.StringVar(&ipFilePath, "ip-file-path", "", "File path for ip list. requered for mismatch_ips command in log parser") // TODO removed because we are not using missing_ip command
+	var follow bool
+	flag.BoolVar(&follow, "follow", follow, "Tail the current log file and alert on leaked passwords, sql injection, new client ips and auth failures until interrupted. file-path is the log directory or glob of the log files")
+	var logFilename string
+	flag.StringVar(&logFilename, "log-filename", "", "log_filename of postgres for finding the current log file in follow mode. default is "+DefaultLogFilename)
 	var hbaConfigFile string
 	flag.StringVar(&hbaConfigFile, "hba-file", "", "file path for pg_hba.conf. for unused_lines command in log parser")
+	var piiReportFile string
+	flag.StringVar(&piiReportFile, "pii-report", "", "file path for json output of pii scanner (kshield_pii_report.json). optional for pgaudit command in log parser to report reads of tables with PII data")
+	var inactiveDays int
+	flag.IntVar(&inactiveDays, "inactive-days", 0, "Number of days without log lines after which a user is inactive, for inactive_users command in log parser. users not seen in the logs are always inactive")
 	var outputType string
-	flag.StringVar(&outputType, "output-type", "", "Output type for log parser. supported types are json, csv, table")
+	flag.StringVar(&outputType, "output-type", "", "Output type of the report file. supported types are json, markdown, csv, table")
 	var cpuLimit int
 	flag.IntVar(&cpuLimit, "cpu-limit", cpuLimit, "CPU limit for log parser. default is 0")
 
@@ -380,6 +645,9 @@ func NewConfig() (*Config, error) {
 	var compareConfigBaseServer string
 	flag.StringVar(&compareConfigBaseServer, "compare-config-base-server", "", "Base server for comparison")
 
+	var prometheusTextfile string
+	flag.StringVar(&prometheusTextfile, "prometheus-textfile", "", "File path for writing prometheus metrics for node_exporter textfile collector e.g /var/lib/node_exporter/textfile_collector/klouddbshield.prom")
+
 	flag.Parse()
 
 	if cpuLimit != 0 {
@@ -426,6 +694,12 @@ func NewConfig() (*Config, error) {
 
 	c.App.PrintProcessTime = printProcessTime
 	c.OutputType = outputType
+	if prometheusTextfile != "" {
+		if c.Prometheus == nil {
+			c.Prometheus = &PrometheusConfig{}
+		}
+		c.Prometheus.Textfile = prometheusTextfile
+	}
 
 	var piiConfig *piiscanner.Config
 	if piiscannerRunOption != "" || (spacyOnly && !run) {
@@ -818,15 +1092,29 @@ func NewConfig() (*Config, error) {
 	}
 
 	if logParser != "" {
-		if run || (allchecks && prefix == "") {
+		if run || (allchecks && prefix == "" && logFormat == "") {
 			c.LogParser, c.LogParserConfigErr = getLogParserInputs(c.Postgres, logParser)
+		} else if follow {
+			var err error
+			c.LogParser, err = NewFollowLogParser(logParser, prefix, logFormat, logfile, logFilename, hbaConfigFile)
+			if err != nil {
+				c.LogParserConfigErr = fmt.Errorf("Invalid input for logparser: %v", err)
+			}
 		} else {
 			var err error
-			c.LogParser, err = NewLogParser(logParser, beginTime, endTime, prefix, logfile, hbaConfigFile)
+			c.LogParser, err = NewLogParser(logParser, beginTime, endTime, prefix, logFormat, logfile, hbaConfigFile)
 			if err != nil {
 				c.LogParserConfigErr = fmt.Errorf("Invalid input for logparser: %v", err)
 			}
 		}
+
+		if c.LogParser != nil {
+			c.LogParser.PIIReportFile = strings.TrimSpace(piiReportFile)
+			c.LogParser.InactiveDays = inactiveDays
+		}
+		if inactiveDays < 0 {
+			c.LogParserConfigErr = fmt.Errorf("Invalid input for logparser: --inactive-days must not be negative")
+		}
 	}
 
 	c.CompareConfig = compareConfig
@@ -921,7 +1209,7 @@ func getLogParserInputs(postgresConf *postgresdb.Postgres, command string) (*Log
 		}
 	}
 
-	prefix := ReadInput("Enter Log Line Prefix", prefixSuggestion)
+	prefix := ReadInput("Enter Log Line Prefix (not required for csvlog and jsonlog)", prefixSuggestion)
 	logfile := ReadInput("Enter Log File Path", logfileSuggestion)
 	beginTime := ReadInput("Enter Begin Time (format: 2006-01-02 15:04:05) [optional]", "")
 	endTime := ReadInput("Enter End Time (format: 2006-01-02 15:04:05) [optional]", "")
@@ -936,7 +1224,8 @@ func getLogParserInputs(postgresConf *postgresdb.Postgres, command string) (*Log
 		hbaConfigFile = ReadInput("Enter pg_hba.conf File Path", hbaConfigSuggestion)
 	}
 
-	l, err := NewLogParser(command, beginTime, endTime, prefix, logfile, hbaConfigFile)
+	// log format is detected from the extension of the log files
+	l, err := NewLogParser(command, beginTime, endTime, prefix, "", logfile, hbaConfigFile)
 	if err != nil {
 		return nil, fmt.Errorf("Invalid input for logparser: %v", err)
 	}
@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	cons "github.com/klouddb/klouddbshield/pkg/const"
 	"github.com/klouddb/klouddbshield/pkg/postgresdb"
 	"github.com/spf13/viper"
 )
@@ -210,3 +213,85 @@ func TestCompareConfigFlag(t *testing.T) {
 	// 	t.Errorf("CompareConfig does not match expected value. Got %v, want %v", config.CompareConfig, expectedCompareConfig)
 	// }
 }
+
+func TestGetLogFormat(t *testing.T) {
+	tests := []struct {
+		name      string
+		logFormat string
+		files     []string
+		want      string
+		wantErr   bool
+	}{
+		{"stderr by default", "", []string{"/log/postgresql.log"}, cons.LogFormat_Stderr, false},
+		{"csv extension", "", []string{"/log/a.csv", "/log/b.CSV"}, cons.LogFormat_CSV, false},
+		{"json extension", "", []string{"/log/a.json"}, cons.LogFormat_JSON, false},
+		{"compressed files", "", []string{"/log/a.csv", "/log/a.csv.1.gz", "/log/b.csv.zst"}, cons.LogFormat_CSV, false},
+		{"compressed stderr", "", []string{"/log/a.log.gz"}, cons.LogFormat_Stderr, false},
+		{"flag overrides extension", cons.LogFormat_CSV, []string{"/log/a.log"}, cons.LogFormat_CSV, false},
+		{"mixed extensions", "", []string{"/log/a.csv", "/log/a.json"}, "", true},
+		{"syslog flag", cons.LogFormat_Syslog, []string{"/var/log/postgresql"}, cons.LogFormat_Syslog, false},
+		{"invalid format", "eventlog", []string{"/log/a.log"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getLogFormat(tt.logFormat, tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getLogFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getLogFormat() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogFilenameGlob(t *testing.T) {
+	tests := []struct {
+		logFilename string
+		logFormat   string
+		want        string
+	}{
+		{DefaultLogFilename, cons.LogFormat_Stderr, "postgresql-*-*-*_*.log"},
+		{DefaultLogFilename, cons.LogFormat_CSV, "postgresql-*-*-*_*.csv"},
+		{"postgresql-%a", cons.LogFormat_JSON, "postgresql-*.json"},
+		{"pg-100%%-%H.log", "", "pg-100%-*.log"},
+		{"pg[1]-%d.log", "", `pg\[1]-*.log`},
+	}
+
+	for _, tt := range tests {
+		if got := logFilenameGlob(tt.logFilename, tt.logFormat); got != tt.want {
+			t.Errorf("logFilenameGlob(%q, %q) = %q, want %q", tt.logFilename, tt.logFormat, got, tt.want)
+		}
+	}
+}
+
+func TestNewFollowLogParser(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{"postgresql-2024-01-02_000000.csv", "postgresql-2024-01-03_000000.csv", "postgresql-2024-01-04_000000.log"}
+	for i, name := range files {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte("x\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cnf, err := NewFollowLogParser("unique_ip", "", cons.LogFormat_CSV, dir, "", "")
+	if err != nil {
+		t.Fatalf("NewFollowLogParser() error = %v", err)
+	}
+	if want := filepath.Join(dir, files[1]); len(cnf.LogFiles) != 1 || cnf.LogFiles[0] != want {
+		t.Errorf("log files = %v, want %s", cnf.LogFiles, want)
+	}
+	if cnf.Follow == nil || cnf.Follow.Pattern != filepath.Join(dir, "postgresql-*-*-*_*.csv") {
+		t.Errorf("follow = %+v", cnf.Follow)
+	}
+
+	if _, err := NewFollowLogParser("unique_ip", "", "", dir, "", ""); err == nil {
+		t.Errorf("NewFollowLogParser() without prefix for stderr files should fail")
+	}
+}
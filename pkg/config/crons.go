@@ -1,12 +1,23 @@
 package config
 
 import (
+	"time"
+
 	"github.com/klouddb/klouddbshield/pkg/postgresdb"
 )
 
 type Cron struct {
 	Schedule string    `toml:"schedule"`
 	Commands []Command `toml:"commands"`
+
+	// To and Cc override the recipients of email config for the cron.
+	To []string `toml:"to"`
+	Cc []string `toml:"cc"`
+
+	// AlertOnChange sends the email and webhook notifications only when a
+	// target has changed since its previous run. Crons with the same
+	// schedule run together, so it applies to all of them if any sets it.
+	AlertOnChange bool `toml:"alertOnChange"`
 }
 
 type Command struct {
@@ -14,6 +25,17 @@ type Command struct {
 	MySQL     []*MySQL               `toml:"mysql"`
 	Postgres  []*postgresdb.Postgres `toml:"postgres"`
 	LogParser *LogParserCronInput    `toml:"logparser"`
+
+	PiiScanner    *PiiScannerCronInput    `toml:"piiscanner"`
+	CompareConfig *CompareConfigCronInput `toml:"compareconfig"`
+	BackupAudit   *BackupAuditCronInput   `toml:"backupaudit"`
+
+	// Timeout is the time limit of the command for each target, like "30m".
+	// Zero means no limit.
+	Timeout time.Duration `toml:"timeout"`
+	// Retries is the number of times the command is retried for a target
+	// after failure or timeout.
+	Retries int `toml:"retries"`
 }
 
 type PostgresWithLogparser struct {
@@ -21,8 +43,34 @@ type PostgresWithLogparser struct {
 }
 
 type LogParserCronInput struct {
-	Prefix      string `toml:"prefix"`
+	Prefix string `toml:"prefix"`
+	// LogFormat is stderr, csvlog or jsonlog, detected from the extension
+	// of the log files when it is not set.
+	LogFormat   string `toml:"logformat"`
 	LogFile     string `toml:"logfile"`
 	HbaConfFile string `toml:"hbaconffile"`
+	// PIIReportFile is the json output of pii scanner for pgaudit command.
+	PIIReportFile string `toml:"piireportfile"`
+	// InactiveDays is the threshold of inactive_users command.
+	InactiveDays int `toml:"inactivedays"`
 	// CPULimit    int    `toml:"cpulimit"`
 }
+
+type PiiScannerCronInput struct {
+	RunOption     string `toml:"runoption"`
+	Database      string `toml:"database"`
+	Schema        string `toml:"schema"`
+	IncludeTables string `toml:"includetables"`
+	ExcludeTables string `toml:"excludetables"`
+}
+
+type CompareConfigCronInput struct {
+	BaseServer string   `toml:"baseserver"`
+	Servers    []string `toml:"servers"`
+}
+
+type BackupAuditCronInput struct {
+	BackupTool      string `toml:"backuptool"`
+	BackupPath      string `toml:"backuppath"`
+	BackupFrequency string `toml:"backupfrequency"`
+}
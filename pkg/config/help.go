@@ -15,6 +15,8 @@ import (
 4.
 5. all: To run all log parser commands at once
 NOTE: --begin-time and --end-time are optional flags and --prefix, --file-path and --hba-file are required flags if you are using --logparser=all
+		printLogFormatNote()
+		printFollowNote()
 If you have postgres connection details in config file then you don't need to provide --hba-file flag
 e.g
 * ciscollector --logparser all --file-path /location/to/log/file.log --begin-time "2021-01-01 00:00:00" --end-time "2021-01-01 23:59:59" --prefix <logline prefix> --hba-file /location/to/pg_hba.conf
@@ -106,6 +108,8 @@ func PrintHelp() {
 		fmt.Println("$ " + mainCommand + " " + fileRegexFlag + " " + beginTimeFlag + " " + endTimeFlag + " " + prefixFlag)
 		fmt.Println("$ " + mainCommand + " " + fileRegexFlag + " " + prefixFlag)
 		fmt.Println("\n> " + text.Bold.Sprint("NOTE: --begin-time and --end-time are optional flags and --prefix and --file-path are required flags if you are using --logparser=inactive_users"))
+		printLogFormatNote()
+		printFollowNote()
 	case cons.SelectionIndex_UniqueIPs: // Client ip report
 		fmt.Println(text.Bold.Sprint("unique_ip") + ": To get client IPs from log file")
 
@@ -126,6 +130,8 @@ func PrintHelp() {
 		fmt.Println("$ " + mainCommand + " " + fileRegexFlag + " " + beginTimeFlag + " " + endTimeFlag + " " + prefixFlag)
 		fmt.Println("$ " + mainCommand + " " + fileRegexFlag + " " + prefixFlag)
 		fmt.Println("\n> " + text.Bold.Sprint("NOTE: --begin-time and --end-time are optional flags and --prefix and --file-path are required flags if you are using --logparser=unique_ip"))
+		printLogFormatNote()
+		printFollowNote()
 
 	case cons.SelectionIndex_HBAUnusedLines: // HBA unused lines report
 		fmt.Println(text.Bold.Sprint("unused_lines") + ": To get unused lines from pg_hba.conf file by comparing that with log file")
@@ -148,6 +154,8 @@ func PrintHelp() {
 		fmt.Println("$ " + mainCommand + " " + fileRegexFlag + " " + beginTimeFlag + " " + endTimeFlag + " " + prefixFlag + " " + hbaFileFlag)
 		fmt.Println("$ " + mainCommand + " " + fileRegexFlag + " " + prefixFlag + " " + hbaFileFlag)
 		fmt.Println("\n> " + text.Bold.Sprint("NOTE: --begin-time and --end-time are optional flags and --prefix, --file-path and --hba-file are required flags if you are using --logparser=unused_lines"))
+		printLogFormatNote()
+		printFollowNote()
 
 	case cons.SelectionIndex_PasswordManager: // Password Manager
 		fmt.Println("This module has 3 different active features 1) Password generator 2) Password attack simulator 3) Common usernames detector")
@@ -182,6 +190,8 @@ func PrintHelp() {
 		fmt.Println("$ " + mainCommand + " " + fileRegexFlag + " " + beginTimeFlag + " " + endTimeFlag + " " + prefixFlag)
 		fmt.Println("$ " + mainCommand + " " + fileRegexFlag + " " + prefixFlag)
 		fmt.Println("\n> " + text.Bold.Sprint("NOTE: --begin-time and --end-time are optional flags and --prefix and --file-path are required flags if you are using --logparser=password_leak_scanner"))
+		printLogFormatNote()
+		printFollowNote()
 
 	case cons.SelectionIndex_AWSRDS: // AWS RDS Sec Report
 		fmt.Println("> Make sure you have properly configured your AWS-CLI with a valid Access Key and Region or declare AWS variables properly.")
@@ -261,3 +271,19 @@ func PrintHelp() {
 	refMessage := "> " + text.FgGreen.Sprint("Refer to the detailed guide at "+text.Underline.Sprint("'https://klouddb.gitbook.io/klouddb_shield'"))
 	fmt.Println(refMessage)
 }
+
+// printLogFormatNote prints the usage of log parser with csvlog and jsonlog
+// files, which don't need log line prefix.
+func printLogFormatNote() {
+	fmt.Println("> " + text.Bold.Sprint("NOTE: for csvlog and jsonlog files --prefix is not required, format is detected from .csv and .json extension or can be set with ") +
+		text.FgCyan.Sprint("--log-format csvlog|jsonlog"))
+	fmt.Println("> " + text.Bold.Sprint("NOTE: for logs written to syslog (log_destination = syslog) use ") +
+		text.FgCyan.Sprint("--log-format syslog") + text.Bold.Sprint(" with --prefix, syslog header is removed before parsing the log line prefix"))
+}
+
+func printFollowNote() {
+	fmt.Println("> " + text.Bold.Sprint("NOTE: to monitor the current log file continuously, use ") +
+		text.FgCyan.Sprint("--follow --file-path /location/to/log/directory") +
+		text.Bold.Sprint(". Log rotation is detected with ") + text.FgCyan.Sprint("--log-filename <log_filename>") +
+		text.Bold.Sprint(" (default ") + DefaultLogFilename + text.Bold.Sprint("), alerts are printed and sent to siem as soon as they are found and summary is printed on Ctrl+C"))
+}
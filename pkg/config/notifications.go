@@ -0,0 +1,16 @@
+package config
+
+// Notification is the webhook which receives the summary of every scheduled
+// run.
+type Notification struct {
+	URL string `toml:"url"`
+	// Format of the payload, one of slack, teams or json. Default is json.
+	Format string `toml:"format"`
+
+	// OnlyNewFailures sends the notification only when a target has failures
+	// which were not failing in its previous run.
+	OnlyNewFailures bool `toml:"onlyNewFailures"`
+	// OnlyCritical ignores the failures which are not critical. Runs with
+	// errors are notified with either filter.
+	OnlyCritical bool `toml:"onlyCritical"`
+}
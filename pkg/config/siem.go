@@ -0,0 +1,15 @@
+package config
+
+// SIEM is the sink which forwards the findings of every run to a SIEM.
+type SIEM struct {
+	// Format is one of syslog (RFC 5424), cef or jsonl.
+	Format string `toml:"format"`
+
+	// Network is one of udp, tcp, unix or unixgram and Address is host:port
+	// or socket path. They are used for syslog and cef formats.
+	Network string `toml:"network"`
+	Address string `toml:"address"`
+
+	// File is the path of the file for jsonl format.
+	File string `toml:"file"`
+}
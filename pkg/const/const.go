@@ -181,11 +181,23 @@ const (
 	LogParserCMD_SqlInjectionScan    = "sql_injection_scan"
 	LogParserCMD_All                 = "all"
 	LogParserCMD_PasswordLeakScanner = "password_leak_scanner"
+	LogParserCMD_AuthFailures        = "auth_failures"
+	LogParserCMD_Connections         = "connection_analytics"
+	LogParserCMD_PrivilegedAudit     = "privileged_audit"
+	LogParserCMD_PgAudit             = "pgaudit"
 	// _LogParserCMD_QueryParser        = "pii_query_parser"
 
 	PasswordManager_CommonUsers = "common_users"
 )
 
+// log formats supported by log parser, same as log_destination of postgres
+const (
+	LogFormat_Stderr = "stderr"
+	LogFormat_CSV    = "csvlog"
+	LogFormat_JSON   = "jsonlog"
+	LogFormat_Syslog = "syslog"
+)
+
 var LogParserChoiseMapping = map[int]string{
 	// 1: LogParserCMD_MismatchIPs,
 	1: LogParserCMD_InactiveUser,
@@ -194,4 +206,8 @@ var LogParserChoiseMapping = map[int]string{
 	// 4: LogParserCMD_QueryParser,
 	4: LogParserCMD_PasswordLeakScanner,
 	5: LogParserCMD_All,
+	6: LogParserCMD_AuthFailures,
+	7: LogParserCMD_Connections,
+	8: LogParserCMD_PrivilegedAudit,
+	9: LogParserCMD_PgAudit,
 }
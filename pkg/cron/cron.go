@@ -43,6 +43,32 @@ func (c *Cron) AddFunc(spec string, cmd func()) error {
 	return err
 }
 
+// AddJob adds a func to the Cron like AddFunc, onSkip is called when a run
+// of the job is skipped because the previous run is still running.
+func (c *Cron) AddJob(spec string, cmd func(), onSkip func()) error {
+	_, err := c.cron.AddJob(spec, &skippableJob{run: cmd, onSkip: onSkip})
+	return err
+}
+
+// skipNotifier is implemented by the jobs which need to know about the runs
+// skipped by SkipIfStillRunning.
+type skipNotifier interface {
+	Skipped()
+}
+
+type skippableJob struct {
+	run    func()
+	onSkip func()
+}
+
+func (j *skippableJob) Run() { j.run() }
+
+func (j *skippableJob) Skipped() {
+	if j.onSkip != nil {
+		j.onSkip()
+	}
+}
+
 // Recover is the recover for any the cron job panic. It logs the returned
 // panic message at highest possible log level: zerolog.NoLevel
 func Recover() cron.JobWrapper {
@@ -82,6 +108,9 @@ func SkipIfStillRunning() cron.JobWrapper {
 				ch <- v
 			default:
 				log.Info().Msg("A job is skipped, due to previous running invocation")
+				if s, ok := job.(skipNotifier); ok {
+					s.Skipped()
+				}
 			}
 		})
 	}
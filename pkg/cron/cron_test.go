@@ -28,3 +28,29 @@ func TestCron_AddFunc(t *testing.T) {
 	ctx := c.Stop()
 	<-ctx.Done()
 }
+
+func TestIsLessThan24Hours(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    bool
+		wantErr bool
+	}{
+		{expr: "*/5 * * * *", want: true},
+		{expr: "0 */6 * * *", want: true},
+		{expr: "0 0 * * *", want: false},
+		{expr: "@weekly", want: false},
+		{expr: "invalid", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := IsLessThan24Hours(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IsLessThan24Hours() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IsLessThan24Hours() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
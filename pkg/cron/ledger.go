@@ -0,0 +1,167 @@
+package cron
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// run statuses recorded in ledger
+const (
+	RunStatus_Success = "success"
+	RunStatus_Failed  = "failed"
+	RunStatus_Skipped = "skipped"
+)
+
+// LedgerEntry is a single execution of a scheduled job.
+type LedgerEntry struct {
+	ID       string          `json:"id"`
+	Schedule string          `json:"schedule"`
+	Status   string          `json:"status"`
+	Start    time.Time       `json:"start"`
+	End      time.Time       `json:"end"`
+	Commands []LedgerCommand `json:"commands,omitempty"`
+	Errors   []string        `json:"errors,omitempty"`
+	Reports  []string        `json:"reports,omitempty"`
+}
+
+// LedgerCommand is the result of a command in the job.
+type LedgerCommand struct {
+	Name     string   `json:"name"`
+	Status   string   `json:"status"`
+	Attempts int      `json:"attempts"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// Ledger records the executions of the scheduled jobs in a json lines file.
+type Ledger struct {
+	mu   sync.Mutex
+	file string
+}
+
+func NewLedger(file string) *Ledger {
+	return &Ledger{file: file}
+}
+
+// Append adds the entry at the end of the ledger.
+func (l *Ledger) Append(entry LedgerEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ledger entry: %v", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.file), 0755); err != nil {
+		return fmt.Errorf("failed to create ledger directory: %v", err)
+	}
+
+	f, err := os.OpenFile(l.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write ledger: %v", err)
+	}
+
+	return nil
+}
+
+// Load returns the last limit entries in the order they were recorded. All
+// the entries are returned if limit is zero or negative.
+func (l *Ledger) Load(limit int) ([]LedgerEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out, nil
+}
+
+// Prune removes the entries which ended before the time.
+func (l *Ledger) Prune(before time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries, err := l.load()
+	if err != nil {
+		return err
+	}
+
+	keep := make([]LedgerEntry, 0, len(entries))
+	for _, e := range entries {
+		if !e.End.Before(before) {
+			keep = append(keep, e)
+		}
+	}
+	if len(keep) == len(entries) {
+		return nil
+	}
+
+	tmp := l.file + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create ledger: %v", err)
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range keep {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return fmt.Errorf("failed to write ledger: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write ledger: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write ledger: %v", err)
+	}
+
+	return os.Rename(tmp, l.file)
+}
+
+func (l *Ledger) load() ([]LedgerEntry, error) {
+	f, err := os.Open(l.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %v", err)
+	}
+	defer f.Close()
+
+	out := []LedgerEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e LedgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		out = append(out, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %v", err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,49 @@
+package cron
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLedger(t *testing.T) {
+	l := NewLedger(filepath.Join(t.TempDir(), "runs", "ledger.jsonl"))
+
+	entries, err := l.Load(0)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Load() of missing ledger = %v, %v", entries, err)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, status := range []string{RunStatus_Success, RunStatus_Failed, RunStatus_Skipped} {
+		e := LedgerEntry{
+			ID:       status,
+			Schedule: "@daily",
+			Status:   status,
+			Start:    start.AddDate(0, 0, i),
+			End:      start.AddDate(0, 0, i).Add(time.Minute),
+		}
+		if err := l.Append(e); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	entries, err = l.Load(2)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].ID != RunStatus_Failed || entries[1].ID != RunStatus_Skipped {
+		t.Errorf("Load(2) = %+v, want last two entries", entries)
+	}
+
+	if err := l.Prune(start.AddDate(0, 0, 1)); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	entries, err = l.Load(0)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].ID != RunStatus_Failed {
+		t.Errorf("after Prune() got %+v, want entries of day 2 and 3", entries)
+	}
+}
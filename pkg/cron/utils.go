@@ -25,6 +25,8 @@ func getNextTwoOccurrences(cronExpr string) ([]time.Time, error) {
 	return occurrences, nil
 }
 
+// IsLessThan24Hours reports whether the interval between two runs of the
+// schedule is less than 24 hours.
 func IsLessThan24Hours(cronExpr string) (bool, error) {
 	occurrences, err := getNextTwoOccurrences(cronExpr)
 	if err != nil {
@@ -33,7 +35,7 @@ func IsLessThan24Hours(cronExpr string) (bool, error) {
 
 	// Compare the difference between the two occurrences
 	duration := occurrences[1].Sub(occurrences[0])
-	return duration >= 24*time.Hour, nil
+	return duration < 24*time.Hour, nil
 }
 
 func GetPreviousExecutionTime(cronExpr string) (time.Time, error) {
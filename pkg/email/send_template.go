@@ -1,24 +1,53 @@
 package email
 
 import (
+	"crypto/tls"
 	"fmt"
 
 	"gopkg.in/gomail.v2"
 )
 
+// supported tls modes
+const (
+	// TLS_StartTLS connects in plain text and upgrades the connection with
+	// STARTTLS extension when the server supports it.
+	TLS_StartTLS = "starttls"
+	// TLS_Implicit connects with TLS from the start, usually on port 465.
+	TLS_Implicit = "tls"
+)
+
 type EmailHelper struct {
 	host     string
 	port     int
 	username string
 	password string
+
+	from               string
+	tlsMode            string
+	insecureSkipVerify bool
 }
 
-func NewEmailHelper(host string, port int, username, password string) *EmailHelper {
+// NewEmailHelper creates the email helper. from defaults to username and
+// tlsMode defaults to implicit TLS for port 465 and STARTTLS otherwise.
+func NewEmailHelper(host string, port int, username, password, from, tlsMode string, insecureSkipVerify bool) *EmailHelper {
+	if from == "" {
+		from = username
+	}
+	if tlsMode == "" {
+		tlsMode = TLS_StartTLS
+		if port == 465 {
+			tlsMode = TLS_Implicit
+		}
+	}
+
 	return &EmailHelper{
-		host:     host,
-		port:     port,
-		username: username,
-		password: password,
+		host:               host,
+		port:               port,
+		username:           username,
+		password:           password,
+		from:               from,
+		tlsMode:            tlsMode,
+		insecureSkipVerify: insecureSkipVerify,
 	}
 }
 
@@ -27,28 +56,54 @@ func (e *EmailHelper) VerifyConfig() error {
 		return fmt.Errorf("missing email configuration")
 	}
 
+	if e.tlsMode != TLS_StartTLS && e.tlsMode != TLS_Implicit {
+		return fmt.Errorf("invalid email tls mode %q, valid modes are %s and %s", e.tlsMode, TLS_StartTLS, TLS_Implicit)
+	}
+
 	return nil
 }
 
-func (e *EmailHelper) Send(to, subject, body string, attachmentPaths []string) error {
-	if e.host == "" || e.port == 0 || e.username == "" || e.password == "" {
-		return fmt.Errorf("missing email configuration")
+// Message is a single email. HTMLBody is sent as text/html body and the
+// attachments are attached with content type detected from file extension.
+type Message struct {
+	To          []string
+	Cc          []string
+	Subject     string
+	HTMLBody    string
+	Attachments []string
+}
+
+func (e *EmailHelper) Send(msg *Message) error {
+	if err := e.VerifyConfig(); err != nil {
+		return err
+	}
+
+	if len(msg.To) == 0 && len(msg.Cc) == 0 {
+		return fmt.Errorf("no recipient for email")
 	}
 
 	m := gomail.NewMessage()
-	m.SetHeader("From", e.username)
-	m.SetHeader("To", to)
-	m.SetHeader("Subject", subject)
-	m.SetBody("text/html; charset=UTF-8", body)
+	m.SetHeader("From", e.from)
+	if len(msg.To) > 0 {
+		m.SetHeader("To", msg.To...)
+	}
+	if len(msg.Cc) > 0 {
+		m.SetHeader("Cc", msg.Cc...)
+	}
+	m.SetHeader("Subject", msg.Subject)
+	// charset is added by gomail
+	m.SetBody("text/html", msg.HTMLBody)
 
-	// Attach the file if an attachment path is provided
-	for _, attachmentPath := range attachmentPaths {
-		m.Attach(attachmentPath, gomail.SetHeader(map[string][]string{
-			"Content-Type": {"text/html; charset=UTF-8"},
-		}))
+	for _, attachmentPath := range msg.Attachments {
+		m.Attach(attachmentPath)
 	}
 
 	d := gomail.NewDialer(e.host, e.port, e.username, e.password)
+	d.SSL = e.tlsMode == TLS_Implicit
+	d.TLSConfig = &tls.Config{
+		ServerName:         e.host,
+		InsecureSkipVerify: e.insecureSkipVerify, //nolint:gosec
+	}
 
 	return d.DialAndSend(m)
 }
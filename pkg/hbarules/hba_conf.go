@@ -0,0 +1,559 @@
+package hbarules
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/klouddb/klouddbshield/model"
+)
+
+// maxIncludeDepth is the nesting limit of included files, same as postgres.
+const maxIncludeDepth = 10
+
+// connection types of hba lines
+const (
+	HBAType_Local        = "local"
+	HBAType_Host         = "host"
+	HBAType_HostSSL      = "hostssl"
+	HBAType_HostNoSSL    = "hostnossl"
+	HBAType_HostGSSEnc   = "hostgssenc"
+	HBAType_HostNoGSSEnc = "hostnogssenc"
+)
+
+var hbaTypes = map[string]bool{
+	HBAType_Local:        true,
+	HBAType_Host:         true,
+	HBAType_HostSSL:      true,
+	HBAType_HostNoSSL:    true,
+	HBAType_HostGSSEnc:   true,
+	HBAType_HostNoGSSEnc: true,
+}
+
+var hbaMethods = map[string]bool{
+	"trust": true, "reject": true, "scram-sha-256": true, "md5": true, "password": true,
+	"gss": true, "sspi": true, "ident": true, "peer": true, "pam": true, "ldap": true,
+	"radius": true, "cert": true,
+}
+
+// HBAToken is a database or user of an hba line. Quoted tokens are never
+// keywords, like "all" is the database named all.
+type HBAToken struct {
+	Value  string
+	Quoted bool
+
+	regex *regexp.Regexp
+}
+
+// IsKeyword returns true when the token is the keyword k.
+func (t HBAToken) IsKeyword(k string) bool {
+	return !t.Quoted && t.Value == k
+}
+
+// IsRegex returns true when the token is a regular expression, like
+// /^.*admin$.
+func (t HBAToken) IsRegex() bool {
+	return t.regex != nil
+}
+
+// IsGroup returns true when the token is a role whose members are matched,
+// like +admins.
+func (t HBAToken) IsGroup() bool {
+	return !t.Quoted && strings.HasPrefix(t.Value, "+") && len(t.Value) > 1
+}
+
+func (t HBAToken) String() string {
+	if t.Quoted {
+		return `"` + t.Value + `"`
+	}
+	return t.Value
+}
+
+// HBAEntry is an authentication line of pg_hba.conf or of a file included
+// by it.
+type HBAEntry struct {
+	File   string
+	LineNo int
+	Raw    string
+
+	Type      string
+	Databases []HBAToken
+	Users     []HBAToken
+	// Address is empty for local lines. It is an IP address, a CIDR, a host
+	// name, a domain starting with dot, or one of all, samehost and samenet.
+	Address string
+	NetMask string
+	Method  string
+	// Options are the auth options, like map=omicron.
+	Options []string
+
+	ipnet *net.IPNet
+}
+
+// IsHostname returns true when the address is a host name or a domain.
+func (e HBAEntry) IsHostname() bool {
+	return e.Type != HBAType_Local && e.ipnet == nil && !isAddressKeyword(e.Address)
+}
+
+func isAddressKeyword(address string) bool {
+	return address == "all" || address == "samehost" || address == "samenet"
+}
+
+// HBALineError is a line of hba file which can not be parsed, postgres does
+// not load the file with it.
+type HBALineError struct {
+	File   string
+	LineNo int
+	Line   string
+	Err    string
+}
+
+func (e HBALineError) Error() string {
+	return fmt.Sprintf("%s:%d: %s (%s)", e.File, e.LineNo, e.Err, e.Line)
+}
+
+// HBAConf is the parsed hba file with its included files, entries are in
+// the order they are checked by postgres.
+type HBAConf struct {
+	Entries []HBAEntry
+	Errors  []HBALineError
+}
+
+// ParseHBAConf parses the hba file like postgres does. It supports quoted
+// names, comma lists, @file lists, +group users, /regex databases and users,
+// host names, auth options, line continuation and include, include_if_exists
+// and include_dir directives. Invalid lines are returned in Errors, error is
+// returned only when the hba file can not be read.
+func ParseHBAConf(hbaFilePath string) (*HBAConf, error) {
+	conf := &HBAConf{}
+	if err := conf.parseFile(hbaFilePath, 0); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+type hbaFileLine struct {
+	lineNo int
+	line   string
+}
+
+// readHBAFileLines returns the lines of the file with the continued lines
+// joined, line number is the first line of the joined lines.
+func readHBAFileLines(path string) ([]hbaFileLine, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var out []hbaFileLine
+	var pending *hbaFileLine
+
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+
+		if pending == nil {
+			pending = &hbaFileLine{lineNo: lineNo}
+		}
+		if strings.HasSuffix(line, `\`) {
+			pending.line += strings.TrimSuffix(line, `\`) + " "
+			continue
+		}
+
+		pending.line += line
+		out = append(out, *pending)
+		pending = nil
+	}
+	if pending != nil {
+		out = append(out, *pending)
+	}
+
+	return out, scanner.Err()
+}
+
+func (c *HBAConf) parseFile(path string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("could not open file %s: maximum nesting depth exceeded", path)
+	}
+
+	lines, err := readHBAFileLines(path)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range lines {
+		lineErr := func(err error) {
+			c.Errors = append(c.Errors, HBALineError{File: path, LineNo: l.lineNo, Line: strings.TrimSpace(l.line), Err: err.Error()})
+		}
+
+		fields, err := tokenizeHBALine(l.line)
+		if err != nil {
+			lineErr(err)
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		if directive, ok := includeDirective(fields); ok {
+			if len(fields) != 2 || len(fields[1]) != 1 {
+				lineErr(fmt.Errorf("%s requires one file name", directive))
+				continue
+			}
+			if err := c.include(directive, resolveHBAPath(path, fields[1][0].Value), depth); err != nil {
+				lineErr(err)
+			}
+			continue
+		}
+
+		entry, err := parseHBAEntry(fields, path)
+		if err != nil {
+			lineErr(err)
+			continue
+		}
+		entry.File = path
+		entry.LineNo = l.lineNo
+		entry.Raw = strings.TrimSpace(l.line)
+		c.Entries = append(c.Entries, *entry)
+	}
+
+	return nil
+}
+
+func includeDirective(fields [][]HBAToken) (string, bool) {
+	if len(fields[0]) != 1 || fields[0][0].Quoted {
+		return "", false
+	}
+
+	switch d := fields[0][0].Value; d {
+	case "include", "include_if_exists", "include_dir":
+		return d, true
+	}
+	return "", false
+}
+
+func (c *HBAConf) include(directive, path string, depth int) error {
+	switch directive {
+	case "include_if_exists":
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil
+		}
+	case "include_dir":
+		dirEntries, err := os.ReadDir(path)
+		if err != nil {
+			return fmt.Errorf("could not open directory %s: %v", path, err)
+		}
+
+		var files []string
+		for _, d := range dirEntries {
+			name := d.Name()
+			if d.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".conf") {
+				continue
+			}
+			files = append(files, filepath.Join(path, name))
+		}
+		sort.Strings(files)
+
+		for _, f := range files {
+			if err := c.parseFile(f, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return c.parseFile(path, depth+1)
+}
+
+// resolveHBAPath returns the path relative to the directory of the file
+// referring it.
+func resolveHBAPath(from, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(from), path)
+}
+
+// tokenizeHBALine splits the line into the fields, a field has more than one
+// token when it is a comma separated list. Comments start with # outside
+// quotes.
+func tokenizeHBALine(line string) ([][]HBAToken, error) {
+	var fields [][]HBAToken
+	var field []HBAToken
+
+	i := 0
+	for i < len(line) {
+		// skip the blanks before the token, commas continue the field
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		if i >= len(line) || line[i] == '#' {
+			break
+		}
+
+		var sb strings.Builder
+		inQuote, quoted, trailingComma := false, false, false
+		for ; i < len(line); i++ {
+			ch := line[i]
+			if !inQuote && (ch == ' ' || ch == '\t' || ch == '#') {
+				break
+			}
+			if !inQuote && ch == ',' {
+				trailingComma = true
+				i++
+				break
+			}
+			if ch == '"' {
+				inQuote = !inQuote
+				quoted = true
+				continue
+			}
+			sb.WriteByte(ch)
+		}
+		if inQuote {
+			return nil, fmt.Errorf("unterminated quoted string")
+		}
+
+		if sb.Len() > 0 || quoted {
+			field = append(field, HBAToken{Value: sb.String(), Quoted: quoted})
+		}
+		if !trailingComma && len(field) > 0 {
+			fields = append(fields, field)
+			field = nil
+		}
+	}
+	if len(field) > 0 {
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// expandHBATokens replaces the @file tokens with the tokens of the file.
+func expandHBATokens(tokens []HBAToken, path string, depth int) ([]HBAToken, error) {
+	var out []HBAToken
+	for _, t := range tokens {
+		if t.Quoted || !strings.HasPrefix(t.Value, "@") || len(t.Value) == 1 {
+			out = append(out, t)
+			continue
+		}
+		if depth > maxIncludeDepth {
+			return nil, fmt.Errorf("could not open file %s: maximum nesting depth exceeded", t.Value[1:])
+		}
+
+		file := resolveHBAPath(path, t.Value[1:])
+		lines, err := readHBAFileLines(file)
+		if err != nil {
+			return nil, fmt.Errorf("could not open secondary authentication file %s: %v", t.Value, err)
+		}
+		for _, l := range lines {
+			fields, err := tokenizeHBALine(l.line)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", file, l.lineNo, err)
+			}
+			for _, f := range fields {
+				expanded, err := expandHBATokens(f, file, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, expanded...)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func compileHBATokens(tokens []HBAToken) error {
+	for i, t := range tokens {
+		if !strings.HasPrefix(t.Value, "/") {
+			continue
+		}
+
+		re, err := regexp.Compile(t.Value[1:])
+		if err != nil {
+			return fmt.Errorf("invalid regular expression %s: %v", t.Value, err)
+		}
+		tokens[i].regex = re
+	}
+	return nil
+}
+
+func parseHBAEntry(fields [][]HBAToken, path string) (*HBAEntry, error) {
+	single := func(i int, name string) (string, error) {
+		if i >= len(fields) {
+			return "", fmt.Errorf("end-of-line before %s", name)
+		}
+		if len(fields[i]) > 1 {
+			return "", fmt.Errorf("multiple values specified for %s", name)
+		}
+		return fields[i][0].Value, nil
+	}
+
+	entry := &HBAEntry{}
+	var err error
+
+	entry.Type, err = single(0, "connection type")
+	if err != nil {
+		return nil, err
+	}
+	if !hbaTypes[entry.Type] {
+		return nil, fmt.Errorf("invalid connection type %q", entry.Type)
+	}
+
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("end-of-line before database or role specification")
+	}
+	if entry.Databases, err = expandHBATokens(fields[1], path, 0); err != nil {
+		return nil, err
+	}
+	if entry.Users, err = expandHBATokens(fields[2], path, 0); err != nil {
+		return nil, err
+	}
+	if err := compileHBATokens(entry.Databases); err != nil {
+		return nil, err
+	}
+	if err := compileHBATokens(entry.Users); err != nil {
+		return nil, err
+	}
+
+	next := 3
+	if entry.Type != HBAType_Local {
+		entry.Address, err = single(next, "IP address")
+		if err != nil {
+			return nil, err
+		}
+		next++
+
+		switch {
+		case fields[3][0].Quoted || isAddressKeyword(entry.Address):
+		case strings.Contains(entry.Address, "/"):
+			_, entry.ipnet, err = net.ParseCIDR(entry.Address)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP address %q", entry.Address)
+			}
+		case net.ParseIP(entry.Address) != nil:
+			entry.NetMask, err = single(next, "IP mask")
+			if err != nil {
+				return nil, err
+			}
+			next++
+
+			entry.ipnet = parseIPAndMask(entry.Address, entry.NetMask)
+			if entry.ipnet == nil {
+				return nil, fmt.Errorf("invalid IP mask %q", entry.NetMask)
+			}
+		}
+	}
+
+	entry.Method, err = single(next, "authentication method")
+	if err != nil {
+		return nil, err
+	}
+	if !hbaMethods[entry.Method] {
+		return nil, fmt.Errorf("invalid authentication method %q", entry.Method)
+	}
+	if entry.Method == "peer" && entry.Type != HBAType_Local {
+		return nil, fmt.Errorf("peer authentication is only supported on local sockets")
+	}
+	next++
+
+	for _, f := range fields[next:] {
+		for _, t := range f {
+			if !strings.Contains(t.Value, "=") {
+				return nil, fmt.Errorf("authentication option not in name=value format: %s", t.Value)
+			}
+			entry.Options = append(entry.Options, t.Value)
+		}
+	}
+
+	return entry, nil
+}
+
+// parseIPAndMask returns the network of the address and mask of the same
+// family, nil when the mask is invalid.
+func parseIPAndMask(address, mask string) *net.IPNet {
+	ip, m := net.ParseIP(address), net.ParseIP(mask)
+	if ip == nil || m == nil {
+		return nil
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		m4 := m.To4()
+		if m4 == nil {
+			return nil
+		}
+		ip, m = ip4, m4
+	} else if m.To4() != nil {
+		return nil
+	}
+
+	return &net.IPNet{IP: ip.Mask(net.IPMask(m)), Mask: net.IPMask(m)}
+}
+
+// HBAEntriesFromRules returns the entries of the rules of pg_hba_file_rules
+// view, for the servers whose hba file can not be read from here. The view
+// does not keep the quotes of names, so they are considered keywords.
+func HBAEntriesFromRules(file string, rules []model.HBAFIleRules) []HBAEntry {
+	out := make([]HBAEntry, 0, len(rules))
+	for _, r := range rules {
+		e := HBAEntry{
+			File:      file,
+			LineNo:    r.LineNumber,
+			Raw:       formatRule(r),
+			Type:      r.Type,
+			Databases: parseHBARuleTokens(r.Database),
+			Users:     parseHBARuleTokens(r.UserName),
+			Address:   r.Address,
+			NetMask:   r.NetMask,
+		}
+		// invalid regular expressions are reported by postgres in the view
+		compileHBATokens(e.Databases) //nolint:errcheck
+		compileHBATokens(e.Users)     //nolint:errcheck
+
+		if method := strings.Fields(r.Method); len(method) > 0 {
+			e.Method, e.Options = method[0], method[1:]
+		}
+		if e.Type != HBAType_Local && r.NetMask != "" {
+			e.ipnet = parseIPAndMask(r.Address, r.NetMask)
+		}
+
+		out = append(out, e)
+	}
+	return out
+}
+
+// parseHBARuleTokens parses the elements of a text array of the view without
+// braces, elements with commas or spaces are double quoted.
+func parseHBARuleTokens(list string) []HBAToken {
+	var out []HBAToken
+	var sb strings.Builder
+	inQuote := false
+	for i := 0; i < len(list); i++ {
+		c := list[i]
+		switch {
+		case c == '\\' && inQuote && i+1 < len(list):
+			i++
+			sb.WriteByte(list[i])
+		case c == '"':
+			inQuote = !inQuote
+		case c == ',' && !inQuote:
+			out = append(out, HBAToken{Value: sb.String()})
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if list != "" {
+		out = append(out, HBAToken{Value: sb.String()})
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package hbarules
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_tokenizeHBALine(t *testing.T) {
+	tests := []struct {
+		line string
+		want [][]HBAToken
+		err  bool
+	}{
+		{
+			line: `host  db1,db2  "all",+admins  10.0.0.0/8  md5 # comment`,
+			want: [][]HBAToken{
+				{{Value: "host"}},
+				{{Value: "db1"}, {Value: "db2"}},
+				{{Value: "all", Quoted: true}, {Value: "+admins"}},
+				{{Value: "10.0.0.0/8"}},
+				{{Value: "md5"}},
+			},
+		},
+		{
+			line: `local "my db", sales  all  peer map="os map"`,
+			want: [][]HBAToken{
+				{{Value: "local"}},
+				{{Value: "my db", Quoted: true}, {Value: "sales"}},
+				{{Value: "all"}},
+				{{Value: "peer"}},
+				{{Value: "map=os map", Quoted: true}},
+			},
+		},
+		{line: "   # only comment"},
+		{line: `host "db all all md5`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := tokenizeHBALine(tt.line)
+			if (err != nil) != tt.err {
+				t.Fatalf("tokenizeHBALine() error = %v, want error %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenizeHBALine() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ParseHBAConf(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"pg_hba.conf": `# TYPE  DATABASE  USER  ADDRESS  METHOD
+local   all       postgres            peer
+host    sales,@dbs  /^app_  192.168.1.0 255.255.255.0 \
+        scram-sha-256
+hostssl all       +admins .example.com cert clientcert=verify-full
+host    all       all     10.0.0.0/33  md5
+include_if_exists missing.conf
+include_dir conf.d
+include extra.conf
+`,
+		"dbs":                `reports, "Billing"`,
+		"extra.conf":         "host replication repl 10.1.0.0/16 scram-sha-256\n",
+		"conf.d/10-app.conf": "host all app samenet md5\n",
+		"conf.d/.hidden":     "host all all all trust\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf, err := ParseHBAConf(filepath.Join(dir, "pg_hba.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type entry struct {
+		file      string
+		lineNo    int
+		typ       string
+		databases string
+		users     string
+		address   string
+		method    string
+		options   []string
+	}
+	want := []entry{
+		{"pg_hba.conf", 2, "local", "all", "postgres", "", "peer", nil},
+		{"pg_hba.conf", 3, "host", `sales,reports,"Billing"`, "/^app_", "192.168.1.0", "scram-sha-256", nil},
+		{"pg_hba.conf", 5, "hostssl", "all", "+admins", ".example.com", "cert", []string{"clientcert=verify-full"}},
+		{"conf.d/10-app.conf", 1, "host", "all", "app", "samenet", "md5", nil},
+		{"extra.conf", 1, "host", "replication", "repl", "10.1.0.0/16", "scram-sha-256", nil},
+	}
+
+	var got []entry
+	for _, e := range conf.Entries {
+		file, _ := filepath.Rel(dir, e.File)
+		got = append(got, entry{file, e.LineNo, e.Type, joinHBATokens(e.Databases), joinHBATokens(e.Users), e.Address, e.Method, e.Options})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseHBAConf() entries = %+v, want %+v", got, want)
+	}
+
+	if len(conf.Errors) != 1 || conf.Errors[0].LineNo != 6 || !strings.Contains(conf.Errors[0].Err, "invalid IP address") {
+		t.Errorf("ParseHBAConf() errors = %+v, want invalid IP address at line 6", conf.Errors)
+	}
+}
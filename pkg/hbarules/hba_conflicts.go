@@ -0,0 +1,258 @@
+package hbarules
+
+// HBARuleConflict is a line of hba file which is affected by an earlier
+// line, postgres uses the first matching line.
+type HBARuleConflict struct {
+	Entry HBAEntry
+	// By is the earlier line.
+	By HBAEntry
+}
+
+// ShadowedHBARules returns the lines which can never match because an
+// earlier line matches all of their connections. Reject and hostssl lines
+// shadowed by permissive and host lines are returned by LateRejectHBARules
+// and ShadowedHostSSLRules.
+func ShadowedHBARules(entries []HBAEntry) []HBARuleConflict {
+	var out []HBARuleConflict
+	for i, e := range entries {
+		by, ok := firstCoveringEntry(entries[:i], e)
+		if !ok || isLateReject(by, e) || isShadowedHostSSL(by, e) {
+			continue
+		}
+		out = append(out, HBARuleConflict{Entry: e, By: by})
+	}
+	return out
+}
+
+// ShadowedHostSSLRules returns the hostssl lines which can never match
+// because an earlier host line matches all of their connections, ssl is not
+// enforced for them.
+func ShadowedHostSSLRules(entries []HBAEntry) []HBARuleConflict {
+	var out []HBARuleConflict
+	for i, e := range entries {
+		if by, ok := firstCoveringEntry(entries[:i], e); ok && isShadowedHostSSL(by, e) {
+			out = append(out, HBARuleConflict{Entry: e, By: by})
+		}
+	}
+	return out
+}
+
+// LateRejectHBARules returns the reject lines placed after a permissive line
+// matching some of their connections, those connections are allowed. Reject
+// lines covering the permissive line, like the catch-all reject at the end
+// of the file, reject the rest of the connections as intended.
+func LateRejectHBARules(entries []HBAEntry) []HBARuleConflict {
+	var out []HBARuleConflict
+	for i, e := range entries {
+		if e.Method != "reject" {
+			continue
+		}
+		for _, by := range entries[:i] {
+			if by.Method != "reject" && overlapsHBAEntry(by, e) && !coversHBAEntry(e, by) {
+				out = append(out, HBARuleConflict{Entry: e, By: by})
+				break
+			}
+		}
+	}
+	return out
+}
+
+// OverlappingHBARules returns the lines with CIDR address overlapping an
+// earlier line with a different auth method, the method of the connections
+// in the overlap depends on the order of the lines. Reject lines and the
+// lines shadowed by the earlier line are not returned.
+func OverlappingHBARules(entries []HBAEntry) []HBARuleConflict {
+	var out []HBARuleConflict
+	for i, e := range entries {
+		if e.ipnet == nil || e.Method == "reject" {
+			continue
+		}
+		for _, by := range entries[:i] {
+			if by.ipnet == nil || by.Method == "reject" || by.Method == e.Method {
+				continue
+			}
+			if overlapsHBAEntry(by, e) && !coversHBAEntry(by, e) {
+				out = append(out, HBARuleConflict{Entry: e, By: by})
+				break
+			}
+		}
+	}
+	return out
+}
+
+func isLateReject(by, e HBAEntry) bool {
+	return e.Method == "reject" && by.Method != "reject"
+}
+
+func isShadowedHostSSL(by, e HBAEntry) bool {
+	return e.Type == HBAType_HostSSL && by.Type == HBAType_Host
+}
+
+func firstCoveringEntry(entries []HBAEntry, e HBAEntry) (HBAEntry, bool) {
+	for _, by := range entries {
+		if coversHBAEntry(by, e) {
+			return by, true
+		}
+	}
+	return HBAEntry{}, false
+}
+
+// coversHBAEntry returns true when all the connections of e match a.
+func coversHBAEntry(a, e HBAEntry) bool {
+	return coversHBAType(a.Type, e.Type) &&
+		coversHBADatabases(a.Databases, e.Databases) &&
+		coversHBAUsers(a.Users, e.Users) &&
+		coversHBAAddress(a, e)
+}
+
+func coversHBAType(a, e string) bool {
+	return a == e || (a == HBAType_Host && e != HBAType_Local)
+}
+
+func coversHBADatabases(a, e []HBAToken) bool {
+	for _, t := range e {
+		// all does not match physical replication connections
+		covered := hasHBAToken(a, t) || (hasHBAKeyword(a, "all") && !t.IsKeyword("replication"))
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+func coversHBAUsers(a, e []HBAToken) bool {
+	if hasHBAKeyword(a, "all") {
+		return true
+	}
+	for _, t := range e {
+		if !hasHBAToken(a, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasHBAToken returns true when the same token is in the list, quotes
+// change only the keywords and groups.
+func hasHBAToken(tokens []HBAToken, t HBAToken) bool {
+	for _, a := range tokens {
+		if a.Value == t.Value && (a.Quoted == t.Quoted || !isSpecialHBAToken(a) && !isSpecialHBAToken(t)) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasHBAKeyword(tokens []HBAToken, k string) bool {
+	for _, t := range tokens {
+		if t.IsKeyword(k) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSpecialHBAToken(t HBAToken) bool {
+	switch {
+	case t.IsGroup(), t.IsRegex():
+		return true
+	case t.Quoted:
+		return false
+	}
+
+	switch t.Value {
+	case "all", "sameuser", "samerole", "samegroup", "replication":
+		return true
+	}
+	return false
+}
+
+func coversHBAAddress(a, e HBAEntry) bool {
+	if e.Type == HBAType_Local || a.Address == "all" {
+		return true
+	}
+	if a.ipnet != nil && e.ipnet != nil {
+		aOnes, aBits := a.ipnet.Mask.Size()
+		eOnes, eBits := e.ipnet.Mask.Size()
+		return aBits == eBits && aOnes <= eOnes && a.ipnet.Contains(e.ipnet.IP)
+	}
+	return a.ipnet == nil && e.ipnet == nil && a.Address == e.Address
+}
+
+// overlapsHBAEntry returns true when some connections can match both the
+// lines. Groups, regular expressions and host names are assumed to overlap.
+func overlapsHBAEntry(a, e HBAEntry) bool {
+	return overlapsHBAType(a.Type, e.Type) &&
+		overlapsHBADatabases(a.Databases, e.Databases) &&
+		overlapsHBATokens(a.Users, e.Users) &&
+		overlapsHBAAddress(a, e)
+}
+
+func overlapsHBAType(a, e string) bool {
+	if a == HBAType_Local || e == HBAType_Local {
+		return a == e
+	}
+	if a == HBAType_Host || e == HBAType_Host || a == e {
+		return true
+	}
+
+	conflicting := map[string]string{
+		HBAType_HostSSL:      HBAType_HostNoSSL,
+		HBAType_HostNoSSL:    HBAType_HostSSL,
+		HBAType_HostGSSEnc:   HBAType_HostNoGSSEnc,
+		HBAType_HostNoGSSEnc: HBAType_HostGSSEnc,
+	}
+	// ssl and gssapi encryption are not used together
+	if (a == HBAType_HostSSL && e == HBAType_HostGSSEnc) || (a == HBAType_HostGSSEnc && e == HBAType_HostSSL) {
+		return false
+	}
+	return conflicting[a] != e
+}
+
+// overlapsHBADatabases checks the physical replication connections
+// separately, they match only replication keyword.
+func overlapsHBADatabases(a, e []HBAToken) bool {
+	splitReplication := func(tokens []HBAToken) (bool, []HBAToken) {
+		var others []HBAToken
+		replication := false
+		for _, t := range tokens {
+			if t.IsKeyword("replication") {
+				replication = true
+				continue
+			}
+			others = append(others, t)
+		}
+		return replication, others
+	}
+
+	aReplication, aOthers := splitReplication(a)
+	eReplication, eOthers := splitReplication(e)
+	if aReplication && eReplication {
+		return true
+	}
+	return len(aOthers) > 0 && len(eOthers) > 0 && overlapsHBATokens(aOthers, eOthers)
+}
+
+func overlapsHBATokens(a, e []HBAToken) bool {
+	for _, x := range a {
+		if isSpecialHBAToken(x) {
+			return true
+		}
+	}
+	for _, y := range e {
+		if isSpecialHBAToken(y) || hasHBAToken(a, y) {
+			return true
+		}
+	}
+	return false
+}
+
+func overlapsHBAAddress(a, e HBAEntry) bool {
+	if a.Type == HBAType_Local || a.Address == "all" || e.Address == "all" {
+		return true
+	}
+	if a.ipnet != nil && e.ipnet != nil {
+		return a.ipnet.Contains(e.ipnet.IP) || e.ipnet.Contains(a.ipnet.IP)
+	}
+	return true
+}
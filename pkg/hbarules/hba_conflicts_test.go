@@ -0,0 +1,144 @@
+package hbarules
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/klouddb/klouddbshield/model"
+)
+
+func conflictLines(conflicts []HBARuleConflict) [][2]int {
+	var out [][2]int
+	for _, c := range conflicts {
+		out = append(out, [2]int{c.Entry.LineNo, c.By.LineNo})
+	}
+	return out
+}
+
+func Test_HBARuleConflicts(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		shadowed    [][2]int
+		hostSSL     [][2]int
+		lateReject  [][2]int
+		overlapping [][2]int
+	}{
+		{
+			name: "broad line shadows later lines",
+			content: `host    all          all      10.0.0.0/8      scram-sha-256
+host    sales        bob      10.1.0.0/16     scram-sha-256
+host    replication  repl     10.1.0.0/16     scram-sha-256
+host    "all"        bob      10.1.2.3/32     md5
+local   all          all                      peer
+local   sales        bob                      md5
+`,
+			shadowed: [][2]int{{2, 1}, {4, 1}, {6, 5}},
+		},
+		{
+			name: "hostssl after host",
+			content: `host    sales  all        192.168.0.0/16  md5
+hostssl sales  app        192.168.1.0/24  cert
+hostssl all    app        192.168.1.0/24  cert
+host    all    +admins    0.0.0.0/0       md5
+hostssl all    +admins    0.0.0.0/0       cert
+`,
+			hostSSL:     [][2]int{{2, 1}, {5, 4}},
+			overlapping: [][2]int{{3, 1}, {4, 2}, {5, 1}},
+		},
+		{
+			name: "reject after permissive",
+			content: `host    all  all   10.0.0.0/8       md5
+host    all  all   10.9.9.9/32      reject
+host    all  bob   172.16.0.0/12    reject
+host    all  all   172.16.0.0/12    md5
+host    replication  all  0.0.0.0/0  reject
+`,
+			lateReject: [][2]int{{2, 1}},
+		},
+		{
+			name: "catch-all reject at the end",
+			content: `hostssl all  app   10.0.0.0/8       scram-sha-256
+host    all  all   192.168.0.0/16   scram-sha-256
+host    all  all   0.0.0.0/0        reject
+host    all  all   ::/0             reject
+`,
+		},
+		{
+			name: "overlapping cidrs with different methods",
+			content: `host    all  all   10.1.0.0/16      trust
+host    all  all   10.0.0.0/8       scram-sha-256
+host    all  all   192.168.0.0/24   md5
+host    all  all   192.168.1.0/24   scram-sha-256
+hostssl all  all   10.0.0.0/8       cert
+host    db1  all   172.16.0.0/12    md5
+host    db2  all   172.16.1.0/24    trust
+`,
+			hostSSL:     [][2]int{{5, 2}},
+			overlapping: [][2]int{{2, 1}, {5, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "pg_hba.conf")
+			if err := os.WriteFile(file, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			conf, err := ParseHBAConf(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(conf.Errors) != 0 {
+				t.Fatalf("ParseHBAConf() errors = %v", conf.Errors)
+			}
+
+			if got := conflictLines(ShadowedHBARules(conf.Entries)); !reflect.DeepEqual(got, tt.shadowed) {
+				t.Errorf("ShadowedHBARules() = %v, want %v", got, tt.shadowed)
+			}
+			if got := conflictLines(ShadowedHostSSLRules(conf.Entries)); !reflect.DeepEqual(got, tt.hostSSL) {
+				t.Errorf("ShadowedHostSSLRules() = %v, want %v", got, tt.hostSSL)
+			}
+			if got := conflictLines(LateRejectHBARules(conf.Entries)); !reflect.DeepEqual(got, tt.lateReject) {
+				t.Errorf("LateRejectHBARules() = %v, want %v", got, tt.lateReject)
+			}
+			if got := conflictLines(OverlappingHBARules(conf.Entries)); !reflect.DeepEqual(got, tt.overlapping) {
+				t.Errorf("OverlappingHBARules() = %v, want %v", got, tt.overlapping)
+			}
+		})
+	}
+}
+
+func Test_HBAEntriesFromRules(t *testing.T) {
+	// rules of pg_hba_file_rules, database and user_name without braces
+	rules := []model.HBAFIleRules{
+		{LineNumber: 1, Type: "local", Database: "all", UserName: "postgres", Method: "peer"},
+		{LineNumber: 2, Type: "host", Database: "all", UserName: "all", Address: "10.0.0.0", NetMask: "255.0.0.0", Method: "scram-sha-256"},
+		{LineNumber: 3, Type: "hostssl", Database: `sales,"my db"`, UserName: "app", Address: "10.1.0.0", NetMask: "255.255.0.0", Method: "cert clientcert=verify-full"},
+		{LineNumber: 4, Type: "host", Database: "all", UserName: "all", Address: "10.9.9.9", NetMask: "255.255.255.255", Method: "reject"},
+		{LineNumber: 5, Type: "host", Database: "all", UserName: "all", Address: ".example.com", Method: "md5"},
+	}
+
+	entries := HBAEntriesFromRules("/etc/pg_hba.conf", rules)
+	if len(entries) != len(rules) {
+		t.Fatalf("HBAEntriesFromRules() returned %d entries, want %d", len(entries), len(rules))
+	}
+	if got := joinHBATokens(entries[2].Databases); got != "sales,my db" {
+		t.Errorf("databases = %s, want sales,my db", got)
+	}
+	if entries[2].Method != "cert" || !reflect.DeepEqual(entries[2].Options, []string{"clientcert=verify-full"}) {
+		t.Errorf("method = %s %v, want cert with clientcert option", entries[2].Method, entries[2].Options)
+	}
+	if !entries[4].IsHostname() || entries[1].IsHostname() {
+		t.Errorf("IsHostname() of host name and ip lines = %v, %v", entries[4].IsHostname(), entries[1].IsHostname())
+	}
+
+	if got := conflictLines(ShadowedHostSSLRules(entries)); !reflect.DeepEqual(got, [][2]int{{3, 2}}) {
+		t.Errorf("ShadowedHostSSLRules() = %v, want [[3 2]]", got)
+	}
+	if got := conflictLines(LateRejectHBARules(entries)); !reflect.DeepEqual(got, [][2]int{{4, 2}}) {
+		t.Errorf("LateRejectHBARules() = %v, want [[4 2]]", got)
+	}
+}
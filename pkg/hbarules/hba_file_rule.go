@@ -1,6 +1,10 @@
 package hbarules
 
-import "sort"
+import (
+	"sort"
+
+	"github.com/klouddb/klouddbshield/model"
+)
 
 /*
 	HBA file rule will be like a map
@@ -21,6 +25,7 @@ type HBARawLine struct {
 type HbaRuleValidator interface {
 	ValidateEntry(database, username, address string)
 	GetUnusedLines() []HBARawLine
+	GetLineUsage() []HBALineUsage
 }
 
 type hbaFileRule struct {
@@ -78,6 +83,7 @@ func (h *hbaFileRule) ValidateEntry(database, username, address string) {
 	for _, line := range lines {
 		if line.addressParser.IsValid(address) {
 			line.FoundMatchingEntry()
+			line.tuples[HBATuple{Database: database, User: username, Address: address}]++
 			return
 		}
 	}
@@ -118,12 +124,18 @@ type hbaLine struct {
 	lineNumber         int
 	addressParser      AddressValidator
 	matchingEntrycount int
+
+	// rule is the line as in hba file, for suggesting the tightened line.
+	rule model.HBAFIleRules
+	// tuples are the connections matched with the line.
+	tuples map[HBATuple]int
 }
 
 func NewHBALine(lineNumber int, addressParser AddressValidator) *hbaLine {
 	return &hbaLine{
 		lineNumber:    lineNumber,
 		addressParser: addressParser,
+		tuples:        map[HBATuple]int{},
 	}
 }
 
@@ -1,17 +1,15 @@
 package hbarules
 
 import (
-	"bufio"
 	"context"
 	"database/sql"
 	"fmt"
-	"os"
-	"path/filepath"
 	"regexp"
 	"strings"
 
 	"github.com/klouddb/klouddbshield/model"
 	"github.com/klouddb/klouddbshield/pkg/utils"
+	"github.com/rs/zerolog/log"
 )
 
 var addressMapping = map[string]string{
@@ -20,137 +18,75 @@ var addressMapping = map[string]string{
 }
 
 // ScanHBAFile will scan the hba file from filepath and return []model.HBAFIleRules
+// of its entries and the entries of its included files, in the order they are
+// checked by postgres. Local lines are returned with empty address like in
+// pg_hba_file_rules. Lines which can not be parsed are skipped with a warning.
 func ScanHBAFile(ctx context.Context, store *sql.DB, hbaFilePath string) ([]model.HBAFIleRules, error) {
-	file, err := os.Open(hbaFilePath)
+	conf, err := ParseHBAConf(hbaFilePath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	var rules []model.HBAFIleRules
-	scanner := bufio.NewScanner(file)
-
-	// Regular expression to match HBA rule lines
-	re := regexp.MustCompile(`^\s*(host|hostssl|hostnossl|hostgssenc|hostnogssenc)\s+(\S+)\s+(\S+)\s+(\S+)?\s+([\d.:]+)?\s+(.+)\s*$`)
-
-	// Read the file line by line and extract the HBA rules
-	lineNumber := 0
-	for scanner.Scan() {
-		lineNumber++
-		line := scanner.Text()
-		line = strings.TrimSpace(line)
-
-		if strings.HasPrefix(line, "#") || len(line) == 0 || strings.HasPrefix(line, "local") {
-			// ignore comments
-			continue
-		}
+	for _, e := range conf.Errors {
+		log.Warn().Str("file", e.File).Int("line", e.LineNo).Str("err", e.Err).Msgf("Skipping hba line: %s", e.Line)
+	}
 
-		rule, err := ParseHBALine(ctx, store, re, lineNumber, line, hbaFilePath)
+	rules := make([]model.HBAFIleRules, 0, len(conf.Entries))
+	for _, e := range conf.Entries {
+		username, err := expandRoles(ctx, store, e.Users)
 		if err != nil {
-			fmt.Printf("WARN: Skipping this line (%v) at linenumber %d, because we got error while parsing (%v) \n", line, lineNumber, err)
+			log.Warn().Str("file", e.File).Int("line", e.LineNo).Err(err).Msgf("Skipping hba line: %s", e.Raw)
 			continue
 		}
 
-		rules = append(rules, *rule)
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
+		rules = append(rules, model.HBAFIleRules{
+			LineNumber: e.LineNo,
+			Type:       e.Type,
+			Database:   hbaTokenValues(e.Databases),
+			UserName:   username,
+			Address:    e.Address,
+			NetMask:    e.NetMask,
+			Method:     strings.Join(append([]string{e.Method}, e.Options...), " "),
+			Raw:        e.Raw,
+		})
 	}
 
 	return rules, nil
-
 }
 
-func ParseHBALine(ctx context.Context, store *sql.DB, re *regexp.Regexp, lineNumber int, line, hbaFilePath string) (*model.HBAFIleRules, error) {
-	match := re.FindStringSubmatch(line)
-	if len(match) == 0 {
-		// ignore invalid lines
-		return nil, fmt.Errorf("invalid line")
+func hbaTokenValues(tokens []HBAToken) string {
+	names := make([]string, len(tokens))
+	for i, t := range tokens {
+		names[i] = t.Value
 	}
-
-	username, err := updateStringWithFilenameOrRolename(ctx, store, match[3], hbaFilePath)
-	if err != nil {
-		return nil, err
-	}
-
-	db, err := updateStringWithFilenameOrRolename(ctx, store, match[2], hbaFilePath)
-	if err != nil {
-		return nil, err
-	}
-
-	return &model.HBAFIleRules{
-		LineNumber: lineNumber,
-		Database:   db,
-		UserName:   username,
-		Address:    match[4],
-		NetMask:    match[5],
-		Raw:        line,
-	}, nil
+	return strings.Join(names, ",")
 }
 
-func updateStringWithFilenameOrRolename(ctx context.Context, store *sql.DB, str, hbaFilePath string) (string, error) {
-	list := strings.Split(str, ",")
-
-	var updatedList []string
-
-	for _, item := range list {
-		if item == "" {
+// expandRoles returns the comma separated users, +role users are replaced
+// with the role and its members. @file users are already expanded by
+// ParseHBAConf.
+func expandRoles(ctx context.Context, store *sql.DB, tokens []HBAToken) (string, error) {
+	names := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		if !t.IsGroup() {
+			names = append(names, t.Value)
 			continue
 		}
 
-		switch item[0] {
-		case '@':
-			filename := strings.TrimPrefix(item, "@")
-			usersFromFile, err := loadListFile(filepath.Join(filepath.Dir(hbaFilePath), filename))
-			if err != nil {
-				return "", fmt.Errorf("error while reading users file (%v): err = (%v)", filename, err)
-			}
-
-			updatedList = append(updatedList, usersFromFile...)
-		case '+':
-			if store == nil {
-				return "", fmt.Errorf("there is role in hba file, to get users from role we need database connection.")
-			}
-			roleName := strings.TrimPrefix(item, "+")
-			users, err := utils.GetUserForGivenRole(ctx, store, roleName)
-			if err != nil {
-				return "", fmt.Errorf("error while fetching users for role (%v): err = (%v)", roleName, err)
-			}
-
-			updatedList = append(updatedList, roleName)
-			updatedList = append(updatedList, users...)
-		default:
-			updatedList = append(updatedList, item)
+		if store == nil {
+			return "", fmt.Errorf("there is role in hba file, to get users from role we need database connection.")
 		}
-
-	}
-
-	return strings.Join(updatedList, ","), nil
-}
-
-func loadListFile(filename string) ([]string, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	var list []string
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		line = strings.TrimSpace(line)
-
-		if line == "" {
-			continue
+		roleName := strings.TrimPrefix(t.Value, "+")
+		members, err := utils.GetUserForGivenRole(ctx, store, roleName)
+		if err != nil {
+			return "", fmt.Errorf("error while fetching users for role (%v): err = (%v)", roleName, err)
 		}
 
-		list = append(list, line)
+		names = append(names, roleName)
+		names = append(names, members...)
 	}
 
-	return list, scanner.Err()
+	return strings.Join(names, ","), nil
 }
 
 // ParseHBAFileRules will parse the hba file rules and return the HbaRuleValidator
@@ -164,6 +100,7 @@ func ParseHBAFileRules(rules []model.HBAFIleRules) (*hbaFileRule, error) {
 			continue
 		}
 
+		rule := r
 		if v, ok := addressMapping[r.Address]; ok {
 			r.Address = v
 			r.NetMask = ""
@@ -192,6 +129,7 @@ func ParseHBAFileRules(rules []model.HBAFIleRules) (*hbaFileRule, error) {
 			addressValidator = NewHostAddressValidator(r.Address)
 		}
 		hbaLine := NewHBALine(r.LineNumber, addressValidator)
+		hbaLine.rule = rule
 
 		hbaFileRule.lineMap[r.LineNumber] = r.Raw
 
@@ -0,0 +1,248 @@
+package hbarules
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// HBAConnection is the connection checked against the hba lines.
+type HBAConnection struct {
+	// Type is local or host.
+	Type     string
+	Database string
+	User     string
+	Address  string
+	SSL      bool
+	GSSEnc   bool
+	// Replication is a physical replication connection, database is not
+	// used for it.
+	Replication bool
+
+	// Roles are the roles the user is member of, directly or indirectly.
+	// The user is always a member of itself.
+	Roles []string
+	// Hostnames are the names of the address by reverse lookup, they are
+	// used for the lines with host names.
+	Hostnames []string
+}
+
+func (c HBAConnection) isMember(role string) bool {
+	if role == c.User {
+		return true
+	}
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HBALineCheck is the result of a line for the connection.
+type HBALineCheck struct {
+	Entry   HBAEntry
+	Matched bool
+	Reason  string
+}
+
+// HBASimulation is the result of checking the connection against the hba
+// lines, postgres uses the first matching line.
+type HBASimulation struct {
+	Connection HBAConnection
+	// Checks are the lines till the matching line.
+	Checks []HBALineCheck
+	// Match is nil when no line matches, the connection is rejected then.
+	Match *HBAEntry
+}
+
+// Rejected returns true when the connection is rejected, by a reject line
+// or no line matching it.
+func (s *HBASimulation) Rejected() bool {
+	return s.Match == nil || s.Match.Method == "reject"
+}
+
+// SimulateHBA checks the connection against the lines in order, like
+// postgres does for a new connection.
+func SimulateHBA(entries []HBAEntry, conn HBAConnection) *HBASimulation {
+	s := &HBASimulation{Connection: conn}
+	for i := range entries {
+		matched, reason := matchHBAEntry(entries[i], conn)
+		s.Checks = append(s.Checks, HBALineCheck{Entry: entries[i], Matched: matched, Reason: reason})
+		if matched {
+			s.Match = &entries[i]
+			break
+		}
+	}
+	return s
+}
+
+// matchHBAEntry returns whether the line matches the connection, with the
+// reason of the first field not matching or the fields matching.
+func matchHBAEntry(e HBAEntry, conn HBAConnection) (bool, string) {
+	if ok, reason := matchHBAType(e.Type, conn); !ok {
+		return false, reason
+	}
+
+	var reasons []string
+	if e.Type != HBAType_Local {
+		ok, reason := matchHBAAddress(e, conn)
+		if !ok {
+			return false, reason
+		}
+		reasons = append(reasons, reason)
+	}
+
+	ok, reason := matchHBADatabase(e.Databases, conn)
+	if !ok {
+		return false, reason
+	}
+	reasons = append(reasons, reason)
+
+	ok, reason = matchHBAUser(e.Users, conn)
+	if !ok {
+		return false, reason
+	}
+	reasons = append(reasons, reason)
+
+	return true, strings.Join(reasons, ", ")
+}
+
+func matchHBAType(t string, conn HBAConnection) (bool, string) {
+	if conn.Type == HBAType_Local {
+		if t != HBAType_Local {
+			return false, "line is for tcp connections, connection is local"
+		}
+		return true, ""
+	}
+
+	switch t {
+	case HBAType_Local:
+		return false, "line is for local connections, connection is tcp"
+	case HBAType_HostSSL:
+		if !conn.SSL {
+			return false, "line requires ssl, connection is not ssl"
+		}
+	case HBAType_HostNoSSL:
+		if conn.SSL {
+			return false, "line is for non ssl connections, connection is ssl"
+		}
+	case HBAType_HostGSSEnc:
+		if !conn.GSSEnc {
+			return false, "line requires gssapi encryption, connection is not gssapi encrypted"
+		}
+	case HBAType_HostNoGSSEnc:
+		if conn.GSSEnc {
+			return false, "line is for non gssapi encrypted connections, connection is gssapi encrypted"
+		}
+	}
+	return true, ""
+}
+
+func matchHBAAddress(e HBAEntry, conn HBAConnection) (bool, string) {
+	switch e.Address {
+	case "all":
+		return true, "address " + conn.Address + " matches all"
+	case "samehost", "samenet":
+		// server addresses are not known to the simulator
+		return false, e.Address + " needs the addresses of the server, it is not simulated"
+	}
+
+	if e.ipnet != nil {
+		ip := net.ParseIP(conn.Address)
+		if ip == nil {
+			return false, fmt.Sprintf("address %s is not an ip address", conn.Address)
+		}
+		if (ip.To4() != nil) != (e.ipnet.IP.To4() != nil) {
+			return false, fmt.Sprintf("address %s is not of the family of %s", conn.Address, e.ipnet)
+		}
+		if !e.ipnet.Contains(ip) {
+			return false, fmt.Sprintf("address %s is not in %s", conn.Address, e.ipnet)
+		}
+		return true, fmt.Sprintf("address %s is in %s", conn.Address, e.ipnet)
+	}
+
+	names := append([]string{}, conn.Hostnames...)
+	if net.ParseIP(conn.Address) == nil {
+		names = append(names, conn.Address)
+	}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		host := strings.ToLower(e.Address)
+		if name == host || (strings.HasPrefix(host, ".") && strings.HasSuffix(name, host)) {
+			return true, fmt.Sprintf("host name %s matches %s", name, e.Address)
+		}
+	}
+	if len(names) == 0 {
+		return false, fmt.Sprintf("address %s has no host name to match %s", conn.Address, e.Address)
+	}
+	return false, fmt.Sprintf("host names %s do not match %s", strings.Join(names, ","), e.Address)
+}
+
+func matchHBADatabase(tokens []HBAToken, conn HBAConnection) (bool, string) {
+	for _, t := range tokens {
+		if conn.Replication {
+			// physical replication connections match only replication
+			// keyword
+			if t.IsKeyword("replication") {
+				return true, "replication connection matches replication"
+			}
+			continue
+		}
+
+		switch {
+		case t.IsKeyword("all"):
+			return true, "database " + conn.Database + " matches all"
+		case t.IsKeyword("sameuser"):
+			if conn.Database == conn.User {
+				return true, "database " + conn.Database + " matches sameuser"
+			}
+		case t.IsKeyword("samerole"), t.IsKeyword("samegroup"):
+			if conn.isMember(conn.Database) {
+				return true, "user " + conn.User + " is member of role " + conn.Database + " for " + t.Value
+			}
+		case t.IsKeyword("replication"):
+			// only physical replication connections match it
+		case t.IsRegex():
+			if t.regex.MatchString(conn.Database) {
+				return true, "database " + conn.Database + " matches regular expression " + t.Value
+			}
+		case t.Value == conn.Database:
+			return true, "database " + conn.Database + " matches " + t.String()
+		}
+	}
+
+	if conn.Replication {
+		return false, "replication connection needs replication in database field"
+	}
+	return false, "database " + conn.Database + " is not in " + joinHBATokens(tokens)
+}
+
+func matchHBAUser(tokens []HBAToken, conn HBAConnection) (bool, string) {
+	for _, t := range tokens {
+		switch {
+		case t.IsKeyword("all"):
+			return true, "user " + conn.User + " matches all"
+		case t.IsGroup():
+			if conn.isMember(t.Value[1:]) {
+				return true, "user " + conn.User + " is member of " + t.Value
+			}
+		case t.IsRegex():
+			if t.regex.MatchString(conn.User) {
+				return true, "user " + conn.User + " matches regular expression " + t.Value
+			}
+		case t.Value == conn.User:
+			return true, "user " + conn.User + " matches " + t.String()
+		}
+	}
+
+	return false, "user " + conn.User + " is not in " + joinHBATokens(tokens)
+}
+
+func joinHBATokens(tokens []HBAToken) string {
+	values := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		values = append(values, t.String())
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,110 @@
+package hbarules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_SimulateHBA(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "pg_hba.conf")
+	content := `local   all          postgres                     peer
+host    "all"        all        10.0.0.0/8        md5
+host    sameuser     all        10.0.0.0/8        scram-sha-256
+host    samerole     all        10.0.0.0/8        scram-sha-256
+host    replication  repl       10.0.0.0/8        scram-sha-256
+hostssl /^sales_     +analysts  10.0.0.0/8        cert
+host    all          all        .example.com      md5
+host    all          all        192.168.0.0/16    reject
+host    all          all        all               scram-sha-256
+`
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := ParseHBAConf(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Errors) != 0 {
+		t.Fatalf("ParseHBAConf() errors = %v", conf.Errors)
+	}
+
+	tests := []struct {
+		name     string
+		conn     HBAConnection
+		line     int
+		rejected bool
+	}{
+		{
+			name: "local connection",
+			conn: HBAConnection{Type: "local", Database: "app", User: "postgres"},
+			line: 1,
+		},
+		{
+			name: "quoted all is a database name",
+			conn: HBAConnection{Type: "host", Database: "all", User: "bob", Address: "10.1.2.3"},
+			line: 2,
+		},
+		{
+			name: "sameuser",
+			conn: HBAConnection{Type: "host", Database: "bob", User: "bob", Address: "10.1.2.3"},
+			line: 3,
+		},
+		{
+			name: "samerole with role membership",
+			conn: HBAConnection{Type: "host", Database: "finance", User: "bob", Address: "10.1.2.3", Roles: []string{"finance"}},
+			line: 4,
+		},
+		{
+			name: "physical replication matches only replication keyword",
+			conn: HBAConnection{Type: "host", Database: "postgres", User: "repl", Address: "10.1.2.3", Replication: true},
+			line: 5,
+		},
+		{
+			name: "ssl line with regex database and group user",
+			conn: HBAConnection{Type: "host", Database: "sales_eu", User: "amy", Address: "10.1.2.3", SSL: true, Roles: []string{"analysts"}},
+			line: 6,
+		},
+		{
+			name: "ssl line is skipped without ssl",
+			conn: HBAConnection{Type: "host", Database: "sales_eu", User: "amy", Address: "10.1.2.3", Roles: []string{"analysts"}},
+			line: 9,
+		},
+		{
+			name: "host name of the address",
+			conn: HBAConnection{Type: "host", Database: "app", User: "amy", Address: "172.16.0.1", Hostnames: []string{"db1.example.com."}},
+			line: 7,
+		},
+		{
+			name:     "reject line",
+			conn:     HBAConnection{Type: "host", Database: "app", User: "amy", Address: "192.168.1.1"},
+			line:     8,
+			rejected: true,
+		},
+		{
+			name:     "no line for replication from outside",
+			conn:     HBAConnection{Type: "host", User: "repl", Address: "172.16.0.1", Replication: true},
+			rejected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SimulateHBA(conf.Entries, tt.conn)
+
+			line := 0
+			if got.Match != nil {
+				line = got.Match.LineNo
+			}
+			if line != tt.line || got.Rejected() != tt.rejected {
+				t.Errorf("SimulateHBA() matched line %d rejected %v, want line %d rejected %v", line, got.Rejected(), tt.line, tt.rejected)
+			}
+			for _, c := range got.Checks {
+				if c.Reason == "" && c.Entry.Type != HBAType_Local {
+					t.Errorf("line %d has no reason", c.Entry.LineNo)
+				}
+			}
+		})
+	}
+}
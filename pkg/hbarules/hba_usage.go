@@ -0,0 +1,313 @@
+package hbarules
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/klouddb/klouddbshield/model"
+)
+
+// HBATuple is a connection observed in the logs.
+type HBATuple struct {
+	Database string
+	User     string
+	Address  string
+}
+
+type HBATupleCount struct {
+	HBATuple
+	Count int
+}
+
+// HBALineUsage is the hit count of a line of hba file with the connections
+// matched with it.
+type HBALineUsage struct {
+	HBARawLine
+	Rule model.HBAFIleRules
+	Hits int
+	// Tuples are sorted by count, most used first.
+	Tuples []HBATupleCount
+}
+
+// GetLineUsage returns the usage of all the lines in the order of line
+// number.
+func (h *hbaFileRule) GetLineUsage() []HBALineUsage {
+	lines := map[int]*hbaLine{}
+	for _, db := range h.m {
+		for _, user := range db {
+			for _, line := range user {
+				lines[line.lineNumber] = line
+			}
+		}
+	}
+
+	out := make([]HBALineUsage, 0, len(lines))
+	for lineNo, line := range lines {
+		usage := HBALineUsage{
+			HBARawLine: HBARawLine{LineNo: lineNo, Line: h.lineMap[lineNo]},
+			Rule:       line.rule,
+			Hits:       line.matchingEntrycount,
+		}
+		for tuple, count := range line.tuples {
+			usage.Tuples = append(usage.Tuples, HBATupleCount{HBATuple: tuple, Count: count})
+		}
+		sort.Slice(usage.Tuples, func(i, j int) bool {
+			a, b := usage.Tuples[i], usage.Tuples[j]
+			if a.Count != b.Count {
+				return a.Count > b.Count
+			}
+			return a.HBATuple.String() < b.HBATuple.String()
+		})
+		out = append(out, usage)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].LineNo < out[j].LineNo
+	})
+	return out
+}
+
+func (t HBATuple) String() string {
+	return t.Database + "/" + t.User + "@" + t.Address
+}
+
+// HBASuggestion is the replacement of a broad or unused line of hba file.
+type HBASuggestion struct {
+	LineNo   int
+	Original string
+	// Lines are the lines replacing the original line, they are commented
+	// out when the line is not used.
+	Lines  []string
+	Reason string
+	Unused bool
+}
+
+// SuggestHBALines suggests the replacement of the lines with all database,
+// all user or any address with the databases, users and addresses observed
+// in the logs. Unused lines are suggested to be commented out, reject lines
+// are kept as is.
+func SuggestHBALines(usage []HBALineUsage) []HBASuggestion {
+	var out []HBASuggestion
+	for _, u := range usage {
+		if u.Rule.Method == "" || strings.HasPrefix(u.Rule.Method, "reject") {
+			continue
+		}
+
+		rule := ruleAsInFile(u.Rule)
+		if u.Hits == 0 {
+			out = append(out, HBASuggestion{
+				LineNo:   u.LineNo,
+				Original: u.Line,
+				Lines:    []string{"# " + formatRule(rule)},
+				Reason:   "not used in logs",
+				Unused:   true,
+			})
+			continue
+		}
+
+		broadDB := isAll(u.Rule.Database)
+		broadUser := isAll(u.Rule.UserName)
+		broadAddress := isBroadAddress(u.Rule.Address, u.Rule.NetMask)
+		if !broadDB && !broadUser && !broadAddress {
+			continue
+		}
+
+		var reasons []string
+		if broadDB {
+			reasons = append(reasons, "databases")
+		}
+		if broadUser {
+			reasons = append(reasons, "users")
+		}
+		if broadAddress {
+			reasons = append(reasons, "addresses")
+		}
+
+		// observed values replace only the broad fields of the line
+		tuples := map[HBATuple]bool{}
+		for _, t := range u.Tuples {
+			if !broadDB {
+				t.Database = ""
+			}
+			if !broadUser {
+				t.User = ""
+			}
+			if !broadAddress {
+				t.Address = ""
+			}
+			tuples[t.HBATuple] = true
+		}
+
+		var lines []string
+		for _, g := range groupTuples(tuples) {
+			r := rule
+			if broadDB {
+				r.Database = g.databases
+			}
+			if broadUser {
+				r.UserName = g.users
+			}
+			if broadAddress {
+				r.Address, r.NetMask = addressCIDR(g.address), ""
+			}
+			lines = append(lines, formatRule(r))
+		}
+
+		out = append(out, HBASuggestion{
+			LineNo:   u.LineNo,
+			Original: u.Line,
+			Lines:    lines,
+			Reason:   "replace all " + strings.Join(reasons, ", ") + " with the ones observed in logs",
+		})
+	}
+
+	return out
+}
+
+type tupleGroup struct {
+	databases string
+	users     string
+	address   string
+}
+
+// groupTuples groups the tuples into the lines of hba file, all the
+// combinations of the databases and users of a group are observed from its
+// address. Tuples are grouped by user and address first, then the users with
+// the same databases are grouped by address.
+func groupTuples(tuples map[HBATuple]bool) []tupleGroup {
+	type userAddress struct {
+		user, address string
+	}
+	databases := map[userAddress][]string{}
+	for t := range tuples {
+		key := userAddress{t.User, t.Address}
+		databases[key] = append(databases[key], t.Database)
+	}
+
+	type databasesAddress struct {
+		databases, address string
+	}
+	users := map[databasesAddress][]string{}
+	for key, dbs := range databases {
+		sort.Strings(dbs)
+		group := databasesAddress{strings.Join(dbs, ","), key.address}
+		users[group] = append(users[group], key.user)
+	}
+
+	out := make([]tupleGroup, 0, len(users))
+	for key, u := range users {
+		sort.Strings(u)
+		out = append(out, tupleGroup{databases: key.databases, users: strings.Join(u, ","), address: key.address})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.address != b.address {
+			return a.address < b.address
+		}
+		if a.users != b.users {
+			return a.users < b.users
+		}
+		return a.databases < b.databases
+	})
+	return out
+}
+
+func isAll(list string) bool {
+	for _, item := range strings.Split(list, ",") {
+		if item == "all" {
+			return true
+		}
+	}
+	return false
+}
+
+func isBroadAddress(address, netmask string) bool {
+	if address == "all" {
+		return true
+	}
+
+	// masks of ipv4 are parsed in ipv6 form, so only the zero mask is checked
+	if netmask != "" {
+		mask := net.ParseIP(netmask)
+		return net.ParseIP(address) != nil && mask != nil && mask.IsUnspecified()
+	}
+
+	_, ipnet, err := net.ParseCIDR(address)
+	if err != nil {
+		return false
+	}
+	ones, _ := ipnet.Mask.Size()
+	return ones == 0
+}
+
+// addressCIDR returns the single host CIDR of the IP address, host names are
+// returned as is.
+func addressCIDR(address string) string {
+	ip := net.ParseIP(address)
+	switch {
+	case ip == nil:
+		return address
+	case ip.To4() != nil:
+		return ip.String() + "/32"
+	default:
+		return ip.String() + "/128"
+	}
+}
+
+// ruleAsInFile returns the rule with the database and user as written in hba
+// file, like +role and @file, instead of the expanded lists.
+func ruleAsInFile(r model.HBAFIleRules) model.HBAFIleRules {
+	if f := strings.Fields(r.Raw); len(f) > 3 && f[0] == r.Type {
+		r.Database, r.UserName = f[1], f[2]
+	}
+	return r
+}
+
+func formatRule(r model.HBAFIleRules) string {
+	address := r.Address
+	if r.NetMask != "" {
+		address += " " + r.NetMask
+	}
+	return strings.TrimSpace(fmt.Sprintf("%-7s %-15s %-15s %-23s %s", r.Type, r.Database, r.UserName, address, r.Method))
+}
+
+// TightenedHBAFile returns the hba file with the suggestions applied, the
+// original line is kept as a comment above its replacement. content is the
+// hba file, the lines are built from the usage when it is empty, like for the
+// rules of pg_hba_file_rules.
+func TightenedHBAFile(content string, usage []HBALineUsage, suggestions []HBASuggestion) string {
+	byLine := map[int]HBASuggestion{}
+	for _, s := range suggestions {
+		byLine[s.LineNo] = s
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("# pg_hba.conf tightened with the connections observed in logs, please review before using.\n")
+
+	write := func(lineNo int, line string) {
+		s, ok := byLine[lineNo]
+		if !ok {
+			sb.WriteString(line + "\n")
+			return
+		}
+		fmt.Fprintf(&sb, "# line %d, %s: %s\n", lineNo, s.Reason, s.Original)
+		for _, l := range s.Lines {
+			sb.WriteString(l + "\n")
+		}
+	}
+
+	if content == "" {
+		sb.WriteString("# local lines are not listed, please keep them from the current pg_hba.conf.\n")
+		for _, u := range usage {
+			write(u.LineNo, formatRule(ruleAsInFile(u.Rule)))
+		}
+		return sb.String()
+	}
+
+	for i, line := range strings.Split(strings.TrimRight(content, "\n"), "\n") {
+		write(i+1, line)
+	}
+	return sb.String()
+}
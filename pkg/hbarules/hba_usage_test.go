@@ -0,0 +1,166 @@
+package hbarules
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/klouddb/klouddbshield/model"
+)
+
+func Test_SuggestHBALines(t *testing.T) {
+	rules := []model.HBAFIleRules{
+		{LineNumber: 1, Type: "host", Database: "postgres", UserName: "admin", Address: "10.0.0.0/8", Method: "md5",
+			Raw: "host postgres admin 10.0.0.0/8 md5"},
+		{LineNumber: 2, Type: "hostssl", Database: "sales", UserName: "all", Address: "all", Method: "cert",
+			Raw: "hostssl sales all all cert"},
+		{LineNumber: 3, Type: "host", Database: "all", UserName: "all", Address: "0.0.0.0/0", Method: "scram-sha-256",
+			Raw: "host all all 0.0.0.0/0 scram-sha-256"},
+		{LineNumber: 4, Type: "host", Database: "all", UserName: "all", Address: "::/0", Method: "reject",
+			Raw: "host all all ::/0 reject"},
+	}
+
+	tests := []struct {
+		name    string
+		entries []HBATuple
+		hits    map[int]int
+		want    []HBASuggestion
+	}{
+		{
+			name: "broad lines are replaced with observed connections",
+			entries: []HBATuple{
+				{Database: "app", User: "web", Address: "10.1.0.5"},
+				{Database: "app", User: "web", Address: "10.1.0.5"},
+				{Database: "reports", User: "web", Address: "10.1.0.5"},
+				{Database: "app", User: "api", Address: "10.1.0.5"},
+				{Database: "reports", User: "api", Address: "10.1.0.5"},
+				{Database: "app", User: "batch", Address: "192.168.1.7"},
+				{Database: "sales", User: "bob", Address: "172.16.0.2"},
+				{Database: "sales", User: "amy", Address: "172.16.0.3"},
+			},
+			hits: map[int]int{1: 0, 2: 2, 3: 6, 4: 0},
+			want: []HBASuggestion{
+				{
+					LineNo:   1,
+					Original: "host postgres admin 10.0.0.0/8 md5",
+					Lines:    []string{"# host    postgres        admin           10.0.0.0/8              md5"},
+					Reason:   "not used in logs",
+					Unused:   true,
+				},
+				{
+					LineNo:   2,
+					Original: "hostssl sales all all cert",
+					Lines: []string{
+						"hostssl sales           bob             172.16.0.2/32           cert",
+						"hostssl sales           amy             172.16.0.3/32           cert",
+					},
+					Reason: "replace all users, addresses with the ones observed in logs",
+				},
+				{
+					LineNo:   3,
+					Original: "host all all 0.0.0.0/0 scram-sha-256",
+					Lines: []string{
+						"host    app,reports     api,web         10.1.0.5/32             scram-sha-256",
+						"host    app             batch           192.168.1.7/32          scram-sha-256",
+					},
+					Reason: "replace all databases, users, addresses with the ones observed in logs",
+				},
+			},
+		},
+		{
+			name:    "unused lines are commented out",
+			entries: []HBATuple{{Database: "postgres", User: "admin", Address: "10.2.3.4"}},
+			hits:    map[int]int{1: 1, 2: 0, 3: 0, 4: 0},
+			want: []HBASuggestion{
+				{
+					LineNo:   2,
+					Original: "hostssl sales all all cert",
+					Lines:    []string{"# hostssl sales           all             all                     cert"},
+					Reason:   "not used in logs",
+					Unused:   true,
+				},
+				{
+					LineNo:   3,
+					Original: "host all all 0.0.0.0/0 scram-sha-256",
+					Lines:    []string{"# host    all             all             0.0.0.0/0               scram-sha-256"},
+					Reason:   "not used in logs",
+					Unused:   true,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := ParseHBAFileRules(rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range tt.entries {
+				h.ValidateEntry(e.Database, e.User, e.Address)
+			}
+
+			usage := h.GetLineUsage()
+			for _, u := range usage {
+				if u.Hits != tt.hits[u.LineNo] {
+					t.Errorf("line %d hits = %d, want %d", u.LineNo, u.Hits, tt.hits[u.LineNo])
+				}
+			}
+
+			got := SuggestHBALines(usage)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SuggestHBALines() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_TightenedHBAFile(t *testing.T) {
+	content := "# TYPE DATABASE USER ADDRESS METHOD\nlocal all all peer\nhost all all 0.0.0.0/0 md5\n"
+	rules := []model.HBAFIleRules{
+		{LineNumber: 3, Type: "host", Database: "all", UserName: "all", Address: "0.0.0.0/0", Method: "md5",
+			Raw: "host all all 0.0.0.0/0 md5"},
+	}
+
+	h, err := ParseHBAFileRules(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.ValidateEntry("app", "web", "10.0.0.1")
+
+	usage := h.GetLineUsage()
+	got := TightenedHBAFile(content, usage, SuggestHBALines(usage))
+
+	want := []string{
+		"# TYPE DATABASE USER ADDRESS METHOD",
+		"local all all peer",
+		"# line 3, replace all databases, users, addresses with the ones observed in logs: host all all 0.0.0.0/0 md5",
+		"host    app             web             10.0.0.1/32             md5",
+	}
+	if !strings.HasSuffix(got, strings.Join(want, "\n")+"\n") {
+		t.Errorf("TightenedHBAFile() = %q, want suffix %q", got, strings.Join(want, "\n"))
+	}
+}
+
+func Test_isBroadAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		netmask string
+		want    bool
+	}{
+		{address: "all", want: true},
+		{address: "0.0.0.0/0", want: true},
+		{address: "::/0", want: true},
+		{address: "0.0.0.0", netmask: "0.0.0.0", want: true},
+		{address: "192.168.0.1", netmask: "255.255.255.0", want: false},
+		{address: "10.0.0.0/8", want: false},
+		{address: "localhost", want: false},
+		{address: ".example.com", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isBroadAddress(tt.address, tt.netmask); got != tt.want {
+			t.Errorf("isBroadAddress(%q, %q) = %v, want %v", tt.address, tt.netmask, got, tt.want)
+		}
+	}
+}
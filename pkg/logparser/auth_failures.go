@@ -0,0 +1,35 @@
+package logparser
+
+import (
+	"context"
+
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/parselog"
+)
+
+type AuthFailureHelper struct {
+	*parselog.AuthFailureParser
+
+	report *parselog.AuthFailureReport
+}
+
+func NewAuthFailureHelper() *AuthFailureHelper {
+	return &AuthFailureHelper{}
+}
+
+func (a *AuthFailureHelper) Init(ctx context.Context, logParserCnf *config.LogParser) error {
+	a.AuthFailureParser = parselog.NewAuthFailureParser(logParserCnf)
+	return nil
+}
+
+func (a *AuthFailureHelper) CalculateResult(ctx context.Context) error {
+	a.report = parselog.NewAuthFailureReport(a.GetAuthFailures())
+	return nil
+}
+
+func (a *AuthFailureHelper) GetResult(ctx context.Context) *parselog.AuthFailureReport {
+	if a.report == nil {
+		a.report = parselog.NewAuthFailureReport(a.GetAuthFailures())
+	}
+	return a.report
+}
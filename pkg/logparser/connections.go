@@ -0,0 +1,39 @@
+package logparser
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/parselog"
+	"github.com/rs/zerolog/log"
+)
+
+type ConnectionHelper struct {
+	*parselog.ConnectionParser
+}
+
+func NewConnectionHelper() *ConnectionHelper {
+	return &ConnectionHelper{}
+}
+
+// Init checks the settings needed for connection matrix. Client ip of the
+// matrix is only from '%h' or '%r' of log_line_prefix, the host of connection
+// received line is not linked to the connection, as lines are parsed
+// concurrently. So ip column is empty when log_line_prefix has neither.
+func (c *ConnectionHelper) Init(ctx context.Context, logParserCnf *config.LogParser) error {
+	// check if postgres setting contains required variable or connection logs
+	if !logParserCnf.HasPrefixField("%u") && !logParserCnf.PgSettings.LogConnections {
+		return fmt.Errorf("please set log_line_prefix to '%%u' or enable log_connections")
+	}
+	if !logParserCnf.HasPrefixField("%h", "%r") {
+		log.Warn().Msg("Connection matrix: client ip is not available, please set log_line_prefix to '%h' or '%r'")
+	}
+
+	c.ConnectionParser = parselog.NewConnectionParser(logParserCnf)
+	return nil
+}
+
+func (c *ConnectionHelper) GetResult(ctx context.Context) []parselog.ConnectionStats {
+	return c.GetConnections()
+}
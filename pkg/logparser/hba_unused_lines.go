@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/klouddb/klouddbshield/model"
@@ -16,6 +17,13 @@ import (
 type UnusedHBALineHelper struct {
 	*parselog.HbaUnusedLineParser
 	store *sql.DB
+
+	// hbaFileContent is empty when the rules are fetched from the database or
+	// when the hba file includes other files.
+	hbaFileContent string
+	usage          []hbarules.HBALineUsage
+	suggestions    []hbarules.HBASuggestion
+	tightenedFile  string
 }
 
 func NewUnusedHBALineHelper(store *sql.DB) *UnusedHBALineHelper {
@@ -30,13 +38,13 @@ func (i *UnusedHBALineHelper) Init(ctx context.Context, logParserCnf *config.Log
 	// 3. log_line_prefix contains %u and %d
 
 	if logParserCnf.PgSettings.LogConnections {
-		if !(strings.Contains(logParserCnf.PgSettings.LogLinePrefix, "%h") || strings.Contains(logParserCnf.PgSettings.LogLinePrefix, "%r")) &&
-			!(strings.Contains(logParserCnf.PgSettings.LogLinePrefix, "%u") && strings.Contains(logParserCnf.PgSettings.LogLinePrefix, "%d")) {
+		if !logParserCnf.HasPrefixField("%h", "%r") &&
+			!(logParserCnf.HasPrefixField("%u") && logParserCnf.HasPrefixField("%d")) {
 			return fmt.Errorf("with log_connections enabled, please set log_line_prefix to '%%h' or '%%r' or '%%u' and '%%d'")
 		}
 	} else {
-		if !(strings.Contains(logParserCnf.PgSettings.LogLinePrefix, "%h") || strings.Contains(logParserCnf.PgSettings.LogLinePrefix, "%r")) ||
-			!(strings.Contains(logParserCnf.PgSettings.LogLinePrefix, "%u") && strings.Contains(logParserCnf.PgSettings.LogLinePrefix, "%d")) {
+		if !logParserCnf.HasPrefixField("%h", "%r") ||
+			!(logParserCnf.HasPrefixField("%u") && logParserCnf.HasPrefixField("%d")) {
 			return fmt.Errorf("please set log_line_prefix to '%%h' or '%%r' or '%%u' and '%%d'")
 		}
 	}
@@ -50,6 +58,16 @@ func (i *UnusedHBALineHelper) Init(ctx context.Context, logParserCnf *config.Log
 		if err != nil {
 			return fmt.Errorf("Got error while scanning hba file: %v", err)
 		}
+
+		content, err := os.ReadFile(logParserCnf.HbaConfFile)
+		if err != nil {
+			return fmt.Errorf("Got error while reading hba file: %v", err)
+		}
+		// line numbers of included files are not the lines of the content,
+		// the tightened file is built from the rules then
+		if !hasHBAIncludes(string(content)) {
+			i.hbaFileContent = string(content)
+		}
 	} else if i.store != nil {
 		var err error
 		hbaRules, err = utils.GetDatabaseAndHostForUSerFromHbaFileRules(ctx, i.store)
@@ -69,6 +87,47 @@ func (i *UnusedHBALineHelper) Init(ctx context.Context, logParserCnf *config.Log
 	return nil
 }
 
+func hasHBAIncludes(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "include", "include_if_exists", "include_dir":
+			return true
+		}
+	}
+	return false
+}
+
+func (i *UnusedHBALineHelper) CalculateResult(ctx context.Context) error {
+	i.usage = i.GetLineUsage()
+	i.suggestions = hbarules.SuggestHBALines(i.usage)
+	if len(i.suggestions) > 0 {
+		i.tightenedFile = hbarules.TightenedHBAFile(i.hbaFileContent, i.usage, i.suggestions)
+	}
+	return nil
+}
+
 func (i *UnusedHBALineHelper) GetResult(ctx context.Context) []hbarules.HBARawLine {
 	return i.GetUnusedLines()
 }
+
+// GetUsage returns the hit counts of the lines with the observed database,
+// user and address.
+func (i *UnusedHBALineHelper) GetUsage() []hbarules.HBALineUsage {
+	return i.usage
+}
+
+// GetSuggestions returns the least privilege replacements of the broad and
+// unused lines.
+func (i *UnusedHBALineHelper) GetSuggestions() []hbarules.HBASuggestion {
+	return i.suggestions
+}
+
+// GetTightenedHBAFile returns the hba file with the suggestions applied, it
+// is empty when there is no suggestion.
+func (i *UnusedHBALineHelper) GetTightenedHBAFile() string {
+	return i.tightenedFile
+}
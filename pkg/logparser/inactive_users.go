@@ -4,9 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"sort"
-	"strings"
+	"time"
 
+	"github.com/klouddb/klouddbshield/model"
 	"github.com/klouddb/klouddbshield/pkg/config"
 	"github.com/klouddb/klouddbshield/pkg/parselog"
 	"github.com/klouddb/klouddbshield/pkg/utils"
@@ -14,9 +14,10 @@ import (
 
 type InactiveUsersHelper struct {
 	*parselog.UniqueUserParser
-	store *sql.DB
+	store        *sql.DB
+	inactiveDays int
 
-	finalResult [][]string
+	finalResult *parselog.InactiveUserReport
 }
 
 func NewInactiveUsersHelper(store *sql.DB) *InactiveUsersHelper {
@@ -25,47 +26,29 @@ func NewInactiveUsersHelper(store *sql.DB) *InactiveUsersHelper {
 
 func (i *InactiveUsersHelper) Init(ctx context.Context, logParserCnf *config.LogParser) error {
 	// check if postgres setting contains required variable or connection logs
-	if !strings.Contains(logParserCnf.PgSettings.LogLinePrefix, "%u") && !logParserCnf.PgSettings.LogConnections {
+	if !logParserCnf.HasPrefixField("%u") && !logParserCnf.PgSettings.LogConnections {
 		return fmt.Errorf("please set log_line_prefix to '%%u' or enable log_connections")
 	}
 
 	i.UniqueUserParser = parselog.NewUserParser(logParserCnf)
+	i.inactiveDays = logParserCnf.InactiveDays
 	return nil
 }
 
 func (i *InactiveUsersHelper) CalculateResult(ctx context.Context) error {
-	uniqueUsers := i.GetUniqueUser()
-
-	usersFromLog := sort.StringSlice{}
-	for user := range uniqueUsers {
-		usersFromLog = append(usersFromLog, user)
-	}
-	usersFromLog.Sort()
-
-	if i.store == nil {
-		i.finalResult = [][]string{nil, usersFromLog, nil}
-		return nil
+	var roles []model.PGRole
+	var err error
+	if i.store != nil {
+		roles, err = utils.GetPGRoles(ctx, i.store)
 	}
 
-	usersFromDb, err := utils.GetPGUsers(ctx, i.store)
-
-	inactiveUsers := sort.StringSlice{}
-	for _, user := range usersFromDb {
-		_, ok := uniqueUsers[user]
-		if !ok {
-			inactiveUsers = append(inactiveUsers, user)
-		}
-	}
-	inactiveUsers.Sort()
-
+	i.finalResult = parselog.NewInactiveUserReport(roles, i.GetUniqueUser(), i.GetLastSeen(), i.inactiveDays, time.Now())
 	if err != nil {
-		usersFromDb = []string{"Error fetching users from DB " + err.Error()}
+		i.finalResult.DBError = "Error fetching users from DB " + err.Error()
 	}
-
-	i.finalResult = [][]string{usersFromDb, usersFromLog, inactiveUsers}
 	return nil
 }
 
-func (i *InactiveUsersHelper) GetResult(ctx context.Context) [][]string {
+func (i *InactiveUsersHelper) GetResult(ctx context.Context) *parselog.InactiveUserReport {
 	return i.finalResult
 }
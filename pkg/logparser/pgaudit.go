@@ -0,0 +1,53 @@
+package logparser
+
+import (
+	"context"
+
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/parselog"
+	"github.com/klouddb/klouddbshield/pkg/piiscanner"
+)
+
+type PgAuditHelper struct {
+	*parselog.PgAuditParser
+
+	// piiTables are the labels of the tables with PII data, nil when pii
+	// scan output is not given.
+	piiTables map[string][]string
+	report    *parselog.PgAuditReport
+}
+
+func NewPgAuditHelper() *PgAuditHelper {
+	return &PgAuditHelper{}
+}
+
+func (p *PgAuditHelper) Init(ctx context.Context, logParserCnf *config.LogParser) error {
+	if logParserCnf.PIIReportFile != "" {
+		piiReport, err := piiscanner.LoadJSONOutputFile(logParserCnf.PIIReportFile)
+		if err != nil {
+			return err
+		}
+		p.piiTables = piiReport.PIITables()
+	}
+
+	p.PgAuditParser = parselog.NewPgAuditParser(logParserCnf)
+	return nil
+}
+
+func (p *PgAuditHelper) CalculateResult(ctx context.Context) error {
+	p.report = parselog.NewPgAuditReport(p.GetPgAuditEvents(), p.piiTables)
+	return nil
+}
+
+func (p *PgAuditHelper) GetResult(ctx context.Context) *parselog.PgAuditReport {
+	if p.report == nil {
+		p.report = parselog.NewPgAuditReport(p.GetPgAuditEvents(), p.piiTables)
+	}
+	return p.report
+}
+
+// HasPIIReport returns true when pii scan output is given, so reads of PII
+// tables are reported.
+func (p *PgAuditHelper) HasPIIReport() bool {
+	return p.piiTables != nil
+}
@@ -0,0 +1,25 @@
+package logparser
+
+import (
+	"context"
+
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/parselog"
+)
+
+type PrivilegedAuditHelper struct {
+	*parselog.PrivilegedAuditParser
+}
+
+func NewPrivilegedAuditHelper() *PrivilegedAuditHelper {
+	return &PrivilegedAuditHelper{}
+}
+
+func (p *PrivilegedAuditHelper) Init(ctx context.Context, logParserCnf *config.LogParser) error {
+	p.PrivilegedAuditParser = parselog.NewPrivilegedAuditParser(logParserCnf)
+	return nil
+}
+
+func (p *PrivilegedAuditHelper) GetResult(ctx context.Context) []parselog.AuditEvent {
+	return p.GetAuditEvents()
+}
@@ -7,13 +7,17 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/jedib0t/go-pretty/v6/text"
 	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/hbarules"
 	"github.com/klouddb/klouddbshield/pkg/logger"
+	"github.com/klouddb/klouddbshield/pkg/parselog"
 	"github.com/klouddb/klouddbshield/pkg/runner"
 	"github.com/olekukonko/tablewriter"
 )
@@ -29,12 +33,10 @@ func PrintTerminalResultsForLogParser(ctx context.Context, runners []runner.Pars
 			PrintErrorBox(r.Status, fmt.Errorf("Error in %s: %s", r.Command, r.Message))
 		case *UnusedHBALineHelper:
 			unusedLine := r.GetResult(ctx)
-			if len(unusedLine) == 0 {
+			switch {
+			case len(unusedLine) == 0:
 				fmt.Println("\nNo unused lines found from given log file please check the file or errors in " + logger.GetLogFileName())
-				continue
-			}
-
-			if outputType == "json" {
+			case outputType == "json":
 				fmt.Println("")
 				lines := []int{}
 				for _, l := range unusedLine {
@@ -42,15 +44,25 @@ func PrintTerminalResultsForLogParser(ctx context.Context, runners []runner.Pars
 				}
 				fmt.Println("Unused lines found from given log file:", lines)
 				fmt.Println("")
-				continue
+			default:
+				fmt.Println("")
+				fmt.Println("Unused lines found from given log file:")
+				for _, line := range unusedLine {
+					fmt.Printf("\tLine No. %d \t:\t%s\n", line.LineNo, line.Line)
+				}
+				fmt.Println("")
 			}
 
-			fmt.Println("")
-			fmt.Println("Unused lines found from given log file:")
-			for _, line := range unusedLine {
-				fmt.Printf("\tLine No. %d \t:\t%s\n", line.LineNo, line.Line)
+			if outputType == "json" {
+				out, _ := json.MarshalIndent(map[string]interface{}{
+					"line_usage":  r.GetUsage(),
+					"suggestions": r.GetSuggestions(),
+				}, "", "\t")
+				fmt.Println(string(out))
+			} else {
+				printHBALineUsage(r.GetUsage(), r.GetSuggestions())
 			}
-			fmt.Println("")
+			writeTightenedHBAFile(r.GetTightenedHBAFile())
 		case *UniqueIPHelper:
 			ips := r.GetResult(ctx)
 			if len(ips) == 0 {
@@ -70,35 +82,19 @@ func PrintTerminalResultsForLogParser(ctx context.Context, runners []runner.Pars
 				fmt.Println("\t" + ip)
 			}
 		case *InactiveUsersHelper:
-			userdata := r.GetResult(ctx)
-			if len(userdata) == 0 || len(userdata[1]) == 0 {
+			report := r.GetResult(ctx)
+			if report == nil || len(report.UsersFromLog) == 0 {
 				fmt.Println("No users found in log file. please check the log file or errors in " + logger.GetLogFileName())
 				continue
 			}
 
-			// userdata[0] contains users from database
-			// userdata[1] contains users from log file
-			// userdata[2] contains inactive users from database
-
 			if outputType == "json" {
-				out, _ := json.MarshalIndent(userdata, "", "\t")
+				out, _ := json.MarshalIndent(report, "", "\t")
 				fmt.Println(string(out))
 				continue
 			}
 
-			table := tablewriter.NewWriter(os.Stdout)
-			if len(userdata[0]) > 0 {
-				table.Append([]string{"Users from DB", strings.Join(userdata[0], ", ")})
-			}
-			table.Append([]string{"Users from log", strings.Join(userdata[1], ", ")})
-			if len(userdata[2]) > 0 {
-				table.Append([]string{"Inactive users in db", strings.Join(userdata[2], ", ")})
-			}
-
-			table.SetRowLine(true)
-			table.SetAlignment(tablewriter.ALIGN_LEFT)
-			table.SetAutoWrapText(false)
-			table.Render()
+			printInactiveUserReport(report)
 		case *PasswordLeakHelper:
 			leakedPasswords := r.GetResult(ctx)
 			if len(leakedPasswords) == 0 {
@@ -147,6 +143,87 @@ func PrintTerminalResultsForLogParser(ctx context.Context, runners []runner.Pars
 			}
 
 			fmt.Println("Successfully parsed them log file")
+		case *AuthFailureHelper:
+			report := r.GetResult(ctx)
+			if report.Total == 0 {
+				fmt.Println("No failed authentications found in log file")
+				continue
+			}
+
+			if outputType == "json" {
+				out, _ := json.MarshalIndent(report, "", "\t")
+				fmt.Println(string(out))
+				continue
+			}
+
+			printAuthFailureReport(report)
+		case *ConnectionHelper:
+			connections := r.GetResult(ctx)
+			if len(connections) == 0 {
+				fmt.Println("No connections found in log file. please check the log file or errors in " + logger.GetLogFileName())
+				continue
+			}
+
+			if outputType == "json" {
+				out, _ := json.MarshalIndent(connections, "", "\t")
+				fmt.Println(string(out))
+				continue
+			}
+
+			fmt.Println("\nConnections found from given log file:")
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"User", "Database", "Client IP", "Application", "Connections", "First Seen", "Last Seen"})
+			for _, c := range connections {
+				table.Append([]string{c.User, c.Database, c.Host, c.ApplicationName, strconv.Itoa(c.Connections),
+					formatSeen(c.FirstSeen), formatSeen(c.LastSeen)})
+			}
+
+			table.SetRowLine(true)
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			table.SetAutoWrapText(false)
+			table.Render()
+		case *PgAuditHelper:
+			report := r.GetResult(ctx)
+			if report.Total == 0 {
+				fmt.Println("No pgAudit logs found in log file, please check pgaudit is in shared_preload_libraries and pgaudit.log is set")
+				continue
+			}
+
+			if outputType == "json" {
+				out, _ := json.MarshalIndent(report, "", "\t")
+				fmt.Println(string(out))
+				continue
+			}
+
+			printPgAuditReport(report, r.HasPIIReport())
+		case *PrivilegedAuditHelper:
+			events := r.GetResult(ctx)
+			if len(events) == 0 {
+				fmt.Println("No privileged or DDL statements found in log file, statements are logged with log_statement = 'ddl' or 'all'")
+				continue
+			}
+
+			if outputType == "json" {
+				out, _ := json.MarshalIndent(events, "", "\t")
+				fmt.Println(string(out))
+				continue
+			}
+
+			fmt.Println("\nPrivileged and DDL statements found in log file:")
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Time", "User", "Database", "Client IP", "Action", "Statement"})
+			for _, e := range events {
+				action := e.Action
+				if e.HighRisk {
+					action = text.FgHiRed.Sprint(action)
+				}
+				table.Append([]string{formatSeen(e.Time), e.User, e.Database, e.Host, action, e.Statement})
+			}
+
+			table.SetRowLine(true)
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			table.SetAutoWrapText(false)
+			table.Render()
 		case *SQLInjectionHelper:
 			queries := r.GetResult(ctx)
 			if len(queries) == 0 {
@@ -172,6 +249,187 @@ func PrintTerminalResultsForLogParser(ctx context.Context, runners []runner.Pars
 	}
 }
 
+// formatSeen formats first and last seen time, which is not known when log
+// line prefix does not have the time.
+func formatSeen(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// printAuthFailureReport prints the suspicious patterns and the top
+// offenders of failed authentications.
+func printAuthFailureReport(report *parselog.AuthFailureReport) {
+	fmt.Printf("\n%d failed authentications found in log file\n", report.Total)
+
+	if len(report.Alerts) > 0 {
+		fmt.Println(text.FgHiRed.Sprint("Suspicious authentication patterns:"))
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Pattern", "Source", "Failures", "Users", "Window"})
+		for _, a := range report.Alerts {
+			table.Append([]string{a.Pattern, a.Host, strconv.Itoa(a.Count), strings.Join(a.Users, ", "),
+				a.Start.Format("2006-01-02 15:04:05") + " - " + a.End.Format("15:04:05")})
+		}
+		table.SetRowLine(true)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAutoWrapText(false)
+		table.Render()
+	}
+
+	fmt.Println("Top offenders:")
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Source", "Failures", "Users", "Databases", "Patterns"})
+	for _, o := range report.Offenders {
+		table.Append([]string{o.Host, strconv.Itoa(o.Count), strings.Join(o.Users, ", "),
+			strings.Join(o.Databases, ", "), strings.Join(o.Patterns, ", ")})
+	}
+	table.SetRowLine(true)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoWrapText(false)
+	table.Render()
+	fmt.Println()
+}
+
+func printHBALineUsage(usage []hbarules.HBALineUsage, suggestions []hbarules.HBASuggestion) {
+	if len(usage) > 0 {
+		fmt.Println("Hit counts of hba lines:")
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Line No", "Line", "Hits", "Observed (Database / User @ Address)"})
+		for _, u := range usage {
+			tuples := make([]string, 0, len(u.Tuples))
+			for _, t := range u.Tuples {
+				tuples = append(tuples, fmt.Sprintf("%s (%d)", t.HBATuple, t.Count))
+			}
+			table.Append([]string{strconv.Itoa(u.LineNo), u.Line, strconv.Itoa(u.Hits), strings.Join(tuples, "\n")})
+		}
+		table.SetRowLine(true)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAutoWrapText(false)
+		table.Render()
+	}
+
+	if len(suggestions) > 0 {
+		fmt.Println("Suggested least privilege hba lines:")
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Line No", "Current Line", "Suggested Lines", "Reason"})
+		for _, s := range suggestions {
+			table.Append([]string{strconv.Itoa(s.LineNo), s.Original, strings.Join(s.Lines, "\n"), s.Reason})
+		}
+		table.SetRowLine(true)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAutoWrapText(false)
+		table.Render()
+	}
+}
+
+// TightenedHBAFileName is the suggested hba file written by unused_lines
+// command.
+const TightenedHBAFileName = "kshield_pg_hba.conf.suggested"
+
+func writeTightenedHBAFile(content string) {
+	if content == "" {
+		return
+	}
+
+	if err := os.WriteFile(TightenedHBAFileName, []byte(content), 0600); err != nil {
+		fmt.Println("Error creating suggested hba file: ", text.FgRed.Sprint(err))
+		return
+	}
+
+	path, _ := filepath.Abs(TightenedHBAFileName)
+	fmt.Println("> Suggested hba file created at: [ " + path + " ], please review before using")
+}
+
+func printInactiveUserReport(report *parselog.InactiveUserReport) {
+	if report.DBError != "" {
+		fmt.Println(text.FgHiRed.Sprint(report.DBError))
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"User", "Last Seen", "Password Valid Until", "Status"})
+	for _, u := range report.Users {
+		validUntil := formatSeen(u.ValidUntil)
+		if u.PasswordExpired {
+			validUntil += " (expired)"
+		}
+
+		status := "active"
+		if u.Inactive {
+			status = text.FgHiRed.Sprint("inactive")
+		}
+		table.Append([]string{u.Name, formatSeen(u.LastSeen), validUntil, status})
+	}
+	table.SetRowLine(true)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoWrapText(false)
+	table.Render()
+
+	if report.InactiveDays > 0 {
+		fmt.Printf("> Users not seen in the logs or not seen in %d days before %s are inactive\n", report.InactiveDays, report.Now.Format("2006-01-02 15:04:05"))
+	} else {
+		fmt.Println("> Users not seen in the logs are inactive, use --inactive-days to flag the users not seen in the last days")
+	}
+	if len(report.ExcludedRoles) > 0 {
+		fmt.Println("> NOLOGIN and system roles are not checked: " + strings.Join(report.ExcludedRoles, ", "))
+	}
+
+	if report.Script != "" {
+		fmt.Println("\nScript for inactive users:")
+		fmt.Println(report.Script)
+	}
+}
+
+func printPgAuditReport(report *parselog.PgAuditReport, hasPIIReport bool) {
+	fmt.Printf("\n%d pgAudit logs found in log file\n", report.Total)
+
+	if len(report.ObjectAccess) > 0 {
+		fmt.Println("Object access per role:")
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"User", "Object Type", "Object", "Reads", "Writes", "Commands", "Last Seen"})
+		for _, a := range report.ObjectAccess {
+			table.Append([]string{a.User, a.ObjectType, a.ObjectName, strconv.Itoa(a.Reads), strconv.Itoa(a.Writes),
+				strings.Join(a.Commands, ", "), formatSeen(a.LastSeen)})
+		}
+		table.SetRowLine(true)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAutoWrapText(false)
+		table.Render()
+	}
+
+	if len(report.DDLChanges) > 0 {
+		fmt.Println("DDL and role changes:")
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Time", "User", "Database", "Command", "Object", "Statement"})
+		for _, e := range report.DDLChanges {
+			table.Append([]string{formatSeen(e.Time), e.User, e.Database, e.Command, e.ObjectName, e.Statement})
+		}
+		table.SetRowLine(true)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAutoWrapText(false)
+		table.Render()
+	}
+
+	switch {
+	case !hasPIIReport:
+		fmt.Println("> Use --pii-report with the json output of pii scanner to find the reads of tables with PII data")
+	case len(report.PIIReads) == 0:
+		fmt.Println("No reads of tables with PII data found in log file")
+	default:
+		fmt.Println(text.FgHiRed.Sprint("Reads of tables with PII data:"))
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"User", "Table", "PII Labels", "Reads", "Last Seen"})
+		for _, a := range report.PIIReads {
+			table.Append([]string{a.User, a.ObjectName, strings.Join(a.PIILabels, ", "), strconv.Itoa(a.Reads), formatSeen(a.LastSeen)})
+		}
+		table.SetRowLine(true)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAutoWrapText(false)
+		table.Render()
+	}
+	fmt.Println()
+}
+
 func PrintFastRunnerReport(logParserCnf *config.LogParser, fastRunnerResp *runner.FastRunnerResponse) {
 	PrintFileParsingError(fastRunnerResp.FileErrors)
 
@@ -226,6 +484,9 @@ func PrintSummary(ctx context.Context, runners []runner.Parser, logParserCnf *co
 			} else {
 				resultMsg = fmt.Sprintf("%d unused lines found in hba_conf file\n", len(unusedLine))
 			}
+			if suggestions := r.GetSuggestions(); len(suggestions) > 0 {
+				resultMsg += fmt.Sprintf("%d hba lines can be tightened with the connections observed in logs\n", len(suggestions))
+			}
 			val = unusedLine
 
 		case *UniqueIPHelper:
@@ -238,21 +499,19 @@ func PrintSummary(ctx context.Context, runners []runner.Parser, logParserCnf *co
 			val = ips
 
 		case *InactiveUsersHelper:
-			userdata := r.GetResult(ctx)
-			if len(userdata) == 0 {
+			report := r.GetResult(ctx)
+			if report == nil {
 				resultMsg = "Some issue with result"
-			} else if len(userdata[1]) == 0 {
+			} else if len(report.UsersFromLog) == 0 {
 				resultMsg = "No users found from log file."
-			} else if len(userdata[0]) == 0 {
+			} else if len(report.UsersFromDB) == 0 {
 				resultMsg = "No users found from database."
+			} else if len(report.InactiveUsers) == 0 {
+				resultMsg = "No inactive users in database"
 			} else {
-				if len(userdata[2]) == 0 {
-					resultMsg = "No inactive users in database"
-				} else {
-					resultMsg = fmt.Sprintf("%d inactive users found in database\n", len(userdata[2]))
-				}
+				resultMsg = fmt.Sprintf("%d inactive users found in database\n", len(report.InactiveUsers))
 			}
-			val = userdata
+			val = report
 
 		case *PasswordLeakHelper:
 			leakedPasswords := r.GetResult(ctx)
@@ -263,6 +522,44 @@ func PrintSummary(ctx context.Context, runners []runner.Parser, logParserCnf *co
 			}
 			val = leakedPasswords
 
+		case *AuthFailureHelper:
+			report := r.GetResult(ctx)
+			if report.Total == 0 {
+				resultMsg = "No failed authentications found in log file."
+			} else {
+				resultMsg = fmt.Sprintf("%d failed authentications from %d sources, %d suspicious patterns\n",
+					report.Total, len(report.Offenders), len(report.Alerts))
+			}
+			val = report
+
+		case *ConnectionHelper:
+			connections := r.GetResult(ctx)
+			if len(connections) == 0 {
+				resultMsg = "No connections found from log file."
+			} else {
+				resultMsg = fmt.Sprintf("%d user, database, client ip and application combinations found from log file\n", len(connections))
+			}
+			val = connections
+
+		case *PgAuditHelper:
+			report := r.GetResult(ctx)
+			if report.Total == 0 {
+				resultMsg = "No pgAudit logs found in log file."
+			} else {
+				resultMsg = fmt.Sprintf("%d pgAudit logs found, %d objects accessed, %d DDL and role changes, %d reads of tables with PII data\n",
+					report.Total, len(report.ObjectAccess), len(report.DDLChanges), len(report.PIIReads))
+			}
+			val = report
+
+		case *PrivilegedAuditHelper:
+			events := r.GetResult(ctx)
+			if len(events) == 0 {
+				resultMsg = "No privileged or DDL statements found in log file."
+			} else {
+				resultMsg = fmt.Sprintf("%d privileged or DDL statements found in log file\n", len(events))
+			}
+			val = events
+
 		case *SQLInjectionHelper:
 			sqlInjection := r.GetResult(ctx)
 			if len(sqlInjection) == 0 {
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"sort"
-	"strings"
 
 	"github.com/klouddb/klouddbshield/pkg/config"
 	"github.com/klouddb/klouddbshield/pkg/parselog"
@@ -20,7 +19,7 @@ func NewUniqueIPHelper() *UniqueIPHelper {
 
 func (i *UniqueIPHelper) Init(ctx context.Context, logParserCnf *config.LogParser) error {
 	// check if postgres setting contains required variable or connection logs
-	if !strings.Contains(logParserCnf.PgSettings.LogLinePrefix, "%h") && !strings.Contains(logParserCnf.PgSettings.LogLinePrefix, "%r") && !logParserCnf.PgSettings.LogConnections {
+	if !logParserCnf.HasPrefixField("%h", "%r") && !logParserCnf.PgSettings.LogConnections {
 		return fmt.Errorf(`please set log_line_prefix to '%%h' or '%%r' or enable log_connections`)
 	}
 
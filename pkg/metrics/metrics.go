@@ -0,0 +1,339 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+)
+
+// metrics relative to the time of rendering. They are not kept from the
+// existing textfile, as the value would not change till the target is
+// scanned again, timestamp metrics are used for them instead.
+const (
+	backupAgeMetric = "klouddbshield_backup_last_age_seconds"
+	sslExpiryMetric = "klouddbshield_ssl_cert_expiry_seconds"
+)
+
+var relativeMetrics = map[string]bool{
+	backupAgeMetric: true,
+	sslExpiryMetric: true,
+}
+
+// values of klouddbshield_control_status metric
+const (
+	ControlStatus_Pass     = 0
+	ControlStatus_Warning  = 1
+	ControlStatus_Fail     = 2
+	ControlStatus_Critical = 3
+)
+
+type targetMetrics struct {
+	summary *htmlreport.ReportSummary
+	lastRun time.Time
+}
+
+// Collector keeps the latest report summary of every target and exposes them
+// in prometheus text format, either as textfile for node_exporter textfile
+// collector or as /metrics endpoint.
+type Collector struct {
+	mu      sync.RWMutex
+	targets map[string]*targetMetrics
+	now     func() time.Time
+}
+
+func NewCollector() *Collector {
+	return &Collector{
+		targets: map[string]*targetMetrics{},
+		now:     time.Now,
+	}
+}
+
+// Update stores the summary of the target. Values of the modules which are
+// not executed in this run are kept from the previous run of the target.
+func (c *Collector) Update(target string, summary *htmlreport.ReportSummary, runTime time.Time) {
+	if c == nil || summary == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, ok := c.targets[target]
+	if !ok {
+		c.targets[target] = &targetMetrics{summary: summary, lastRun: runTime}
+		return
+	}
+
+	t.summary = t.summary.Merge(summary)
+	t.lastRun = runTime
+}
+
+// Render returns the metrics in prometheus text exposition format.
+func (c *Collector) Render() []byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	targets := make([]string, 0, len(c.targets))
+	for k := range c.targets {
+		targets = append(targets, k)
+	}
+	sort.Strings(targets)
+
+	now := c.now()
+	var (
+		lastRun       = newFamily("klouddbshield_last_run_timestamp_seconds", "Unix time of the last run for the target.")
+		cisScore      = newFamily("klouddbshield_cis_score", "CIS benchmark score in percentage, by section.")
+		controlStatus = newFamily("klouddbshield_control_status", "Status of the control. 0=pass, 1=warning or manual, 2=fail, 3=critical.")
+		hbaFailures   = newFamily("klouddbshield_hba_failures", "Number of failed HBA scanner checks.")
+		wraparound    = newFamily("klouddbshield_wraparound_percent", "Percentage towards transaction id wraparound.")
+		backupAge     = newFamily(backupAgeMetric, "Seconds since the last backup found by backup audit tool.")
+		backupTime    = newFamily("klouddbshield_backup_last_timestamp_seconds", "Unix time of the last backup found by backup audit tool.")
+		sslExpiry     = newFamily(sslExpiryMetric, "Seconds until the server certificate expires, negative if expired.")
+		sslExpiryTime = newFamily("klouddbshield_ssl_cert_expiry_timestamp_seconds", "Unix time when the server certificate expires.")
+		uniqueIPs     = newFamily("klouddbshield_unique_ips", "Number of unique client IPs found by log parser.")
+		piiColumns    = newFamily("klouddbshield_pii_columns", "Number of columns with PII data found by PII scanner.")
+	)
+
+	for _, target := range targets {
+		t := c.targets[target]
+		s := t.summary
+
+		lastRun.add(float64(t.lastRun.Unix()), "target", target)
+
+		if s.CISScore != nil {
+			for _, section := range s.CISSections {
+				cisScore.add(section.Percentage, "section", section.SectionName, "target", target)
+			}
+			cisScore.add(s.CISScore.Percentage, "section", s.CISScore.SectionName, "target", target)
+		}
+
+		for _, control := range s.Controls {
+			v, ok := controlStatusValue(control.Status)
+			if !ok {
+				continue
+			}
+			controlStatus.add(v, "control", control.ID, "module", control.Module, "target", target, "title", control.Title)
+		}
+
+		if s.HBAChecks > 0 {
+			hbaFailures.add(float64(s.HBAFailures), "target", target)
+		}
+		if s.HasWraparound {
+			wraparound.add(float64(s.WraparoundPercent), "target", target)
+		}
+		if s.LastBackupDate != nil {
+			backupAge.add(s.LastBackupAge(now).Seconds(), "target", target)
+			backupTime.add(float64(s.LastBackupDate.Unix()), "target", target)
+		}
+		if s.SSLCertExpiry != nil {
+			sslExpiry.add(s.SSLCertExpiry.Sub(now).Seconds(), "target", target)
+			sslExpiryTime.add(float64(s.SSLCertExpiry.Unix()), "target", target)
+		}
+		if s.HasUniqueIPs {
+			uniqueIPs.add(float64(s.UniqueIPs), "target", target)
+		}
+		if s.HasPII {
+			piiColumns.add(float64(s.PIIColumns), "target", target)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	for _, f := range []*family{lastRun, cisScore, controlStatus, hbaFailures, wraparound,
+		backupAge, backupTime, sslExpiry, sslExpiryTime, uniqueIPs, piiColumns} {
+		f.writeTo(out)
+	}
+
+	return out.Bytes()
+}
+
+// WriteTextfile writes the metrics in the file. Every cli run has its own
+// collector, so the series of the existing file which are not in the
+// collector, like of other targets and modules, are kept, except the relative
+// metrics. File is written atomically, so node_exporter never reads a
+// partially written file.
+func (c *Collector) WriteTextfile(filename string) error {
+	data := c.Render()
+	existing, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read metrics file: %v", err)
+	}
+	data = mergeSamples(data, existing)
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write metrics: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write metrics: %v", err)
+	}
+
+	// node_exporter needs to read the file
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to change permission of metrics file: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("failed to rename metrics file: %v", err)
+	}
+
+	return nil
+}
+
+// mergeSamples adds the samples of existing metrics whose series are not in
+// rendered metrics, samples are grouped by their family. Relative metrics of
+// existing metrics are dropped.
+func mergeSamples(rendered, existing []byte) []byte {
+	type familyLines struct {
+		header  []string
+		samples []string
+	}
+
+	parse := func(data []byte) ([]string, map[string]*familyLines) {
+		order := []string{}
+		families := map[string]*familyLines{}
+		get := func(name string) *familyLines {
+			f, ok := families[name]
+			if !ok {
+				f = &familyLines{}
+				families[name] = f
+				order = append(order, name)
+			}
+			return f
+		}
+
+		for _, line := range strings.Split(string(data), "\n") {
+			switch {
+			case line == "":
+			case strings.HasPrefix(line, "# "):
+				if fields := strings.Fields(line); len(fields) > 2 {
+					f := get(fields[2])
+					f.header = append(f.header, line)
+				}
+			default:
+				name := line
+				if i := strings.IndexAny(line, "{ "); i >= 0 {
+					name = line[:i]
+				}
+				f := get(name)
+				f.samples = append(f.samples, line)
+			}
+		}
+		return order, families
+	}
+
+	series := func(sample string) string {
+		if i := strings.LastIndex(sample, " "); i >= 0 {
+			return sample[:i]
+		}
+		return sample
+	}
+
+	order, families := parse(rendered)
+	existingOrder, existingFamilies := parse(existing)
+
+	seen := map[string]bool{}
+	for _, f := range families {
+		for _, s := range f.samples {
+			seen[series(s)] = true
+		}
+	}
+
+	for _, name := range existingOrder {
+		if relativeMetrics[name] {
+			continue
+		}
+		e := existingFamilies[name]
+		f, ok := families[name]
+		if !ok {
+			f = &familyLines{header: e.header}
+			families[name] = f
+			order = append(order, name)
+		}
+		for _, s := range e.samples {
+			if !seen[series(s)] {
+				f.samples = append(f.samples, s)
+			}
+		}
+	}
+
+	out := &bytes.Buffer{}
+	for _, name := range order {
+		f := families[name]
+		if len(f.samples) == 0 {
+			continue
+		}
+		for _, l := range append(f.header, f.samples...) {
+			out.WriteString(l + "\n")
+		}
+	}
+	return out.Bytes()
+}
+
+// ServeHTTP serves the metrics for /metrics endpoint.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(c.Render()) //nolint:errcheck
+}
+
+func controlStatusValue(status string) (float64, bool) {
+	switch status {
+	case "Pass":
+		return ControlStatus_Pass, true
+	case "Warning", "Manual":
+		return ControlStatus_Warning, true
+	case "Fail":
+		return ControlStatus_Fail, true
+	case "Critical":
+		return ControlStatus_Critical, true
+	default:
+		return 0, false
+	}
+}
+
+type family struct {
+	name    string
+	help    string
+	samples []string
+}
+
+func newFamily(name, help string) *family {
+	return &family{name: name, help: help}
+}
+
+// add adds a sample with the label name and value pairs.
+func (f *family) add(value float64, labels ...string) {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+`="`+labelValueReplacer.Replace(labels[i+1])+`"`)
+	}
+
+	f.samples = append(f.samples, fmt.Sprintf("%s{%s} %g", f.name, strings.Join(pairs, ","), value))
+}
+
+func (f *family) writeTo(out *bytes.Buffer) {
+	if len(f.samples) == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s gauge\n", f.name, f.help, f.name)
+	for _, s := range f.samples {
+		out.WriteString(s + "\n")
+	}
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+)
+
+func TestCollector_Render(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	expiry := now.Add(time.Hour)
+
+	c := NewCollector()
+	c.now = func() time.Time { return now }
+
+	c.Update("pg1", &htmlreport.ReportSummary{
+		CISScore:    &htmlreport.SectionProgress{SectionName: "Overall Score", Percentage: 80},
+		HBAChecks:   5,
+		HBAFailures: 2,
+		Controls: []htmlreport.ControlStatus{
+			{Module: "Postgres Security Report", ID: "1.1", Title: `Ensure "packages"`, Status: "Fail"},
+		},
+	}, now)
+
+	// second run only has ssl check, so cis and hba values are kept
+	c.Update("pg1", &htmlreport.ReportSummary{
+		SSLStatus:     htmlreport.SSLStatus_Pass,
+		SSLCertExpiry: &expiry,
+	}, now)
+
+	got := string(c.Render())
+	for _, want := range []string{
+		"# TYPE klouddbshield_cis_score gauge",
+		`klouddbshield_cis_score{section="Overall Score",target="pg1"} 80`,
+		`klouddbshield_control_status{control="1.1",module="Postgres Security Report",target="pg1",title="Ensure \"packages\""} 2`,
+		`klouddbshield_hba_failures{target="pg1"} 2`,
+		`klouddbshield_ssl_cert_expiry_seconds{target="pg1"} 3600`,
+		`klouddbshield_ssl_cert_expiry_timestamp_seconds{target="pg1"} 1.7048484e+09`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Render() output does not contain %q\n%s", want, got)
+		}
+	}
+
+	if strings.Contains(got, "klouddbshield_wraparound_percent") {
+		t.Errorf("Render() should not contain metrics for modules which are not executed\n%s", got)
+	}
+}
+
+func TestCollector_WriteTextfileKeepsPreviousRuns(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	filename := filepath.Join(t.TempDir(), "klouddbshield.prom")
+	backup := now.Add(-time.Hour)
+
+	// every cli run has its own collector
+	runs := []struct {
+		target  string
+		summary *htmlreport.ReportSummary
+	}{
+		{"pg1", &htmlreport.ReportSummary{CISScore: &htmlreport.SectionProgress{SectionName: "Overall Score", Percentage: 80}, LastBackupDate: &backup}},
+		{"pg1", &htmlreport.ReportSummary{HBAChecks: 5, HBAFailures: 2}},
+		{"pg2", &htmlreport.ReportSummary{HBAChecks: 5, HBAFailures: 1}},
+		{"pg1", &htmlreport.ReportSummary{HBAChecks: 5, HBAFailures: 3}},
+	}
+	for _, r := range runs {
+		c := NewCollector()
+		c.now = func() time.Time { return now }
+		c.Update(r.target, r.summary, now)
+		if err := c.WriteTextfile(filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		`klouddbshield_cis_score{section="Overall Score",target="pg1"} 80`,
+		`klouddbshield_hba_failures{target="pg1"} 3`,
+		`klouddbshield_hba_failures{target="pg2"} 1`,
+		`klouddbshield_backup_last_timestamp_seconds{target="pg1"} 1.7048412e+09`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("textfile does not contain %q:\n%s", want, got)
+		}
+	}
+	// age of the backup would not change till pg1 is scanned again
+	if strings.Contains(got, "klouddbshield_backup_last_age_seconds") {
+		t.Errorf("textfile contains the relative metric of previous run:\n%s", got)
+	}
+	if strings.Contains(got, `klouddbshield_hba_failures{target="pg1"} 2`) {
+		t.Errorf("textfile contains the replaced value:\n%s", got)
+	}
+	if n := strings.Count(got, "# TYPE klouddbshield_hba_failures gauge"); n != 1 {
+		t.Errorf("textfile has %d TYPE lines of hba failures, want 1:\n%s", n, got)
+	}
+}
@@ -0,0 +1,239 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+)
+
+// supported payload formats
+const (
+	Format_Slack = "slack"
+	Format_Teams = "teams"
+	Format_JSON  = "json"
+)
+
+// RunSummary is the summary of a scheduled run which is sent to webhooks.
+type RunSummary struct {
+	Schedule string          `json:"schedule"`
+	Time     time.Time       `json:"time"`
+	Targets  []TargetSummary `json:"targets"`
+	Errors   []string        `json:"errors,omitempty"`
+}
+
+// TargetSummary is the result of a single target in the run.
+type TargetSummary struct {
+	Target      string   `json:"target"`
+	CISScore    *float64 `json:"cis_score,omitempty"`
+	HBAFailures *int     `json:"hba_failures,omitempty"`
+
+	Failing  int `json:"failing"`
+	Critical int `json:"critical"`
+	Resolved int `json:"resolved"`
+
+	// NewFailures are the failing findings which were not failing or got
+	// worse since the previous run of the target.
+	NewFailures      []htmlreport.Finding `json:"new_failures,omitempty"`
+	ResolvedFindings []htmlreport.Finding `json:"resolved_findings,omitempty"`
+
+	NewLeakedPasswords []string `json:"new_leaked_passwords,omitempty"`
+	NewUniqueIPs       []string `json:"new_unique_ips,omitempty"`
+	NewUnusedHBALines  []string `json:"new_unused_hba_lines,omitempty"`
+	BackupSLABreaches  []string `json:"backup_sla_breaches,omitempty"`
+}
+
+// NewTargetSummary creates the summary of the target from its current
+// findings and the previous history entry. When there is no previous entry
+// all the failing findings are considered new.
+func NewTargetSummary(target string, summary *htmlreport.ReportSummary, findings []htmlreport.Finding,
+	previous *htmlreport.HistoryEntry) TargetSummary {
+
+	out := TargetSummary{Target: target}
+	if summary != nil {
+		if summary.CISScore != nil {
+			score := summary.CISScore.Percentage
+			out.CISScore = &score
+		}
+		if summary.HBAChecks > 0 {
+			hbaFailures := summary.HBAFailures
+			out.HBAFailures = &hbaFailures
+		}
+	}
+
+	for _, f := range findings {
+		if !f.IsFailing() {
+			continue
+		}
+		out.Failing++
+		if isCritical(f) {
+			out.Critical++
+		}
+	}
+
+	changes := htmlreport.NewTargetChanges(findings, previous)
+	out.NewFailures = truncateReasons(changes.NewFailures)
+	out.Resolved = len(changes.Resolved)
+	out.ResolvedFindings = truncateReasons(changes.Resolved)
+	out.NewLeakedPasswords = changes.NewLeakedPasswords
+	out.NewUniqueIPs = changes.NewUniqueIPs
+	out.NewUnusedHBALines = changes.NewUnusedHBALines
+	out.BackupSLABreaches = changes.BackupSLABreaches
+
+	return out
+}
+
+// HasChanges reports whether the target has changed since its previous run.
+func (t TargetSummary) HasChanges() bool {
+	return len(t.NewFailures) > 0 || t.Resolved > 0 || len(t.NewLeakedPasswords) > 0 ||
+		len(t.NewUniqueIPs) > 0 || len(t.NewUnusedHBALines) > 0 || len(t.BackupSLABreaches) > 0
+}
+
+// OnlyChanges returns the summary with only the targets which have changed
+// since their previous run. False is returned if nothing has changed and
+// there is no error in the run.
+func (s *RunSummary) OnlyChanges() (*RunSummary, bool) {
+	if s == nil {
+		return nil, false
+	}
+
+	out := &RunSummary{Schedule: s.Schedule, Time: s.Time, Errors: s.Errors}
+	for _, t := range s.Targets {
+		if t.HasChanges() {
+			out.Targets = append(out.Targets, t)
+		}
+	}
+
+	return out, len(out.Targets) > 0 || len(out.Errors) > 0
+}
+
+func isCritical(f htmlreport.Finding) bool {
+	return f.Severity == htmlreport.Severity_Critical
+}
+
+// WebhookNotifier posts the run summary to a webhook.
+type WebhookNotifier struct {
+	url             string
+	format          string
+	onlyNewFailures bool
+	onlyCritical    bool
+
+	client *http.Client
+}
+
+func NewWebhookNotifier(webhookURL, format string, onlyNewFailures, onlyCritical bool) *WebhookNotifier {
+	if format == "" {
+		format = Format_JSON
+	}
+
+	return &WebhookNotifier{
+		url:             webhookURL,
+		format:          format,
+		onlyNewFailures: onlyNewFailures,
+		onlyCritical:    onlyCritical,
+		client:          &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (n *WebhookNotifier) VerifyConfig() error {
+	u, err := url.Parse(n.url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid notification url %q", n.url)
+	}
+
+	switch n.format {
+	case Format_Slack, Format_Teams, Format_JSON:
+	default:
+		return fmt.Errorf("invalid notification format %q, valid formats are %s, %s and %s",
+			n.format, Format_Slack, Format_Teams, Format_JSON)
+	}
+
+	return nil
+}
+
+// Notify sends the summary to the webhook after applying the filters. It
+// returns false if nothing was sent because the filters excluded all the
+// targets.
+func (n *WebhookNotifier) Notify(ctx context.Context, summary *RunSummary) (bool, error) {
+	filtered, ok := n.filter(summary)
+	if !ok {
+		return false, nil
+	}
+
+	var payload interface{}
+	switch n.format {
+	case Format_Slack:
+		payload = slackPayload(filtered)
+	case Format_Teams:
+		payload = teamsPayload(filtered)
+	default:
+		payload = filtered
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal notification: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(b))
+	if err != nil {
+		return false, fmt.Errorf("failed to create notification request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to send notification: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, fmt.Errorf("notification webhook returned %s: %s", resp.Status, string(body))
+	}
+
+	return true, nil
+}
+
+// filter returns the summary with only the targets matching the filters of
+// the notifier. Errors of the run are kept, as a failed scan can hide the
+// new failures. False is returned if no target and no error is left after
+// filtering.
+func (n *WebhookNotifier) filter(summary *RunSummary) (*RunSummary, bool) {
+	if summary == nil {
+		return nil, false
+	}
+	if !n.onlyNewFailures && !n.onlyCritical {
+		return summary, len(summary.Targets) > 0 || len(summary.Errors) > 0
+	}
+
+	out := &RunSummary{Schedule: summary.Schedule, Time: summary.Time, Errors: summary.Errors}
+	for _, t := range summary.Targets {
+		if n.onlyCritical {
+			critical := []htmlreport.Finding{}
+			for _, f := range t.NewFailures {
+				if isCritical(f) {
+					critical = append(critical, f)
+				}
+			}
+			t.NewFailures = critical
+		}
+
+		switch {
+		case n.onlyNewFailures && len(t.NewFailures) == 0:
+			continue
+		case !n.onlyNewFailures && t.Critical == 0:
+			continue
+		}
+
+		out.Targets = append(out.Targets, t)
+	}
+
+	return out, len(out.Targets) > 0 || len(out.Errors) > 0
+}
@@ -0,0 +1,285 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+)
+
+func TestNewTargetSummary(t *testing.T) {
+	previous := &htmlreport.HistoryEntry{Findings: []htmlreport.Finding{
+		{Module: "HBA Scanner", Control: "1", Status: "Fail", Severity: htmlreport.Severity_High, Items: []string{"line 1"}},
+		{Module: "HBA Scanner", Control: "2", Status: "Fail", Severity: htmlreport.Severity_High},
+		{Module: "HBA Scanner", Control: "3", Status: "Pass"},
+	}}
+	current := []htmlreport.Finding{
+		{Module: "HBA Scanner", Control: "1", Status: "Fail", Severity: htmlreport.Severity_High, Items: []string{"line 1", "line 2"}},
+		{Module: "HBA Scanner", Control: "2", Status: "Pass"},
+		{Module: "HBA Scanner", Control: "3", Status: "Fail", Severity: htmlreport.Severity_Critical},
+		{Module: "Postgres", Control: "1.1", Status: "Fail", Severity: htmlreport.Severity_Critical},
+	}
+
+	got := NewTargetSummary("pg", nil, current, previous)
+	if got.Failing != 3 || got.Critical != 2 || got.Resolved != 1 {
+		t.Errorf("failing, critical, resolved = %d, %d, %d; want 3, 2, 1", got.Failing, got.Critical, got.Resolved)
+	}
+
+	want := map[string]bool{"HBA Scanner/1": true, "HBA Scanner/3": true, "Postgres/1.1": true}
+	if len(got.NewFailures) != len(want) {
+		t.Fatalf("got %d new failures, want %d: %+v", len(got.NewFailures), len(want), got.NewFailures)
+	}
+	for _, f := range got.NewFailures {
+		if !want[f.Module+"/"+f.Control] {
+			t.Errorf("unexpected new failure %s/%s", f.Module, f.Control)
+		}
+	}
+
+	first := NewTargetSummary("pg", nil, current, nil)
+	if len(first.NewFailures) != 3 {
+		t.Errorf("first run: got %d new failures, want 3", len(first.NewFailures))
+	}
+}
+
+func TestNewTargetSummaryTruncatesReason(t *testing.T) {
+	current := []htmlreport.Finding{
+		{Module: "HBA Scanner", Control: "1", Status: "Fail", Severity: htmlreport.Severity_High, Reason: strings.Repeat("é", 400)},
+		{Module: "Log Parser", Control: htmlreport.LeakedPasswordControl, Status: "Fail", Severity: htmlreport.Severity_High,
+			Reason: strings.Repeat("x", 290) + " ALTER USER app PASSWORD 'secret'"},
+	}
+
+	got := NewTargetSummary("pg", nil, current, nil)
+	if len(got.NewFailures) != 2 {
+		t.Fatalf("got %d new failures, want 2", len(got.NewFailures))
+	}
+	for _, f := range got.NewFailures {
+		if !utf8.ValidString(f.Reason) || utf8.RuneCountInString(f.Reason) > maxReasonLength+3 {
+			t.Errorf("reason of %s is not truncated: %q", f.Control, f.Reason)
+		}
+		if strings.Contains(f.Reason, "sec") {
+			t.Errorf("reason of %s has the password: %q", f.Control, f.Reason)
+		}
+	}
+	if current[0].Reason != strings.Repeat("é", 400) {
+		t.Errorf("reason of current finding is changed")
+	}
+}
+
+func TestWebhookNotifierNotify(t *testing.T) {
+	summary := &RunSummary{
+		Schedule: "@daily",
+		Time:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Targets: []TargetSummary{
+			{Target: "noisy", Failing: 2, NewFailures: []htmlreport.Finding{
+				{Module: "HBA Scanner", Control: "1", Status: "Fail", Severity: htmlreport.Severity_High},
+			}},
+			{Target: "critical", Failing: 1, Critical: 1, NewFailures: []htmlreport.Finding{
+				{Module: "Postgres", Control: "1.1", Status: "Fail", Severity: htmlreport.Severity_Critical},
+			}},
+			{Target: "unchanged", Failing: 1, Critical: 1},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		format          string
+		onlyNewFailures bool
+		onlyCritical    bool
+		wantSent        bool
+		check           func(t *testing.T, body map[string]interface{})
+	}{
+		{
+			name:     "json without filters",
+			format:   Format_JSON,
+			wantSent: true,
+			check: func(t *testing.T, body map[string]interface{}) {
+				if targets := body["targets"].([]interface{}); len(targets) != 3 {
+					t.Errorf("got %d targets, want 3", len(targets))
+				}
+			},
+		},
+		{
+			name:            "json only new critical failures",
+			format:          Format_JSON,
+			onlyNewFailures: true,
+			onlyCritical:    true,
+			wantSent:        true,
+			check: func(t *testing.T, body map[string]interface{}) {
+				targets := body["targets"].([]interface{})
+				if len(targets) != 1 || targets[0].(map[string]interface{})["target"] != "critical" {
+					t.Errorf("unexpected targets %v", targets)
+				}
+			},
+		},
+		{
+			name:     "slack",
+			format:   Format_Slack,
+			wantSent: true,
+			check: func(t *testing.T, body map[string]interface{}) {
+				if _, ok := body["blocks"].([]interface{}); !ok {
+					t.Errorf("slack payload has no blocks: %v", body)
+				}
+				if body["text"] == "" {
+					t.Errorf("slack payload has no fallback text")
+				}
+			},
+		},
+		{
+			name:     "teams",
+			format:   Format_Teams,
+			wantSent: true,
+			check: func(t *testing.T, body map[string]interface{}) {
+				if body["@type"] != "MessageCard" {
+					t.Errorf("got @type %v, want MessageCard", body["@type"])
+				}
+				if sections := body["sections"].([]interface{}); len(sections) != 3 {
+					t.Errorf("got %d sections, want 3", len(sections))
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("got content type %q", r.Header.Get("Content-Type"))
+				}
+				b, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(b, &body); err != nil {
+					t.Errorf("invalid json payload: %v", err)
+				}
+			}))
+			defer server.Close()
+
+			n := NewWebhookNotifier(server.URL, tt.format, tt.onlyNewFailures, tt.onlyCritical)
+			if err := n.VerifyConfig(); err != nil {
+				t.Fatalf("VerifyConfig() error = %v", err)
+			}
+
+			sent, err := n.Notify(context.Background(), summary)
+			if err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+			if sent != tt.wantSent {
+				t.Fatalf("Notify() sent = %v, want %v", sent, tt.wantSent)
+			}
+			if tt.check != nil {
+				tt.check(t, body)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifierSkipsFilteredRun(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	n := NewWebhookNotifier(server.URL, Format_Slack, true, false)
+	sent, err := n.Notify(context.Background(), &RunSummary{Targets: []TargetSummary{{Target: "pg", Failing: 3}}})
+	if err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if sent || called {
+		t.Errorf("notification sent for run without new failures")
+	}
+
+	// scan errors are sent even when no target matches the filters
+	for _, onlyNewFailures := range []bool{true, false} {
+		n := NewWebhookNotifier(server.URL, Format_Slack, onlyNewFailures, true)
+		sent, err := n.Notify(context.Background(), &RunSummary{
+			Targets: []TargetSummary{{Target: "pg", Failing: 3}},
+			Errors:  []string{"pg: connection refused"},
+		})
+		if err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+		if !sent {
+			t.Errorf("notification with onlyNewFailures=%v not sent for run with errors", onlyNewFailures)
+		}
+	}
+}
+
+func TestWebhookNotifierError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_payload", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	n := NewWebhookNotifier(server.URL, Format_Slack, false, false)
+	if _, err := n.Notify(context.Background(), &RunSummary{Targets: []TargetSummary{{Target: "pg"}}}); err == nil {
+		t.Errorf("Notify() expected error for non 2xx response")
+	}
+
+	if err := NewWebhookNotifier("ftp://example.com", Format_JSON, false, false).VerifyConfig(); err == nil {
+		t.Errorf("VerifyConfig() expected error for invalid url")
+	}
+	if err := NewWebhookNotifier("https://example.com", "xml", false, false).VerifyConfig(); err == nil {
+		t.Errorf("VerifyConfig() expected error for invalid format")
+	}
+}
+
+func TestRunSummaryOnlyChanges(t *testing.T) {
+	summary := &RunSummary{Schedule: "@daily", Targets: []TargetSummary{
+		{Target: "unchanged", Failing: 2},
+		{Target: "resolved", Resolved: 1},
+		{Target: "new ip", NewUniqueIPs: []string{"10.0.0.3"}},
+	}}
+
+	got, ok := summary.OnlyChanges()
+	if !ok || len(got.Targets) != 2 || got.Targets[0].Target != "resolved" || got.Targets[1].Target != "new ip" {
+		t.Errorf("OnlyChanges() = %+v, %v; want resolved and new ip targets", got, ok)
+	}
+
+	if _, ok := (&RunSummary{Targets: summary.Targets[:1]}).OnlyChanges(); ok {
+		t.Errorf("OnlyChanges() of unchanged run should not notify")
+	}
+	if _, ok := (&RunSummary{Targets: summary.Targets[:1], Errors: []string{"failed"}}).OnlyChanges(); !ok {
+		t.Errorf("OnlyChanges() of run with errors should notify")
+	}
+}
+
+func TestFindingLinesMaskPassword(t *testing.T) {
+	findings := []htmlreport.Finding{
+		{Module: "Log Parser", Control: "Leaked Password", Status: "Fail", Severity: htmlreport.Severity_High, Reason: "statement: ALTER USER app PASSWORD 'secret'"},
+	}
+
+	got := findingLines(findings, "- ", "")
+	want := []string{"- [High] Log Parser / Leaked Password: statement: ALTER USER app PASSWORD '***'"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findingLines() = %q, want %q", got, want)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"abcdef", 3, "abc..."},
+		{"héllo wörld", 5, "héllo..."},
+		{"日本語テキスト", 3, "日本語..."},
+		{"日本語", 3, "日本語"},
+	}
+
+	for _, tt := range tests {
+		got := truncate(tt.s, tt.n)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
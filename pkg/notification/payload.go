@@ -0,0 +1,207 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+	"github.com/klouddb/klouddbshield/pkg/parselog"
+)
+
+// maxListedFailures is the number of new failures listed per target, rest
+// of them are only counted to keep the message readable.
+const maxListedFailures = 10
+
+const notificationTitle = "KloudDB Shield Report"
+
+func targetStats(t TargetSummary) string {
+	stats := []string{}
+	if t.CISScore != nil {
+		stats = append(stats, fmt.Sprintf("CIS score: %.2f%%", *t.CISScore))
+	}
+	if t.HBAFailures != nil {
+		stats = append(stats, fmt.Sprintf("HBA failures: %d", *t.HBAFailures))
+	}
+	stats = append(stats,
+		fmt.Sprintf("Failing: %d (%d critical)", t.Failing, t.Critical),
+		fmt.Sprintf("New: %d", len(t.NewFailures)),
+		fmt.Sprintf("Resolved: %d", t.Resolved),
+	)
+	for _, c := range []struct {
+		name  string
+		count int
+	}{
+		{"New leaked passwords", len(t.NewLeakedPasswords)},
+		{"New unique IPs", len(t.NewUniqueIPs)},
+		{"Newly unused HBA lines", len(t.NewUnusedHBALines)},
+		{"Backup SLA breaches", len(t.BackupSLABreaches)},
+	} {
+		if c.count > 0 {
+			stats = append(stats, fmt.Sprintf("%s: %d", c.name, c.count))
+		}
+	}
+
+	return strings.Join(stats, " | ")
+}
+
+// failureLines returns the changes of the target since its previous run as
+// list items, bullet is prepended to each line. Leaked passwords, unused HBA
+// lines and backup breaches are failures, so they are listed with them.
+func failureLines(t TargetSummary, bullet string) []string {
+	out := findingLines(t.NewFailures, bullet, "")
+	out = append(out, findingLines(t.ResolvedFindings, bullet, "Resolved ")...)
+
+	if len(t.NewUniqueIPs) > 0 {
+		out = append(out, bullet+"New unique IPs: "+truncate(strings.Join(t.NewUniqueIPs, ", "), 200))
+	}
+
+	return out
+}
+
+func findingLines(findings []htmlreport.Finding, bullet, prefix string) []string {
+	out := []string{}
+	for i, f := range findings {
+		if i == maxListedFailures {
+			out = append(out, fmt.Sprintf("%s... and %d more", bullet, len(findings)-maxListedFailures))
+			break
+		}
+
+		line := fmt.Sprintf("%s%s[%s] %s / %s", bullet, prefix, f.Severity, f.Module, f.Control)
+		reason := f.Reason
+		if f.Control == htmlreport.LeakedPasswordControl {
+			reason = parselog.MaskPasswords(reason)
+		}
+		if reason != "" {
+			line += ": " + truncate(reason, 200)
+		}
+		out = append(out, line)
+	}
+
+	return out
+}
+
+// maxReasonLength is the number of runes of a finding reason in the summary,
+// same as in the email body. Reasons of findings with many items can be very
+// long.
+const maxReasonLength = 300
+
+// truncateReasons returns a copy of the findings with the reasons truncated,
+// leaked passwords are masked before truncating so that a password is not
+// cut out of the mask.
+func truncateReasons(findings []htmlreport.Finding) []htmlreport.Finding {
+	if len(findings) == 0 {
+		return findings
+	}
+
+	out := make([]htmlreport.Finding, len(findings))
+	for i, f := range findings {
+		if f.Control == htmlreport.LeakedPasswordControl {
+			f.Reason = parselog.MaskPasswords(f.Reason)
+		}
+		f.Reason = truncate(f.Reason, maxReasonLength)
+		out[i] = f
+	}
+	return out
+}
+
+// truncate truncates s to n runes, so that multi-byte characters are not
+// split in the payload.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "..."
+}
+
+func summaryText(s *RunSummary) string {
+	newFailures := 0
+	for _, t := range s.Targets {
+		newFailures += len(t.NewFailures)
+	}
+
+	return fmt.Sprintf("%s: %d target(s) scanned, %d new failure(s)", notificationTitle, len(s.Targets), newFailures)
+}
+
+// slackPayload creates the message with slack block kit.
+func slackPayload(s *RunSummary) map[string]interface{} {
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]interface{}{"type": "plain_text", "text": notificationTitle},
+		},
+		{
+			"type": "context",
+			"elements": []map[string]interface{}{
+				{"type": "mrkdwn", "text": fmt.Sprintf("Schedule `%s` at %s", s.Schedule, s.Time.Format("2006-01-02 15:04:05 MST"))},
+			},
+		},
+	}
+
+	for _, t := range s.Targets {
+		lines := append([]string{"*" + t.Target + "*", targetStats(t)}, failureLines(t, "• ")...)
+		blocks = append(blocks,
+			map[string]interface{}{"type": "divider"},
+			map[string]interface{}{
+				"type": "section",
+				// section text is limited to 3000 characters by slack
+				"text": map[string]interface{}{"type": "mrkdwn", "text": truncate(strings.Join(lines, "\n"), 2900)},
+			},
+		)
+	}
+
+	if len(s.Errors) > 0 {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": truncate("*Errors*\n• "+strings.Join(s.Errors, "\n• "), 2900)},
+		})
+	}
+
+	return map[string]interface{}{
+		"text":   summaryText(s),
+		"blocks": blocks,
+	}
+}
+
+// teamsPayload creates the message card for microsoft teams incoming webhook.
+func teamsPayload(s *RunSummary) map[string]interface{} {
+	themeColor := "2EB886"
+	sections := []map[string]interface{}{}
+	for _, t := range s.Targets {
+		if len(t.NewFailures) > 0 {
+			themeColor = "D70000"
+		}
+
+		facts := []map[string]string{}
+		for _, stat := range strings.Split(targetStats(t), " | ") {
+			name, value, _ := strings.Cut(stat, ": ")
+			facts = append(facts, map[string]string{"name": name, "value": value})
+		}
+
+		section := map[string]interface{}{
+			"activityTitle": t.Target,
+			"facts":         facts,
+		}
+		if lines := failureLines(t, "- "); len(lines) > 0 {
+			section["text"] = strings.Join(lines, "\n\n")
+		}
+		sections = append(sections, section)
+	}
+
+	if len(s.Errors) > 0 {
+		sections = append(sections, map[string]interface{}{
+			"activityTitle": "Errors",
+			"text":          "- " + strings.Join(s.Errors, "\n\n- "),
+		})
+	}
+
+	return map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    summaryText(s),
+		"themeColor": themeColor,
+		"title":      notificationTitle,
+		"text":       fmt.Sprintf("Schedule `%s` at %s", s.Schedule, s.Time.Format("2006-01-02 15:04:05 MST")),
+		"sections":   sections,
+	}
+}
@@ -0,0 +1,478 @@
+package parselog
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/utils"
+)
+
+// kinds of the failed authentications
+const (
+	AuthFailure_Password    = "password"
+	AuthFailure_NoHBAEntry  = "no_hba_entry"
+	AuthFailure_UnknownRole = "unknown_role"
+	AuthFailure_Other       = "other"
+)
+
+// patterns flagged from the failed authentications of a source
+const (
+	AuthPattern_BruteForce         = "brute_force"
+	AuthPattern_CredentialStuffing = "credential_stuffing"
+	AuthPattern_UserEnumeration    = "user_enumeration"
+)
+
+// Patterns are counted in a sliding window of authFailureWindow, a source is
+// flagged when the failures in any window reach the threshold.
+var (
+	authFailureWindow = 5 * time.Minute
+
+	// failed passwords of the same user from the source
+	bruteForceThreshold = 10
+	// distinct users with failed password from the source
+	credentialStuffingThreshold = 5
+	// distinct roles which don't exist, tried from the source
+	userEnumerationThreshold = 3
+)
+
+// authFailureTopN is the number of offenders, users and databases in the
+// report.
+const authFailureTopN = 10
+
+var (
+	passwordFailedRegexp = regexp.MustCompile(`password authentication failed for user "([^"]*)"`)
+	noHBAEntryRegexp     = regexp.MustCompile(`no pg_hba\.conf entry for (?:replication connection from )?host "([^"]*)", user "([^"]*)"(?:, database "([^"]*)")?`)
+	roleNotExistRegexp   = regexp.MustCompile(`role "([^"]*)" does not exist`)
+)
+
+// AuthFailureEvent is a failed authentication found in the logs.
+type AuthFailureEvent struct {
+	Time     time.Time
+	Kind     string
+	Host     string
+	User     string
+	Database string
+}
+
+// GetAuthFailure returns the failed authentication of the log line. User,
+// host and database are taken from log line prefix, or from the message
+// when they are not in the prefix.
+func GetAuthFailure(parsedData ParsedData) (AuthFailureEvent, bool) {
+	if !IsAuthFailure(parsedData) {
+		return AuthFailureEvent{}, false
+	}
+
+	e := AuthFailureEvent{Time: parsedData.GetTime(), Kind: AuthFailure_Other}
+	e.User, _ = parsedData.GetUser()
+	e.Host, _ = parsedData.GetHost()
+	e.Database, _ = parsedData.GetDatabase()
+
+	var parts utils.StringSlice
+	desc := parsedData.GetDescription()
+	if parts = passwordFailedRegexp.FindStringSubmatch(desc); parts != nil {
+		e.Kind = AuthFailure_Password
+		e.User = firstNonEmpty(e.User, parts.Get(1))
+	} else if parts = noHBAEntryRegexp.FindStringSubmatch(desc); parts != nil {
+		e.Kind = AuthFailure_NoHBAEntry
+		e.Host = firstNonEmpty(e.Host, parts.Get(1))
+		e.User = firstNonEmpty(e.User, parts.Get(2))
+		e.Database = firstNonEmpty(e.Database, parts.Get(3))
+	} else if parts = roleNotExistRegexp.FindStringSubmatch(desc); parts != nil {
+		e.Kind = AuthFailure_UnknownRole
+		e.User = firstNonEmpty(e.User, parts.Get(1))
+	}
+
+	return e, true
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// AuthFailureParser collects the failed authentications of the log files.
+type AuthFailureParser struct {
+	logParserCnf *config.LogParser
+
+	events []AuthFailureEvent
+	mt     sync.Mutex
+}
+
+func NewAuthFailureParser(logParserCnf *config.LogParser) *AuthFailureParser {
+	return &AuthFailureParser{logParserCnf: logParserCnf}
+}
+
+func (a *AuthFailureParser) Feed(parsedData ParsedData) error {
+	e, ok := GetAuthFailure(parsedData)
+	if !ok {
+		return nil
+	}
+
+	a.mt.Lock()
+	a.events = append(a.events, e)
+	a.mt.Unlock()
+
+	return nil
+}
+
+// GetAuthFailures returns the failed authentications in the order of time.
+func (a *AuthFailureParser) GetAuthFailures() []AuthFailureEvent {
+	a.mt.Lock()
+	defer a.mt.Unlock()
+
+	sort.SliceStable(a.events, func(i, j int) bool {
+		return a.events[i].Time.Before(a.events[j].Time)
+	})
+	return a.events
+}
+
+// AuthFailureTracker finds the patterns of failed authentications as they
+// are added one by one, like in follow mode. Only the failures of the last
+// window are kept for each source.
+type AuthFailureTracker struct {
+	events map[string][]AuthFailureEvent
+	// alerted is the time a pattern of a source was last found, it is not
+	// returned again until a window passes without it.
+	alerted map[string]time.Time
+	mt      sync.Mutex
+}
+
+func NewAuthFailureTracker() *AuthFailureTracker {
+	return &AuthFailureTracker{events: map[string][]AuthFailureEvent{}, alerted: map[string]time.Time{}}
+}
+
+// Add adds the failed authentication and returns the patterns it starts.
+// Events must be in the order of time, events without time are ignored.
+func (t *AuthFailureTracker) Add(e AuthFailureEvent) []AuthFailureAlert {
+	if e.Time.IsZero() {
+		return nil
+	}
+
+	t.mt.Lock()
+	defer t.mt.Unlock()
+
+	host := hostLabel(e.Host)
+	events := append(t.events[host], e)
+	for e.Time.Sub(events[0].Time) > authFailureWindow {
+		events = events[1:]
+	}
+	t.events[host] = events
+
+	var out []AuthFailureAlert
+	for _, a := range authFailureAlerts(host, events) {
+		key := host + "/" + a.Pattern
+		if a.Pattern == AuthPattern_BruteForce {
+			key += "/" + strings.Join(a.Users, ",")
+		}
+
+		last, ok := t.alerted[key]
+		t.alerted[key] = e.Time
+		if !ok || e.Time.Sub(last) > authFailureWindow {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// AuthFailureCount is the number of failed authentications of a user or a
+// database.
+type AuthFailureCount struct {
+	Name  string
+	Count int
+}
+
+// AuthFailureOffender is a source of failed authentications.
+type AuthFailureOffender struct {
+	Host      string
+	Count     int
+	Users     []string
+	Databases []string
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Patterns  []string
+}
+
+// AuthFailureAlert is a brute-force, credential stuffing or user enumeration
+// pattern found in the failed authentications of a source. It has the window
+// with the most failures.
+type AuthFailureAlert struct {
+	Pattern string
+	Host    string
+	Users   []string
+	Count   int
+	Start   time.Time
+	End     time.Time
+}
+
+// AuthFailureBucket is the failed authentications of a period in timeline.
+type AuthFailureBucket struct {
+	Start       time.Time
+	Total       int
+	Password    int
+	NoHBAEntry  int
+	UnknownRole int
+	Other       int
+}
+
+// AuthFailureReport is the summary of failed authentications.
+type AuthFailureReport struct {
+	Total     int
+	ByKind    map[string]int
+	Offenders []AuthFailureOffender
+	Users     []AuthFailureCount
+	Databases []AuthFailureCount
+	Alerts    []AuthFailureAlert
+	// Timeline is empty when log lines don't have the time.
+	Timeline       []AuthFailureBucket
+	TimelineBucket string
+}
+
+// NewAuthFailureReport summarizes the failed authentications, events must be
+// in the order of time.
+func NewAuthFailureReport(events []AuthFailureEvent) *AuthFailureReport {
+	r := &AuthFailureReport{Total: len(events), ByKind: map[string]int{}}
+
+	byHost := map[string][]AuthFailureEvent{}
+	users := map[string]int{}
+	databases := map[string]int{}
+	for _, e := range events {
+		r.ByKind[e.Kind]++
+		byHost[e.Host] = append(byHost[e.Host], e)
+		if e.User != "" {
+			users[e.User]++
+		}
+		if e.Database != "" {
+			databases[e.Database]++
+		}
+	}
+
+	for host, hostEvents := range byHost {
+		host = hostLabel(host)
+		alerts := authFailureAlerts(host, hostEvents)
+		r.Alerts = append(r.Alerts, alerts...)
+		r.Offenders = append(r.Offenders, newAuthFailureOffender(host, hostEvents, alerts))
+	}
+
+	sort.Slice(r.Alerts, func(i, j int) bool {
+		if !r.Alerts[i].Start.Equal(r.Alerts[j].Start) {
+			return r.Alerts[i].Start.Before(r.Alerts[j].Start)
+		}
+		return r.Alerts[i].Host+r.Alerts[i].Pattern < r.Alerts[j].Host+r.Alerts[j].Pattern
+	})
+	sort.Slice(r.Offenders, func(i, j int) bool {
+		if r.Offenders[i].Count != r.Offenders[j].Count {
+			return r.Offenders[i].Count > r.Offenders[j].Count
+		}
+		return r.Offenders[i].Host < r.Offenders[j].Host
+	})
+	if len(r.Offenders) > authFailureTopN {
+		r.Offenders = r.Offenders[:authFailureTopN]
+	}
+
+	r.Users = topAuthFailureCounts(users)
+	r.Databases = topAuthFailureCounts(databases)
+	r.Timeline, r.TimelineBucket = authFailureTimeline(events)
+
+	return r
+}
+
+func newAuthFailureOffender(host string, events []AuthFailureEvent, alerts []AuthFailureAlert) AuthFailureOffender {
+	o := AuthFailureOffender{Host: host, Count: len(events)}
+
+	users := utils.NewSet[string]()
+	databases := utils.NewSet[string]()
+	for _, e := range events {
+		if !e.Time.IsZero() {
+			if o.FirstSeen.IsZero() {
+				o.FirstSeen = e.Time
+			}
+			o.LastSeen = e.Time
+		}
+		if e.User != "" {
+			users.Add(e.User)
+		}
+		if e.Database != "" {
+			databases.Add(e.Database)
+		}
+	}
+
+	o.Users, o.Databases = users.Slice(), databases.Slice()
+	sort.Strings(o.Users)
+	sort.Strings(o.Databases)
+	for _, a := range alerts {
+		o.Patterns = append(o.Patterns, a.Pattern)
+	}
+	return o
+}
+
+// authFailureAlerts returns the patterns found in the failed authentications
+// of a source. Events without time can not be windowed, so they are only
+// counted in the totals.
+func authFailureAlerts(host string, events []AuthFailureEvent) []AuthFailureAlert {
+	passwordByUser := map[string][]AuthFailureEvent{}
+	password := []AuthFailureEvent{}
+	unknownRole := []AuthFailureEvent{}
+	for _, e := range events {
+		if e.Time.IsZero() {
+			continue
+		}
+		switch e.Kind {
+		case AuthFailure_Password:
+			password = append(password, e)
+			passwordByUser[e.User] = append(passwordByUser[e.User], e)
+		case AuthFailure_UnknownRole:
+			unknownRole = append(unknownRole, e)
+		}
+	}
+
+	out := []AuthFailureAlert{}
+
+	userNames := make([]string, 0, len(passwordByUser))
+	for user := range passwordByUser {
+		userNames = append(userNames, user)
+	}
+	sort.Strings(userNames)
+	for _, user := range userNames {
+		if w := peakWindow(passwordByUser[user], false); w.Count >= bruteForceThreshold {
+			w.Pattern, w.Host = AuthPattern_BruteForce, host
+			out = append(out, w)
+		}
+	}
+
+	if w := peakWindow(password, true); len(w.Users) >= credentialStuffingThreshold {
+		w.Pattern, w.Host = AuthPattern_CredentialStuffing, host
+		out = append(out, w)
+	}
+
+	if w := peakWindow(unknownRole, true); len(w.Users) >= userEnumerationThreshold {
+		w.Pattern, w.Host = AuthPattern_UserEnumeration, host
+		out = append(out, w)
+	}
+
+	return out
+}
+
+// peakWindow returns the window of authFailureWindow with the most events,
+// or with the most distinct users when distinct is set.
+func peakWindow(events []AuthFailureEvent, distinct bool) AuthFailureAlert {
+	best := AuthFailureAlert{}
+	bestValue := 0
+
+	inWindow := map[string]int{}
+	start := 0
+	for end, e := range events {
+		inWindow[e.User]++
+		for e.Time.Sub(events[start].Time) > authFailureWindow {
+			u := events[start].User
+			inWindow[u]--
+			if inWindow[u] == 0 {
+				delete(inWindow, u)
+			}
+			start++
+		}
+
+		value := end - start + 1
+		if distinct {
+			value = len(inWindow)
+		}
+		if value <= bestValue {
+			continue
+		}
+
+		bestValue = value
+		best = AuthFailureAlert{Count: end - start + 1, Start: events[start].Time, End: e.Time}
+		best.Users = make([]string, 0, len(inWindow))
+		for u := range inWindow {
+			best.Users = append(best.Users, u)
+		}
+		sort.Strings(best.Users)
+	}
+
+	return best
+}
+
+func topAuthFailureCounts(counts map[string]int) []AuthFailureCount {
+	out := make([]AuthFailureCount, 0, len(counts))
+	for name, count := range counts {
+		out = append(out, AuthFailureCount{Name: name, Count: count})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Name < out[j].Name
+	})
+	if len(out) > authFailureTopN {
+		out = out[:authFailureTopN]
+	}
+	return out
+}
+
+// authFailureTimeline counts the failed authentications per minute, hour or
+// day as per the period of the logs.
+func authFailureTimeline(events []AuthFailureEvent) ([]AuthFailureBucket, string) {
+	var first, last time.Time
+	for _, e := range events {
+		if e.Time.IsZero() {
+			continue
+		}
+		if first.IsZero() {
+			first = e.Time
+		}
+		last = e.Time
+	}
+	if first.IsZero() {
+		return nil, ""
+	}
+
+	bucket, name := time.Minute, "minute"
+	switch span := last.Sub(first); {
+	case span > 7*24*time.Hour:
+		bucket, name = 24*time.Hour, "day"
+	case span > 2*time.Hour:
+		bucket, name = time.Hour, "hour"
+	}
+
+	out := []AuthFailureBucket{}
+	for _, e := range events {
+		if e.Time.IsZero() {
+			continue
+		}
+
+		start := e.Time.Truncate(bucket)
+		if len(out) == 0 || !out[len(out)-1].Start.Equal(start) {
+			out = append(out, AuthFailureBucket{Start: start})
+		}
+
+		b := &out[len(out)-1]
+		b.Total++
+		switch e.Kind {
+		case AuthFailure_Password:
+			b.Password++
+		case AuthFailure_NoHBAEntry:
+			b.NoHBAEntry++
+		case AuthFailure_UnknownRole:
+			b.UnknownRole++
+		default:
+			b.Other++
+		}
+	}
+
+	return out, name
+}
+
+// hostLabel is the source shown for the failures without client host.
+func hostLabel(host string) string {
+	if strings.TrimSpace(host) == "" {
+		return "[unknown]"
+	}
+	return host
+}
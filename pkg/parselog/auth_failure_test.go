@@ -0,0 +1,116 @@
+package parselog
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetAuthFailure(t *testing.T) {
+	parser := GetDynamicBaseParser("%m [%p] %u@%d %h ")
+	tests := []struct {
+		name string
+		line string
+		want AuthFailureEvent
+		ok   bool
+	}{
+		{
+			name: "wrong password",
+			line: `2024-01-02 03:04:05.000 UTC [1] app@db1 10.0.0.1 FATAL:  password authentication failed for user "app"`,
+			want: AuthFailureEvent{Kind: AuthFailure_Password, Host: "10.0.0.1", User: "app", Database: "db1"},
+			ok:   true,
+		},
+		{
+			name: "no pg_hba.conf entry without prefix fields",
+			line: `2024-01-02 03:04:05.000 UTC [1] [unknown]@[unknown]  FATAL:  no pg_hba.conf entry for host "10.0.0.2", user "bob", database "db2", no encryption`,
+			want: AuthFailureEvent{Kind: AuthFailure_NoHBAEntry, Host: "10.0.0.2", User: "bob", Database: "db2"},
+			ok:   true,
+		},
+		{
+			name: "role does not exist",
+			line: `2024-01-02 03:04:05.000 UTC [1] admin@db1 10.0.0.3 FATAL:  role "admin" does not exist`,
+			want: AuthFailureEvent{Kind: AuthFailure_UnknownRole, Host: "10.0.0.3", User: "admin", Database: "db1"},
+			ok:   true,
+		},
+		{
+			name: "not a failure",
+			line: `2024-01-02 03:04:05.000 UTC [1] app@db1 10.0.0.1 LOG:  connection authorized: user=app database=db1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := parser.Parse(tt.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, ok := GetAuthFailure(d)
+			if ok != tt.ok {
+				t.Fatalf("GetAuthFailure() ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+
+			got.Time = time.Time{}
+			if got != tt.want {
+				t.Errorf("GetAuthFailure() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAuthFailureReport(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	events := []AuthFailureEvent{}
+	add := func(offset time.Duration, kind, host, user string) {
+		events = append(events, AuthFailureEvent{Time: start.Add(offset), Kind: kind, Host: host, User: user, Database: "db1"})
+	}
+
+	// brute force of one user, 10 failures in 4.5 minutes
+	for i := 0; i < 10; i++ {
+		add(time.Duration(i)*30*time.Second, AuthFailure_Password, "10.0.0.1", "app")
+	}
+	// credential stuffing, 5 users in a minute
+	for i := 0; i < 5; i++ {
+		add(time.Hour+time.Duration(i)*10*time.Second, AuthFailure_Password, "10.0.0.2", fmt.Sprintf("user%d", i))
+	}
+	// user enumeration, 3 roles which don't exist
+	for _, role := range []string{"admin", "root", "oracle"} {
+		add(2*time.Hour, AuthFailure_UnknownRole, "10.0.0.3", role)
+	}
+	// failures spread over the day are not flagged
+	for i := 0; i < 10; i++ {
+		add(3*time.Hour+time.Duration(i)*10*time.Minute, AuthFailure_Password, "10.0.0.4", "app")
+	}
+
+	r := NewAuthFailureReport(events)
+
+	got := []string{}
+	for _, a := range r.Alerts {
+		got = append(got, a.Host+" "+a.Pattern)
+	}
+	want := []string{
+		"10.0.0.1 " + AuthPattern_BruteForce,
+		"10.0.0.2 " + AuthPattern_CredentialStuffing,
+		"10.0.0.3 " + AuthPattern_UserEnumeration,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("alerts = %v, want %v", got, want)
+	}
+
+	if r.Total != 28 || r.ByKind[AuthFailure_Password] != 25 || r.ByKind[AuthFailure_UnknownRole] != 3 {
+		t.Errorf("total = %d, by kind = %v", r.Total, r.ByKind)
+	}
+	if o := r.Offenders[0]; o.Host != "10.0.0.1" || o.Count != 10 || !reflect.DeepEqual(o.Patterns, []string{AuthPattern_BruteForce}) {
+		t.Errorf("top offender = %+v", o)
+	}
+	if r.Users[0] != (AuthFailureCount{Name: "app", Count: 20}) {
+		t.Errorf("top user = %+v", r.Users[0])
+	}
+	if r.TimelineBucket != "hour" || len(r.Timeline) != 5 || r.Timeline[0].Password != 10 {
+		t.Errorf("timeline per %s = %+v", r.TimelineBucket, r.Timeline)
+	}
+}
@@ -0,0 +1,140 @@
+package parselog
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/utils"
+)
+
+// ConnectionAuthorizedRegexp matches the connection authorized line of
+// log_connections, application_name is logged since postgres 12.
+var ConnectionAuthorizedRegexp = regexp.MustCompile(`connection authorized:\s+user=(\S*)(?:\s+database=(\S*))?(?:\s+application_name=(\S*))?`)
+
+// ConnectionKey is a row of connection matrix.
+type ConnectionKey struct {
+	User            string
+	Database        string
+	Host            string
+	ApplicationName string
+}
+
+// ConnectionStats is the connections of a connection matrix row. Connections
+// is zero when connections are not logged, then first and last seen are of
+// the log lines of the user.
+type ConnectionStats struct {
+	ConnectionKey
+	Connections int
+	FirstSeen   time.Time
+	LastSeen    time.Time
+}
+
+func (c *ConnectionStats) observe(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	if c.FirstSeen.IsZero() || t.Before(c.FirstSeen) {
+		c.FirstSeen = t
+	}
+	if t.After(c.LastSeen) {
+		c.LastSeen = t
+	}
+}
+
+// ConnectionParser builds the (user, database, client ip, application_name)
+// matrix of the connections. Connections are counted from the connection
+// authorized lines, client ip is only from '%h' or '%r' of log line prefix,
+// as the connection received line can not be linked to the session when
+// lines are parsed concurrently. application_name is from the connection
+// authorized line, '%a' of log line prefix or the column of csvlog and
+// jsonlog. When connections are not logged, the matrix is built from the
+// fields of log line prefix.
+type ConnectionParser struct {
+	logParserCnf *config.LogParser
+	ips          *UniqueIPParser
+
+	authorized map[ConnectionKey]*ConnectionStats
+	sessions   map[ConnectionKey]*ConnectionStats
+	mt         sync.Mutex
+}
+
+func NewConnectionParser(logParserCnf *config.LogParser) *ConnectionParser {
+	return &ConnectionParser{
+		logParserCnf: logParserCnf,
+		ips:          NewUniqueIPParser(logParserCnf),
+		authorized:   map[ConnectionKey]*ConnectionStats{},
+		sessions:     map[ConnectionKey]*ConnectionStats{},
+	}
+}
+
+func (c *ConnectionParser) Feed(parsedData ParsedData) error {
+	key := ConnectionKey{}
+	key.User, _ = parsedData.GetUser()
+	key.Database, _ = parsedData.GetDatabase()
+	key.ApplicationName, _ = parsedData.GetApplicationName()
+	if c.logParserCnf.HasPrefixField("%h", "%r") {
+		key.Host, _ = c.ips.GetIP(parsedData)
+	}
+
+	rows, authorized := c.sessions, false
+	if parts := ConnectionAuthorizedRegexp.FindStringSubmatch(parsedData.GetDescription()); parts != nil {
+		var p utils.StringSlice = parts
+		key.User = firstNonEmpty(p.Get(1), key.User)
+		key.Database = firstNonEmpty(p.Get(2), key.Database)
+		key.ApplicationName = firstNonEmpty(p.Get(3), key.ApplicationName)
+		rows, authorized = c.authorized, true
+	} else if key.User == "" {
+		return nil
+	}
+
+	c.mt.Lock()
+	defer c.mt.Unlock()
+
+	stats, ok := rows[key]
+	if !ok {
+		stats = &ConnectionStats{ConnectionKey: key}
+		rows[key] = stats
+	}
+	if authorized {
+		stats.Connections++
+	}
+	stats.observe(parsedData.GetTime())
+
+	return nil
+}
+
+// GetConnections returns the rows of connection matrix sorted by user,
+// database, host and application_name. Rows of the connection authorized
+// lines are used when there are any.
+func (c *ConnectionParser) GetConnections() []ConnectionStats {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+
+	rows := c.authorized
+	if len(rows) == 0 {
+		rows = c.sessions
+	}
+
+	out := make([]ConnectionStats, 0, len(rows))
+	for _, stats := range rows {
+		out = append(out, *stats)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].ConnectionKey, out[j].ConnectionKey
+		if a.User != b.User {
+			return a.User < b.User
+		}
+		if a.Database != b.Database {
+			return a.Database < b.Database
+		}
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		return a.ApplicationName < b.ApplicationName
+	})
+
+	return out
+}
@@ -0,0 +1,88 @@
+package parselog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/klouddb/klouddbshield/model"
+	"github.com/klouddb/klouddbshield/pkg/config"
+)
+
+func TestConnectionParser(t *testing.T) {
+	at := func(sec int) time.Time { return time.Date(2024, 1, 2, 3, 4, sec, 0, time.UTC) }
+	tests := []struct {
+		name           string
+		prefix         string
+		logConnections bool
+		lines          []string
+		want           []ConnectionStats
+	}{
+		{
+			name:           "connection authorized lines",
+			logConnections: true,
+			lines: []string{
+				`2024-01-02 03:04:01.000 UTC [1] [unknown]@[unknown] 10.0.0.1 LOG:  connection received: host=10.0.0.1 port=5000`,
+				`2024-01-02 03:04:01.000 UTC [1] app@db1 10.0.0.1 LOG:  connection authorized: user=app database=db1 application_name=api`,
+				`2024-01-02 03:04:02.000 UTC [1] app@db1 10.0.0.1 LOG:  statement: SELECT 1`,
+				`2024-01-02 03:04:05.000 UTC [2] app@db1 10.0.0.1 LOG:  connection authorized: user=app database=db1 application_name=api SSL enabled (protocol=TLSv1.3)`,
+				`2024-01-02 03:04:06.000 UTC [3] bob@db2 10.0.0.2 LOG:  connection authorized: user=bob database=db2`,
+			},
+			want: []ConnectionStats{
+				{ConnectionKey{"app", "db1", "10.0.0.1", "api"}, 2, at(1), at(5)},
+				{ConnectionKey{"bob", "db2", "10.0.0.2", ""}, 1, at(6), at(6)},
+			},
+		},
+		{
+			name: "log line prefix without connection logs",
+			lines: []string{
+				`2024-01-02 03:04:01.000 UTC [1] app@db1 10.0.0.1 LOG:  statement: SELECT 1`,
+				`2024-01-02 03:04:09.000 UTC [1] app@db1 10.0.0.1 LOG:  statement: SELECT 2`,
+				`2024-01-02 03:04:09.000 UTC [4] [unknown]@[unknown]  LOG:  checkpoint starting: time`,
+			},
+			want: []ConnectionStats{
+				{ConnectionKey{"app", "db1", "10.0.0.1", ""}, 0, at(1), at(9)},
+			},
+		},
+		{
+			name:   "application name of log line prefix",
+			prefix: "%m [%p] %u@%d %h %a ",
+			lines: []string{
+				`2024-01-02 03:04:01.000 UTC [1] app@db1 10.0.0.1 api LOG:  statement: SELECT 1`,
+				`2024-01-02 03:04:02.000 UTC [2] app@db1 10.0.0.1 psql LOG:  statement: SELECT 2`,
+				`2024-01-02 03:04:03.000 UTC [2] app@db1 10.0.0.1 psql LOG:  statement: SELECT 3`,
+			},
+			want: []ConnectionStats{
+				{ConnectionKey{"app", "db1", "10.0.0.1", "api"}, 0, at(1), at(1)},
+				{ConnectionKey{"app", "db1", "10.0.0.1", "psql"}, 0, at(2), at(3)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix := tt.prefix
+			if prefix == "" {
+				prefix = "%m [%p] %u@%d %h "
+			}
+			p := NewConnectionParser(&config.LogParser{
+				PgSettings: &model.PgSettings{LogLinePrefix: prefix, LogConnections: tt.logConnections},
+			})
+
+			base := GetDynamicBaseParser(prefix)
+			for _, line := range tt.lines {
+				d, err := base.Parse(line)
+				if err != nil {
+					t.Fatalf("Parse(%q) error = %v", line, err)
+				}
+				if err := p.Feed(d); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := p.GetConnections(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetConnections() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
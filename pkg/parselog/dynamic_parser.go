@@ -132,6 +132,8 @@ func GetDynamicBaseParser(logPattern string) BaseParser {
 			b.SetHostIndex(v)
 		case "%e":
 			b.SetErrorCodeIndex(v)
+		case "%a":
+			b.SetAppNameIndex(v)
 		}
 	}
 
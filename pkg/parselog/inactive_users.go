@@ -0,0 +1,145 @@
+package parselog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klouddb/klouddbshield/model"
+)
+
+// UserActivity is a user with the time it is last seen in the logs.
+type UserActivity struct {
+	Name  string
+	InLog bool
+	// LastSeen is zero when the user is not seen in the logs, or the log
+	// lines have no time.
+	LastSeen time.Time
+	// ValidUntil is the expiry of the password, zero when it does not
+	// expire or the database is not connected.
+	ValidUntil      time.Time
+	PasswordExpired bool
+	Inactive        bool
+}
+
+// InactiveUserReport is the activity of the login roles of the database in
+// the logs. Without the database, it has the users of the logs.
+type InactiveUserReport struct {
+	UsersFromDB  []string
+	UsersFromLog []string
+	// ExcludedRoles are the NOLOGIN and system roles of the database, they
+	// are not checked.
+	ExcludedRoles []string
+
+	// InactiveDays is the threshold of the last seen time, users not seen in
+	// the logs are inactive regardless of it.
+	InactiveDays int
+	// Now is the time of the latest log line, inactive days are counted till
+	// it.
+	Now time.Time
+
+	Users         []UserActivity
+	InactiveUsers []UserActivity
+	// Script disables the logins of the inactive users of the database.
+	Script string
+	// DBError is set when the roles can not be fetched from the database,
+	// the report has the users of the logs then.
+	DBError string
+}
+
+// NewInactiveUserReport creates the report of the users. roles are nil when
+// the database is not connected. now is used when the log lines have no
+// time.
+func NewInactiveUserReport(roles []model.PGRole, usersFromLog map[string]bool, lastSeen map[string]time.Time,
+	inactiveDays int, now time.Time) *InactiveUserReport {
+
+	r := &InactiveUserReport{InactiveDays: inactiveDays, Now: now}
+	var latest time.Time
+	for _, t := range lastSeen {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	if !latest.IsZero() {
+		r.Now = latest
+	}
+
+	for user := range usersFromLog {
+		r.UsersFromLog = append(r.UsersFromLog, user)
+	}
+	sort.Strings(r.UsersFromLog)
+
+	validUntil := map[string]time.Time{}
+	for _, role := range roles {
+		if !role.CanLogin || role.System {
+			r.ExcludedRoles = append(r.ExcludedRoles, role.Name)
+			continue
+		}
+		r.UsersFromDB = append(r.UsersFromDB, role.Name)
+		validUntil[role.Name] = role.ValidUntil
+	}
+	sort.Strings(r.UsersFromDB)
+
+	users := r.UsersFromDB
+	if roles == nil {
+		users = r.UsersFromLog
+	}
+
+	threshold := time.Duration(inactiveDays) * 24 * time.Hour
+	for _, user := range users {
+		u := UserActivity{
+			Name:       user,
+			InLog:      usersFromLog[user],
+			LastSeen:   lastSeen[user],
+			ValidUntil: validUntil[user],
+		}
+		u.PasswordExpired = !u.ValidUntil.IsZero() && u.ValidUntil.Before(r.Now)
+		u.Inactive = !u.InLog || (inactiveDays > 0 && !u.LastSeen.IsZero() && r.Now.Sub(u.LastSeen) > threshold)
+
+		r.Users = append(r.Users, u)
+		if u.Inactive {
+			r.InactiveUsers = append(r.InactiveUsers, u)
+		}
+	}
+
+	if roles != nil {
+		r.Script = inactiveUsersScript(r.InactiveUsers, r.Now)
+	}
+
+	return r
+}
+
+// inactiveUsersScript returns the ALTER ROLE statements disabling the logins
+// of the users, with expiring the password as an alternative. Expired
+// passwords still allow the other authentication methods, like cert and
+// peer, so NOLOGIN is suggested for them too.
+func inactiveUsersScript(users []UserActivity, now time.Time) string {
+	if len(users) == 0 {
+		return ""
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("-- Disable the logins of the inactive users, please review before running.\n")
+	for _, u := range users {
+		lastSeen := "not seen in logs"
+		if !u.LastSeen.IsZero() {
+			lastSeen = "last seen " + u.LastSeen.Format("2006-01-02 15:04:05")
+		}
+		if u.PasswordExpired {
+			lastSeen += ", password expired " + u.ValidUntil.Format("2006-01-02")
+		}
+
+		fmt.Fprintf(&sb, "\n-- %s: %s\n", u.Name, lastSeen)
+		fmt.Fprintf(&sb, "ALTER ROLE %s NOLOGIN;\n", quoteIdentifier(u.Name))
+		if !u.PasswordExpired {
+			fmt.Fprintf(&sb, "-- or expire the password: ALTER ROLE %s VALID UNTIL '%s';\n", quoteIdentifier(u.Name), now.Format("2006-01-02"))
+		}
+	}
+
+	return sb.String()
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,71 @@
+package parselog
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klouddb/klouddbshield/model"
+)
+
+func TestNewInactiveUserReport(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	roles := []model.PGRole{
+		{Name: "postgres", CanLogin: true, System: true},
+		{Name: "pg_monitor", System: true},
+		{Name: "readers"},
+		{Name: "app", CanLogin: true},
+		{Name: "old", CanLogin: true, ValidUntil: day(1)},
+		{Name: "report", CanLogin: true, ValidUntil: day(31)},
+		{Name: "unused", CanLogin: true},
+	}
+	usersFromLog := map[string]bool{"postgres": true, "app": true, "old": true, "report": true}
+	lastSeen := map[string]time.Time{"postgres": day(30), "app": day(29), "old": day(2), "report": day(10)}
+
+	tests := []struct {
+		name         string
+		roles        []model.PGRole
+		inactiveDays int
+		want         []string
+	}{
+		{name: "not seen in logs", roles: roles, want: []string{"unused"}},
+		{name: "inactive days", roles: roles, inactiveDays: 7, want: []string{"old", "report", "unused"}},
+		{name: "without database", inactiveDays: 7, want: []string{"old", "report"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewInactiveUserReport(tt.roles, usersFromLog, lastSeen, tt.inactiveDays, day(31))
+
+			got := []string{}
+			for _, u := range r.InactiveUsers {
+				got = append(got, u.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("inactive users = %v, want %v", got, tt.want)
+			}
+			if !r.Now.Equal(day(30)) {
+				t.Errorf("now = %v, want latest log time %v", r.Now, day(30))
+			}
+		})
+	}
+
+	r := NewInactiveUserReport(roles, usersFromLog, lastSeen, 7, day(31))
+	if !reflect.DeepEqual(r.ExcludedRoles, []string{"postgres", "pg_monitor", "readers"}) {
+		t.Errorf("excluded roles = %v", r.ExcludedRoles)
+	}
+	for _, want := range []string{
+		"ALTER ROLE \"old\" NOLOGIN;",
+		"-- old: last seen 2024-01-02 00:00:00, password expired 2024-01-01",
+		"-- unused: not seen in logs",
+		"-- or expire the password: ALTER ROLE \"unused\" VALID UNTIL '2024-01-30';",
+	} {
+		if !strings.Contains(r.Script, want) {
+			t.Errorf("script does not contain %q:\n%s", want, r.Script)
+		}
+	}
+	if strings.Contains(r.Script, `ALTER ROLE "old" VALID UNTIL`) {
+		t.Errorf("script expires the expired password of old:\n%s", r.Script)
+	}
+}
@@ -39,6 +39,7 @@ type ParsedData interface {
 	GetDescription() string
 	GetTime() time.Time
 	GetErrorCode() (string, error)
+	GetApplicationName() (string, error)
 }
 
 type parsingIndex struct {
@@ -48,6 +49,7 @@ type parsingIndex struct {
 	hostIndex      *int
 	databaseIndex  *int
 	errorCodeIndex *int
+	appNameIndex   *int
 }
 
 type parsedData struct {
@@ -131,6 +133,20 @@ func (b *parsedData) GetErrorCode() (string, error) {
 	return e, nil
 }
 
+// GetApplicationName will return application name from parsed data
+func (b *parsedData) GetApplicationName() (string, error) {
+	if b.appNameIndex == nil {
+		return "", fmt.Errorf("application name is not set in this parser")
+	}
+
+	a := b.parsedData.Get(*b.appNameIndex)
+	if a == "" || a == "[unknown]" {
+		return "", fmt.Errorf("invalid value for application name")
+	}
+
+	return a, nil
+}
+
 func (b *parsedData) GetLogLevel() string {
 	return b.parsedData.Get(b.levelIndex)
 }
@@ -217,6 +233,12 @@ func (b *baseParser) SetErrorCodeIndex(errorCodeIndex int) *baseParser {
 	return b
 }
 
+// SetAppNameIndex will set appNameIndex
+func (b *baseParser) SetAppNameIndex(appNameIndex int) *baseParser {
+	b.appNameIndex = &appNameIndex
+	return b
+}
+
 // SetDatabaseIndex will set databaseIndex
 func (b *baseParser) SetDatabaseIndex(databaseIndex int) *baseParser {
 	b.databaseIndex = &databaseIndex
@@ -4,6 +4,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/klouddb/klouddbshield/pkg/config"
 	"github.com/klouddb/klouddbshield/pkg/hbarules"
@@ -26,30 +27,44 @@ func NewUniqueIPParser(logParserCnf *config.LogParser) *UniqueIPParser {
 }
 
 func (u *UniqueIPParser) Feed(parsedData ParsedData) error {
+	if ip, ok := u.GetIP(parsedData); ok {
+		u.uniqueIPs.Add(ip)
+	}
+	return nil
+}
+
+// GetIP returns the client ip of the log line, from log line prefix or from
+// the connection received message of log_connections.
+func (u *UniqueIPParser) GetIP(parsedData ParsedData) (string, bool) {
 
 	// if logline prefix contains %h then use base parser then try parsing loglineprefix
-	if strings.Contains(u.logParserCnf.PgSettings.LogLinePrefix, "%h") || strings.Contains(u.logParserCnf.PgSettings.LogLinePrefix, "%r") {
+	if u.logParserCnf.HasPrefixField("%h", "%r") {
 		if host, err := parsedData.GetHost(); err == nil {
-			u.uniqueIPs.Add(host)
-			return nil
+			return host, true
 		}
 	}
 
 	// if logConnection is not enabled then return as below logic
 	// is dependent on logConnection
 	if !u.logParserCnf.PgSettings.LogConnections {
-		return nil
+		return "", false
 	}
 
 	desc := parsedData.GetDescription()
 	if !LogPrefixPostgresIpsRegexp.MatchString(desc) {
-		return nil
+		return "", false
 	}
 
 	var parts utils.StringSlice = LogPrefixPostgresIpsRegexp.FindStringSubmatch(desc)
 
-	u.uniqueIPs.Add(parts.Get(3))
-	return nil
+	return parts.Get(3), true
+}
+
+// AddIPs adds the ips found in the previous runs.
+func (u *UniqueIPParser) AddIPs(ips ...string) {
+	for _, ip := range ips {
+		u.uniqueIPs.Add(ip)
+	}
 }
 
 func (u *UniqueIPParser) GetUniqueIPs() map[string]bool {
@@ -59,20 +74,26 @@ func (u *UniqueIPParser) GetUniqueIPs() map[string]bool {
 type UniqueUserParser struct {
 	uniqueUsers  *utils.LockSet
 	logParserCnf *config.LogParser
+
+	// lastSeen is the time of the latest log line of the users.
+	lastSeen map[string]time.Time
+	mt       sync.Mutex
 }
 
 func NewUserParser(logParserCnf *config.LogParser) *UniqueUserParser {
 	return &UniqueUserParser{
 		uniqueUsers:  utils.NewLockSet(),
 		logParserCnf: logParserCnf,
+		lastSeen:     map[string]time.Time{},
 	}
 }
 
 func (u *UniqueUserParser) Feed(parsedData ParsedData) error {
 
-	if strings.Contains(u.logParserCnf.PgSettings.LogLinePrefix, "%u") {
+	if u.logParserCnf.HasPrefixField("%u") {
 		if user, err := parsedData.GetUser(); err == nil {
 			u.uniqueUsers.Add(user)
+			u.observe(user, parsedData.GetTime())
 			return nil
 		}
 	}
@@ -89,14 +110,53 @@ func (u *UniqueUserParser) Feed(parsedData ParsedData) error {
 	var parts utils.StringSlice = UserConnAuthRegexp.FindStringSubmatch(desc)
 
 	u.uniqueUsers.Add(parts.Get(3))
+	u.observe(parts.Get(3), parsedData.GetTime())
 
 	return nil
 }
 
+func (u *UniqueUserParser) observe(user string, t time.Time) {
+	u.mt.Lock()
+	defer u.mt.Unlock()
+
+	if t.After(u.lastSeen[user]) {
+		u.lastSeen[user] = t
+	}
+}
+
+// AddUsers adds the users found in the previous runs.
+func (u *UniqueUserParser) AddUsers(users ...string) {
+	for _, user := range users {
+		u.uniqueUsers.Add(user)
+	}
+}
+
+// AddLastSeen adds the last seen time of the users found in the previous
+// runs.
+func (u *UniqueUserParser) AddLastSeen(lastSeen map[string]time.Time) {
+	for user, t := range lastSeen {
+		u.uniqueUsers.Add(user)
+		u.observe(user, t)
+	}
+}
+
 func (u *UniqueUserParser) GetUniqueUser() map[string]bool {
 	return u.uniqueUsers.GetAll()
 }
 
+// GetLastSeen returns the time of the latest log line of the users, users
+// without time in the log lines are not in it.
+func (u *UniqueUserParser) GetLastSeen() map[string]time.Time {
+	u.mt.Lock()
+	defer u.mt.Unlock()
+
+	out := make(map[string]time.Time, len(u.lastSeen))
+	for user, t := range u.lastSeen {
+		out[user] = t
+	}
+	return out
+}
+
 type HbaUnusedLineParser struct {
 	logParserCnf *config.LogParser
 
@@ -182,11 +242,27 @@ func (u *HbaUnusedLineParser) GetUnusedLines() []hbarules.HBARawLine {
 	return u.hbafileRulesValidator.GetUnusedLines()
 }
 
+// GetLineUsage returns the hit counts of the lines with the connections
+// matched with them.
+func (u *HbaUnusedLineParser) GetLineUsage() []hbarules.HBALineUsage {
+	return u.hbafileRulesValidator.GetLineUsage()
+}
+
 type LeakedPasswordResponse struct {
 	Query    string
 	Password string
 }
 
+// RedactedQuery returns the query with the password masked, it is used
+// wherever the leak leaves the html report.
+func (l LeakedPasswordResponse) RedactedQuery() string {
+	query := MaskPasswords(l.Query)
+	if l.Password != "" {
+		query = strings.ReplaceAll(query, l.Password, "***")
+	}
+	return query
+}
+
 type PasswordLeakParser struct {
 	logParserCnf *config.LogParser
 
@@ -208,31 +284,41 @@ func NewPasswordLeakParser(logParserCnf *config.LogParser) *PasswordLeakParser {
 }
 
 func (u *PasswordLeakParser) Feed(parsedData ParsedData) error {
+	leak, ok := u.Match(parsedData)
+	if !ok {
+		return nil
+	}
+
+	u.mt.Lock()
+	defer u.mt.Unlock()
+
+	u.leakPasswordResp = append(u.leakPasswordResp, leak)
+
+	return nil
+}
+
+// Match returns the plain text password of the log line, if any.
+func (u *PasswordLeakParser) Match(parsedData ParsedData) (LeakedPasswordResponse, bool) {
 
 	msg := parsedData.GetDescription()
 
 	resp := u.passwordRegex.FindStringSubmatch(msg)
 	if len(resp) == 0 {
-		return nil
+		return LeakedPasswordResponse{}, false
 	}
 
 	passwordLower := strings.ToLower(resp[1])
 	// if it is encrypted password then we can consider it as safe
 	for _, alg := range u.supportedEncryptionAlgorithms {
 		if strings.HasPrefix(passwordLower, alg) && len(passwordLower) > len(alg) {
-			return nil
+			return LeakedPasswordResponse{}, false
 		}
 	}
 
-	u.mt.Lock()
-	defer u.mt.Unlock()
-
-	u.leakPasswordResp = append(u.leakPasswordResp, LeakedPasswordResponse{
+	return LeakedPasswordResponse{
 		Query:    msg,
 		Password: resp[1],
-	})
-
-	return nil
+	}, true
 }
 
 func (u *PasswordLeakParser) GetLeakedPasswords() []LeakedPasswordResponse {
@@ -241,3 +327,23 @@ func (u *PasswordLeakParser) GetLeakedPasswords() []LeakedPasswordResponse {
 
 	return u.leakPasswordResp
 }
+
+// authFailureErrorCodes are invalid_authorization_specification and
+// invalid_password.
+var authFailureErrorCodes = utils.NewSetFromSlice([]string{"28000", "28P01"})
+
+var authFailureRegexp = regexp.MustCompile(`(?i)(password authentication failed for user|no pg_hba\.conf entry for|authentication failed for user|role "[^"]*" does not exist)`)
+
+// IsAuthFailure reports whether the log line is a failed authentication. The
+// error code is used when it is in the log line, otherwise the message.
+func IsAuthFailure(parsedData ParsedData) bool {
+	if !strings.EqualFold(parsedData.GetLogLevel(), "FATAL") {
+		return false
+	}
+
+	if errorCode, err := parsedData.GetErrorCode(); err == nil {
+		return authFailureErrorCodes.Contains(errorCode)
+	}
+
+	return authFailureRegexp.MatchString(parsedData.GetDescription())
+}
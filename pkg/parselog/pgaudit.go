@@ -0,0 +1,229 @@
+package parselog
+
+import (
+	"encoding/csv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/utils"
+)
+
+// pgAuditPrefix is the start of the messages logged by pgAudit, the rest of
+// the message is the csv payload.
+const pgAuditPrefix = "AUDIT: "
+
+// classes of the statements logged by pgAudit
+const (
+	PgAuditClass_Read     = "READ"
+	PgAuditClass_Write    = "WRITE"
+	PgAuditClass_Function = "FUNCTION"
+	PgAuditClass_Role     = "ROLE"
+	PgAuditClass_DDL      = "DDL"
+	PgAuditClass_Misc     = "MISC"
+)
+
+// PgAuditEvent is a statement logged by pgAudit.
+type PgAuditEvent struct {
+	Time     time.Time
+	User     string
+	Database string
+	Host     string
+
+	// AuditType is SESSION or OBJECT.
+	AuditType      string
+	StatementID    int
+	SubstatementID int
+	Class          string
+	Command        string
+	ObjectType     string
+	ObjectName     string
+	// Statement is <previous statement logged> for the substatements when
+	// pgaudit.log_statement_once is on.
+	Statement string
+}
+
+// ParsePgAuditMessage parses the csv payload of the pgAudit message, like
+// AUDIT: SESSION,1,1,READ,SELECT,TABLE,public.account,"select * from account",<not logged>
+func ParsePgAuditMessage(msg string) (PgAuditEvent, bool) {
+	msg = strings.TrimSpace(msg)
+	if !strings.HasPrefix(msg, pgAuditPrefix) {
+		return PgAuditEvent{}, false
+	}
+
+	r := csv.NewReader(strings.NewReader(msg[len(pgAuditPrefix):]))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	fields, err := r.Read()
+	// parameter and rows fields are optional
+	if err != nil || len(fields) < 8 {
+		return PgAuditEvent{}, false
+	}
+
+	var f utils.StringSlice = fields
+	e := PgAuditEvent{
+		AuditType:  f.Get(0),
+		Class:      f.Get(3),
+		Command:    f.Get(4),
+		ObjectType: f.Get(5),
+		ObjectName: f.Get(6),
+		Statement:  f.Get(7),
+	}
+	e.StatementID, _ = strconv.Atoi(f.Get(1))
+	e.SubstatementID, _ = strconv.Atoi(f.Get(2))
+
+	return e, true
+}
+
+// PgAuditParser collects the statements logged by pgAudit.
+type PgAuditParser struct {
+	logParserCnf *config.LogParser
+
+	events []PgAuditEvent
+	mt     sync.Mutex
+}
+
+func NewPgAuditParser(logParserCnf *config.LogParser) *PgAuditParser {
+	return &PgAuditParser{logParserCnf: logParserCnf}
+}
+
+func (p *PgAuditParser) Feed(parsedData ParsedData) error {
+	e, ok := ParsePgAuditMessage(parsedData.GetDescription())
+	if !ok {
+		return nil
+	}
+
+	e.Time = parsedData.GetTime()
+	e.User, _ = parsedData.GetUser()
+	e.Database, _ = parsedData.GetDatabase()
+	e.Host, _ = parsedData.GetHost()
+
+	p.mt.Lock()
+	p.events = append(p.events, e)
+	p.mt.Unlock()
+
+	return nil
+}
+
+// GetPgAuditEvents returns the pgAudit events in the order of time.
+func (p *PgAuditParser) GetPgAuditEvents() []PgAuditEvent {
+	p.mt.Lock()
+	defer p.mt.Unlock()
+
+	sort.SliceStable(p.events, func(i, j int) bool {
+		return p.events[i].Time.Before(p.events[j].Time)
+	})
+	return p.events
+}
+
+// PgAuditObjectAccess is the access of a role to an object.
+type PgAuditObjectAccess struct {
+	User       string
+	ObjectType string
+	ObjectName string
+	Reads      int
+	Writes     int
+	// Commands are the distinct commands run on the object.
+	Commands []string
+	LastSeen time.Time
+	// PIILabels are the labels of the table in the PII scan output.
+	PIILabels []string
+}
+
+// PgAuditReport is the report of the pgAudit events.
+type PgAuditReport struct {
+	Total   int
+	ByClass map[string]int
+	// ObjectAccess is sorted by user and object name.
+	ObjectAccess []PgAuditObjectAccess
+	// DDLChanges are the events of DDL and ROLE classes.
+	DDLChanges []PgAuditEvent
+	// PIIReads are the object accesses with reads of the tables which have
+	// PII data.
+	PIIReads []PgAuditObjectAccess
+}
+
+// NewPgAuditReport creates the report of the events. piiTables has the PII
+// labels of the tables by schema qualified name, it is nil when PII scan
+// output is not given.
+func NewPgAuditReport(events []PgAuditEvent, piiTables map[string][]string) *PgAuditReport {
+	r := &PgAuditReport{
+		Total:   len(events),
+		ByClass: map[string]int{},
+	}
+
+	labels := map[string][]string{}
+	for name, l := range piiTables {
+		labels[normalizeObjectName(name)] = l
+	}
+
+	type accessKey struct {
+		user, objectType, objectName string
+	}
+	access := map[accessKey]*PgAuditObjectAccess{}
+	commands := map[accessKey]utils.Set[string]{}
+	for _, e := range events {
+		r.ByClass[e.Class]++
+		if e.Class == PgAuditClass_DDL || e.Class == PgAuditClass_Role {
+			// role statements can have the password, like privileged audit
+			change := e
+			change.Statement = MaskPasswords(change.Statement)
+			r.DDLChanges = append(r.DDLChanges, change)
+		}
+		if e.ObjectName == "" {
+			continue
+		}
+
+		key := accessKey{e.User, e.ObjectType, e.ObjectName}
+		a, ok := access[key]
+		if !ok {
+			a = &PgAuditObjectAccess{User: e.User, ObjectType: e.ObjectType, ObjectName: e.ObjectName}
+			a.PIILabels = labels[normalizeObjectName(e.ObjectName)]
+			access[key] = a
+			commands[key] = utils.NewSet[string]()
+		}
+		switch e.Class {
+		case PgAuditClass_Read:
+			a.Reads++
+		case PgAuditClass_Write:
+			a.Writes++
+		}
+		commands[key].Add(e.Command)
+		if e.Time.After(a.LastSeen) {
+			a.LastSeen = e.Time
+		}
+	}
+
+	for key, a := range access {
+		a.Commands = commands[key].Slice()
+		sort.Strings(a.Commands)
+		r.ObjectAccess = append(r.ObjectAccess, *a)
+	}
+	sort.Slice(r.ObjectAccess, func(i, j int) bool {
+		a, b := r.ObjectAccess[i], r.ObjectAccess[j]
+		if a.User != b.User {
+			return a.User < b.User
+		}
+		if a.ObjectName != b.ObjectName {
+			return a.ObjectName < b.ObjectName
+		}
+		return a.ObjectType < b.ObjectType
+	})
+
+	for _, a := range r.ObjectAccess {
+		if a.Reads > 0 && len(a.PIILabels) > 0 {
+			r.PIIReads = append(r.PIIReads, a)
+		}
+	}
+
+	return r
+}
+
+// normalizeObjectName returns the object name without quotes in lower case,
+// like public.account for "public"."Account".
+func normalizeObjectName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, `"`, ""))
+}
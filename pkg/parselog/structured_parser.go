@@ -0,0 +1,282 @@
+package parselog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/klouddb/klouddbshield/model"
+	cons "github.com/klouddb/klouddbshield/pkg/const"
+)
+
+// ErrInvalidEntry is returned by EntryReader for an entry which can not be
+// parsed, reading can continue after it.
+var ErrInvalidEntry = errors.New("invalid log entry")
+
+// EntryReader reads the log entries one by one. Next returns io.EOF after
+// the last entry.
+type EntryReader interface {
+	Next() (ParsedData, error)
+}
+
+// MultilineParser is implemented by the parsers of the log formats where an
+// entry can span multiple lines, like csvlog with multiline queries. Files of
+// such formats are read entry by entry instead of splitting them in lines.
+type MultilineParser interface {
+	BaseParser
+	NewEntryReader(r io.Reader) EntryReader
+}
+
+// GetBaseParser returns the parser for the log format, log line prefix is
+// only used for stderr and syslog formats.
+func GetBaseParser(logFormat string, pgSettings *model.PgSettings) BaseParser {
+	switch logFormat {
+	case cons.LogFormat_CSV:
+		return NewCsvLogParser()
+	case cons.LogFormat_JSON:
+		return NewJsonLogParser()
+	case cons.LogFormat_Syslog:
+		return NewSyslogParser(pgSettings.SyslogIdent, pgSettings.LogLinePrefix)
+	default:
+		return GetDynamicBaseParser(pgSettings.LogLinePrefix)
+	}
+}
+
+// structuredData is the parsed entry of csvlog and jsonlog, which have all
+// the fields of log line prefix.
+type structuredData struct {
+	user      string
+	host      string
+	database  string
+	level     string
+	message   string
+	errorCode string
+	appName   string
+	time      time.Time
+}
+
+func (s *structuredData) GetUser() (string, error) {
+	if s.user == "" || s.user == "[unknown]" {
+		return "", fmt.Errorf("invalid value for user")
+	}
+	return s.user, nil
+}
+
+func (s *structuredData) GetHost() (string, error) {
+	if s.host == "" || s.host == "[unknown]" {
+		return "", fmt.Errorf("invalid value for host")
+	}
+	return s.host, nil
+}
+
+func (s *structuredData) GetDatabase() (string, error) {
+	if s.database == "" || s.database == "[unknown]" {
+		return "", fmt.Errorf("invalid value for database")
+	}
+	return s.database, nil
+}
+
+func (s *structuredData) GetErrorCode() (string, error) {
+	if s.errorCode == "" || s.errorCode == "00000" {
+		return "", fmt.Errorf("invalid value for error code")
+	}
+	return s.errorCode, nil
+}
+
+func (s *structuredData) GetApplicationName() (string, error) {
+	if s.appName == "" || s.appName == "[unknown]" {
+		return "", fmt.Errorf("invalid value for application name")
+	}
+	return s.appName, nil
+}
+
+func (s *structuredData) GetLogLevel() string {
+	return s.level
+}
+
+func (s *structuredData) GetDescription() string {
+	return s.message
+}
+
+func (s *structuredData) GetTime() time.Time {
+	return s.time
+}
+
+// structuredTimeFormats are the formats of log_time in csvlog and timestamp
+// in jsonlog, timezone is as per log_timezone.
+var structuredTimeFormats = []string{
+	"2006-01-02 15:04:05.999 MST",
+	"2006-01-02 15:04:05.999 -0700",
+	"2006-01-02 15:04:05.999 -07",
+}
+
+func parseStructuredTime(s string) (time.Time, error) {
+	var err error
+	for _, format := range structuredTimeFormats {
+		var t time.Time
+		t, err = time.Parse(format, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// hostWithoutPort removes the port from connection_from of csvlog, which is
+// host:port for tcp connections and [local] for unix socket.
+func hostWithoutPort(connectionFrom string) string {
+	i := strings.LastIndex(connectionFrom, ":")
+	if i <= 0 || strings.HasSuffix(connectionFrom, "]") {
+		return connectionFrom
+	}
+	for _, c := range connectionFrom[i+1:] {
+		if c < '0' || c > '9' {
+			return connectionFrom
+		}
+	}
+	return connectionFrom[:i]
+}
+
+// columns of csvlog, the columns added in newer versions are at the end so
+// the indexes are same for all the versions.
+const (
+	csvColumn_LogTime        = 0
+	csvColumn_UserName       = 1
+	csvColumn_DatabaseName   = 2
+	csvColumn_ConnectionFrom = 4
+	csvColumn_ErrorSeverity  = 11
+	csvColumn_SQLStateCode   = 12
+	csvColumn_Message        = 13
+	csvColumn_AppName        = 22
+
+	csvMinColumns = 14
+)
+
+type csvLogParser struct{}
+
+// NewCsvLogParser returns the parser for csvlog files.
+func NewCsvLogParser() *csvLogParser {
+	return &csvLogParser{}
+}
+
+// Parse parses a single csvlog entry, entry can have new lines in quoted
+// fields.
+func (c *csvLogParser) Parse(line string) (ParsedData, error) {
+	record, err := newCsvReader(strings.NewReader(line)).Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid csvlog format: %v", err)
+	}
+	return parseCsvRecord(record)
+}
+
+func (c *csvLogParser) NewEntryReader(r io.Reader) EntryReader {
+	return &csvEntryReader{r: newCsvReader(r)}
+}
+
+func newCsvReader(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(r)
+	// number of columns changes with postgres version
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.ReuseRecord = true
+	return cr
+}
+
+type csvEntryReader struct {
+	r *csv.Reader
+}
+
+func (c *csvEntryReader) Next() (ParsedData, error) {
+	record, err := c.r.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEntry, err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	data, err := parseCsvRecord(record)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEntry, err)
+	}
+	return data, nil
+}
+
+func parseCsvRecord(record []string) (ParsedData, error) {
+	if len(record) < csvMinColumns {
+		return nil, fmt.Errorf("invalid csvlog format: got %d columns, want at least %d", len(record), csvMinColumns)
+	}
+
+	t, err := parseStructuredTime(record[csvColumn_LogTime])
+	if err != nil {
+		return nil, fmt.Errorf("invalid csvlog time: %v", err)
+	}
+
+	data := &structuredData{
+		user:      record[csvColumn_UserName],
+		host:      hostWithoutPort(record[csvColumn_ConnectionFrom]),
+		database:  record[csvColumn_DatabaseName],
+		level:     record[csvColumn_ErrorSeverity],
+		message:   record[csvColumn_Message],
+		errorCode: record[csvColumn_SQLStateCode],
+		time:      t,
+	}
+	if len(record) > csvColumn_AppName {
+		data.appName = record[csvColumn_AppName]
+	}
+	return data, nil
+}
+
+// jsonLogEntry has the keys of jsonlog used by the parsers.
+type jsonLogEntry struct {
+	Timestamp     string `json:"timestamp"`
+	User          string `json:"user"`
+	DBName        string `json:"dbname"`
+	RemoteHost    string `json:"remote_host"`
+	ErrorSeverity string `json:"error_severity"`
+	StateCode     string `json:"state_code"`
+	Message       string `json:"message"`
+	AppName       string `json:"application_name"`
+}
+
+type jsonLogParser struct{}
+
+// NewJsonLogParser returns the parser for jsonlog files of postgres 15+.
+// Every entry is a single line, so it works with line based processing.
+func NewJsonLogParser() *jsonLogParser {
+	return &jsonLogParser{}
+}
+
+func (j *jsonLogParser) Parse(line string) (ParsedData, error) {
+	var e jsonLogEntry
+	if err := json.Unmarshal([]byte(line), &e); err != nil {
+		return nil, fmt.Errorf("invalid jsonlog format: %v", err)
+	}
+	if e.Timestamp == "" || e.ErrorSeverity == "" {
+		return nil, fmt.Errorf("invalid jsonlog format: timestamp and error_severity are required")
+	}
+
+	t, err := parseStructuredTime(e.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jsonlog time: %v", err)
+	}
+
+	return &structuredData{
+		user:      e.User,
+		host:      e.RemoteHost,
+		database:  e.DBName,
+		level:     e.ErrorSeverity,
+		message:   e.Message,
+		errorCode: e.StateCode,
+		appName:   e.AppName,
+		time:      t,
+	}, nil
+}
@@ -0,0 +1,113 @@
+package parselog
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_csvLogParser(t *testing.T) {
+	logs := `2024-01-02 03:04:05.123 UTC,"user1","db1",1234,"10.0.0.1:53412",65b2a1c1.4d2,1,"authentication",2024-01-02 03:04:05 UTC,3/1,0,LOG,00000,"connection authorized: user=user1 database=db1",,,,,,,,,"psql","client backend",,0
+2024-01-02 03:04:06.456 UTC,"user1","db1",1234,"10.0.0.1:53412",65b2a1c1.4d2,2,"idle",2024-01-02 03:04:05 UTC,3/2,0,LOG,00000,"statement: ALTER USER user1
+	PASSWORD 'secret'",,,,,,,,,"psql","client backend",,0
+invalid line
+2024-01-02 03:04:07.789 UTC,,,999,"[local]",65b2a1c2.3e7,1,"",2024-01-02 03:04:07 UTC,,0,FATAL,28000,"no pg_hba.conf entry",,,,,,,,,"","client backend",,0
+`
+
+	r := NewCsvLogParser().NewEntryReader(strings.NewReader(logs))
+
+	var entries []ParsedData
+	invalid := 0
+	for {
+		d, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			invalid++
+			continue
+		}
+		entries = append(entries, d)
+	}
+
+	if len(entries) != 3 || invalid != 1 {
+		t.Fatalf("got %d entries and %d invalid, want 3 and 1", len(entries), invalid)
+	}
+
+	user, _ := entries[0].GetUser()
+	host, _ := entries[0].GetHost()
+	database, _ := entries[0].GetDatabase()
+	if user != "user1" || host != "10.0.0.1" || database != "db1" || entries[0].GetLogLevel() != "LOG" {
+		t.Errorf("unexpected first entry user=%s host=%s database=%s level=%s", user, host, database, entries[0].GetLogLevel())
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 123000000, time.UTC); !entries[0].GetTime().Equal(want) {
+		t.Errorf("got time %v, want %v", entries[0].GetTime(), want)
+	}
+
+	if want := "statement: ALTER USER user1\n\tPASSWORD 'secret'"; entries[1].GetDescription() != want {
+		t.Errorf("multiline message = %q, want %q", entries[1].GetDescription(), want)
+	}
+
+	if _, err := entries[2].GetUser(); err == nil {
+		t.Errorf("empty user should be invalid")
+	}
+	if host, _ := entries[2].GetHost(); host != "[local]" {
+		t.Errorf("got host %s, want [local]", host)
+	}
+	if code, _ := entries[2].GetErrorCode(); code != "28000" {
+		t.Errorf("got error code %s, want 28000", code)
+	}
+	if app, _ := entries[0].GetApplicationName(); app != "psql" {
+		t.Errorf("got application name %s, want psql", app)
+	}
+	if _, err := entries[2].GetApplicationName(); err == nil {
+		t.Errorf("empty application name should be invalid")
+	}
+}
+
+func Test_jsonLogParser(t *testing.T) {
+	p := NewJsonLogParser()
+
+	d, err := p.Parse(`{"timestamp":"2024-01-02 03:04:05.123 UTC","user":"user1","dbname":"db1","pid":1234,"remote_host":"10.0.0.1","remote_port":53412,"error_severity":"LOG","message":"statement: ALTER USER user1 PASSWORD 'secret'","application_name":"psql","backend_type":"client backend"}`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	user, _ := d.GetUser()
+	host, _ := d.GetHost()
+	database, _ := d.GetDatabase()
+	if user != "user1" || host != "10.0.0.1" || database != "db1" || d.GetLogLevel() != "LOG" {
+		t.Errorf("unexpected entry user=%s host=%s database=%s level=%s", user, host, database, d.GetLogLevel())
+	}
+	if app, _ := d.GetApplicationName(); app != "psql" {
+		t.Errorf("got application name %s, want psql", app)
+	}
+	if !strings.HasPrefix(d.GetDescription(), "statement: ALTER USER") {
+		t.Errorf("unexpected description %s", d.GetDescription())
+	}
+
+	for _, line := range []string{
+		`2024-01-02 03:04:05 UTC [1]: LOG:  not json`,
+		`{"user":"user1"}`,
+	} {
+		if _, err := p.Parse(line); err == nil {
+			t.Errorf("Parse(%s) should fail", line)
+		}
+	}
+}
+
+func Test_hostWithoutPort(t *testing.T) {
+	tests := map[string]string{
+		"10.0.0.1:5432": "10.0.0.1",
+		"::1:5432":      "::1",
+		"[local]":       "[local]",
+		"db.example":    "db.example",
+		"":              "",
+	}
+	for in, want := range tests {
+		if got := hostWithoutPort(in); got != want {
+			t.Errorf("hostWithoutPort(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package parselog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultSyslogIdent is the default syslog_ident of postgres.
+const defaultSyslogIdent = "postgres"
+
+// Chunks of a split message are logged one after another by the backend,
+// but lines of other processes can be between them. A message is complete
+// when the next chunk is not logged in syslogChunkTimeout or in
+// syslogChunkWindow lines.
+const (
+	syslogChunkTimeout = time.Second
+	syslogChunkWindow  = 1000
+)
+
+var (
+	// syslog3164Regex matches the BSD syslog header, which is also the format
+	// of journalctl short, short-precise and short-iso output:
+	//
+	//	Jan  2 03:04:05 dbhost postgres[1234]: [5-1] message
+	//	2024-01-02T03:04:05+0000 dbhost postgres[1234]: [5-1] message
+	syslog3164Regex = regexp.MustCompile(`^(?:<\d{1,3}>)?([A-Z][a-z]{2} {1,2}\d{1,2} \d{2}:\d{2}:\d{2}(?:\.\d+)?|\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2})) (\S+) ([^\s\[:]+)(?:\[(\d+)\])?: ?(.*)$`)
+
+	// syslog5424Regex matches the RFC 5424 header, structured data is matched
+	// as a whole as postgres does not log it:
+	//
+	//	<134>1 2024-01-02T03:04:05.123456+00:00 dbhost postgres 1234 - - [5-1] message
+	syslog5424Regex = regexp.MustCompile(`^<\d{1,3}>\d{1,2} (\S+) (\S+) (\S+) (\S+) \S+ (?:-|(?:\[(?:[^\]"]|"(?:[^"\\]|\\.)*")*\])+) ?(.*)$`)
+
+	// syslogSequenceRegex matches the sequence and chunk number added by
+	// postgres, [seq-chunk] when both syslog_sequence_numbers and
+	// syslog_split_messages are on, [seq] or [chunk] when only one of them
+	// is on.
+	syslogSequenceRegex = regexp.MustCompile(`^\[(\d+)(?:-(\d+))?\](?: |$)(.*)$`)
+)
+
+// syslogMessage is a postgres message with the syslog header removed.
+type syslogMessage struct {
+	time    time.Time
+	program string
+	pid     string
+
+	// number is the sequence number, or the chunk number when only
+	// syslog_split_messages is on. chunk is set only when both are logged.
+	number  int
+	chunk   int
+	hasSeq  bool
+	message string
+}
+
+// parseSyslogLine removes the syslog header and the sequence number of
+// postgres from the line. Year of the BSD timestamp is taken from now.
+func parseSyslogLine(line string, now time.Time) (*syslogMessage, error) {
+	m := &syslogMessage{}
+
+	var timePart string
+	if match := syslog5424Regex.FindStringSubmatch(line); match != nil {
+		timePart, m.program, m.pid, m.message = match[1], match[3], match[4], match[5]
+		m.message = strings.TrimPrefix(m.message, "\ufeff")
+	} else if match := syslog3164Regex.FindStringSubmatch(line); match != nil {
+		timePart, m.program, m.pid, m.message = match[1], match[3], match[4], match[5]
+	} else {
+		return nil, fmt.Errorf("invalid syslog format")
+	}
+
+	t, err := parseSyslogTime(timePart, now)
+	if err != nil {
+		return nil, fmt.Errorf("invalid syslog time: %v", err)
+	}
+	m.time = t
+
+	if match := syslogSequenceRegex.FindStringSubmatch(m.message); match != nil {
+		m.hasSeq = true
+		m.number, _ = strconv.Atoi(match[1])
+		if match[2] != "" {
+			m.chunk, _ = strconv.Atoi(match[2])
+		}
+		m.message = match[3]
+	}
+
+	// syslog daemons escape the control characters, the tabs of postgres
+	// messages like the one starting the continuation lines are restored
+	// like in stderr logs.
+	m.message = strings.ReplaceAll(m.message, "#011", "\t")
+
+	return m, nil
+}
+
+// syslogTimeFormats are the formats of RFC 5424 and ISO timestamps of
+// rsyslog and journalctl.
+var syslogTimeFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999-0700",
+}
+
+func parseSyslogTime(s string, now time.Time) (time.Time, error) {
+	if s == "" || s[0] < 'A' || s[0] > 'Z' {
+		var err error
+		for _, format := range syslogTimeFormats {
+			var t time.Time
+			t, err = time.Parse(format, s)
+			if err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, err
+	}
+
+	// BSD timestamp is in local time without year, a time after now is of
+	// the previous year, like December lines read in January. It is parsed
+	// with the year, Feb 29 is valid only in leap years.
+	var t time.Time
+	var err error
+	for year := now.Year(); year >= now.Year()-4; year-- {
+		t, err = time.ParseInLocation("2006 Jan _2 15:04:05", fmt.Sprintf("%d %s", year, s), now.Location())
+		if err == nil && !t.After(now.Add(24*time.Hour)) {
+			return t, nil
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("time %s is after %s", s, now)
+	}
+	return time.Time{}, err
+}
+
+type syslogParser struct {
+	ident  string
+	prefix BaseParser
+	now    func() time.Time
+}
+
+// NewSyslogParser returns the parser for the logs written to syslog with
+// log_destination = syslog. The syslog header is removed and rest of the
+// line is parsed with the log line prefix. Lines of other programs than
+// syslog_ident are skipped.
+func NewSyslogParser(ident, logLinePrefix string) *syslogParser {
+	if ident == "" {
+		ident = defaultSyslogIdent
+	}
+	return &syslogParser{ident: ident, prefix: GetDynamicBaseParser(logLinePrefix), now: time.Now}
+}
+
+// Parse parses a single syslog line, chunks of the split messages are only
+// joined by the entry reader.
+func (s *syslogParser) Parse(line string) (ParsedData, error) {
+	m, err := parseSyslogLine(line, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if m.program != s.ident {
+		return nil, fmt.Errorf("not a %s message", s.ident)
+	}
+	return s.parseMessage(m.message, m.time)
+}
+
+func (s *syslogParser) parseMessage(message string, t time.Time) (ParsedData, error) {
+	data, err := s.prefix.Parse(message)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogData{ParsedData: data, time: t}, nil
+}
+
+func (s *syslogParser) NewEntryReader(r io.Reader) EntryReader {
+	now := s.now()
+	return &syslogEntryReader{
+		p: s,
+		r: bufio.NewReader(r),
+		j: newSyslogChunkJoiner(s, func() time.Time { return now }),
+	}
+}
+
+// syslogData uses the time of syslog header when log line prefix does not
+// have the time.
+type syslogData struct {
+	ParsedData
+	time time.Time
+}
+
+func (s *syslogData) GetTime() time.Time {
+	if t := s.ParsedData.GetTime(); !t.IsZero() {
+		return t
+	}
+	return s.time
+}
+
+// syslogEntry is a message being assembled from its chunks.
+type syslogEntry struct {
+	first   *syslogMessage
+	message strings.Builder
+	// line is the first line with the next chunks appended, it is parsed
+	// as a single line in follow mode.
+	line     strings.Builder
+	number   int
+	chunk    int
+	lastLine int
+	lastTime time.Time
+	// done is set when the process logs the next message.
+	done bool
+}
+
+// syslogEntryReader joins the chunks of the messages split by postgres as
+// per syslog_split_messages.
+type syslogEntryReader struct {
+	p *syslogParser
+	r *bufio.Reader
+	j *SyslogChunkJoiner
+
+	eof   bool
+	ready []*syslogEntry
+}
+
+func (s *syslogEntryReader) Next() (ParsedData, error) {
+	for {
+		if len(s.ready) > 0 {
+			e := s.ready[0]
+			s.ready = s.ready[1:]
+			data, err := s.p.parseMessage(e.message.String(), e.first.time)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidEntry, err)
+			}
+			return data, nil
+		}
+
+		if s.eof {
+			s.ready = s.j.flush()
+			if len(s.ready) == 0 {
+				return nil, io.EOF
+			}
+			continue
+		}
+
+		line, err := s.r.ReadString('\n')
+		if err == io.EOF {
+			s.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			continue
+		}
+
+		entries, err := s.j.add(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidEntry, err)
+		}
+		s.ready = append(s.ready, entries...)
+	}
+}
+
+// SyslogChunkJoiner joins the chunks of the split messages of the lines
+// added one by one, like the lines of follow mode. Chunks are matched by the
+// process id, and the messages are returned in the order they started.
+type SyslogChunkJoiner struct {
+	p   *syslogParser
+	now func() time.Time
+
+	line    int
+	pending map[string]*syslogEntry
+	order   []*syslogEntry
+}
+
+func newSyslogChunkJoiner(p *syslogParser, now func() time.Time) *SyslogChunkJoiner {
+	return &SyslogChunkJoiner{p: p, now: now, pending: map[string]*syslogEntry{}}
+}
+
+// NewChunkJoiner returns the chunk joiner for the lines read one by one.
+func (s *syslogParser) NewChunkJoiner() *SyslogChunkJoiner {
+	return newSyslogChunkJoiner(s, s.now)
+}
+
+// Add adds the line and returns the messages completed by it as single
+// lines. Invalid line is returned as it is to be reported by the parser.
+func (j *SyslogChunkJoiner) Add(line string) []string {
+	entries, err := j.add(line)
+	if err != nil {
+		return []string{line}
+	}
+	return syslogEntryLines(entries)
+}
+
+// Flush returns all the pending messages as single lines.
+func (j *SyslogChunkJoiner) Flush() []string {
+	return syslogEntryLines(j.flush())
+}
+
+func syslogEntryLines(entries []*syslogEntry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = e.line.String()
+	}
+	return out
+}
+
+// add adds the line and returns the messages completed by it. Lines of
+// other programs are skipped.
+func (j *SyslogChunkJoiner) add(line string) ([]*syslogEntry, error) {
+	j.line++
+	m, err := parseSyslogLine(line, j.now())
+	if err != nil {
+		return nil, err
+	}
+	if m.program == j.p.ident {
+		j.addMessage(m, line)
+	}
+	return j.complete(m.time), nil
+}
+
+// addMessage adds the message in the pending entry of its process when it
+// is the next chunk of the entry, otherwise it starts a new entry.
+func (j *SyslogChunkJoiner) addMessage(m *syslogMessage, line string) {
+	e := j.pending[m.pid]
+	if e != nil && j.continues(e, m) {
+		e.message.WriteString(m.message)
+		e.line.WriteString(m.message)
+		e.number, e.chunk, e.lastLine, e.lastTime = m.number, m.chunk, j.line, m.time
+		return
+	}
+
+	if e != nil {
+		e.done = true
+	}
+
+	e = &syslogEntry{first: m, number: m.number, chunk: m.chunk, lastLine: j.line, lastTime: m.time}
+	e.message.WriteString(m.message)
+	e.line.WriteString(line)
+	j.pending[m.pid] = e
+	j.order = append(j.order, e)
+}
+
+// continues reports whether the message is the next chunk of the entry.
+// With both sequence and chunk number it is known from the numbers. With a
+// single number, it can be either the sequence or the chunk number, so a
+// message is the next chunk only when it does not start with the log line
+// prefix.
+func (j *SyslogChunkJoiner) continues(e *syslogEntry, m *syslogMessage) bool {
+	switch {
+	case !m.hasSeq:
+		return false
+	case m.chunk > 0:
+		return m.chunk > 1 && m.number == e.number && m.chunk == e.chunk+1
+	default:
+		if m.number != e.number+1 {
+			return false
+		}
+		_, err := j.p.prefix.Parse(m.message)
+		return err != nil
+	}
+}
+
+// complete removes and returns the complete entries from the start of the
+// pending entries, an entry waiting for its next chunk holds the ones
+// started after it to keep the order of the messages.
+func (j *SyslogChunkJoiner) complete(now time.Time) []*syslogEntry {
+	var out []*syslogEntry
+	for len(j.order) > 0 {
+		e := j.order[0]
+		if !e.done && e.lastLine >= j.line-syslogChunkWindow && now.Sub(e.lastTime) <= syslogChunkTimeout {
+			break
+		}
+
+		if j.pending[e.first.pid] == e {
+			delete(j.pending, e.first.pid)
+		}
+		j.order = j.order[1:]
+		out = append(out, e)
+	}
+	return out
+}
+
+// flush removes and returns all the pending entries.
+func (j *SyslogChunkJoiner) flush() []*syslogEntry {
+	for _, e := range j.order {
+		e.done = true
+	}
+	return j.complete(time.Time{})
+}
@@ -0,0 +1,184 @@
+package parselog
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseSyslogLine(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		line    string
+		now     time.Time
+		time    time.Time
+		program string
+		pid     string
+		number  int
+		chunk   int
+		message string
+		wantErr bool
+	}{
+		{
+			name:    "rfc 3164",
+			line:    "Jan  2 03:04:05 dbhost postgres[1234]: [5-1] user1@db1 LOG:  statement: SELECT 1",
+			time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			program: "postgres", pid: "1234", number: 5, chunk: 1,
+			message: "user1@db1 LOG:  statement: SELECT 1",
+		},
+		{
+			name:    "rfc 3164 of previous year",
+			line:    "Dec 31 23:59:59 dbhost postgres[1234]: [7] user1@db1 LOG:  statement: SELECT 1",
+			time:    time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC),
+			program: "postgres", pid: "1234", number: 7,
+			message: "user1@db1 LOG:  statement: SELECT 1",
+		},
+		{
+			name:    "escaped tab of continuation",
+			line:    "Jan  2 03:04:05 dbhost postgres[1234]: [5-2] #011FROM t",
+			time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			program: "postgres", pid: "1234", number: 5, chunk: 2,
+			message: "\tFROM t",
+		},
+		{
+			name:    "rfc 3164 of leap day",
+			line:    "Feb 29 03:04:05 dbhost postgres[1234]: [5-1] user1@db1 LOG:  statement: SELECT 1",
+			now:     time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			time:    time.Date(2024, 2, 29, 3, 4, 5, 0, time.UTC),
+			program: "postgres", pid: "1234", number: 5, chunk: 1,
+			message: "user1@db1 LOG:  statement: SELECT 1",
+		},
+		{
+			name:    "escaped tabs in message",
+			line:    "Jan  2 03:04:05 dbhost postgres[1234]: [5-2] #011FROM t#011WHERE a = 1",
+			time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			program: "postgres", pid: "1234", number: 5, chunk: 2,
+			message: "\tFROM t\tWHERE a = 1",
+		},
+		{
+			name:    "journalctl short-iso",
+			line:    "2024-01-02T03:04:05+0000 dbhost postgres[1234]: user1@db1 LOG:  statement: SELECT 1",
+			time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			program: "postgres", pid: "1234",
+			message: "user1@db1 LOG:  statement: SELECT 1",
+		},
+		{
+			name:    "rfc 5424",
+			line:    "<134>1 2024-01-02T03:04:05.5Z dbhost postgres 1234 - - [5-1] user1@db1 LOG:  statement: SELECT 1",
+			time:    time.Date(2024, 1, 2, 3, 4, 5, 500000000, time.UTC),
+			program: "postgres", pid: "1234", number: 5, chunk: 1,
+			message: "user1@db1 LOG:  statement: SELECT 1",
+		},
+		{
+			name:    "rfc 5424 with structured data",
+			line:    `<134>1 2024-01-02T03:04:05Z dbhost postgres 1234 - [meta x="a]b"] [5-1] LOG:  started`,
+			time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			program: "postgres", pid: "1234", number: 5, chunk: 1,
+			message: "LOG:  started",
+		},
+		{
+			name:    "not syslog",
+			line:    "2024-01-02 03:04:05.123 UTC [1234] user1@db1 LOG:  statement: SELECT 1",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lineNow := now
+			if !tt.now.IsZero() {
+				lineNow = tt.now
+			}
+			m, err := parseSyslogLine(tt.line, lineNow)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSyslogLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if !m.time.Equal(tt.time) || m.program != tt.program || m.pid != tt.pid ||
+				m.number != tt.number || m.chunk != tt.chunk || m.message != tt.message {
+				t.Errorf("parseSyslogLine() = %+v", m)
+			}
+		})
+	}
+}
+
+func Test_syslogEntryReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		logs   string
+		want   []string
+	}{
+		{
+			name:   "split messages with sequence numbers",
+			prefix: "%m [%p] %u@%d ",
+			logs: `Jan  2 03:04:05 dbhost postgres[1234]: [5-1] 2024-01-02 03:04:05.123 UTC [1234] user1@db1 LOG:  statement: SELECT a
+Jan  2 03:04:05 dbhost postgres[999]: [3-1] 2024-01-02 03:04:05.200 UTC [999] user2@db1 LOG:  statement: SELECT 2
+Jan  2 03:04:05 dbhost postgres[1234]: [5-2] #011FROM t
+Jan  2 03:04:05 dbhost kernel: eth0 link up
+Jan  2 03:04:05 dbhost postgres[1234]: [6-1] 2024-01-02 03:04:06.123 UTC [1234] user1@db1 LOG:  statement: SELECT 3
+`,
+			want: []string{"user1 statement: SELECT a\tFROM t", "user2 statement: SELECT 2", "user1 statement: SELECT 3"},
+		},
+		{
+			name:   "chunk numbers without sequence numbers",
+			prefix: "%u@%d ",
+			logs: `Jan  2 03:04:05 dbhost postgres[1234]: [1] user1@db1 LOG:  statement: SELECT a
+Jan  2 03:04:05 dbhost postgres[1234]: [2] #011FROM t
+Jan  2 03:04:06 dbhost postgres[1234]: [1] user1@db1 LOG:  statement: SELECT 2
+`,
+			want: []string{"user1 statement: SELECT a\tFROM t", "user1 statement: SELECT 2"},
+		},
+		{
+			name:   "sequence numbers without split messages",
+			prefix: "%u@%d ",
+			logs: `Jan  2 03:04:05 dbhost postgres[1234]: [1] user1@db1 LOG:  statement: SELECT 1
+Jan  2 03:04:06 dbhost postgres[1234]: [2] user1@db1 LOG:  statement: SELECT 2`,
+			want: []string{"user1 statement: SELECT 1", "user1 statement: SELECT 2"},
+		},
+		{
+			name:   "messages in the order they started",
+			prefix: "%u@%d ",
+			logs: `Jan  2 03:04:05 dbhost postgres[1234]: [1-1] user1@db1 LOG:  statement: SELECT 1
+Jan  2 03:04:05 dbhost postgres[999]: [1-1] user2@db1 LOG:  statement: SELECT 2
+Jan  2 03:04:05 dbhost postgres[999]: [2-1] user2@db1 LOG:  statement: SELECT 3
+Jan  2 03:04:07 dbhost postgres[999]: [3-1] user2@db1 LOG:  statement: SELECT 4
+Jan  2 03:04:07 dbhost postgres[1234]: [2-1] user1@db1 LOG:  statement: SELECT 5
+`,
+			want: []string{"user1 statement: SELECT 1", "user2 statement: SELECT 2", "user2 statement: SELECT 3", "user2 statement: SELECT 4", "user1 statement: SELECT 5"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewSyslogParser("", tt.prefix)
+			p.now = func() time.Time { return time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC) }
+			r := p.NewEntryReader(strings.NewReader(tt.logs))
+
+			got := []string{}
+			for {
+				d, err := r.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				user, _ := d.GetUser()
+				got = append(got, user+" "+d.GetDescription())
+				if d.GetTime().IsZero() {
+					t.Errorf("entry %q has no time", got[len(got)-1])
+				}
+			}
+
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("entries = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
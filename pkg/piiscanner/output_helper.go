@@ -2,10 +2,12 @@ package piiscanner
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/jedib0t/go-pretty/v6/text"
@@ -60,6 +62,69 @@ func CreateTabularOutputfile(i *DatabasePIIScanOutput, cnf Config) {
 	fmt.Println("> Low confidence log file created at: [ " + lowConfidenceFilePath + " ]")
 }
 
+// JSONOutputFile is the pii scan output read by pgaudit command of log
+// parser, for finding the reads of the tables with PII data.
+const JSONOutputFile = "kshield_pii_report.json"
+
+func CreateJSONOutputFile(i *DatabasePIIScanOutput) {
+	if i == nil || len(i.Data) == 0 {
+		return
+	}
+
+	out, err := json.MarshalIndent(i, "", "\t")
+	if err != nil {
+		fmt.Println("Error creating json output file: ", text.FgRed.Sprint(err))
+		return
+	}
+
+	if err := os.WriteFile(JSONOutputFile, out, 0600); err != nil {
+		fmt.Println("Error creating json output file: ", text.FgRed.Sprint(err))
+		return
+	}
+
+	jsonFilePath, _ := filepath.Abs(JSONOutputFile)
+	fmt.Println("> JSON output file created at: [ " + jsonFilePath + " ]")
+}
+
+// LoadJSONOutputFile reads the pii scan output written by
+// CreateJSONOutputFile.
+func LoadJSONOutputFile(path string) (*DatabasePIIScanOutput, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading pii scan output: %v", err)
+	}
+
+	out := &DatabasePIIScanOutput{}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, fmt.Errorf("error parsing pii scan output %s: %v", path, err)
+	}
+
+	return out, nil
+}
+
+// PIITables returns the distinct labels of the tables with high confidence
+// PII data, by table name.
+func (i *DatabasePIIScanOutput) PIITables() map[string][]string {
+	out := map[string][]string{}
+	for tablename, columns := range i.Data {
+		labels := utils.NewSet[string]()
+		for _, piidatas := range columns {
+			for _, piidata := range piidatas {
+				if piidata.Confidence == "High" {
+					labels.Add(string(piidata.Label))
+				}
+			}
+		}
+
+		if labels.Len() > 0 {
+			out[tablename] = labels.Slice()
+			sort.Strings(out[tablename])
+		}
+	}
+
+	return out
+}
+
 func GenerateTabularOutput(w io.Writer, i *DatabasePIIScanOutput, cnf Config, filePrint string) {
 	columnTable := tablewriter.NewWriter(w)
 	headers := []string{"Table", "Column", "Label", "Confidence"}
@@ -2,15 +2,23 @@ package queryparser
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/xwb1989/sqlparser"
 )
 
+// statementPrefixRegexp matches the prefix of the statements logged by
+// log_statement and log_min_duration_statement, for simple and extended
+// query protocol.
+var statementPrefixRegexp = regexp.MustCompile(`^(?:duration:\s+[\d.]+\s+ms\s+)?(?:statement|(?:execute|bind|parse)\s+[^:]*):\s*`)
+
 func GetQueryFromMessage(msg string) (string, bool) {
 
-	msg = strings.TrimPrefix(strings.TrimSpace(msg), "statement:")
 	msg = strings.TrimSpace(msg)
+	if stmt, ok := GetStatementFromMessage(msg); ok {
+		msg = stmt
+	}
 	if strings.HasPrefix(msg, "select") || strings.HasPrefix(msg, "SELECT") ||
 		strings.HasPrefix(msg, "update") || strings.HasPrefix(msg, "UPDATE") ||
 		strings.HasPrefix(msg, "delete") || strings.HasPrefix(msg, "DELETE") ||
@@ -21,6 +29,20 @@ func GetQueryFromMessage(msg string) (string, bool) {
 	return "", false
 }
 
+// GetStatementFromMessage returns the statement of the log message of any
+// command type, like DDL and utility statements. Unlike GetQueryFromMessage,
+// message must have the statement prefix.
+func GetStatementFromMessage(msg string) (string, bool) {
+	msg = strings.TrimSpace(msg)
+	loc := statementPrefixRegexp.FindStringIndex(msg)
+	if loc == nil {
+		return "", false
+	}
+
+	stmt := strings.TrimSpace(msg[loc[1]:])
+	return stmt, stmt != ""
+}
+
 func ParseSqlQuery(sql string) (*KVPairs, error) {
 
 	stmt, err := sqlparser.Parse(sql)
@@ -0,0 +1,25 @@
+package queryparser
+
+import "testing"
+
+func TestGetStatementFromMessage(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want string
+		ok   bool
+	}{
+		{msg: "statement: GRANT ALL ON t TO bob", want: "GRANT ALL ON t TO bob", ok: true},
+		{msg: "duration: 1.234 ms  statement: DROP ROLE bob", want: "DROP ROLE bob", ok: true},
+		{msg: "execute <unnamed>: SELECT pg_read_file($1)", want: "SELECT pg_read_file($1)", ok: true},
+		{msg: "duration: 0.050 ms  bind S_1: SET ROLE admin", want: "SET ROLE admin", ok: true},
+		{msg: "checkpoint starting: time"},
+		{msg: "statement: "},
+	}
+
+	for _, tt := range tests {
+		got, ok := GetStatementFromMessage(tt.msg)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("GetStatementFromMessage(%q) = %q, %v, want %q, %v", tt.msg, got, ok, tt.want, tt.ok)
+		}
+	}
+}
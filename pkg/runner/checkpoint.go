@@ -0,0 +1,229 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileCheckpoint is the position till which a log file is processed.
+type FileCheckpoint struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+	// LastTime is the time of the last entry processed from the file.
+	LastTime time.Time `json:"last_time"`
+}
+
+// Checkpoint is the progress of log parsing persisted between the runs, so
+// every run processes only the lines written since the previous run. It also
+// has the results accumulated since the first run.
+type Checkpoint struct {
+	// Since is the time of the first run.
+	Since time.Time                 `json:"since"`
+	Files map[string]FileCheckpoint `json:"files"`
+	// LastTime is the time of the latest entry processed from any file.
+	LastTime time.Time `json:"last_time"`
+
+	UniqueIPs []string `json:"unique_ips,omitempty"`
+	Users     []string `json:"users,omitempty"`
+	// UserLastSeen is the time of the latest log line of the users.
+	UserLastSeen map[string]time.Time `json:"user_last_seen,omitempty"`
+
+	mu sync.Mutex
+}
+
+// LoadCheckpoint reads the checkpoint file. A new checkpoint is returned if
+// the file does not exist.
+func LoadCheckpoint(file string) (*Checkpoint, error) {
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Checkpoint{Since: time.Now(), Files: map[string]FileCheckpoint{}}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+
+	c := &Checkpoint{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %v", file, err)
+	}
+	if c.Files == nil {
+		c.Files = map[string]FileCheckpoint{}
+	}
+
+	return c, nil
+}
+
+// Save writes the checkpoint with the files of the current run, checkpoints
+// of the files which are not matched anymore are removed. The file is
+// replaced at once so an interrupted save does not lose the checkpoint.
+func (c *Checkpoint) Save(file string, logFiles []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := map[string]bool{}
+	for _, f := range logFiles {
+		current[f] = true
+	}
+	for f := range c.Files {
+		if !current[f] {
+			delete(c.Files, f)
+		}
+	}
+
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %v", err)
+	}
+
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+
+	return nil
+}
+
+// AddUniqueIPs adds the ips in accumulated unique ips.
+func (c *Checkpoint) AddUniqueIPs(ips map[string]bool) {
+	c.UniqueIPs = mergeSorted(c.UniqueIPs, ips)
+}
+
+// AddUsers adds the users in accumulated users seen in the logs.
+func (c *Checkpoint) AddUsers(users map[string]bool) {
+	c.Users = mergeSorted(c.Users, users)
+}
+
+// AddUserLastSeen updates the last seen time of the users with the times of
+// the current run.
+func (c *Checkpoint) AddUserLastSeen(lastSeen map[string]time.Time) {
+	if c.UserLastSeen == nil {
+		c.UserLastSeen = map[string]time.Time{}
+	}
+	for user, t := range lastSeen {
+		if t.After(c.UserLastSeen[user]) {
+			c.UserLastSeen[user] = t
+		}
+	}
+}
+
+func mergeSorted(list []string, values map[string]bool) []string {
+	all := map[string]bool{}
+	for _, v := range list {
+		all[v] = true
+	}
+	for v := range values {
+		all[v] = true
+	}
+
+	out := make([]string, 0, len(all))
+	for v := range all {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// resume returns the offset to start processing the file from. The file is
+// matched with the checkpoints by inode, so a file renamed by log rotation
+// is resumed too. Files which are not matched or are truncated are processed
+// from start, skipping the entries till the last processed entry. Compressed
+// files can not be resumed from an offset, they are either skipped when
+// unchanged or processed from start.
+func (c *Checkpoint) resume(file string, info os.FileInfo, compressed bool) (int64, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	inode := fileInode(info)
+	fc, ok := c.Files[file]
+	if !ok || fc.Inode != inode {
+		ok = false
+		for _, v := range c.Files {
+			if inode != 0 && v.Inode == inode {
+				fc, ok = v, true
+				break
+			}
+		}
+	}
+
+	switch {
+	case !ok || info.Size() < fc.Offset:
+		return 0, c.LastTime
+	case compressed && info.Size() != fc.Offset:
+		return 0, c.LastTime
+	default:
+		return fc.Offset, time.Time{}
+	}
+}
+
+// done records the offset till which the file is processed.
+func (c *Checkpoint) done(file string, info os.FileInfo, offset int64, lastTime time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if previous, ok := c.Files[file]; ok && lastTime.IsZero() {
+		lastTime = previous.LastTime
+	}
+
+	c.Files[file] = FileCheckpoint{Inode: fileInode(info), Offset: offset, LastTime: lastTime}
+	if lastTime.After(c.LastTime) {
+		c.LastTime = lastTime
+	}
+}
+
+// lastLineEnd returns the offset after the last new line between start and
+// end, so the line which is still being written is processed in the next run.
+func lastLineEnd(f io.ReaderAt, start, end int64) (int64, error) {
+	buf := make([]byte, 64*1024)
+	for end > start {
+		n := int64(len(buf))
+		if end-start < n {
+			n = end - start
+		}
+
+		if _, err := f.ReadAt(buf[:n], end-n); err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		for i := n - 1; i >= 0; i-- {
+			if buf[i] == '\n' {
+				return end - n + i + 1, nil
+			}
+		}
+		end -= n
+	}
+
+	return start, nil
+}
+
+// lastTime tracks the time of the latest entry fed to the parsers.
+type lastTime struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (l *lastTime) observe(t time.Time) {
+	l.mu.Lock()
+	if t.After(l.t) {
+		l.t = t
+	}
+	l.mu.Unlock()
+}
+
+func (l *lastTime) get() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.t
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package runner
+
+import "os"
+
+// fileInode returns zero as inode is not available, rotation is detected
+// only by the file getting smaller than the checkpoint offset.
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
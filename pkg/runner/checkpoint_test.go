@@ -0,0 +1,135 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/parselog"
+)
+
+func TestProcessWithCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	current := filepath.Join(dir, "postgresql.log")
+	rotated := filepath.Join(dir, "postgresql.log.1")
+	line := func(user string) string {
+		return "2024-01-02 03:04:05.123 UTC [1234] " + user + "@db1 LOG:  statement: SELECT 1\n"
+	}
+	appendFile := func(file, data string) {
+		t.Helper()
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkpointFile := filepath.Join(dir, "checkpoint.json")
+	// run processes the files like a scheduled run, with the checkpoint of
+	// the previous run.
+	run := func(files ...string) []string {
+		t.Helper()
+		checkpoint, err := LoadCheckpoint(checkpointFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var mu sync.Mutex
+		users := []string{}
+		h := &ProcessHelper{
+			baseParser:   parselog.GetDynamicBaseParser("%m [%p] %u@%d "),
+			logParserCnf: &config.LogParser{},
+			fns: []ParserFunc{func(d parselog.ParsedData) error {
+				user, err := d.GetUser()
+				mu.Lock()
+				users = append(users, user)
+				mu.Unlock()
+				return err
+			}},
+			SuccessLines: make([]int64, 1),
+			checkpoint:   checkpoint,
+		}
+
+		for _, file := range files {
+			if err := h.Process(context.Background(), file); err != nil {
+				t.Fatalf("Process(%s) error = %v", file, err)
+			}
+		}
+		if err := checkpoint.Save(checkpointFile, files); err != nil {
+			t.Fatal(err)
+		}
+
+		sort.Strings(users)
+		return users
+	}
+
+	// line which is being written is left for the next run
+	partial := line("user3")
+	appendFile(current, line("user1")+line("user2")+partial[:20])
+	if got, want := run(current), []string{"user1", "user2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("first run users = %v, want %v", got, want)
+	}
+
+	if got := run(current); len(got) != 0 {
+		t.Errorf("run without new lines users = %v, want none", got)
+	}
+
+	appendFile(current, partial[20:]+line("user4"))
+	if got, want := run(current), []string{"user3", "user4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("run after new lines users = %v, want %v", got, want)
+	}
+
+	// rotation renames the file, lines written before it are resumed from
+	// the renamed file.
+	appendFile(current, line("user5"))
+	if err := os.Rename(current, rotated); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(current, line("user6"))
+	if got, want := run(rotated, current), []string{"user5", "user6"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("run after rotation users = %v, want %v", got, want)
+	}
+
+	checkpoint, err := LoadCheckpoint(checkpointFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpoint.Files) != 2 || checkpoint.LastTime.IsZero() || checkpoint.Since.IsZero() {
+		t.Errorf("checkpoint = %+v", checkpoint)
+	}
+}
+
+func TestCheckpointAccumulatedResults(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "checkpoint.json")
+	c, err := LoadCheckpoint(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.AddUniqueIPs(map[string]bool{"10.0.0.2": true, "10.0.0.1": true})
+	c.AddUsers(map[string]bool{"app": true})
+	if err := c.Save(file, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = LoadCheckpoint(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.AddUniqueIPs(map[string]bool{"10.0.0.3": true, "10.0.0.1": true})
+
+	if want := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}; !reflect.DeepEqual(c.UniqueIPs, want) {
+		t.Errorf("unique ips = %v, want %v", c.UniqueIPs, want)
+	}
+	if want := []string{"app"}; !reflect.DeepEqual(c.Users, want) {
+		t.Errorf("users = %v, want %v", c.Users, want)
+	}
+}
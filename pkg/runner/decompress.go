@@ -0,0 +1,112 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/schollz/progressbar/v3"
+	"github.com/ulikunitz/xz"
+)
+
+// compressions are detected from the magic bytes instead of extension, as
+// rotated files are not always named as per their compression.
+var compressionMagic = []struct {
+	name  string
+	magic []byte
+}{
+	{"gzip", []byte{0x1f, 0x8b}},
+	{"bzip2", []byte("BZh")},
+	{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+}
+
+// compressionOf returns the compression of the file from its first bytes,
+// empty for plain files.
+func compressionOf(header []byte) string {
+	for _, c := range compressionMagic {
+		if bytes.HasPrefix(header, c.magic) {
+			return c.name
+		}
+	}
+	return ""
+}
+
+// isCompressed reports whether the file is compressed, it does not change
+// the offset of the file.
+func isCompressed(f io.ReaderAt) (bool, error) {
+	header := make([]byte, 6)
+	n, err := f.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return compressionOf(header[:n]) != "", nil
+}
+
+// newLogReader returns the reader of the log file content. gzip, bzip2,
+// zstd and xz files are decompressed while reading, other files are read as
+// it is.
+func newLogReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(6)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch compressionOf(header) {
+	case "gzip":
+		// rotated logs can be concatenated gzip streams, gzip reader reads
+		// all of them by default.
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip file: %v", err)
+		}
+		return gr, nil
+	case "bzip2":
+		return io.NopCloser(bzip2.NewReader(br)), nil
+	case "zstd":
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zstd file: %v", err)
+		}
+		return zr.IOReadCloser(), nil
+	case "xz":
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read xz file: %v", err)
+		}
+		return io.NopCloser(xr), nil
+	default:
+		return io.NopCloser(br), nil
+	}
+}
+
+// progressReader adds the bytes read from the file in the progress bar. It
+// reads the file before decompression, so progress is based on the size of
+// the file on disk.
+type progressReader struct {
+	r    io.Reader
+	bar  *progressbar.ProgressBar
+	read int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if p.bar != nil {
+		p.bar.Add(n) //nolint:errcheck
+	}
+	return n, err
+}
+
+// finish adds the bytes which are not read, like when processing stopped
+// early or file was skipped, so the bar completes with all the files.
+func (p *progressReader) finish(size int64) {
+	if p.bar != nil && size > p.read {
+		p.bar.Add64(size - p.read) //nolint:errcheck
+	}
+}
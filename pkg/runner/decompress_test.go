@@ -0,0 +1,147 @@
+package runner
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/parselog"
+	"github.com/ulikunitz/xz"
+)
+
+const testLogContent = "line 1\nline 2\n"
+
+// bzip2 of testLogContent, standard library can not compress bzip2
+var testLogBzip2 = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x31, 0x88, 0x21, 0x68, 0x00, 0x00, 0x05,
+	0x59, 0x00, 0x00, 0x10, 0x40, 0x00, 0x30, 0x00, 0x02, 0x25, 0x20, 0x00, 0x31, 0x0c, 0x08, 0x12, 0x86,
+	0x46, 0x89, 0x31, 0x90, 0x87, 0x10, 0xf1, 0x77, 0x24, 0x53, 0x85, 0x09, 0x03, 0x18, 0x82, 0x16, 0x80,
+}
+
+func compressWith(t *testing.T, newWriter func(io.Writer) (io.WriteCloser, error)) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w, err := newWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(testLogContent)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewLogReader(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"plain", []byte(testLogContent)},
+		{"gzip", compressWith(t, func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil })},
+		{"bzip2", testLogBzip2},
+		{"zstd", compressWith(t, func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) })},
+		{"xz", compressWith(t, func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) })},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			progress := &progressReader{r: bytes.NewReader(tt.input)}
+			r, err := newLogReader(progress)
+			if err != nil {
+				t.Fatalf("newLogReader() error = %v", err)
+			}
+			defer r.Close()
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if string(got) != testLogContent {
+				t.Errorf("got %q, want %q", got, testLogContent)
+			}
+			if progress.read != int64(len(tt.input)) {
+				t.Errorf("progress read %d bytes, want %d compressed bytes", progress.read, len(tt.input))
+			}
+		})
+	}
+
+	// short files are read as plain text
+	r, err := newLogReader(bytes.NewReader([]byte("a")))
+	if err != nil {
+		t.Fatalf("newLogReader() error = %v", err)
+	}
+	if got, _ := io.ReadAll(r); string(got) != "a" {
+		t.Errorf("got %q, want a", got)
+	}
+}
+
+func TestProcessCompressedFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		baseParser parselog.BaseParser
+		logs       string
+	}{
+		{
+			name:       "stderr",
+			baseParser: parselog.GetDynamicBaseParser("%m [%p] %u@%d "),
+			logs: `2024-01-02 03:04:05.123 UTC [1234] user1@db1 LOG:  statement: SELECT 1
+2024-01-02 03:04:06.123 UTC [1235] user2@db1 LOG:  statement: SELECT
+	2
+`,
+		},
+		{
+			name:       "csvlog",
+			baseParser: parselog.NewCsvLogParser(),
+			logs: `2024-01-02 03:04:05.123 UTC,"user1","db1",1234,"10.0.0.1:53412",65b2a1c1.4d2,1,"idle",2024-01-02 03:04:05 UTC,3/1,0,LOG,00000,"statement: SELECT 1",,,,,,,,,"psql","client backend",,0
+2024-01-02 03:04:06.123 UTC,"user2","db1",1235,"10.0.0.2:53412",65b2a1c1.4d3,1,"idle",2024-01-02 03:04:05 UTC,3/1,0,LOG,00000,"statement: SELECT
+	2",,,,,,,,,"psql","client backend",,0
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "postgresql.log.1.gz")
+			buf := &bytes.Buffer{}
+			gw := gzip.NewWriter(buf)
+			gw.Write([]byte(tt.logs)) //nolint:errcheck
+			gw.Close()
+			if err := os.WriteFile(file, buf.Bytes(), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			var mu sync.Mutex
+			users := []string{}
+			h := &ProcessHelper{
+				baseParser:   tt.baseParser,
+				logParserCnf: &config.LogParser{},
+				fns: []ParserFunc{func(d parselog.ParsedData) error {
+					user, err := d.GetUser()
+					mu.Lock()
+					users = append(users, user)
+					mu.Unlock()
+					return err
+				}},
+				SuccessLines: make([]int64, 1),
+			}
+
+			if err := h.Process(context.Background(), file); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			if h.TotalLines != 2 || h.SuccessLines[0] != 2 || len(users) != 2 {
+				t.Errorf("total, success = %d, %d and users %v; want 2 entries", h.TotalLines, h.SuccessLines[0], users)
+			}
+		})
+	}
+}
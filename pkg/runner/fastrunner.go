@@ -3,6 +3,7 @@ package runner
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -37,6 +38,14 @@ type ParserFunc func(parselog.ParsedData) error
 
 // RunFastParser runs the log parser using fast processing.
 func RunFastParser(ctx context.Context, runCmd bool, baseParser parselog.BaseParser, logParserCnf *config.LogParser, fns []ParserFunc) (*FastRunnerResponse, error) {
+	return RunFastParserWithCheckpoint(ctx, runCmd, baseParser, logParserCnf, fns, nil)
+}
+
+// RunFastParserWithCheckpoint runs the log parser processing only the lines
+// written after the checkpoint, checkpoint is updated with the processed
+// files. Saving it is left to the caller, after the results are used.
+func RunFastParserWithCheckpoint(ctx context.Context, runCmd bool, baseParser parselog.BaseParser, logParserCnf *config.LogParser,
+	fns []ParserFunc, checkpoint *Checkpoint) (*FastRunnerResponse, error) {
 	// Read log file path from user
 	defer func() {
 		if r := recover(); r != nil {
@@ -52,19 +61,30 @@ func RunFastParser(ctx context.Context, runCmd bool, baseParser parselog.BasePar
 		baseParser:   baseParser,
 		logParserCnf: logParserCnf,
 		SuccessLines: make([]int64, len(fns)),
+		checkpoint:   checkpoint,
 	}
 
 	fileErrors := utils.NewLockedKeyValue[string]()
 	g, groupCTX := errgroup.WithContext(ctx)
 	g.SetLimit(10)
 
-	bar := progressbar.NewOptions(len(logParserCnf.LogFiles),
-		progressbar.OptionSetDescription("Processing Log Files"),
+	// progress is based on the size of files on disk, compressed files are
+	// counted by their compressed size.
+	var totalSize int64
+	for _, file := range logParserCnf.LogFiles {
+		if fileInfo, err := os.Stat(file); err == nil {
+			totalSize += fileInfo.Size()
+		}
+	}
+
+	bar := progressbar.NewOptions64(totalSize,
+		progressbar.OptionSetDescription(fmt.Sprintf("Processing %d Log Files", len(logParserCnf.LogFiles))),
+		progressbar.OptionShowBytes(true),
 		progressbar.OptionShowCount(),
 		progressbar.OptionFullWidth(),
-		progressbar.OptionSetItsString("files"),
 		progressbar.OptionShowIts(),
 	)
+	h.bar = bar
 
 	// this is to refresh progress bar every second if file is taking more then second to process
 	// go func() {
@@ -77,8 +97,6 @@ func RunFastParser(ctx context.Context, runCmd bool, baseParser parselog.BasePar
 		// handle parallel processing of files\
 		func(file string) {
 			g.Go(func() error {
-				defer bar.Add(1) //nolint:errcheck
-
 				err := h.Process(groupCTX, file)
 				if err != nil {
 					fileErrors.Add(file, err.Error())
@@ -114,10 +132,16 @@ type ProcessHelper struct {
 	logParserCnf *config.LogParser
 
 	fns []ParserFunc
+
+	bar *progressbar.ProgressBar
+
+	// checkpoint is nil when all the lines are processed
+	checkpoint *Checkpoint
 }
 
-// Process processes the log file in chunks.
-func (p *ProcessHelper) Process(ctx context.Context, filename string) error {
+// Process processes the log file in chunks. Compressed files are
+// decompressed while reading.
+func (p *ProcessHelper) Process(ctx context.Context, filename string) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Error().Interface("panic", r).Str("file", filename).Caller().Msg("Process recovered from panic")
@@ -135,6 +159,9 @@ func (p *ProcessHelper) Process(ctx context.Context, filename string) error {
 	if err != nil {
 		return err
 	}
+	progress := &progressReader{r: f, bar: p.bar}
+	defer progress.finish(fileInfo.Size())
+
 	if fileInfo.Size() > 50*1024*1024*1024 {
 		return fmt.Errorf("size of this file is %dGB, currently we are not supporting file size greater than 50GB", fileInfo.Size()/(1024*1024*1024))
 	}
@@ -149,6 +176,54 @@ func (p *ProcessHelper) Process(ctx context.Context, filename string) error {
 		return nil
 	}
 
+	var after time.Time
+	var latest *lastTime
+	if p.checkpoint != nil {
+		compressed, err := isCompressed(f)
+		if err != nil {
+			return err
+		}
+
+		var start int64
+		start, after = p.checkpoint.resume(filename, fileInfo, compressed)
+		end := fileInfo.Size()
+		if !compressed {
+			end, err = lastLineEnd(f, start, end)
+			if err != nil {
+				return err
+			}
+		}
+
+		if start >= end {
+			p.checkpoint.done(filename, fileInfo, start, time.Time{})
+			return nil
+		}
+
+		if _, err := f.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		progress.r = io.LimitReader(f, end-start)
+
+		latest = &lastTime{}
+		defer func() {
+			// checkpoint is updated only when the file is processed
+			// completely, otherwise the file is processed again.
+			if err == nil && ctx.Err() == nil {
+				p.checkpoint.done(filename, fileInfo, end, latest.get())
+			}
+		}()
+	}
+
+	if mp, ok := p.baseParser.(parselog.MultilineParser); ok {
+		lr, err := newLogReader(progress)
+		if err != nil {
+			return err
+		}
+		defer lr.Close()
+
+		return p.processEntries(ctx, mp.NewEntryReader(lr), after, latest)
+	}
+
 	linesPool := sync.Pool{New: func() interface{} {
 		lines := make([]byte, 250*1024)
 		return &lines
@@ -164,11 +239,18 @@ func (p *ProcessHelper) Process(ctx context.Context, filename string) error {
 		return err
 	}
 
-	r := bufio.NewReader(f)
+	lr, err := newLogReader(progress)
+	if err != nil {
+		return err
+	}
+	defer lr.Close()
+
+	r := bufio.NewReader(lr)
 
 	var wg sync.WaitGroup
 
 	chunkProcessors := NewChunkProcessor(&linesPool, p.baseParser, p.logParserCnf, &stringPool, p.fns)
+	chunkProcessors.after, chunkProcessors.latest = after, latest
 
 	previousLine := []byte{}
 	for {
@@ -187,7 +269,8 @@ func (p *ProcessHelper) Process(ctx context.Context, filename string) error {
 		if len(buf) == 0 {
 			break
 		}
-		if err != nil {
+		// decompressors can return the last bytes with EOF
+		if err != nil && err != io.EOF {
 			return err
 		}
 
@@ -225,16 +308,107 @@ func (p *ProcessHelper) Process(ctx context.Context, filename string) error {
 	return nil
 }
 
+// processEntries processes the file entry by entry, for the log formats where
+// an entry can span multiple lines. Like validateFile, first 100 entries are
+// validated before feeding them to the parsers.
+func (p *ProcessHelper) processEntries(ctx context.Context, r parselog.EntryReader, after time.Time, latest *lastTime) error {
+	c := NewChunkProcessor(nil, p.baseParser, p.logParserCnf, nil, p.fns)
+	c.after, c.latest = after, latest
+
+	pending := []parselog.ParsedData{}
+	errorCount := 0
+	validated := false
+	validate := func() error {
+		validated = true
+		if err := validateEntries(len(pending)+errorCount, errorCount); err != nil {
+			return err
+		}
+		for _, d := range pending {
+			c.feed(d, "")
+		}
+		pending = nil
+		return nil
+	}
+
+	for {
+		// if context context expired, stop processing
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		parsedData, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+
+		c.totalLines++
+		if errors.Is(err, parselog.ErrInvalidEntry) {
+			logger.FileLogger().Err(err).Msg("Failed to parse entry")
+			errorCount++
+		} else if err != nil {
+			return err
+		} else if validated {
+			c.feed(parsedData, "")
+		} else {
+			pending = append(pending, parsedData)
+		}
+
+		if !validated && len(pending)+errorCount == 100 {
+			if err := validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !validated {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+
+	atomic.AddInt64(&p.TotalLines, c.totalLines)
+	for i, v := range c.successLines {
+		atomic.AddInt64(&p.SuccessLines[i], v)
+	}
+
+	return nil
+}
+
+func validateEntries(total, errorCount int) error {
+	if total == 0 {
+		return fmt.Errorf("no log entries found in the log file")
+	}
+
+	if total*70/100 < errorCount {
+		return fmt.Errorf("log format is wrong [validation failed]")
+	}
+
+	return nil
+}
+
+// validateFile validates the lines from the current offset of the file,
+// which is the start of the file or the checkpoint.
 func (p *ProcessHelper) validateFile(ctx context.Context, f *os.File) error {
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
 
-	r := bufio.NewReader(f)
 	defer func() {
-		_, err := f.Seek(0, io.SeekStart)
+		_, err := f.Seek(offset, io.SeekStart)
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to seek file to start")
 		}
 	}()
 
+	lr, err := newLogReader(f)
+	if err != nil {
+		return err
+	}
+	defer lr.Close()
+
+	r := bufio.NewReader(lr)
+
 	errorCount := 0
 	totalLine := 0
 	for totalLine < 100 {
@@ -286,6 +460,14 @@ type ChunkProcessor struct {
 
 	totalLines   int64
 	successLines []int64
+
+	// after skips the entries before the time, which are already processed
+	// as per the checkpoint. Entries of the same time are not skipped, as
+	// missing an entry is worse than processing it again.
+	after time.Time
+	// latest is the time of latest entry fed to the parsers, nil if it is
+	// not needed.
+	latest *lastTime
 }
 
 // NewChunkProcessor creates a new chunk processor.
@@ -370,22 +552,7 @@ func (c *ChunkProcessor) Parse(ctx context.Context, chunk []byte) {
 					continue
 				}
 
-				// if time is not valid then return
-				if !c.logParserCnf.IsValidTime(parsedData.GetTime()) {
-					for i := range c.successLines {
-						atomic.AddInt64(&c.successLines[i], 1)
-					}
-					continue
-				}
-
-				for i, fn := range c.fns {
-					err := fn(parsedData)
-					if err != nil {
-						logger.FileLogger().Err(err).Str("line", logLine).Msg("Failed to parse line")
-						continue
-					}
-					atomic.AddInt64(&c.successLines[i], 1)
-				}
+				c.feed(parsedData, logLine)
 			}
 		}(i, endIndex)
 	}
@@ -394,6 +561,32 @@ func (c *ChunkProcessor) Parse(ctx context.Context, chunk []byte) {
 	logLines = nil
 }
 
+// feed passes the parsed data to all the parser functions, if it is in the
+// time range of log parser config.
+func (c *ChunkProcessor) feed(parsedData parselog.ParsedData, logLine string) {
+	// if time is not valid then return
+	t := parsedData.GetTime()
+	if !c.logParserCnf.IsValidTime(t) || t.Before(c.after) {
+		for i := range c.successLines {
+			atomic.AddInt64(&c.successLines[i], 1)
+		}
+		return
+	}
+
+	if c.latest != nil {
+		c.latest.observe(t)
+	}
+
+	for i, fn := range c.fns {
+		err := fn(parsedData)
+		if err != nil {
+			logger.FileLogger().Err(err).Str("line", logLine).Msg("Failed to parse line")
+			continue
+		}
+		atomic.AddInt64(&c.successLines[i], 1)
+	}
+}
+
 func mergeContinueLines(lines []string) []string {
 	mergedLines := []string{}
 	for _, line := range lines {
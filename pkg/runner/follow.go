@@ -0,0 +1,335 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/text"
+	"github.com/klouddb/klouddbshield/pkg/config"
+	cons "github.com/klouddb/klouddbshield/pkg/const"
+	"github.com/klouddb/klouddbshield/pkg/logger"
+	"github.com/klouddb/klouddbshield/pkg/parselog"
+)
+
+// followPollInterval is how often the current log file is checked for new
+// lines and rotation.
+var followPollInterval = time.Second
+
+// RunFollower tails the current log file of follow mode and feeds the new log
+// entries to the parser functions until the context is done. alert is called
+// for every entry, it is not counted in success lines of parser functions.
+// When the log is rotated rest of the old file is read before switching to
+// the new file.
+func RunFollower(ctx context.Context, baseParser parselog.BaseParser, logParserCnf *config.LogParser,
+	fns []ParserFunc, alert ParserFunc) (*FastRunnerResponse, error) {
+
+	if logParserCnf.Follow == nil {
+		return nil, fmt.Errorf("follow mode is not enabled")
+	}
+
+	current, err := logParserCnf.Follow.CurrentFile()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	f := &follower{
+		follow:  logParserCnf.Follow,
+		c:       NewChunkProcessor(nil, baseParser, logParserCnf, nil, fns),
+		alert:   alert,
+		entries: newEntryAssembler(logParserCnf.LogFormat, baseParser),
+	}
+
+	// lines written before starting are not followed, batch mode can be
+	// used for them.
+	if err := f.open(current, true); err != nil {
+		return nil, err
+	}
+	defer f.close()
+
+	fmt.Println(text.FgCyan.Sprint("Following " + current + ", press Ctrl+C to stop"))
+
+	ticker := time.NewTicker(followPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := f.poll(); err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			if err := f.flush(); err != nil {
+				return nil, err
+			}
+			return &FastRunnerResponse{
+				TotalLines:   f.c.totalLines,
+				SuccessLines: f.c.successLines,
+				StartTime:    start,
+				FileErrors:   map[string]string{},
+			}, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+type follower struct {
+	follow  *config.LogFollow
+	c       *ChunkProcessor
+	alert   ParserFunc
+	entries *entryAssembler
+
+	name    string
+	file    *os.File
+	r       *bufio.Reader
+	offset  int64
+	partial []byte
+
+	// like validateFile, first 100 entries are validated to stop following
+	// when log line prefix or format is wrong.
+	validated  bool
+	errorCount int
+}
+
+func (f *follower) open(name string, seekEnd bool) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	if seekEnd {
+		offset, err = file.Seek(0, io.SeekEnd)
+		if err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	f.close()
+	f.name, f.file, f.r, f.offset, f.partial = name, file, bufio.NewReader(file), offset, nil
+	return nil
+}
+
+func (f *follower) close() {
+	if f.file != nil {
+		f.file.Close()
+	}
+}
+
+// poll processes the lines written since the last poll. When nothing is
+// written, the log is checked for rotation.
+func (f *follower) poll() error {
+	n, err := f.readLines()
+	if err != nil || n > 0 {
+		return err
+	}
+
+	// postgres writes an entry at once, so the stderr entry waiting for its
+	// continuation lines or the syslog message waiting for its next chunk is
+	// complete when nothing is written. Pending csvlog
+	// entry has an open quoted field, so rest of it is not written yet.
+	if f.entries.logFormat != cons.LogFormat_CSV {
+		if err := f.flush(); err != nil {
+			return err
+		}
+	}
+
+	next, err := f.rotated()
+	if err != nil || next == "" {
+		return err
+	}
+
+	// lines written to the old file between the last read and rotation
+	if _, err := f.readLines(); err != nil {
+		return err
+	}
+	if len(f.partial) > 0 {
+		if err := f.addLine(string(f.partial)); err != nil {
+			return err
+		}
+	}
+	if err := f.flush(); err != nil {
+		return err
+	}
+
+	logger.FileLogger().Info().Str("file", next).Msg("Log file rotated")
+	fmt.Println(text.FgCyan.Sprint("Log file rotated, following " + next))
+	return f.open(next, false)
+}
+
+// readLines processes the complete lines written since the last read and
+// returns the number of bytes read. Last line without new line is kept
+// until rest of it is written.
+func (f *follower) readLines() (int, error) {
+	n := 0
+	for {
+		line, err := f.r.ReadBytes('\n')
+		n += len(line)
+		f.offset += int64(len(line))
+		if err == io.EOF {
+			f.partial = append(f.partial, line...)
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+
+		if len(f.partial) > 0 {
+			line = append(f.partial, line...)
+			f.partial = nil
+		}
+
+		if err := f.addLine(strings.TrimRight(string(line), "\r\n")); err != nil {
+			return n, err
+		}
+	}
+}
+
+// rotated returns the file to follow when the log is rotated. It is the
+// newer file matching the pattern, or the same file when it is truncated
+// or recreated.
+func (f *follower) rotated() (string, error) {
+	if current, err := f.follow.CurrentFile(); err == nil && current != f.name {
+		return current, nil
+	}
+
+	info, err := os.Stat(f.name)
+	if err != nil {
+		// file is removed, wait for the new file
+		return "", nil
+	}
+
+	openInfo, err := f.file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	if !os.SameFile(info, openInfo) || info.Size() < f.offset {
+		return f.name, nil
+	}
+
+	return "", nil
+}
+
+func (f *follower) addLine(line string) error {
+	return f.processAll(f.entries.add(line))
+}
+
+func (f *follower) flush() error {
+	return f.processAll(f.entries.flush())
+}
+
+func (f *follower) processAll(entries []string) error {
+	for _, entry := range entries {
+		if err := f.process(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *follower) process(entry string) error {
+	if strings.TrimSpace(entry) == "" {
+		return nil
+	}
+
+	f.c.totalLines++
+	parsedData, err := f.c.baseParser.Parse(entry)
+	if err != nil {
+		logger.FileLogger().Err(err).Str("line", entry).Msg("Failed to parse line")
+		f.errorCount++
+	} else {
+		if f.alert != nil {
+			if err := f.alert(parsedData); err != nil {
+				logger.FileLogger().Err(err).Str("line", entry).Msg("Failed to check alerts")
+			}
+		}
+		f.c.feed(parsedData, entry)
+	}
+
+	if !f.validated && f.c.totalLines == 100 {
+		f.validated = true
+		return validateEntries(int(f.c.totalLines), f.errorCount)
+	}
+
+	return nil
+}
+
+// entryAssembler joins the lines of a log entry. Continuation lines of
+// stderr start with tab, csvlog entries can have new lines in quoted fields,
+// jsonlog entries are always a single line and syslog messages can be split
+// in chunks.
+type entryAssembler struct {
+	logFormat string
+	// syslog joins the chunks of syslog messages same as the entry reader of
+	// batch mode.
+	syslog *parselog.SyslogChunkJoiner
+
+	lines  []string
+	quotes int
+}
+
+func newEntryAssembler(logFormat string, baseParser parselog.BaseParser) *entryAssembler {
+	e := &entryAssembler{logFormat: logFormat}
+	if p, ok := baseParser.(interface {
+		NewChunkJoiner() *parselog.SyslogChunkJoiner
+	}); ok {
+		e.syslog = p.NewChunkJoiner()
+	}
+	return e
+}
+
+// add adds the line and returns the entries completed by it.
+func (e *entryAssembler) add(line string) []string {
+	if e.syslog != nil {
+		return e.syslog.Add(line)
+	}
+
+	switch e.logFormat {
+	case cons.LogFormat_JSON:
+		return []string{line}
+	case cons.LogFormat_CSV:
+		e.lines = append(e.lines, line)
+		e.quotes += strings.Count(line, `"`)
+		if e.quotes%2 != 0 {
+			return nil
+		}
+		return e.flush()
+	}
+
+	if strings.HasPrefix(line, "\t") && len(e.lines) > 0 {
+		e.lines = append(e.lines, line)
+		return nil
+	}
+
+	entries := e.flush()
+	e.lines = append(e.lines, line)
+	return entries
+}
+
+// flush returns the pending entries.
+func (e *entryAssembler) flush() []string {
+	if e.syslog != nil {
+		return e.syslog.Flush()
+	}
+	if len(e.lines) == 0 {
+		return nil
+	}
+
+	// continuation lines of stderr are merged without new line, same as
+	// mergeContinueLines.
+	sep := ""
+	if e.logFormat == cons.LogFormat_CSV {
+		sep = "\n"
+	}
+
+	entry := strings.Join(e.lines, sep)
+	e.lines = e.lines[:0]
+	e.quotes = 0
+	return []string{entry}
+}
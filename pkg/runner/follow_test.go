@@ -0,0 +1,169 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klouddb/klouddbshield/model"
+	"github.com/klouddb/klouddbshield/pkg/config"
+	cons "github.com/klouddb/klouddbshield/pkg/const"
+	"github.com/klouddb/klouddbshield/pkg/parselog"
+)
+
+func TestEntryAssembler(t *testing.T) {
+	tests := []struct {
+		name      string
+		logFormat string
+		prefix    string
+		lines     []string
+		want      []string
+	}{
+		{
+			name:      "stderr continuation lines",
+			logFormat: cons.LogFormat_Stderr,
+			lines:     []string{"LOG: statement: SELECT", "\t1", "LOG: statement: SELECT 2"},
+			want:      []string{"LOG: statement: SELECT\t1", "LOG: statement: SELECT 2"},
+		},
+		{
+			name:      "csvlog quoted new line",
+			logFormat: cons.LogFormat_CSV,
+			lines:     []string{`a,"SELECT`, `1",b`, `c,"SELECT 2"`},
+			want:      []string{"a,\"SELECT\n1\",b", `c,"SELECT 2"`},
+		},
+		{
+			name:      "jsonlog",
+			logFormat: cons.LogFormat_JSON,
+			lines:     []string{`{"message":"a"}`, `{"message":"b"}`},
+			want:      []string{`{"message":"a"}`, `{"message":"b"}`},
+		},
+		{
+			name:      "syslog split messages",
+			logFormat: cons.LogFormat_Syslog,
+			prefix:    "%u@%d ",
+			lines: []string{
+				"Jan  2 03:04:05 dbhost postgres[1234]: [5-1] user1@db1 LOG:  statement: SELECT a",
+				"Jan  2 03:04:05 dbhost postgres[999]: [3-1] user2@db1 LOG:  statement: SELECT 2",
+				"Jan  2 03:04:05 dbhost postgres[1234]: [5-2] #011FROM t",
+				"Jan  2 03:04:05 dbhost kernel: eth0 link up",
+			},
+			want: []string{
+				"Jan  2 03:04:05 dbhost postgres[1234]: [5-1] user1@db1 LOG:  statement: SELECT a\tFROM t",
+				"Jan  2 03:04:05 dbhost postgres[999]: [3-1] user2@db1 LOG:  statement: SELECT 2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEntryAssembler(tt.logFormat, parselog.GetBaseParser(tt.logFormat, &model.PgSettings{LogLinePrefix: tt.prefix}))
+			got := []string{}
+			for _, line := range tt.lines {
+				got = append(got, e.add(line)...)
+			}
+			got = append(got, e.flush()...)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("entries = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunFollower(t *testing.T) {
+	defer func(interval time.Duration) { followPollInterval = interval }(followPollInterval)
+	followPollInterval = 10 * time.Millisecond
+
+	dir := t.TempDir()
+	first := filepath.Join(dir, "postgresql-2024-01-02_000000.log")
+	second := filepath.Join(dir, "postgresql-2024-01-03_000000.log")
+	line := func(user string) string {
+		return "2024-01-02 03:04:05.123 UTC [1234] " + user + "@db1 LOG:  statement: SELECT 1\n"
+	}
+
+	// lines written before following are skipped
+	if err := os.WriteFile(first, []byte(line("old")), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	users := []string{}
+	usersSoFar := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, users...)
+	}
+	fn := func(d parselog.ParsedData) error {
+		user, err := d.GetUser()
+		mu.Lock()
+		users = append(users, user)
+		mu.Unlock()
+		return err
+	}
+
+	alerts := 0
+	cnf := &config.LogParser{
+		LogFormat: cons.LogFormat_Stderr,
+		Follow:    &config.LogFollow{Pattern: filepath.Join(dir, "postgresql-*.log")},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type result struct {
+		resp *FastRunnerResponse
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := RunFollower(ctx, parselog.GetDynamicBaseParser("%m [%p] %u@%d "), cnf,
+			[]ParserFunc{fn}, func(parselog.ParsedData) error { alerts++; return nil })
+		done <- result{resp, err}
+	}()
+
+	waitFor := func(want []string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if got := usersSoFar(); reflect.DeepEqual(got, want) {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("users = %v, want %v", usersSoFar(), want)
+	}
+
+	// wait for the follower to open the file at its end
+	time.Sleep(50 * time.Millisecond)
+
+	f, err := os.OpenFile(first, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(line("user1")) //nolint:errcheck
+	f.Close()
+	waitFor([]string{"user1"})
+
+	// rotation to the new file of log_filename pattern
+	if err := os.WriteFile(second, []byte(line("user2")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(second, future, future); err != nil {
+		t.Fatal(err)
+	}
+	waitFor([]string{"user1", "user2"})
+
+	cancel()
+	r := <-done
+	if r.err != nil {
+		t.Fatalf("RunFollower() error = %v", r.err)
+	}
+	if r.resp.TotalLines != 2 || r.resp.SuccessLines[0] != 2 || alerts != 2 {
+		t.Errorf("total, success, alerts = %d, %d, %d; want 2, 2, 2", r.resp.TotalLines, r.resp.SuccessLines[0], alerts)
+	}
+}
@@ -0,0 +1,125 @@
+package siem
+
+import (
+	"strings"
+	"time"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+	"github.com/klouddb/klouddbshield/pkg/parselog"
+)
+
+// event classes, used as message id in syslog and signature id in cef.
+const (
+	EventClass_Finding        = "finding"
+	EventClass_LeakedPassword = "leaked_password"
+	EventClass_SQLInjection   = "sql_injection"
+	EventClass_UnusedHBALine  = "unused_hba_line"
+	EventClass_NewUniqueIP    = "new_unique_ip"
+	EventClass_AuthFailure    = "auth_failure"
+)
+
+// Event is a single message sent to the SIEM.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Target   string    `json:"target"`
+	Class    string    `json:"class"`
+	Module   string    `json:"module"`
+	Control  string    `json:"control"`
+	Status   string    `json:"status"`
+	Severity string    `json:"severity"`
+	Message  string    `json:"message"`
+}
+
+// NewEvents creates the events for the failing findings of the target and
+// for the client IPs which were not seen in the previous run. When previous
+// is nil all the client IPs are considered new.
+func NewEvents(t time.Time, target string, findings []htmlreport.Finding, previous *htmlreport.HistoryEntry) []Event {
+	out := []Event{}
+	for _, f := range findings {
+		if f.Control == htmlreport.UniqueIPsControl {
+			out = append(out, newUniqueIPEvents(t, target, f, previous)...)
+			continue
+		}
+		if !f.IsFailing() {
+			continue
+		}
+
+		class := findingClass(f)
+		message := f.Reason
+		if class == EventClass_LeakedPassword {
+			// reason is masked by the report already, masked again for the
+			// findings of older reports.
+			message = parselog.MaskPasswords(message)
+		}
+
+		out = append(out, Event{
+			Time:     t,
+			Target:   target,
+			Class:    class,
+			Module:   f.Module,
+			Control:  f.Control,
+			Status:   f.Status,
+			Severity: f.Severity,
+			Message:  message,
+		})
+	}
+
+	return out
+}
+
+func findingClass(f htmlreport.Finding) string {
+	switch {
+	case f.Control == htmlreport.LeakedPasswordControl:
+		return EventClass_LeakedPassword
+	case f.Control == "SQL Injection":
+		return EventClass_SQLInjection
+	case strings.HasPrefix(f.Control, "Unused HBA Line"):
+		return EventClass_UnusedHBALine
+	default:
+		return EventClass_Finding
+	}
+}
+
+func newUniqueIPEvents(t time.Time, target string, f htmlreport.Finding, previous *htmlreport.HistoryEntry) []Event {
+	seen := map[string]bool{}
+	if previous != nil {
+		for _, p := range previous.Findings {
+			if p.Control == htmlreport.UniqueIPsControl {
+				for _, ip := range splitIPs(p.Reason) {
+					seen[ip] = true
+				}
+			}
+		}
+	}
+
+	out := []Event{}
+	for _, ip := range splitIPs(f.Reason) {
+		if seen[ip] {
+			continue
+		}
+		seen[ip] = true
+
+		out = append(out, Event{
+			Time:     t,
+			Target:   target,
+			Class:    EventClass_NewUniqueIP,
+			Module:   f.Module,
+			Control:  f.Control,
+			Status:   "Info",
+			Severity: htmlreport.Severity_Info,
+			Message:  ip,
+		})
+	}
+
+	return out
+}
+
+func splitIPs(s string) []string {
+	out := []string{}
+	for _, ip := range strings.Split(s, ",") {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			out = append(out, ip)
+		}
+	}
+	return out
+}
@@ -0,0 +1,126 @@
+package siem
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+)
+
+const (
+	appName = "klouddbshield"
+
+	// facilityLocal0 is the syslog facility of all the messages.
+	facilityLocal0 = 16
+
+	// sdID is the structured data id of syslog messages. 32473 is the
+	// enterprise number reserved for documentation by RFC 5612.
+	sdID = "klouddbshield@32473"
+)
+
+// syslogSeverity maps finding severity to syslog severity.
+func syslogSeverity(severity string) int {
+	switch severity {
+	case htmlreport.Severity_Critical:
+		return 2
+	case htmlreport.Severity_High:
+		return 3
+	case htmlreport.Severity_Medium:
+		return 4
+	case htmlreport.Severity_Low:
+		return 5
+	default:
+		return 6
+	}
+}
+
+// cefSeverity maps finding severity to cef severity, 0 to 10.
+func cefSeverity(severity string) int {
+	switch severity {
+	case htmlreport.Severity_Critical:
+		return 10
+	case htmlreport.Severity_High:
+		return 8
+	case htmlreport.Severity_Medium:
+		return 5
+	case htmlreport.Severity_Low:
+		return 3
+	default:
+		return 1
+	}
+}
+
+// syslogHeader returns RFC 5424 header of the event till MSGID.
+func syslogHeader(e Event, hostname string) string {
+	return fmt.Sprintf("<%d>1 %s %s %s - %s",
+		facilityLocal0*8+syslogSeverity(e.Severity),
+		e.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		nilValue(hostname), appName, e.Class)
+}
+
+// FormatSyslog formats the event as RFC 5424 message with the details of
+// the finding as structured data.
+func FormatSyslog(e Event, hostname string) string {
+	params := []string{}
+	for _, kv := range [][2]string{
+		{"target", e.Target},
+		{"module", e.Module},
+		{"control", e.Control},
+		{"status", e.Status},
+		{"severity", e.Severity},
+	} {
+		params = append(params, kv[0]+`="`+sdParamReplacer.Replace(kv[1])+`"`)
+	}
+
+	return syslogHeader(e, hostname) + " [" + sdID + " " + strings.Join(params, " ") + "] " + e.Message
+}
+
+// FormatCEF formats the event as CEF message with RFC 5424 header.
+func FormatCEF(e Event, hostname string) string {
+	ext := []string{}
+	for _, kv := range [][2]string{
+		{"rt", fmt.Sprint(e.Time.UnixNano() / 1e6)},
+		{"dhost", e.Target},
+		{"cat", e.Module},
+		{"outcome", e.Status},
+		{"cs1Label", "control"},
+		{"cs1", e.Control},
+		{"msg", e.Message},
+	} {
+		ext = append(ext, kv[0]+"="+cefExtReplacer.Replace(kv[1]))
+	}
+
+	name := e.Control
+	if name == "" {
+		name = e.Class
+	}
+
+	cef := fmt.Sprintf("CEF:0|KloudDB|KloudDB Shield|1.0|%s|%s|%d|%s",
+		cefHeaderReplacer.Replace(e.Class), cefHeaderReplacer.Replace(name),
+		cefSeverity(e.Severity), strings.Join(ext, " "))
+
+	return syslogHeader(e, hostname) + " - " + cef
+}
+
+// FormatJSON formats the event as single line json.
+func FormatJSON(e Event) (string, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+var (
+	sdParamReplacer   = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	cefHeaderReplacer = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtReplacer    = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
@@ -0,0 +1,112 @@
+package siem
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/parselog"
+	"github.com/klouddb/klouddbshield/pkg/utils"
+)
+
+// liveAlertModule is the module of the alerts, same as the tab of log parser
+// findings in the report.
+const liveAlertModule = "Log Parser"
+
+// LiveAlerter checks every log line in follow mode and emits an alert as
+// soon as a leaked password, sql injection indicator, new client ip or
+// suspicious pattern of failed authentications is found.
+type LiveAlerter struct {
+	target string
+	emit   func(Event)
+
+	passwordLeak *parselog.PasswordLeakParser
+	sqlInjection *parselog.SqlInjectionScanner
+	uniqueIPs    *parselog.UniqueIPParser
+	authFailures *parselog.AuthFailureTracker
+
+	// seenIPs are the known client ips and the ones seen since follow
+	// started, only the first connection from a new ip is alerted.
+	seenIPs *utils.LockSet
+}
+
+func NewLiveAlerter(logParserCnf *config.LogParser, target string, emit func(Event)) *LiveAlerter {
+	return &LiveAlerter{
+		target: target,
+		emit:   emit,
+
+		passwordLeak: parselog.NewPasswordLeakParser(logParserCnf),
+		sqlInjection: parselog.NewSqlInjectionScanner(logParserCnf),
+		uniqueIPs:    parselog.NewUniqueIPParser(logParserCnf),
+		authFailures: parselog.NewAuthFailureTracker(),
+
+		seenIPs: utils.NewLockSet(),
+	}
+}
+
+// AddKnownIPs adds the client ips seen before follow started, like the ones
+// of log parser checkpoint, connections from them are not alerted.
+func (a *LiveAlerter) AddKnownIPs(ips ...string) {
+	for _, ip := range ips {
+		a.seenIPs.Add(ip)
+	}
+}
+
+// AddKnownIPsFromHistory adds the unique ips of the previous runs of the
+// target as known ips.
+func (a *LiveAlerter) AddKnownIPsFromHistory(entries []htmlreport.HistoryEntry) {
+	for _, e := range entries {
+		for _, f := range e.Findings {
+			if f.Control == htmlreport.UniqueIPsControl {
+				a.AddKnownIPs(splitIPs(f.Reason)...)
+			}
+		}
+	}
+}
+
+func (a *LiveAlerter) Feed(parsedData parselog.ParsedData) error {
+	if leak, ok := a.passwordLeak.Match(parsedData); ok {
+		a.alert(parsedData, EventClass_LeakedPassword, htmlreport.LeakedPasswordControl, htmlreport.Severity_High, leak.RedactedQuery())
+	}
+
+	for _, indicator := range a.sqlInjection.Match(parsedData) {
+		a.alert(parsedData, EventClass_SQLInjection, "SQL Injection", htmlreport.Severity_High, indicator)
+	}
+
+	if ip, ok := a.uniqueIPs.GetIP(parsedData); ok && !a.seenIPs.IsAvailable(ip) {
+		a.seenIPs.Add(ip)
+		a.alert(parsedData, EventClass_NewUniqueIP, htmlreport.UniqueIPsControl, htmlreport.Severity_Info, ip)
+	}
+
+	// single failures are common, like a mistyped password, so only the
+	// brute force, credential stuffing and user enumeration patterns are
+	// alerted.
+	if e, ok := parselog.GetAuthFailure(parsedData); ok {
+		for _, w := range a.authFailures.Add(e) {
+			a.alert(parsedData, EventClass_AuthFailure, "Authentication "+strings.ReplaceAll(w.Pattern, "_", " "), htmlreport.Severity_High,
+				fmt.Sprintf("%d failed authentications of %s from %s since %s", w.Count, strings.Join(w.Users, ", "), w.Host,
+					w.Start.Format("2006-01-02 15:04:05")))
+		}
+	}
+
+	return nil
+}
+
+func (a *LiveAlerter) alert(parsedData parselog.ParsedData, class, control, severity, message string) {
+	status := "Fail"
+	if severity == htmlreport.Severity_Info {
+		status = "Info"
+	}
+
+	a.emit(Event{
+		Time:     parsedData.GetTime(),
+		Target:   a.target,
+		Class:    class,
+		Module:   liveAlertModule,
+		Control:  control,
+		Status:   status,
+		Severity: severity,
+		Message:  strings.TrimSpace(message),
+	})
+}
@@ -0,0 +1,102 @@
+package siem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+	"github.com/klouddb/klouddbshield/model"
+	"github.com/klouddb/klouddbshield/pkg/config"
+	"github.com/klouddb/klouddbshield/pkg/parselog"
+)
+
+func TestLiveAlerter(t *testing.T) {
+	cnf := &config.LogParser{PgSettings: &model.PgSettings{LogLinePrefix: "%m [%p] %u@%d %h "}}
+	baseParser := parselog.GetDynamicBaseParser(cnf.PgSettings.LogLinePrefix)
+
+	lines := []string{
+		`2024-01-02 03:04:05.123 UTC [1] app@db1 10.0.0.1 LOG:  statement: SELECT 1`,
+		`2024-01-02 03:04:06.123 UTC [2] app@db1 10.0.0.1 LOG:  statement: SELECT 2`,
+		`2024-01-02 03:04:07.123 UTC [3] admin@db1 10.0.0.2 LOG:  statement: ALTER USER app PASSWORD 'secret'`,
+		`2024-01-02 03:04:08.123 UTC [4] app@db1 10.0.0.1 FATAL:  password authentication failed for user "app"`,
+		`2024-01-02 03:04:09.123 UTC [5] app@db1 10.0.0.1 LOG:  statement: SELECT usename FROM pg_user`,
+	}
+
+	got := []Event{}
+	a := NewLiveAlerter(cnf, "pg", func(e Event) { got = append(got, e) })
+	// ip of a previous run is not alerted as new
+	a.AddKnownIPsFromHistory([]htmlreport.HistoryEntry{{Findings: []htmlreport.Finding{
+		{Module: "Log Parser", Control: htmlreport.UniqueIPsControl, Status: "Info", Reason: "10.0.0.2, 10.0.0.9"},
+	}}})
+	for _, line := range lines {
+		d, err := baseParser.Parse(line)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", line, err)
+		}
+		if err := a.Feed(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []struct{ class, message string }{
+		{EventClass_NewUniqueIP, "10.0.0.1"},
+		{EventClass_LeakedPassword, "statement: ALTER USER app PASSWORD '***'"},
+		// failed authentication is an indicator of sql injection scanner too
+		{EventClass_SQLInjection, `password authentication failed for user "app"`},
+		{EventClass_SQLInjection, "SELECT usename FROM pg_user"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d alerts, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Class != w.class || got[i].Message != w.message || got[i].Target != "pg" {
+			t.Errorf("alert %d = %s %q, want %s %q", i, got[i].Class, got[i].Message, w.class, w.message)
+		}
+	}
+}
+
+func TestLiveAlerterAuthFailures(t *testing.T) {
+	cnf := &config.LogParser{PgSettings: &model.PgSettings{LogLinePrefix: "%m [%p] %u@%d %h "}}
+	baseParser := parselog.GetDynamicBaseParser(cnf.PgSettings.LogLinePrefix)
+
+	got := []Event{}
+	a := NewLiveAlerter(cnf, "pg", func(e Event) {
+		if e.Class == EventClass_AuthFailure {
+			got = append(got, e)
+		}
+	})
+
+	start := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)
+	feed := func(at time.Duration) {
+		line := start.Add(at).Format("2006-01-02 15:04:05.000 MST") +
+			` [1] app@db1 10.0.0.1 FATAL:  password authentication failed for user "app"`
+		d, err := baseParser.Parse(line)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", line, err)
+		}
+		if err := a.Feed(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a single failure is not alerted, 10 failures in the window are a
+	// brute force which is alerted once while it goes on.
+	for i := 0; i < 15; i++ {
+		feed(time.Duration(i) * 10 * time.Second)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d alerts, want 1: %+v", len(got), got)
+	}
+	want := "10 failed authentications of app from 10.0.0.1 since 2024-01-02 03:04:00"
+	if got[0].Control != "Authentication brute force" || got[0].Message != want {
+		t.Errorf("alert = %s %q, want %q", got[0].Control, got[0].Message, want)
+	}
+
+	// same pattern after a quiet window is alerted again
+	for i := 0; i < 10; i++ {
+		feed(time.Hour + time.Duration(i)*time.Second)
+	}
+	if len(got) != 2 {
+		t.Errorf("got %d alerts after quiet window, want 2", len(got))
+	}
+}
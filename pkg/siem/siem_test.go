@@ -0,0 +1,188 @@
+package siem
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klouddb/klouddbshield/htmlreport"
+)
+
+var testTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestNewEvents(t *testing.T) {
+	previous := &htmlreport.HistoryEntry{Findings: []htmlreport.Finding{
+		{Module: "Log Parser", Control: "Unique IPs", Status: "Info", Reason: "10.0.0.1, 10.0.0.2"},
+	}}
+	findings := []htmlreport.Finding{
+		{Module: "HBA Scanner Report", Control: "HBA Check 1", Status: "Fail", Severity: htmlreport.Severity_High},
+		{Module: "HBA Scanner Report", Control: "HBA Check 2", Status: "Pass"},
+		{Module: "Log Parser", Control: "Leaked Password", Status: "Fail", Severity: htmlreport.Severity_High, Reason: "statement: ALTER USER app PASSWORD 'secret'"},
+		{Module: "Log Parser", Control: "Unused HBA Line 3", Status: "Fail", Severity: htmlreport.Severity_Low},
+		{Module: "Log Parser", Control: "Unique IPs", Status: "Info", Reason: "10.0.0.1, 10.0.0.3"},
+	}
+
+	got := NewEvents(testTime, "pg", findings, previous)
+	wantClasses := []string{EventClass_Finding, EventClass_LeakedPassword, EventClass_UnusedHBALine, EventClass_NewUniqueIP}
+	if len(got) != len(wantClasses) {
+		t.Fatalf("got %d events, want %d: %+v", len(got), len(wantClasses), got)
+	}
+	for i, want := range wantClasses {
+		if got[i].Class != want {
+			t.Errorf("event %d class = %s, want %s", i, got[i].Class, want)
+		}
+	}
+	if got[1].Message != "statement: ALTER USER app PASSWORD '***'" {
+		t.Errorf("leaked password message = %s, want password masked", got[1].Message)
+	}
+	if got[3].Message != "10.0.0.3" {
+		t.Errorf("new unique ip = %s, want 10.0.0.3", got[3].Message)
+	}
+
+	if got := NewEvents(testTime, "pg", findings[4:], nil); len(got) != 2 {
+		t.Errorf("without previous run got %d ip events, want 2", len(got))
+	}
+}
+
+func TestFormat(t *testing.T) {
+	e := Event{
+		Time:     testTime,
+		Target:   "pg",
+		Class:    EventClass_Finding,
+		Module:   "HBA Scanner Report",
+		Control:  `HBA "Check" 1`,
+		Status:   "Fail",
+		Severity: htmlreport.Severity_Critical,
+		Message:  "a=b|c",
+	}
+
+	wantSyslog := `<130>1 2024-01-02T03:04:05.000000Z host klouddbshield - finding ` +
+		`[klouddbshield@32473 target="pg" module="HBA Scanner Report" control="HBA \"Check\" 1" status="Fail" severity="Critical"] a=b|c`
+	if got := FormatSyslog(e, "host"); got != wantSyslog {
+		t.Errorf("FormatSyslog() got\n%s\nwant\n%s", got, wantSyslog)
+	}
+
+	e.Control = "HBA|1"
+	wantCEF := `<130>1 2024-01-02T03:04:05.000000Z host klouddbshield - finding - ` +
+		`CEF:0|KloudDB|KloudDB Shield|1.0|finding|HBA\|1|10|rt=1704164645000 dhost=pg cat=HBA Scanner Report outcome=Fail cs1Label=control cs1=HBA|1 msg=a\=b|c`
+	if got := FormatCEF(e, "host"); got != wantCEF {
+		t.Errorf("FormatCEF() got\n%s\nwant\n%s", got, wantCEF)
+	}
+}
+
+func TestSinkUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("can not listen on udp: %v", err)
+	}
+	defer conn.Close()
+
+	s := NewSink(Format_CEF, "udp", conn.LocalAddr().String(), "")
+	if err := s.VerifyConfig(); err != nil {
+		t.Fatalf("VerifyConfig() error = %v", err)
+	}
+	if err := s.Send([]Event{{Time: testTime, Class: EventClass_SQLInjection, Control: "SQL Injection"}}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second)) //nolint:errcheck
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom() error = %v", err)
+	}
+	if !strings.Contains(string(buf[:n]), "CEF:0|KloudDB|KloudDB Shield|1.0|sql_injection|SQL Injection|") {
+		t.Errorf("unexpected message %s", buf[:n])
+	}
+}
+
+func TestSinkTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("can not listen on tcp: %v", err)
+	}
+	defer l.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			received <- ""
+			return
+		}
+		defer c.Close()
+		b, _ := bufio.NewReader(c).ReadString(0)
+		received <- b
+	}()
+
+	s := NewSink(Format_Syslog, "tcp", l.Addr().String(), "")
+	events := []Event{{Time: testTime, Class: EventClass_Finding, Message: "one"}, {Time: testTime, Class: EventClass_Finding, Message: "two"}}
+	if err := s.Send(events); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	got := <-received
+	first := FormatSyslog(events[0], s.hostname)
+	if !strings.HasPrefix(got, fmt.Sprintf("%d %s", len(first), first)) {
+		t.Errorf("message is not octet counted: %q", got)
+	}
+	if !strings.HasSuffix(got, "] two") {
+		t.Errorf("second message missing: %q", got)
+	}
+}
+
+func TestSinkJSONL(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "events.jsonl")
+	s := NewSink(Format_JSONL, "", "", file)
+	if err := s.VerifyConfig(); err != nil {
+		t.Fatalf("VerifyConfig() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.Send([]Event{{Time: testTime, Target: "pg", Class: EventClass_Finding}}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	var e Event
+	if err := json.Unmarshal([]byte(lines[0]), &e); err != nil || e.Target != "pg" {
+		t.Errorf("invalid json line %s: %v", lines[0], err)
+	}
+}
+
+func TestSinkVerifyConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		sink    *Sink
+		wantErr bool
+	}{
+		{"syslog udp", NewSink(Format_Syslog, "", "localhost:514", ""), false},
+		{"unix socket", NewSink(Format_Syslog, "unixgram", "/dev/log", ""), false},
+		{"invalid format", NewSink("xml", "udp", "localhost:514", ""), true},
+		{"invalid network", NewSink(Format_CEF, "http", "localhost:514", ""), true},
+		{"missing address", NewSink(Format_CEF, "tcp", "", ""), true},
+		{"missing file", NewSink(Format_JSONL, "", "", ""), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.sink.VerifyConfig(); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package siem
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// supported formats
+const (
+	Format_Syslog = "syslog"
+	Format_CEF    = "cef"
+	Format_JSONL  = "jsonl"
+)
+
+// Sink forwards the events to a syslog server, a unix socket or appends
+// them in a json lines file.
+type Sink struct {
+	format  string
+	network string
+	address string
+	file    string
+
+	hostname string
+}
+
+func NewSink(format, network, address, file string) *Sink {
+	hostname, _ := os.Hostname()
+	if network == "" {
+		network = "udp"
+	}
+
+	return &Sink{
+		format:   format,
+		network:  network,
+		address:  address,
+		file:     file,
+		hostname: hostname,
+	}
+}
+
+func (s *Sink) VerifyConfig() error {
+	switch s.format {
+	case Format_JSONL:
+		if s.file == "" {
+			return fmt.Errorf("file is required for %s format", s.format)
+		}
+		return nil
+	case Format_Syslog, Format_CEF:
+	default:
+		return fmt.Errorf("invalid siem format %q, valid formats are %s, %s and %s", s.format, Format_Syslog, Format_CEF, Format_JSONL)
+	}
+
+	switch s.network {
+	case "udp", "tcp", "unix", "unixgram":
+	default:
+		return fmt.Errorf("invalid siem network %q, valid networks are udp, tcp, unix and unixgram", s.network)
+	}
+
+	if s.address == "" {
+		return fmt.Errorf("address is required for %s format", s.format)
+	}
+
+	return nil
+}
+
+// Send forwards all the events. Connection is opened for each call, as
+// events are sent once per run.
+func (s *Sink) Send(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	if s.format == Format_JSONL {
+		return s.writeFile(events)
+	}
+
+	conn, err := net.DialTimeout(s.network, s.address, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s %s: %v", s.network, s.address, err)
+	}
+	defer conn.Close()
+
+	// stream transports need framing, octet counting from RFC 6587 is used
+	// as messages can have new lines.
+	stream := s.network == "tcp" || s.network == "unix"
+
+	w := bufio.NewWriter(conn)
+	for _, e := range events {
+		msg := FormatSyslog(e, s.hostname)
+		if s.format == Format_CEF {
+			msg = FormatCEF(e, s.hostname)
+		}
+
+		if stream {
+			_, err = fmt.Fprintf(w, "%d %s", len(msg), msg)
+		} else {
+			// datagram transports send one message per write
+			_, err = conn.Write([]byte(msg))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to send event: %v", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to send event: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Sink) writeFile(events []Event) error {
+	f, err := os.OpenFile(s.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", s.file, err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, e := range events {
+		line, err := FormatJSON(e)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %v", err)
+		}
+		if _, err := w.WriteString(line + "\n"); err != nil {
+			return fmt.Errorf("failed to write %s: %v", s.file, err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", s.file, err)
+	}
+
+	return nil
+}
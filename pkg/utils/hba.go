@@ -4,12 +4,23 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/klouddb/klouddbshield/model"
 )
 
 func GetDatabaseAndHostForUSerFromHbaFileRules(ctx context.Context, store *sql.DB) ([]model.HBAFIleRules, error) {
-	sqlStr := `select line_number, database, user_name, address, netmask from pg_hba_file_rules where type != 'local';`
+	return queryHBAFileRules(store, "type != 'local'")
+}
+
+// GetHBAFileRules returns all the valid lines of pg_hba_file_rules, local
+// lines included, in the order postgres checks them.
+func GetHBAFileRules(ctx context.Context, store *sql.DB) ([]model.HBAFIleRules, error) {
+	return queryHBAFileRules(store, "error is null")
+}
+
+func queryHBAFileRules(store *sql.DB, where string) ([]model.HBAFIleRules, error) {
+	sqlStr := `select line_number, type, database, user_name, address, netmask, auth_method, coalesce(array_to_string(options, ' '), '') from pg_hba_file_rules where ` + where + `;`
 	stmt, err := store.Prepare(sqlStr)
 	if err != nil {
 		return nil, err
@@ -26,10 +37,12 @@ func GetDatabaseAndHostForUSerFromHbaFileRules(ctx context.Context, store *sql.D
 	for rows.Next() {
 		var data model.HBAFIleRules
 		var addr, netmask sql.NullString
-		err := rows.Scan(&data.LineNumber, &data.Database, &data.UserName, &addr, &netmask)
+		var options string
+		err := rows.Scan(&data.LineNumber, &data.Type, &data.Database, &data.UserName, &addr, &netmask, &data.Method, &options)
 		if err != nil {
 			return nil, err
 		}
+		data.Method = strings.TrimSpace(data.Method + " " + options)
 
 		if addr.Valid {
 			data.Address = addr.String
@@ -76,3 +89,34 @@ func GetUserForGivenRole(ctx context.Context, store *sql.DB, role string) ([]str
 
 	return out, nil
 }
+
+// getRolesOfUserQuery follows pg_auth_members like +role lines of hba file,
+// superusers are not members of every role for them.
+const getRolesOfUserQuery = `WITH RECURSIVE member_of(oid) AS (
+	SELECT oid FROM pg_roles WHERE rolname = $1
+	UNION
+	SELECT m.roleid FROM pg_auth_members m JOIN member_of ON m.member = member_of.oid
+)
+SELECT r.rolname FROM pg_roles r JOIN member_of ON r.oid = member_of.oid;`
+
+// GetRolesOfUser returns the roles the user is member of, directly or
+// indirectly, including the user. No roles are returned for a user which
+// does not exist.
+func GetRolesOfUser(ctx context.Context, store *sql.DB, user string) ([]string, error) {
+	rows, err := store.QueryContext(ctx, getRolesOfUserQuery, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		out = append(out, role)
+	}
+
+	return out, rows.Err()
+}
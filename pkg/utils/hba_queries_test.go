@@ -3,6 +3,7 @@ package utils
 import (
 	"context"
 	"reflect"
+	"regexp"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -13,72 +14,90 @@ func TestGetDatabaseAndHostForUSerFromHbaFileRules(t *testing.T) {
 
 	tests := []struct {
 		name        string
-		QueryResult [][5]interface{}
+		QueryResult [][8]interface{}
 		want        []model.HBAFIleRules
 		wantErr     bool
 	}{
 		// TODO: Add test cases.
 		{
 			name: "all database and user with localhost",
-			QueryResult: [][5]interface{}{
-				{95, "{all}", "{all}", "127.0.0.1", "255.255.255.255"},
+			QueryResult: [][8]interface{}{
+				{95, "host", "{all}", "{all}", "127.0.0.1", "255.255.255.255", "scram-sha-256", ""},
 			},
 			wantErr: false,
 			want: []model.HBAFIleRules{
 				{
 					LineNumber: 95,
+					Type:       "host",
 					Database:   "all",
 					UserName:   "all",
 					Address:    "127.0.0.1",
 					NetMask:    "255.255.255.255",
+					Method:     "scram-sha-256",
+					Raw:        "From DB: database={all}, user={all}, address=127.0.0.1, netmask=255.255.255.255",
 				},
 			},
 		},
 		{
 			name: "all default entries from hba file rules",
-			QueryResult: [][5]interface{}{
-				{95, "{all}", "{all}", "127.0.0.1", "255.255.255.255"},
-				{97, "{all}", "{all}", "::1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
-				{100, "{replication}", "{all}", "127.0.0.1", "255.255.255.255"},
-				{102, "{replication}", "{all}", "::1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
-				{103, "{testing1}", "{pradip,testuser,newuser,pradipparmar}", ".example.com", nil},
+			QueryResult: [][8]interface{}{
+				{95, "host", "{all}", "{all}", "127.0.0.1", "255.255.255.255", "scram-sha-256", ""},
+				{97, "host", "{all}", "{all}", "::1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "scram-sha-256", ""},
+				{100, "host", "{replication}", "{all}", "127.0.0.1", "255.255.255.255", "scram-sha-256", ""},
+				{102, "host", "{replication}", "{all}", "::1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "scram-sha-256", ""},
+				{103, "hostssl", "{testing1}", "{pradip,testuser,newuser,pradipparmar}", ".example.com", nil, "cert", "clientcert=verify-full"},
 			},
 			wantErr: false,
 			want: []model.HBAFIleRules{
 				{
 					LineNumber: 95,
+					Type:       "host",
 					Database:   "all",
 					UserName:   "all",
 					Address:    "127.0.0.1",
 					NetMask:    "255.255.255.255",
+					Method:     "scram-sha-256",
+					Raw:        "From DB: database={all}, user={all}, address=127.0.0.1, netmask=255.255.255.255",
 				},
 				{
 					LineNumber: 97,
+					Type:       "host",
 					Database:   "all",
 					UserName:   "all",
 					Address:    "::1",
 					NetMask:    "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+					Method:     "scram-sha-256",
+					Raw:        "From DB: database={all}, user={all}, address=::1, netmask=ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
 				},
 				{
 					LineNumber: 100,
+					Type:       "host",
 					Database:   "replication",
 					UserName:   "all",
 					Address:    "127.0.0.1",
 					NetMask:    "255.255.255.255",
+					Method:     "scram-sha-256",
+					Raw:        "From DB: database={replication}, user={all}, address=127.0.0.1, netmask=255.255.255.255",
 				},
 				{
 					LineNumber: 102,
+					Type:       "host",
 					Database:   "replication",
 					UserName:   "all",
 					Address:    "::1",
 					NetMask:    "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+					Method:     "scram-sha-256",
+					Raw:        "From DB: database={replication}, user={all}, address=::1, netmask=ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
 				},
 				{
 					LineNumber: 103,
+					Type:       "hostssl",
 					Database:   "testing1",
 					UserName:   "pradip,testuser,newuser,pradipparmar",
 					Address:    ".example.com",
 					NetMask:    "",
+					Method:     "cert clientcert=verify-full",
+					Raw:        "From DB: database={testing1}, user={pradip,testuser,newuser,pradipparmar}, address=.example.com, netmask=",
 				},
 			},
 		},
@@ -94,12 +113,12 @@ func TestGetDatabaseAndHostForUSerFromHbaFileRules(t *testing.T) {
 			defer mockDB.Close()
 
 			// Create the expected SQL query
-			expectedQuery := "select line_number, database, user_name, address, netmask from pg_hba_file_rules where type != 'local';"
+			expectedQuery := regexp.QuoteMeta("select line_number, type, database, user_name, address, netmask, auth_method, coalesce(array_to_string(options, ' '), '') from pg_hba_file_rules where type != 'local';")
 
 			// Set up the mock expectation for the query and result
-			rows := sqlmock.NewRows([]string{"line_number", "database", "user_name", "address", "netmask"})
+			rows := sqlmock.NewRows([]string{"line_number", "type", "database", "user_name", "address", "netmask", "auth_method", "options"})
 			for _, data := range tt.QueryResult {
-				rows.AddRow(data[0], data[1], data[2], data[3], data[4])
+				rows.AddRow(data[0], data[1], data[2], data[3], data[4], data[5], data[6], data[7])
 			}
 
 			mock.ExpectPrepare(expectedQuery).ExpectQuery().WillReturnRows(rows)
@@ -115,3 +134,41 @@ func TestGetDatabaseAndHostForUSerFromHbaFileRules(t *testing.T) {
 		})
 	}
 }
+
+func TestGetRolesOfUser(t *testing.T) {
+	tests := []struct {
+		name  string
+		user  string
+		roles []string
+	}{
+		{name: "member of roles", user: "bob", roles: []string{"bob", "analysts", "readers"}},
+		{name: "unknown user", user: "nobody"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create mock database: %v", err)
+			}
+			defer mockDB.Close()
+
+			rows := sqlmock.NewRows([]string{"rolname"})
+			for _, r := range tt.roles {
+				rows.AddRow(r)
+			}
+			mock.ExpectQuery(regexp.QuoteMeta(getRolesOfUserQuery)).WithArgs(tt.user).WillReturnRows(rows)
+
+			got, err := GetRolesOfUser(context.Background(), mockDB, tt.user)
+			if err != nil {
+				t.Fatalf("GetRolesOfUser() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.roles) {
+				t.Errorf("GetRolesOfUser() = %v, want %v", got, tt.roles)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
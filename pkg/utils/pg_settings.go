@@ -4,14 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/klouddb/klouddbshield/model"
 )
 
-// GetPGSettings will give log_connections using query.
-// query := `SELECT name, setting FROM pg_settings WHERE name IN ('log_connections');`
+// GetPGSettings will give log_connections and syslog_ident using query.
+// query := `SELECT name, setting FROM pg_settings WHERE name IN ('log_connections', 'syslog_ident');`
 func GetPGSettings(ctx context.Context, store *sql.DB) (*model.PgSettings, error) {
-	query := `SELECT name, setting FROM pg_settings WHERE name IN ('log_connections');`
+	query := `SELECT name, setting FROM pg_settings WHERE name IN ('log_connections', 'syslog_ident');`
 	data, err := GetJSON(store, query)
 	if err != nil {
 		return nil, fmt.Errorf("error while getting pg_settings: %v", err)
@@ -22,6 +23,8 @@ func GetPGSettings(ctx context.Context, store *sql.DB) (*model.PgSettings, error
 	for _, val := range data {
 		if val["name"] == "log_connections" {
 			out.LogConnections = val["setting"] == "on" || val["setting"] == "yes"
+		} else if val["name"] == "syslog_ident" {
+			out.SyslogIdent = fmt.Sprint(val["setting"])
 		}
 	}
 
@@ -78,6 +81,46 @@ func GetPGUsers(ctx context.Context, store *sql.DB) ([]string, error) {
 	return listOfPGUsers, nil
 }
 
+// managedServiceRoles are the admin roles created by managed postgres
+// services, they are used by the service and not by the clients.
+var managedServiceRoles = map[string]bool{
+	"rdsadmin":        true,
+	"rdsrepladmin":    true,
+	"rdstopmgr":       true,
+	"azuresu":         true,
+	"azure_superuser": true,
+	"cloudsqladmin":   true,
+	"alloydbadmin":    true,
+}
+
+// GetPGRoles returns the roles of pg_roles. Roles with oid below 16384
+// (FirstNormalObjectId) are created by initdb.
+func GetPGRoles(ctx context.Context, store *sql.DB) ([]model.PGRole, error) {
+	query := `SELECT rolname, rolcanlogin, oid < 16384, CASE WHEN isfinite(rolvaliduntil) THEN rolvaliduntil END FROM pg_roles ORDER BY rolname;`
+	rows, err := store.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error while getting pg_roles: %v", err)
+	}
+	defer rows.Close()
+
+	out := []model.PGRole{}
+	for rows.Next() {
+		var role model.PGRole
+		var validUntil sql.NullTime
+		if err := rows.Scan(&role.Name, &role.CanLogin, &role.System, &validUntil); err != nil {
+			return nil, fmt.Errorf("error while getting pg_roles: %v", err)
+		}
+
+		role.System = role.System || strings.HasPrefix(role.Name, "pg_") || managedServiceRoles[role.Name]
+		if validUntil.Valid {
+			role.ValidUntil = validUntil.Time
+		}
+		out = append(out, role)
+	}
+
+	return out, rows.Err()
+}
+
 func GetHBAFilePath(ctx context.Context, store *sql.DB) (string, error) {
 	query := `SHOW hba_file;`
 	data, err := GetJSON(store, query)
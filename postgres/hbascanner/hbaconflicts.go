@@ -0,0 +1,161 @@
+package hbascanner
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/klouddb/klouddbshield/model"
+	"github.com/klouddb/klouddbshield/pkg/hbarules"
+	"github.com/klouddb/klouddbshield/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// hbaconflictfuncStore has the checks of rule order in hba file, postgres
+// uses the first matching line so these need the whole file.
+var hbaconflictfuncStore = map[int]func([]hbarules.HBAEntry, string) *model.HBAScannerResult{
+	10: CheckShadowedRules,
+	11: CheckOverlappingRules,
+	12: CheckLateReject,
+	13: CheckShadowedHostSSL,
+}
+
+// HBAConflictScanner runs the rule order checks on hba file of the server.
+func HBAConflictScanner(store *sql.DB, ctx context.Context) ([]*model.HBAScannerResult, error) {
+	hbaFile, entries, err := getHBAEntries(store, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var listOfResult []*model.HBAScannerResult
+	for i := 10; i < 10+len(hbaconflictfuncStore); i++ {
+		listOfResult = append(listOfResult, hbaconflictfuncStore[i](entries, hbaFile))
+	}
+	return listOfResult, nil
+}
+
+// getHBAEntries returns the lines of hba file of the server. The rules of
+// pg_hba_file_rules view are used when the file can not be read from here,
+// like for remote and managed servers.
+func getHBAEntries(store *sql.DB, ctx context.Context) (string, []hbarules.HBAEntry, error) {
+	hbaFile, err := utils.GetHBAFilePath(ctx, store)
+	if err != nil {
+		return "", nil, err
+	}
+
+	conf, err := hbarules.ParseHBAConf(hbaFile)
+	if err == nil {
+		return hbaFile, conf.Entries, nil
+	}
+
+	rules, qerr := utils.GetHBAFileRules(ctx, store)
+	if qerr != nil {
+		return "", nil, fmt.Errorf("%v, and pg_hba_file_rules can not be queried: %v", err, qerr)
+	}
+	log.Warn().Err(err).Msg("HBA file can not be read, using pg_hba_file_rules for rule order checks")
+	return hbaFile, hbarules.HBAEntriesFromRules(hbaFile, rules), nil
+}
+
+func CheckShadowedRules(entries []hbarules.HBAEntry, hbaFile string) *model.HBAScannerResult {
+	result := model.HBAScannerResult{
+		Title:       "Check for lines which can never match because an earlier line covers them",
+		Description: "Remove or move up the lines shadowed by an earlier line - postgres uses the first matching line",
+		Control:     10,
+		Procedure: `
+		Method 1-
+		For each line of the hba file check if an earlier line has the same or
+		broader type, database, user and address.
+		If such an earlier line is found this is a FAIL
+		Method 2-
+		Use ciscollector hba simulate with the connection of the line to see
+		which line matches it`,
+	}
+	setConflictRows(&result, hbarules.ShadowedHBARules(entries), "shadowed by", hbaFile)
+	return &result
+}
+
+func CheckOverlappingRules(entries []hbarules.HBAEntry, hbaFile string) *model.HBAScannerResult {
+	result := model.HBAScannerResult{
+		Title:       "Check for overlapping CIDR addresses with different auth methods",
+		Description: "Avoid overlapping addresses with different auth methods - the method depends on the order of lines",
+		Control:     11,
+		Procedure: `
+		Method 1-
+		select line_number, address, netmask, auth_method from pg_hba_file_rules
+		where address is not null order by line_number;
+		If addresses of two lines with same database and user overlap and
+		auth_method differs this is a FAIL
+		Method 2-
+		Manually check your hba file for overlapping addresses with different
+		auth-method`,
+	}
+	setConflictRows(&result, hbarules.OverlappingHBARules(entries), "overlaps with", hbaFile)
+	return &result
+}
+
+func CheckLateReject(entries []hbarules.HBAEntry, hbaFile string) *model.HBAScannerResult {
+	result := model.HBAScannerResult{
+		Title:       "Check for reject lines placed after permissive lines",
+		Description: "Place reject lines before the permissive lines - connections matching an earlier line are not rejected",
+		Control:     12,
+		Procedure: `
+		Method 1-
+		select line_number, database, user_name, address, auth_method from
+		pg_hba_file_rules order by line_number;
+		If an earlier line with auth_method other than reject matches some
+		connections of a reject line, and the reject line does not match all
+		connections of the earlier line, this is a FAIL
+		Method 2-
+		Manually check your hba file to see if ‘reject’ lines are placed
+		before the lines they are meant to restrict`,
+	}
+	setConflictRows(&result, hbarules.LateRejectHBARules(entries), "allowed earlier by", hbaFile)
+	return &result
+}
+
+func CheckShadowedHostSSL(entries []hbarules.HBAEntry, hbaFile string) *model.HBAScannerResult {
+	result := model.HBAScannerResult{
+		Title:       "Check for hostssl lines shadowed by earlier host lines",
+		Description: "Place hostssl lines before host lines - an earlier host line allows the connections without ssl",
+		Control:     13,
+		Procedure: `
+		Method 1-
+		select line_number, type, database, user_name, address from
+		pg_hba_file_rules order by line_number;
+		If an earlier line with type host matches all connections of a
+		hostssl line this is a FAIL
+		Method 2-
+		Manually check your hba file to see if ‘hostssl’ lines are placed
+		before the ‘host’ lines with same database, user and address`,
+	}
+	setConflictRows(&result, hbarules.ShadowedHostSSLRules(entries), "shadowed by", hbaFile)
+	return &result
+}
+
+func setConflictRows(result *model.HBAScannerResult, conflicts []hbarules.HBARuleConflict, relation, hbaFile string) {
+	if len(conflicts) == 0 {
+		result.Status = "Pass"
+		return
+	}
+
+	result.Status = "Fail"
+	for _, c := range conflicts {
+		row := fmt.Sprintf("line %s: %s - %s line %s: %s",
+			hbaLocation(c.Entry, hbaFile), c.Entry.Raw, relation, hbaLocation(c.By, hbaFile), c.By.Raw)
+		result.FailRows = append(result.FailRows, row)
+		result.FailRowsLineNums = append(result.FailRowsLineNums, c.Entry.LineNo)
+		result.FailRowsConflictLineNums = append(result.FailRowsConflictLineNums, c.By.LineNo)
+	}
+	result.FailRowsInString = strings.Join(result.FailRows, "\n")
+}
+
+// hbaLocation returns the line number, with the file name for the lines of
+// included files.
+func hbaLocation(e hbarules.HBAEntry, hbaFile string) string {
+	if e.File != hbaFile {
+		return e.File + ":" + strconv.Itoa(e.LineNo)
+	}
+	return strconv.Itoa(e.LineNo)
+}
@@ -36,6 +36,17 @@ func HBAScannerByControl(store *sql.DB, ctx context.Context, control string) *mo
 		os.Exit(1)
 	}
 
+	if conflictFunc, ok := hbaconflictfuncStore[con]; ok {
+		hbaFile, entries, err := getHBAEntries(store, ctx)
+		if err != nil {
+			fmt.Println("Error while reading HBA file: ", err)
+			return nil
+		}
+		result := conflictFunc(entries, hbaFile)
+		PrintVerbose(result)
+		return result
+	}
+
 	listRows, listOfLineNums, _ := GetHBAFileData(store, ctx)
 	if _, ok := funcStore[con]; !ok {
 		// the key 'elliot' exists within the map
@@ -47,7 +58,10 @@ func HBAScannerByControl(store *sql.DB, ctx context.Context, control string) *mo
 	PrintVerbose(result)
 	return result
 }
-func HBAScanner(store *sql.DB, ctx context.Context) []*model.HBAScannerResult {
+
+// HBAScanner runs all the HBA checks, using pg_hba_file_rules view and
+// falling back to reading pg_hba.conf file if the view can not be queried.
+func HBAScanner(store *sql.DB, ctx context.Context) ([]*model.HBAScannerResult, error) {
 
 	hbaqueryfuncStore := map[int]func(*sql.DB, context.Context) (*model.HBAScannerResult, error){
 		1: QueryTrustInMethod,
@@ -90,8 +104,7 @@ func HBAScanner(store *sql.DB, ctx context.Context) []*model.HBAScannerResult {
 		listOfResult = nil
 		listRows, listOfLineNums, err := GetHBAFileData(store, ctx)
 		if err != nil {
-			fmt.Println("Got error while parsing HBA file", err)
-			os.Exit(1)
+			return nil, fmt.Errorf("got error while parsing HBA file: %v", err)
 		}
 		for i := 1; i <= len(hbafilefuncStore); i++ {
 			result := hbafilefuncStore[i](listRows, listOfLineNums)
@@ -102,7 +115,14 @@ func HBAScanner(store *sql.DB, ctx context.Context) []*model.HBAScannerResult {
 		}
 	}
 
-	return listOfResult
+	conflictResults, err := HBAConflictScanner(store, ctx)
+	if err != nil {
+		fmt.Println("Skipping HBA rule order checks, got error while parsing HBA file: ", err)
+		return listOfResult, nil
+	}
+	listOfResult = append(listOfResult, conflictResults...)
+
+	return listOfResult, nil
 }
 func PrintVerbose(result *model.HBAScannerResult) {
 
@@ -32,11 +32,12 @@ func AuditSSL(ctx context.Context, store *sql.DB, host string, port string) (*mo
 	out.SSLParams = sslParams
 
 	// Check SSL Certificate Expiry
-	results, err := ValidateCertificate(ctx, host, port)
+	results, expiryDate, err := ValidateCertificate(ctx, host, port)
 	if err != nil {
 		return nil, err
 	}
 	out.Cells = append(out.Cells, results...)
+	out.CertExpiry = expiryDate
 
 	// Check SSL HBA
 	result, failRows, err := CheckSSLHBA(ctx, store)
@@ -92,7 +93,10 @@ func CheckSSLParameters(ctx context.Context, store *sql.DB) (map[string]string,
 	return result, nil
 }
 
-func ValidateCertificate(ctx context.Context, host, port string) ([]*model.SSLScanResultCell, error) {
+// ValidateCertificate checks the server certificate and returns the check
+// results with expiry time of the certificate. Expiry time is nil if the
+// certificate could not be fetched.
+func ValidateCertificate(ctx context.Context, host, port string) ([]*model.SSLScanResultCell, *time.Time, error) {
 	out := []*model.SSLScanResultCell{
 		{
 			Title:  "Self-Signed Certificate Check",
@@ -120,7 +124,7 @@ func ValidateCertificate(ctx context.Context, host, port string) ([]*model.SSLSc
 			out[1].Message = fmt.Sprintf("Failed to check certificate: %v", string(output))
 		}
 
-		return out, nil
+		return out, nil, nil
 	}
 
 	outputStr := string(output)
@@ -138,7 +142,7 @@ func ValidateCertificate(ctx context.Context, host, port string) ([]*model.SSLSc
 
 	expiryDate, err := ParseNotAfter(outputStr)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if expiryDate.Before(time.Now()) {
@@ -149,7 +153,7 @@ func ValidateCertificate(ctx context.Context, host, port string) ([]*model.SSLSc
 		out[1].Message = fmt.Sprintf("SSL Certificate will expire in %d days", int(time.Until(expiryDate).Hours()/24))
 	}
 
-	return out, nil
+	return out, &expiryDate, nil
 }
 
 func CheckSSLHBA(ctx context.Context, store *sql.DB) (*model.SSLScanResultCell, []string, error) {